@@ -15,28 +15,50 @@
 package filemonitor
 
 import (
-	"fmt"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
 	"path"
+	"path/filepath"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/google/uuid"
+	"github.com/pmezard/go-difflib/difflib"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/agent/network"
 	"openeuler.org/PilotGo/PilotGo/pkg/global"
 	"openeuler.org/PilotGo/PilotGo/pkg/logger"
 	"openeuler.org/PilotGo/PilotGo/pkg/utils/message/protocol"
-	uos "openeuler.org/PilotGo/PilotGo/pkg/utils/os"
+	"openeuler.org/PilotGo/PilotGo/pkg/utils/os/common"
 )
 
 var RESP_MSG = make(chan interface{})
 
-func FileMonitorInit() error {
-	//获取IP
-	IP, err := uos.OS().GetHostIp()
-	if err != nil {
-		return fmt.Errorf("can not to get IP")
-	}
+const (
+	// 不超过该大小的文本文件上报内容diff
+	diffSizeLimit = 64 * 1024
+	// 缓存用于diff的文件内容的最大数量
+	cacheLimit = 2000
+)
+
+type monitor struct {
+	mutex   sync.Mutex
+	watcher *fsnotify.Watcher
+	rules   []common.WatchRule
+	dirs    map[string]bool
+	// 小文本文件上次的内容
+	cache map[string]string
+}
 
+var m *monitor
+
+func FileMonitorInit() error {
 	// 1、NewWatcher 初始化一个 watcher
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
@@ -44,18 +66,13 @@ func FileMonitorInit() error {
 	}
 	defer watcher.Close()
 
-	// 2、使用 watcher 的 Add 方法增加需要监听的文件或目录到监听队列中
-	err = watcher.Add(global.RepoPath)
-	if err != nil {
-		logger.Debug("failed to monitor repo")
-	}
-	logger.Info("start to monitor repo")
-
-	err = watcher.Add(global.NetWorkPath)
-	if err != nil {
-		logger.Debug("failed to monitor network")
+	// 2、在server下发监控列表前使用默认的监控列表
+	m = &monitor{
+		watcher: watcher,
+		dirs:    map[string]bool{},
+		cache:   map[string]string{},
 	}
-	logger.Info("start to monitor network")
+	m.apply(defaultWatchList())
 
 	//3、创建新的 goroutine，等待管道中的事件或错误
 	done := make(chan bool)
@@ -71,9 +88,8 @@ func FileMonitorInit() error {
 				if strings.Contains(fileExt, ".sw") || strings.Contains(fileExt, "~") || strings.Contains(e.Name, "~") {
 					continue
 				}
-
-				if e.Op&fsnotify.Write == fsnotify.Write {
-					RESP_MSG <- fmt.Sprintf("机器 %s 上的文件已被修改 : %s", IP, e.Name)
+				for _, event := range m.handle(e) {
+					RESP_MSG <- event
 				}
 
 			case err, ok := <-watcher.Errors:
@@ -88,6 +104,238 @@ func FileMonitorInit() error {
 	return nil
 }
 
+// 替换当前的监控列表，列表为空时恢复默认监控
+func Apply(l *common.WatchList) error {
+	if m == nil {
+		return errors.New("文件监控未初始化")
+	}
+	if len(l.Rules) == 0 {
+		l = defaultWatchList()
+	}
+	for _, r := range l.Rules {
+		if !filepath.IsAbs(r.Path) {
+			return errors.New("监控路径须为绝对路径: " + r.Path)
+		}
+	}
+	m.apply(l)
+	return nil
+}
+
+func defaultWatchList() *common.WatchList {
+	return common.DefaultWatchList(global.RepoPath, global.NetWorkPath)
+}
+
+func (m *monitor) apply(l *common.WatchList) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for dir := range m.dirs {
+		m.watcher.Remove(dir)
+	}
+	m.dirs = map[string]bool{}
+	m.cache = map[string]string{}
+	m.rules = l.Rules
+
+	for i := range m.rules {
+		r := &m.rules[i]
+		info, err := os.Stat(r.Path)
+		if err != nil {
+			logger.Warn("failed to monitor %s: %s", r.Path, err.Error())
+			continue
+		}
+		// 监控单个文件时监控其所在目录，以免文件被替换后失去监控
+		if !info.IsDir() {
+			m.addDir(filepath.Dir(r.Path))
+			m.remember(r.Path)
+			continue
+		}
+		m.addTree(r, r.Path)
+		logger.Info("start to monitor %s", r.Path)
+	}
+}
+
+// 监控目录，规则为递归时同时监控所有子目录
+func (m *monitor) addTree(r *common.WatchRule, root string) {
+	filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if p != root && !r.Recursive {
+				return filepath.SkipDir
+			}
+			m.addDir(p)
+			return nil
+		}
+		if r.Match(p) {
+			m.remember(p)
+		}
+		return nil
+	})
+}
+
+func (m *monitor) addDir(dir string) {
+	if m.dirs[dir] {
+		return
+	}
+	if err := m.watcher.Add(dir); err != nil {
+		logger.Warn("failed to monitor %s: %s", dir, err.Error())
+		return
+	}
+	m.dirs[dir] = true
+}
+
+// 缓存小文本文件的内容作为diff的基准
+func (m *monitor) remember(p string) {
+	if len(m.cache) >= cacheLimit {
+		return
+	}
+	if text, ok := readText(p); ok {
+		m.cache[p] = text
+	}
+}
+
+// 处理文件事件，返回需要上报的事件
+func (m *monitor) handle(e fsnotify.Event) []*common.FileWatchEvent {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	info, statErr := os.Lstat(e.Name)
+	if e.Op&fsnotify.Create == fsnotify.Create && statErr == nil && info.IsDir() {
+		for i := range m.rules {
+			if r := &m.rules[i]; r.Recursive && covers(r, e.Name) {
+				m.addTree(r, e.Name)
+			}
+		}
+	}
+	if e.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+		if m.dirs[e.Name] {
+			m.watcher.Remove(e.Name)
+			delete(m.dirs, e.Name)
+		}
+	}
+
+	events := []*common.FileWatchEvent{}
+	for _, op := range opNames(e.Op) {
+		for i := range m.rules {
+			r := &m.rules[i]
+			if !r.Match(e.Name) || !r.Watches(op) {
+				continue
+			}
+			event := &common.FileWatchEvent{Rule: r.Path, Path: e.Name, Op: op, Time: time.Now()}
+			if statErr == nil {
+				m.describe(event, info)
+			} else {
+				delete(m.cache, e.Name)
+			}
+			events = append(events, event)
+			break
+		}
+	}
+	return events
+}
+
+// 补充文件的大小、属主、hash及内容diff
+func (m *monitor) describe(event *common.FileWatchEvent, info os.FileInfo) {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		event.UID = int(stat.Uid)
+	}
+	if !info.Mode().IsRegular() {
+		return
+	}
+	event.Size = info.Size()
+	event.Hash = fileHash(event.Path)
+
+	if info.Size() > diffSizeLimit {
+		delete(m.cache, event.Path)
+		return
+	}
+	text, ok := readText(event.Path)
+	if !ok {
+		delete(m.cache, event.Path)
+		return
+	}
+	last, cached := m.cache[event.Path]
+	if text == last && cached {
+		return
+	}
+	event.Diff, _ = difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(last),
+		B:        splitLines(text),
+		FromFile: event.Path,
+		ToFile:   event.Path,
+		Context:  3,
+	})
+	if cached || len(m.cache) < cacheLimit {
+		m.cache[event.Path] = text
+	}
+}
+
+// 路径是否在规则的监控目录下，不考虑文件名过滤条件
+func covers(r *common.WatchRule, p string) bool {
+	rel, err := filepath.Rel(filepath.Clean(r.Path), p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
+
+func opNames(op fsnotify.Op) []string {
+	ops := []string{}
+	for _, o := range []struct {
+		op   fsnotify.Op
+		name string
+	}{
+		{fsnotify.Create, common.WatchOpCreate},
+		{fsnotify.Write, common.WatchOpWrite},
+		{fsnotify.Remove, common.WatchOpRemove},
+		{fsnotify.Rename, common.WatchOpRename},
+		{fsnotify.Chmod, common.WatchOpChmod},
+	} {
+		if op&o.op == o.op {
+			ops = append(ops, o.name)
+		}
+	}
+	return ops
+}
+
+// 读取不超过diff大小限制的文本文件
+func readText(p string) (string, bool) {
+	info, err := os.Stat(p)
+	if err != nil || !info.Mode().IsRegular() || info.Size() > diffSizeLimit {
+		return "", false
+	}
+	bs, err := os.ReadFile(p)
+	if err != nil || !utf8.Valid(bs) || strings.IndexByte(string(bs), 0) >= 0 {
+		return "", false
+	}
+	return string(bs), true
+}
+
+func fileHash(p string) string {
+	f, err := os.Open(p)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// 按行切分并保留换行符，最后一行缺少换行符时补齐
+func splitLines(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if last := lines[len(lines)-1]; last == "" {
+		lines = lines[:len(lines)-1]
+	} else {
+		lines[len(lines)-1] = last + "\n"
+	}
+	return lines
+}
+
 func FileMonitor(client *network.SocketClient) {
 	for data := range RESP_MSG {
 		if data == nil {
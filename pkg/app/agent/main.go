@@ -27,6 +27,7 @@ import (
 	"openeuler.org/PilotGo/PilotGo/pkg/app/agent/localstorage"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/agent/network"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/agent/register"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/agent/register/handler"
 	"openeuler.org/PilotGo/PilotGo/pkg/logger"
 	"openeuler.org/PilotGo/PilotGo/pkg/utils/os/common"
 )
@@ -51,6 +52,9 @@ func main() {
 		logger.Error("cron init failed: %s", err)
 		os.Exit(-1)
 	}
+	if err := common.CronRestore(); err != nil {
+		logger.Error("cron restore failed: %s", err)
+	}
 
 	// init agent info
 	if err := localstorage.Init(); err != nil {
@@ -64,6 +68,7 @@ func main() {
 		client := network.NewSocketClient()
 		register.RegitsterHandler(client)
 		go filemonitor.FileMonitor(client)
+		go handler.CronResultReport(client)
 
 		for {
 			logger.Info("start to connect server")
@@ -2,6 +2,8 @@ package handler
 
 import (
 	"encoding/base64"
+	"io/ioutil"
+	"os"
 	"path"
 	"strings"
 
@@ -77,13 +79,16 @@ func RunScriptHandler(c *network.SocketClient, msg *protocol.Message) error {
 	var result *utils.CmdResult
 	var err error
 	var decoded_script []byte
+	var interpreter []string
 	workDir := "/opt/PilotGo/agent/"
 	fileName := uuid.New().String()
 	filePath := path.Join(workDir, fileName+".sh")
 
 	d := &struct {
-		Script string
-		Params []string
+		Script      string
+		Params      []string
+		Interpreter string
+		Options     *utils.ScriptOptions
 	}{}
 
 	err = msg.BindData(d)
@@ -93,6 +98,13 @@ func RunScriptHandler(c *network.SocketClient, msg *protocol.Message) error {
 		goto ERROR
 	}
 
+	interpreter, err = utils.ResolveInterpreter(d.Interpreter)
+	if err != nil {
+		errorInfo = err.Error()
+		logger.Error(errorInfo)
+		goto ERROR
+	}
+
 	decoded_script, err = base64.StdEncoding.DecodeString(d.Script)
 	if err != nil {
 		errorInfo = "Err decoding base64: " + err.Error()
@@ -102,14 +114,16 @@ func RunScriptHandler(c *network.SocketClient, msg *protocol.Message) error {
 
 	logger.Debug("process run script command: %s %v", filePath+" ", d.Params)
 
-	err = utils.FileSaveString(filePath, strings.Replace(string(decoded_script), "\r", "", -1))
+	err = saveScript(filePath, strings.Replace(string(decoded_script), "\r", "", -1), d.Options)
+	// 脚本执行结束后删除临时文件
+	defer os.Remove(filePath)
 	if err != nil {
-		errorInfo = "Err running filesavestring:" + err.Error()
+		errorInfo = "Err saving script:" + err.Error()
 		logger.Error(errorInfo)
 		goto ERROR
 	}
 
-	result, err = utils.RunScript(filePath, d.Params)
+	result, err = utils.RunScriptWithInterpreter(interpreter, filePath, d.Params, d.Options)
 	if err != nil {
 		errorInfo = "run command error:" + err.Error()
 		logger.Error(errorInfo)
@@ -125,3 +139,21 @@ ERROR:
 	resp_msg.Error = errorInfo
 	return c.Send(resp_msg)
 }
+
+// 保存脚本文件，仅执行用户可读写
+func saveScript(filePath string, content string, opts *utils.ScriptOptions) error {
+	if err := os.MkdirAll(path.Dir(filePath), 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filePath, []byte(content), 0700); err != nil {
+		return err
+	}
+	if opts != nil && opts.User != "" {
+		uid, gid, err := utils.LookupUser(opts.User)
+		if err != nil {
+			return err
+		}
+		return os.Chown(filePath, uid, gid)
+	}
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/google/uuid"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/agent/network"
 	"openeuler.org/PilotGo/PilotGo/pkg/logger"
 	"openeuler.org/PilotGo/PilotGo/pkg/utils/message/protocol"
@@ -13,13 +14,17 @@ import (
 func CronStartHandler(c *network.SocketClient, msg *protocol.Message) error {
 	logger.Debug("process agent info command:%s", msg.String())
 
-	msgg := msg.Data.(string)
-	message := strings.Split(msgg, ",")
-	id, _ := strconv.Atoi(message[0])
-	spec := message[1]
-	command := message[2]
+	// 任务参数以逗号拼接，命令中可能含有逗号，只拆分前两项
+	task := common.CronTask{}
+	msgg, _ := msg.Data.(string)
+	message := strings.SplitN(msgg, ",", 3)
+	if len(message) == 3 {
+		task.ID, _ = strconv.Atoi(message[0])
+		task.Spec = message[1]
+		task.Command = message[2]
+	}
 
-	err := common.CronStart(id, spec, command)
+	err := common.CronStart(task.ID, task.Spec, task.Command)
 	if err != nil {
 		resp_msg := &protocol.Message{
 			UUID:   msg.UUID,
@@ -65,3 +70,54 @@ func CronStopAndDelHandler(c *network.SocketClient, msg *protocol.Message) error
 		return c.Send(resp_msg)
 	}
 }
+
+func CronListHandler(c *network.SocketClient, msg *protocol.Message) error {
+	logger.Debug("process agent info command:%s", msg.String())
+
+	resp_msg := &protocol.Message{
+		UUID:   msg.UUID,
+		Type:   msg.Type,
+		Status: 0,
+		Data:   common.Cron.List(),
+	}
+	return c.Send(resp_msg)
+}
+
+func CronRunNowHandler(c *network.SocketClient, msg *protocol.Message) error {
+	logger.Debug("process agent info command:%s", msg.String())
+
+	task := common.CronTask{}
+	if err := msg.BindData(&task); err != nil {
+		resp_msg := &protocol.Message{
+			UUID:   msg.UUID,
+			Type:   msg.Type,
+			Status: -1,
+			Error:  err.Error(),
+		}
+		return c.Send(resp_msg)
+	}
+
+	common.CronRunNow(task)
+	resp_msg := &protocol.Message{
+		UUID:   msg.UUID,
+		Type:   msg.Type,
+		Status: 0,
+		Data:   "任务已触发",
+	}
+	return c.Send(resp_msg)
+}
+
+// 将定时任务的执行结果上报给server
+func CronResultReport(client *network.SocketClient) {
+	for r := range common.CronResults {
+		msg := &protocol.Message{
+			UUID:   uuid.New().String(),
+			Type:   protocol.CronResult,
+			Status: 0,
+			Data:   r,
+		}
+		if err := client.Send(msg); err != nil {
+			logger.Debug("send cron result failed, error: %s", err)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"openeuler.org/PilotGo/PilotGo/pkg/app/agent/filemonitor"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/agent/global"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/agent/network"
 	"openeuler.org/PilotGo/PilotGo/pkg/logger"
@@ -68,6 +69,80 @@ func EditFileHandler(c *network.SocketClient, msg *protocol.Message) error {
 	}
 }
 
+func ApplyFileHandler(c *network.SocketClient, msg *protocol.Message) error {
+	logger.Debug("process agent info command:%s", msg.String())
+	apply := &common.FileApply{}
+	err := msg.BindData(apply)
+	if err != nil {
+		resp_msg := &protocol.Message{
+			UUID:   msg.UUID,
+			Type:   msg.Type,
+			Status: -1,
+			Error:  err.Error(),
+		}
+		return c.Send(resp_msg)
+	}
+
+	// 校验或生效失败时同样返回执行结果，由server端根据结果判断
+	result := common.ApplyFile(apply)
+	resp_msg := &protocol.Message{
+		UUID:   msg.UUID,
+		Type:   msg.Type,
+		Status: 0,
+		Data:   result,
+	}
+	return c.Send(resp_msg)
+}
+
+func FileWatchSetHandler(c *network.SocketClient, msg *protocol.Message) error {
+	logger.Debug("process agent info command:%s", msg.String())
+	list := &common.WatchList{}
+	err := msg.BindData(list)
+	if err == nil {
+		err = filemonitor.Apply(list)
+	}
+	if err != nil {
+		resp_msg := &protocol.Message{
+			UUID:   msg.UUID,
+			Type:   msg.Type,
+			Status: -1,
+			Error:  err.Error(),
+		}
+		return c.Send(resp_msg)
+	}
+
+	resp_msg := &protocol.Message{
+		UUID:   msg.UUID,
+		Type:   msg.Type,
+		Status: 0,
+		Data:   struct{}{},
+	}
+	return c.Send(resp_msg)
+}
+
+func IntegritySnapshotHandler(c *network.SocketClient, msg *protocol.Message) error {
+	logger.Debug("process agent info command:%s", msg.String())
+	param := &common.IntegrityParam{}
+	err := msg.BindData(param)
+	if err != nil {
+		resp_msg := &protocol.Message{
+			UUID:   msg.UUID,
+			Type:   msg.Type,
+			Status: -1,
+			Error:  err.Error(),
+		}
+		return c.Send(resp_msg)
+	}
+
+	resp_msg := &protocol.Message{
+		UUID:   msg.UUID,
+		Type:   msg.Type,
+		Status: 0,
+		Data:   common.Snapshot(param),
+	}
+	return c.Send(resp_msg)
+}
+
 func AgentConfigHandler(c *network.SocketClient, msg *protocol.Message) error {
 	logger.Debug("process agent info command:%s", msg.String())
 	p, ok := msg.Data.(map[string]interface{})
@@ -7,6 +7,7 @@ import (
 	"openeuler.org/PilotGo/PilotGo/pkg/app/agent/localstorage"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/agent/network"
 	"openeuler.org/PilotGo/PilotGo/pkg/logger"
+	"openeuler.org/PilotGo/PilotGo/pkg/utils"
 	"openeuler.org/PilotGo/PilotGo/pkg/utils/message/protocol"
 	uos "openeuler.org/PilotGo/PilotGo/pkg/utils/os"
 	"openeuler.org/PilotGo/PilotGo/pkg/utils/os/common"
@@ -96,10 +97,15 @@ func AgentInfoHandler(c *network.SocketClient, msg *protocol.Message) error {
 		AgentVersion string `json:"agent_version"`
 		IP           string `json:"IP"`
 		AgentUUID    string `json:"agent_uuid"`
+		// agent能力信息
+		Interpreters    []utils.Interpreter `json:"interpreters"`
+		ProtocolVersion int                 `json:"protocol_version"`
 	}{
-		AgentVersion: global.AgentVersion,
-		IP:           IP,
-		AgentUUID:    localstorage.AgentUUID(),
+		AgentVersion:    global.AgentVersion,
+		IP:              IP,
+		AgentUUID:       localstorage.AgentUUID(),
+		Interpreters:    utils.AvailableInterpreters(),
+		ProtocolVersion: protocol.Version,
 	}
 
 	resp_msg := &protocol.Message{
@@ -126,8 +126,13 @@ func RegitsterHandler(c *network.SocketClient) {
 
 	c.BindHandler(protocol.CronStart, handler.CronStartHandler)
 	c.BindHandler(protocol.CronStopAndDel, handler.CronStopAndDelHandler)
+	c.BindHandler(protocol.CronList, handler.CronListHandler)
+	c.BindHandler(protocol.CronRunNow, handler.CronRunNowHandler)
 
 	c.BindHandler(protocol.ReadFile, handler.ReadFileHandler)
 	c.BindHandler(protocol.EditFile, handler.EditFileHandler)
+	c.BindHandler(protocol.ApplyFile, handler.ApplyFileHandler)
+	c.BindHandler(protocol.FileWatchSet, handler.FileWatchSetHandler)
+	c.BindHandler(protocol.IntegritySnapshot, handler.IntegritySnapshotHandler)
 	c.BindHandler(protocol.AgentConfig, handler.AgentConfigHandler)
 }
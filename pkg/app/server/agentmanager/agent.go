@@ -15,9 +15,13 @@
 package agentmanager
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -34,13 +38,57 @@ type AgentMessageHandler func(*Agent, *protocol.Message) error
 
 var WARN_MSG chan interface{}
 
+// 未指定超时时间时等待agent响应的最长时间
+const DefaultMessageTimeout = 10 * time.Minute
+
+var (
+	ErrMessageTimeout    = errors.New("wait for agent response timeout")
+	ErrAgentDisconnected = errors.New("agent disconnected")
+	ErrUnsupported       = errors.New("agent版本过低，不支持该操作")
+)
+
+// 各消息类型要求agent支持的最低协议版本，未列出的消息所有版本的agent均支持
+var minProtocolVersion = map[int]int{
+	protocol.CronList:          1,
+	protocol.CronRunNow:        1,
+	protocol.ApplyFile:         1,
+	protocol.FileWatchSet:      1,
+	protocol.IntegritySnapshot: 1,
+}
+
+// 运行脚本时设置执行选项、使用bash以外的解释器要求agent支持的最低协议版本，
+// 旧版本agent会忽略这些字段
+const (
+	minScriptOptionsVersion     = 1
+	minScriptInterpreterVersion = 1
+)
+
+// 定时任务执行结果的处理函数
+var cronResultHandler func(a *Agent, r *common.CronRunResult)
+
+func SetCronResultHandler(f func(a *Agent, r *common.CronRunResult)) {
+	cronResultHandler = f
+}
+
+var fileWatchEventHandler func(a *Agent, e *common.FileWatchEvent)
+
+func SetFileWatchEventHandler(f func(a *Agent, e *common.FileWatchEvent)) {
+	fileWatchEventHandler = f
+}
+
 type Agent struct {
 	UUID             string
 	Version          string
+	ProtocolVersion  int
 	IP               string
+	Interpreters     []utils.Interpreter
 	conn             net.Conn
 	MessageProcesser *protocol.MessageProcesser
 	messageChan      chan *protocol.Message
+	// 连接断开时关闭
+	done chan struct{}
+	// 可选，取消后不再等待agent的响应
+	ctx context.Context
 }
 
 // 通过给定的conn连接初始化一个agent并启动监听
@@ -50,13 +98,18 @@ func NewAgent(conn net.Conn) (*Agent, error) {
 		conn:             conn,
 		MessageProcesser: protocol.NewMessageProcesser(),
 		messageChan:      make(chan *protocol.Message, 50),
+		done:             make(chan struct{}),
 	}
 
 	go func(agent *Agent) {
 		for {
-			msg := <-agent.messageChan
-			logger.Debug("send message:%s", msg.String())
-			pnet.SendBytes(agent.conn, protocol.TlvEncode(msg.Encode()))
+			select {
+			case msg := <-agent.messageChan:
+				logger.Debug("send message:%s", msg.String())
+				pnet.SendBytes(agent.conn, protocol.TlvEncode(msg.Encode()))
+			case <-agent.done:
+				return
+			}
 		}
 	}(agent)
 
@@ -71,6 +124,13 @@ func NewAgent(conn net.Conn) (*Agent, error) {
 	return agent, nil
 }
 
+// 返回绑定ctx的agent副本，ctx取消或超时后通过该副本发送的请求不再等待响应
+func (a *Agent) WithContext(ctx context.Context) *Agent {
+	c := *a
+	c.ctx = ctx
+	return &c
+}
+
 func (a *Agent) bindHandler(t int, f AgentMessageHandler) {
 	a.MessageProcesser.BindHandler(t, func(c protocol.MessageContext, msg *protocol.Message) error {
 		return f(c.(*Agent), msg)
@@ -78,6 +138,7 @@ func (a *Agent) bindHandler(t int, f AgentMessageHandler) {
 }
 
 func (a *Agent) startListen() {
+	defer close(a.done)
 	defer func() {
 		if err := recover(); err != nil {
 			logger.Error("server processor panic error:%s", err.(error).Error())
@@ -124,7 +185,27 @@ func (a *Agent) Init() error {
 	})
 	a.bindHandler(protocol.FileMonitor, func(a *Agent, msg *protocol.Message) error {
 		logger.Info("process file monitor from processor:%s", msg.String())
-		WARN_MSG <- msg.Data.(string)
+		// 旧版本agent上报的是文字描述
+		if str, ok := msg.Data.(string); ok {
+			select {
+			case WARN_MSG <- str:
+			default:
+			}
+			return nil
+		}
+		// 含时间字段，经json转换而非mapstructure解析
+		e := &common.FileWatchEvent{}
+		bs, err := json.Marshal(msg.Data)
+		if err == nil {
+			err = json.Unmarshal(bs, e)
+		}
+		if err != nil {
+			logger.Error("failed to parse file event from %s: %s", a.UUID, err.Error())
+			return err
+		}
+		if fileWatchEventHandler != nil {
+			fileWatchEventHandler(a, e)
+		}
 		return nil
 	})
 
@@ -141,14 +222,34 @@ func (a *Agent) Init() error {
 		return nil
 	})
 
+	a.bindHandler(protocol.CronResult, func(a *Agent, msg *protocol.Message) error {
+		// 含时间字段，经json转换而非mapstructure解析
+		r := &common.CronRunResult{}
+		bs, err := json.Marshal(msg.Data)
+		if err == nil {
+			err = json.Unmarshal(bs, r)
+		}
+		if err != nil {
+			logger.Error("failed to parse cron result from %s: %s", a.UUID, err.Error())
+			return err
+		}
+		if cronResultHandler != nil {
+			cronResultHandler(a, r)
+		}
+		return nil
+	})
+
 	data, err := a.AgentInfo()
 	if err != nil {
 		logger.Error("fail to get agent info, address:%s", a.conn.RemoteAddr().String())
+		return err
 	}
 
 	a.UUID = data.AgentUUID
 	a.IP = data.IP
 	a.Version = data.AgentVersion
+	a.Interpreters = data.Interpreters
+	a.ProtocolVersion = data.ProtocolVersion
 
 	return nil
 }
@@ -181,19 +282,41 @@ func (a *Agent) RunCommand(cmd string) (*utils.CmdResult, error) {
 
 // 远程在agent上运行脚本文件
 func (a *Agent) RunScript(script string, params []string) (*utils.CmdResult, error) {
+	return a.RunScriptWithOptions(script, params, "", nil)
+}
+
+// 远程在agent上以指定解释器、用户、工作目录、超时及资源限制运行脚本文件，解释器为空时使用bash
+func (a *Agent) RunScriptWithOptions(script string, params []string, interpreter string, opts *utils.ScriptOptions) (*utils.CmdResult, error) {
+	// 不能以root用户且不受限制地运行本应受限的脚本
+	if !opts.IsEmpty() && a.ProtocolVersion < minScriptOptionsVersion {
+		return nil, ErrUnsupported
+	}
+	// 旧版本agent会以bash运行其他语言的脚本
+	if i := strings.TrimSpace(interpreter); i != "" && i != utils.DefaultInterpreter && a.ProtocolVersion < minScriptInterpreterVersion {
+		return nil, ErrUnsupported
+	}
 	msg := &protocol.Message{
 		UUID: uuid.New().String(),
 		Type: protocol.RunScript,
 		Data: struct {
-			Script string
-			Params []string
+			Script      string
+			Params      []string
+			Interpreter string
+			Options     *utils.ScriptOptions
 		}{
-			Script: script,
-			Params: params,
+			Script:      script,
+			Params:      params,
+			Interpreter: interpreter,
+			Options:     opts,
 		},
 	}
 
-	resp_message, err := a.sendMessage(msg, true, 0)
+	// 脚本设置的超时时间较长时相应延长等待时间
+	timeout := time.Duration(0)
+	if opts != nil && opts.Timeout > 0 {
+		timeout = time.Duration(opts.Timeout)*time.Second + time.Minute
+	}
+	resp_message, err := a.sendMessage(msg, true, timeout)
 	if err != nil {
 		logger.Error("failed to run script on agent")
 		return nil, err
@@ -207,34 +330,78 @@ func (a *Agent) RunScript(script string, params []string) (*utils.CmdResult, err
 	return result, nil
 }
 
-// TODO: err未发挥作用
+// 发送消息，wait为true时等待agent响应，超过timeout(为0时为DefaultMessageTimeout)、
+// 连接断开或ctx取消时返回错误
 func (a *Agent) sendMessage(msg *protocol.Message, wait bool, timeout time.Duration) (*protocol.Message, error) {
 	logger.Debug("send message:%s", msg.String())
 
+	// 旧版本agent不会响应未知的消息类型
+	if !a.Supports(msg.Type) {
+		return nil, ErrUnsupported
+	}
 	if msg.UUID == "" {
 		msg.UUID = uuid.New().String()
 	}
+	ctx := a.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if timeout <= 0 {
+		timeout = DefaultMessageTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var waitChan chan *protocol.Message
 	if wait {
-		waitChan := make(chan *protocol.Message)
+		// 带缓冲，放弃等待后迟到的响应不会阻塞消息处理
+		waitChan = make(chan *protocol.Message, 1)
 		a.MessageProcesser.WaitMap.Store(msg.UUID, waitChan)
+		defer a.MessageProcesser.WaitMap.Delete(msg.UUID)
+	}
 
-		// send message to data send channel
-		a.messageChan <- msg
+	// send message to data send channel
+	select {
+	case a.messageChan <- msg:
+	case <-a.done:
+		return nil, ErrAgentDisconnected
+	case <-ctx.Done():
+		return nil, waitError(ctx)
+	}
+	if !wait {
+		return nil, nil
+	}
 
-		// wail for response
-		data := <-waitChan
+	// wail for response
+	select {
+	case data := <-waitChan:
 		return data, nil
+	case <-a.done:
+		return nil, ErrAgentDisconnected
+	case <-ctx.Done():
+		logger.Warn("agent %s message %d: %s", a.UUID, msg.Type, ctx.Err().Error())
+		return nil, waitError(ctx)
 	}
+}
+
+// agent是否支持该类型的消息
+func (a *Agent) Supports(msgType int) bool {
+	return a.ProtocolVersion >= minProtocolVersion[msgType]
+}
 
-	// just send message to channel
-	a.messageChan <- msg
-	return nil, nil
+func waitError(ctx context.Context) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return ErrMessageTimeout
+	}
+	return ctx.Err()
 }
 
 type AgentInfo struct {
-	AgentVersion string `mapstructure:"agent_version"`
-	AgentUUID    string `mapstructure:"agent_uuid"`
-	IP           string `mapstructure:"IP"`
+	AgentVersion    string              `mapstructure:"agent_version"`
+	AgentUUID       string              `mapstructure:"agent_uuid"`
+	IP              string              `mapstructure:"IP"`
+	Interpreters    []utils.Interpreter `mapstructure:"interpreters"`
+	ProtocolVersion int                 `mapstructure:"protocol_version"`
 }
 
 // 远程获取agent端的系统信息
@@ -1356,7 +1523,8 @@ func (a *Agent) CronStart(id int, spec string, command string) (string, string,
 	msg := &protocol.Message{
 		UUID: uuid.New().String(),
 		Type: protocol.CronStart,
-		Data: strconv.Itoa(id) + "," + spec + "," + command,
+		// 旧版本agent只能解析以逗号拼接的任务参数
+		Data: fmt.Sprintf("%d,%s,%s", id, spec, command),
 	}
 
 	resp_message, err := a.sendMessage(msg, true, 0)
@@ -1395,6 +1563,58 @@ func (a *Agent) CronStopAndDel(id int) (string, error) {
 	return resp_message.Data.(string), nil
 }
 
+// 立即执行一次定时任务
+func (a *Agent) CronRunNow(id int, spec string, command string) (string, error) {
+	msg := &protocol.Message{
+		UUID: uuid.New().String(),
+		Type: protocol.CronRunNow,
+		Data: common.CronTask{ID: id, Spec: spec, Command: command},
+	}
+
+	resp_message, err := a.sendMessage(msg, true, 0)
+	if err != nil {
+		logger.Error("failed to run cron task on agent")
+		return "", err
+	}
+
+	if resp_message.Status == -1 || resp_message.Error != "" {
+		logger.Error("failed to run cron task on agent: %s", resp_message.Error)
+		return "", fmt.Errorf(resp_message.Error)
+	}
+
+	return resp_message.Data.(string), nil
+}
+
+// 获取定时任务列表的等待时间，对账时不应长时间阻塞
+const cronListTimeout = 30 * time.Second
+
+// 获取agent上正在运行的定时任务
+func (a *Agent) CronList() ([]common.CronTask, error) {
+	msg := &protocol.Message{
+		UUID: uuid.New().String(),
+		Type: protocol.CronList,
+		Data: struct{}{},
+	}
+
+	resp_message, err := a.sendMessage(msg, true, cronListTimeout)
+	if err != nil {
+		logger.Error("failed to get cron list on agent")
+		return nil, err
+	}
+
+	if resp_message.Status == -1 || resp_message.Error != "" {
+		logger.Error("failed to get cron list on agent: %s", resp_message.Error)
+		return nil, fmt.Errorf(resp_message.Error)
+	}
+
+	tasks := []common.CronTask{}
+	if err := resp_message.BindData(&tasks); err != nil {
+		logger.Error("bind data error: %s", err)
+		return nil, err
+	}
+	return tasks, nil
+}
+
 // 远程获取agent端的repo文件
 func (a *Agent) GetRepoSource() ([]*common.RepoSource, string, error) {
 	msg := &protocol.Message{
@@ -1545,6 +1765,91 @@ func (a *Agent) ReadFile(filepath string) (string, string, error) {
 	return resp_message.Data.(string), resp_message.Error, nil
 }
 
+// 校验后安装配置文件并使其生效，校验或生效失败时返回的结果中包含失败原因
+func (a *Agent) ApplyFile(apply *common.FileApply) (*common.FileApplyResult, string, error) {
+	msg := &protocol.Message{
+		UUID: uuid.New().String(),
+		Type: protocol.ApplyFile,
+		Data: apply,
+	}
+
+	resp_message, err := a.sendMessage(msg, true, 0)
+	if err != nil {
+		logger.Error("failed to apply file on agent")
+		return nil, "", err
+	}
+
+	if resp_message.Status == -1 || resp_message.Error != "" {
+		logger.Error("failed to apply file on agent: %s", resp_message.Error)
+		return nil, resp_message.Error, fmt.Errorf(resp_message.Error)
+	}
+
+	result := &common.FileApplyResult{}
+	err = resp_message.BindData(result)
+	if err != nil {
+		logger.Error("bind ApplyFile data error: %s", err.Error())
+		return nil, resp_message.Error, err
+	}
+	return result, resp_message.Error, nil
+}
+
+// 下发文件监控列表，agent收到后立即替换当前的监控
+// 下发文件监控列表的等待时间，定期同步时不应长时间阻塞
+const fileWatchSetTimeout = 30 * time.Second
+
+func (a *Agent) FileWatchSet(list *common.WatchList) (string, error) {
+	msg := &protocol.Message{
+		UUID: uuid.New().String(),
+		Type: protocol.FileWatchSet,
+		Data: list,
+	}
+
+	resp_message, err := a.sendMessage(msg, true, fileWatchSetTimeout)
+	if err != nil {
+		logger.Error("failed to set file watch list on agent")
+		return "", err
+	}
+
+	if resp_message.Status == -1 || resp_message.Error != "" {
+		logger.Error("failed to set file watch list on agent: %s", resp_message.Error)
+		return resp_message.Error, fmt.Errorf(resp_message.Error)
+	}
+	return "", nil
+}
+
+// 获取agent上文件的完整性快照
+func (a *Agent) IntegritySnapshot(param *common.IntegrityParam) (*common.IntegritySnapshot, error) {
+	msg := &protocol.Message{
+		UUID: uuid.New().String(),
+		Type: protocol.IntegritySnapshot,
+		Data: param,
+	}
+
+	// 需计算所有文件的hash，耗时较长
+	resp_message, err := a.sendMessage(msg, true, 30*time.Minute)
+	if err != nil {
+		logger.Error("failed to get integrity snapshot on agent")
+		return nil, err
+	}
+
+	if resp_message.Status == -1 || resp_message.Error != "" {
+		logger.Error("failed to get integrity snapshot on agent: %s", resp_message.Error)
+		return nil, fmt.Errorf(resp_message.Error)
+	}
+
+	// 含时间字段，经json转换而非mapstructure解析
+	snapshot := &common.IntegritySnapshot{}
+	bs, err := json.Marshal(resp_message.Data)
+	if err == nil {
+		err = json.Unmarshal(bs, snapshot)
+	}
+	if err != nil {
+		logger.Error("bind IntegritySnapshot data error: %s", err.Error())
+		return nil, err
+	}
+	return snapshot, nil
+}
+
 // 更新配置文件
 func (a *Agent) UpdateFile(filepath string, filename string, text string) (*common.UpdateFile, string, error) {
 	updatefile := common.UpdateFile{
@@ -0,0 +1,32 @@
+package agentmanager
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"openeuler.org/PilotGo/PilotGo/pkg/utils"
+	"openeuler.org/PilotGo/PilotGo/pkg/utils/message/protocol"
+)
+
+func TestSupports(t *testing.T) {
+	old := &Agent{UUID: "old"}
+	assert.True(t, old.Supports(protocol.RunScript))
+	assert.False(t, old.Supports(protocol.CronList))
+	assert.False(t, old.Supports(protocol.ApplyFile))
+
+	current := &Agent{UUID: "current", ProtocolVersion: 1}
+	assert.True(t, current.Supports(protocol.CronList))
+	assert.True(t, current.Supports(protocol.IntegritySnapshot))
+}
+
+func TestRunScriptOptionsRequireProtocol(t *testing.T) {
+	old := &Agent{UUID: "old"}
+	for _, opts := range []*utils.ScriptOptions{
+		{User: "nobody"},
+		{Timeout: 30},
+		{MemoryLimit: 64},
+	} {
+		_, err := old.RunScriptWithOptions("echo 1", nil, "", opts)
+		assert.Equal(t, ErrUnsupported, err, "%+v", opts)
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"sync"
 
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/eventbus"
 	"openeuler.org/PilotGo/PilotGo/pkg/global"
 	"openeuler.org/PilotGo/PilotGo/pkg/logger"
 )
@@ -86,6 +87,14 @@ func DeleteAgent(uuid string) {
 	}
 }
 
+// agent上线后的处理函数
+var onlineHandlers []func(a *Agent)
+
+// 注册agent上线后的处理函数，须在socket server启动前调用
+func AddOnlineHandler(f func(a *Agent)) {
+	onlineHandlers = append(onlineHandlers, f)
+}
+
 func AddandRunAgent(c net.Conn) {
 	agent, err := NewAgent(c)
 	if err != nil {
@@ -96,6 +105,10 @@ func AddandRunAgent(c net.Conn) {
 	AddAgent(agent)
 	logger.Info("Add new agent from:%s", c.RemoteAddr().String())
 	AddAgents2DB(agent)
+
+	for _, f := range onlineHandlers {
+		go f(agent)
+	}
 }
 
 func StopAgentManager() {
@@ -155,4 +168,10 @@ func AddAgents2DB(a *Agent) {
 		logger.Error(err.Error())
 		return
 	}
+
+	eventbus.PublishEvent(&eventbus.EventMessage{
+		MessageType: eventbus.MsgHostAdd,
+		MachineUUID: a.UUID,
+		MessageData: map[string]string{"ip": agent_os.IP},
+	})
 }
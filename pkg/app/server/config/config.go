@@ -46,12 +46,41 @@ type RedisDBInfo struct {
 	EnableRedis bool          `yaml:"enableRedis"`
 }
 
+type EventConf struct {
+	RetentionDays int `yaml:"retention_days"`
+}
+
+type ExecutorConf struct {
+	MaxConcurrency int `yaml:"max_concurrency"`
+	HostTimeout    int `yaml:"host_timeout"`
+}
+
+type ApprovalConf struct {
+	// 需要审批后才能执行的操作类型
+	Operations []string `yaml:"operations"`
+	// 可以审批的角色id，为空时任意其他用户均可审批
+	ApproverRoles []string `yaml:"approver_roles"`
+	// 审批请求的有效期，单位秒
+	ExpireTime int `yaml:"expire_time"`
+}
+
+type AuditConf struct {
+	// 签名检查点的ed25519私钥文件，不存在时自动生成，历次公钥记录在同名.pub文件中
+	KeyFile string `yaml:"key_file"`
+	// 生成签名检查点的间隔，单位分钟
+	CheckpointInterval int `yaml:"checkpoint_interval"`
+}
+
 type ServerConfig struct {
 	HttpServer   HttpServer     `yaml:"http_server"`
 	SocketServer SocketServer   `yaml:"socket_server"`
 	Logopts      logger.LogOpts `yaml:"log"`
 	MysqlDBinfo  MysqlDBInfo    `yaml:"mysql"`
 	RedisDBinfo  RedisDBInfo    `yaml:"redis"`
+	Event        EventConf      `yaml:"event"`
+	Executor     ExecutorConf   `yaml:"executor"`
+	Approval     ApprovalConf   `yaml:"approval"`
+	Audit        AuditConf      `yaml:"audit"`
 }
 
 const config_file = "./config_server.yaml"
@@ -24,18 +24,29 @@ import (
 	"openeuler.org/PilotGo/PilotGo/pkg/utils/response"
 )
 
+// 查询agent信息及能力，包括可用的脚本解释器
 func AgentInfoHandler(c *gin.Context) {
 	logger.Debug("process get agent request")
-	// TODO: process agent info
-	agent := agentmanager.GetAgent("uuid")
+	uuid := c.Query("uuid")
+	agent := agentmanager.GetAgent(uuid)
 	if agent == nil {
-		c.JSON(http.StatusOK, `{"status":-1}`)
+		response.Fail(c, nil, "获取uuid失败!")
+		return
 	}
 
-	agent.AgentInfo()
-	// TODO: 此处处理并返回agent信息
+	info, err := agent.AgentInfo()
+	if err != nil {
+		response.Fail(c, nil, "获取agent信息失败: "+err.Error())
+		return
+	}
+	agent.Interpreters = info.Interpreters
 
-	c.JSON(http.StatusOK, `{"status":0}`)
+	response.Success(c, gin.H{
+		"agent_uuid":    info.AgentUUID,
+		"agent_version": info.AgentVersion,
+		"IP":            info.IP,
+		"interpreters":  info.Interpreters,
+	}, "Success")
 }
 
 func AgentListHandler(c *gin.Context) {
@@ -0,0 +1,163 @@
+package agentcontroller
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/agentmanager"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/approval"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/auth"
+	"openeuler.org/PilotGo/PilotGo/pkg/utils/response"
+)
+
+type diskFormatParam struct {
+	UUID     string `json:"uuid"`
+	FileType string `json:"type"`
+	DiskPath string `json:"path"`
+}
+
+type userDelParam struct {
+	UUID     string `json:"uuid"`
+	UserName string `json:"username"`
+}
+
+type firewallStopParam struct {
+	UUID string `json:"uuid"`
+}
+
+// 停止服务的审批参数
+type ServiceStopParam struct {
+	UUIDs    []string `json:"uuids"`
+	Service  string   `json:"service"`
+	UserName string   `json:"userName"`
+	UserDept string   `json:"userDept"`
+}
+
+func init() {
+	approval.Register(approval.OpDiskFormat, func(params string) (interface{}, error) {
+		p := &diskFormatParam{}
+		agent, err := approvalAgent(params, p, &p.UUID)
+		if err != nil {
+			return nil, err
+		}
+		disk_format, err := agent.DiskFormat(p.FileType, p.DiskPath)
+		if disk_format == "" || err != nil {
+			return nil, fmt.Errorf("格式化磁盘失败: %v", err)
+		}
+		return disk_format, nil
+	})
+	approval.Register(approval.OpUserDel, func(params string) (interface{}, error) {
+		p := &userDelParam{}
+		agent, err := approvalAgent(params, p, &p.UUID)
+		if err != nil {
+			return nil, err
+		}
+		user_del, Err, err := agent.DelUser(p.UserName)
+		if len(Err) != 0 || err != nil {
+			return nil, agentError(Err, err)
+		}
+		return user_del, nil
+	})
+	approval.Register(approval.OpFirewallStop, func(params string) (interface{}, error) {
+		p := &firewallStopParam{}
+		agent, err := approvalAgent(params, p, &p.UUID)
+		if err != nil {
+			return nil, err
+		}
+		stop, Err, err := agent.FirewalldStop()
+		if len(Err) != 0 || err != nil {
+			return nil, agentError(Err, err)
+		}
+		return stop, nil
+	})
+	approval.Register(approval.OpNetworkConfig, func(params string) (interface{}, error) {
+		p := &networkParam{}
+		if err := json.Unmarshal([]byte(params), p); err != nil {
+			return nil, err
+		}
+		return nil, configNetwork(p)
+	})
+	approval.Register(approval.OpRpmRemove, func(params string) (interface{}, error) {
+		rpm := &RPMS{}
+		if err := json.Unmarshal([]byte(params), rpm); err != nil {
+			return nil, err
+		}
+		results, ok := removeRpm(rpm)
+		if !ok {
+			return results, errors.New("软件包卸载失败")
+		}
+		return results, nil
+	})
+	approval.Register(approval.OpServiceStop, func(params string) (interface{}, error) {
+		p := &ServiceStopParam{}
+		if err := json.Unmarshal([]byte(params), p); err != nil {
+			return nil, err
+		}
+		results, ok := stopService(p)
+		if !ok {
+			return results, errors.New("关闭服务失败")
+		}
+		return results, nil
+	})
+}
+
+// 操作需要审批时以当前登录用户的身份提交审批请求并返回true，申请原因从请求参数reason中获取
+func requireApproval(c *gin.Context, op string, targets []string, params interface{}) bool {
+	return requireApprovalBy(c, op, targets, params, c.Query("reason"))
+}
+
+func requireApprovalBy(c *gin.Context, op string, targets []string, params interface{}, reason string) bool {
+	if !approval.Required(op) {
+		return false
+	}
+	submitApproval(c, op, targets, params, reason)
+	return true
+}
+
+// 以当前登录用户的身份提交审批请求
+func submitApproval(c *gin.Context, op string, targets []string, params interface{}, reason string) {
+	u, ok := auth.LoginUser(c)
+	if !ok {
+		response.Fail(c, nil, "未登录，无法提交审批请求")
+		return
+	}
+	SubmitApproval(c, &approval.Request{
+		Operation:   op,
+		Targets:     targets,
+		Params:      params,
+		Reason:      reason,
+		Requester:   u.Email,
+		RequesterID: u.ID,
+	})
+}
+
+// 提交审批请求并返回响应
+func SubmitApproval(c *gin.Context, r *approval.Request) {
+	a, err := approval.Submit(r)
+	if err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, gin.H{"approval": a}, "该操作需要审批，已提交审批请求")
+}
+
+// 解析审批参数并获取目标机器
+func approvalAgent(params string, p interface{}, uuid *string) (*agentmanager.Agent, error) {
+	if err := json.Unmarshal([]byte(params), p); err != nil {
+		return nil, err
+	}
+	agent := agentmanager.GetAgent(*uuid)
+	if agent == nil {
+		return nil, errors.New("获取uuid失败!")
+	}
+	return agent, nil
+}
+
+func agentError(Err string, err error) error {
+	if len(Err) != 0 {
+		return errors.New(Err)
+	}
+	return err
+}
@@ -22,6 +22,7 @@ import (
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/common"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/cron"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/policy"
 	"openeuler.org/PilotGo/PilotGo/pkg/logger"
 	"openeuler.org/PilotGo/PilotGo/pkg/utils/response"
 )
@@ -59,6 +60,14 @@ func CreatCron(c *gin.Context) {
 		response.Fail(c, nil, "执行命令不能为空")
 		return
 	}
+	if err := cron.ValidateTarget(&newCron); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	if d, err := cron.CheckCommand(policy.LoginSubject(c), command, newCron.Confirm); err != nil {
+		response.Fail(c, gin.H{"decision": d}, err.Error())
+		return
+	}
 	newcron := dao.CrontabList{
 		MachineUUID: uuid,
 		TaskName:    TaskName,
@@ -66,6 +75,9 @@ func CreatCron(c *gin.Context) {
 		CronSpec:    spec[:len(spec)-2],
 		Command:     command,
 		Status:      &status,
+		BatchIDs:    cron.JoinIDs(newCron.BatchIDs),
+		DepartIDs:   cron.JoinIDs(newCron.DepartIDs),
+		UserName:    policy.LoginSubject(c).Name,
 	}
 	id, err := dao.NewCron(newcron)
 	if err != nil {
@@ -76,6 +88,12 @@ func CreatCron(c *gin.Context) {
 		response.Fail(c, nil, "定时任务已保存")
 		return
 	}
+	// 批次及部门任务由对账下发到各成员机器
+	if cron.IsGroup(&newcron) {
+		cron.SyncGroups()
+		response.Success(c, gin.H{"data": newCron}, "任务已生效")
+		return
+	}
 
 	// 远程命令执行
 	cronSpec, Command, err := dao.Id2CronInfo(id)
@@ -97,7 +115,15 @@ func DeleteCronTask(c *gin.Context) {
 	var cronIds string
 	c.Bind(&crons)
 	uuid := crons.MachineUUID
+	group := false
 	for _, cronId := range crons.IDs {
+		if task, err := dao.GetCron(cronId); err == nil && cron.IsGroup(task) {
+			if err := dao.DeleteTask(cronId); err != nil {
+				logger.Error(err.Error())
+			}
+			group = true
+			continue
+		}
 		_, err := cron.StopAndDel(uuid, cronId)
 		if err != nil {
 			cronIds = strconv.Itoa(cronId) + ","
@@ -107,6 +133,9 @@ func DeleteCronTask(c *gin.Context) {
 			logger.Error(err.Error())
 		}
 	}
+	if group {
+		cron.SyncGroups()
+	}
 	if len(cronIds) != 0 {
 		msg := fmt.Sprintf("以下任务编号未删除成功：%s", cronIds[:len(cronIds)-1])
 		response.Fail(c, nil, msg)
@@ -125,16 +154,42 @@ func UpdateCron(c *gin.Context) {
 	command := Cron.Command
 	uuid := Cron.MachineUUID
 	status := Cron.Status
+	task, err := dao.GetCron(id)
+	if err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	group := cron.IsGroup(task)
+	if group && len(Cron.BatchIDs) == 0 && len(Cron.DepartIDs) == 0 {
+		response.Fail(c, nil, "请指定执行任务的批次或部门")
+		return
+	}
+	if d, err := cron.CheckCommand(policy.LoginSubject(c), command, Cron.Confirm); err != nil {
+		response.Fail(c, gin.H{"decision": d}, err.Error())
+		return
+	}
 	UpdateCron := dao.CrontabList{
 		TaskName:    TaskName,
 		Description: description,
 		CronSpec:    spec[:len(spec)-2],
 		Command:     command,
 		Status:      &status,
+		UserName:    policy.LoginSubject(c).Name,
+	}
+	if group {
+		UpdateCron.BatchIDs = cron.JoinIDs(Cron.BatchIDs)
+		UpdateCron.DepartIDs = cron.JoinIDs(Cron.DepartIDs)
 	}
 	// 数据库内容修改
 	if err := dao.UpdateTask(id, UpdateCron); err != nil {
 		response.Fail(c, nil, err.Error())
+		return
+	}
+	// 批次及部门任务由对账同步到各成员机器，已关闭的任务也会从成员机器上移除
+	if group {
+		cron.SyncGroups()
+		response.Success(c, nil, "任务已保存,正在同步到目标机器")
+		return
 	}
 	if !status {
 		response.Fail(c, nil, "定时任务已保存,未执行")
@@ -142,7 +197,7 @@ func UpdateCron(c *gin.Context) {
 	}
 
 	// 更新agent任务
-	_, err := cron.StopAndDel(uuid, id)
+	_, err = cron.StopAndDel(uuid, id)
 	if err != nil {
 		msg := fmt.Sprintf("任务已保存,重启失败：%s", err)
 		response.Fail(c, nil, msg)
@@ -174,8 +229,27 @@ func CronTaskStatus(c *gin.Context) {
 		return
 	}
 
+	// status为false时开启任务，需先通过命令策略检查
+	if !status {
+		_, command, err := dao.Id2CronInfo(id)
+		if err != nil {
+			response.Fail(c, gin.H{"error": err}, "任务执行失败!")
+			return
+		}
+		if d, err := cron.CheckCommand(policy.LoginSubject(c), command, Cron.Confirm); err != nil {
+			response.Fail(c, gin.H{"decision": d}, err.Error())
+			return
+		}
+	}
+
 	if err := dao.CronTaskStatus(id, status); err != nil {
 		response.Fail(c, nil, err.Error())
+		return
+	}
+	if task, err := dao.GetCron(id); err == nil && cron.IsGroup(task) {
+		cron.SyncGroups()
+		response.Success(c, nil, "任务状态已更新,正在同步到目标机器")
+		return
 	}
 
 	if status {
@@ -227,3 +301,85 @@ func CronTaskList(c *gin.Context) {
 	// 返回数据开始拼装分页的json
 	common.JsonPagination(c, list, total, query)
 }
+
+// 以数据库为准同步机器上的定时任务，uuid为空时同步所有在线机器
+func CronReconcileHandler(c *gin.Context) {
+	p := struct {
+		MachineUUID string `json:"uuid"`
+	}{}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&p); err != nil {
+			response.Fail(c, nil, "parameter error")
+			return
+		}
+	}
+
+	if p.MachineUUID == "" {
+		response.Success(c, gin.H{"reports": cron.ReconcileAll()}, "定时任务同步完成")
+		return
+	}
+	report, err := cron.Reconcile(p.MachineUUID)
+	if err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, gin.H{"reports": []*cron.Report{report}}, "定时任务同步完成")
+}
+
+// 查询最近一次定时任务对账结果，inconsistent=true时只返回存在差异的机器
+func CronReportHandler(c *gin.Context) {
+	onlyInconsistent := c.Query("inconsistent") == "true"
+	response.Success(c, gin.H{"reports": cron.Reports(onlyInconsistent)}, "Success")
+}
+
+// 立即执行一次定时任务
+func CronRunNowHandler(c *gin.Context) {
+	var Cron dao.CrontabUpdate
+	if err := c.ShouldBindJSON(&Cron); err != nil {
+		response.Fail(c, nil, "parameter error")
+		return
+	}
+	if err := cron.RunNow(Cron.ID, policy.LoginSubject(c), Cron.Confirm); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, nil, "任务已触发")
+}
+
+// 查询定时任务执行记录，支持按任务id、机器及是否失败过滤
+func CronHistoryHandler(c *gin.Context) {
+	query := &common.PaginationQ{}
+	if err := c.ShouldBindQuery(query); err != nil {
+		response.Fail(c, gin.H{"status": false}, err.Error())
+		return
+	}
+
+	id, _ := strconv.Atoi(c.Query("id"))
+	f := &cron.RunFilter{
+		CronID:      id,
+		MachineUUID: c.Query("uuid"),
+		FailedOnly:  c.Query("failed") == "true",
+	}
+	list, tx := cron.QueryRuns(f)
+	total, err := common.CrudAll(query, tx, list)
+	if err != nil {
+		response.Fail(c, gin.H{"status": false}, err.Error())
+		return
+	}
+	common.JsonPagination(c, list, total, query)
+}
+
+// 按机器汇总定时任务的执行情况
+func CronSummaryHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Query("id"))
+	if err != nil {
+		response.Fail(c, nil, "任务ID输入格式有误")
+		return
+	}
+	summary, err := cron.Summarize(id)
+	if err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, gin.H{"summary": summary}, "Success")
+}
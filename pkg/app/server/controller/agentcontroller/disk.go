@@ -17,6 +17,7 @@ package agentcontroller
 import (
 	"github.com/gin-gonic/gin"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/agentmanager"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/approval"
 	"openeuler.org/PilotGo/PilotGo/pkg/utils/response"
 )
 
@@ -92,6 +93,10 @@ func DiskFormatHandler(c *gin.Context) {
 	uuid := c.Query("uuid")
 	fileType := c.Query("type")
 	diskPath := c.Query("path")
+	p := &diskFormatParam{UUID: uuid, FileType: fileType, DiskPath: diskPath}
+	if requireApproval(c, approval.OpDiskFormat, []string{uuid}, p) {
+		return
+	}
 
 	agent := agentmanager.GetAgent(uuid)
 	if agent == nil {
@@ -16,15 +16,14 @@
 package agentcontroller
 
 import (
-	"net/http"
-	"strconv"
-
 	"github.com/gin-gonic/gin"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/agentmanager"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/auditlog"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/executor"
 	fileservice "openeuler.org/PilotGo/PilotGo/pkg/app/server/service/file"
-	"openeuler.org/PilotGo/PilotGo/pkg/logger"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/policy"
 	"openeuler.org/PilotGo/PilotGo/pkg/utils/response"
 )
 
@@ -67,6 +66,7 @@ func FileBroadcastToAgents(c *gin.Context) {
 
 	batchIds := fb.BatchId
 	UUIDs := dao.BatchIds2UUIDs(batchIds)
+	auditlog.AddMachines(c, UUIDs...)
 
 	path := fb.Path
 	filename := fb.FileName
@@ -84,76 +84,50 @@ func FileBroadcastToAgents(c *gin.Context) {
 		response.Fail(c, nil, "文件内容为空，请重新检查文件内容")
 		return
 	}
-	logParent := dao.AgentLogParent{
-		UserName:   fb.User,
-		DepartName: fb.UserDept,
-		Type:       service.LogTypeBroadcast,
-	}
-	logParentId, err := dao.ParentAgentLog(logParent)
-	if err != nil {
-		logger.Error(err.Error())
-	}
-	StatusCodes := make([]string, 0)
-
-	for _, uuid := range UUIDs {
-		agent := agentmanager.GetAgent(uuid)
-		if agent == nil {
-			log := dao.AgentLog{
-				LogParentID:     logParentId,
-				IP:              "", // TODO
-				OperationObject: filename,
-				Action:          service.BroadcastFile,
-				StatusCode:      http.StatusBadRequest,
-				Message:         "获取uuid失败",
-			}
-			if dao.AgentLogMessage(log) != nil {
-				logger.Error(err.Error())
-			}
-
-			StatusCodes = append(StatusCodes, strconv.Itoa(http.StatusBadRequest))
-			continue
+	// 受管文件的校验及生效设置已在保存时检查
+	hooks := fb.Hooks
+	if hooks != nil {
+		if err := hooks.Check(policy.LoginSubject(c), fb.Confirm); err != nil {
+			response.Fail(c, nil, err.Error())
+			return
 		}
-
-		_, Err, err := agent.UpdateFile(path, filename, text)
-		if len(Err) != 0 || err != nil {
-
-			log := dao.AgentLog{
-				LogParentID:     logParentId,
-				IP:              agent.IP,
-				OperationObject: filename,
-				Action:          service.BroadcastFile,
-				StatusCode:      http.StatusBadRequest,
-				Message:         Err,
-			}
-			if dao.AgentLogMessage(log) != nil {
-				logger.Error(err.Error())
-			}
-
-			StatusCodes = append(StatusCodes, strconv.Itoa(http.StatusBadRequest))
-			continue
-		} else {
-			log := dao.AgentLog{
-				LogParentID:     logParentId,
-				IP:              agent.IP,
-				OperationObject: filename,
-				Action:          service.BroadcastFile,
-				StatusCode:      http.StatusOK,
-				Message:         "配置文件下发成功",
-			}
-			if dao.AgentLogMessage(log) != nil {
-				logger.Error(err.Error())
-			}
-
-			StatusCodes = append(StatusCodes, strconv.Itoa(http.StatusOK))
+	} else if fb.ID != 0 {
+		f, err := dao.GetFile(fb.ID)
+		if err != nil {
+			response.Fail(c, nil, "受管文件不存在")
+			return
+		}
+		hooks = fileservice.FileHooks(f)
+		fb.Template = fb.Template || f.Template
+	}
+	if fb.Template {
+		if _, err := fileservice.ParseTemplate(text); err != nil {
+			response.Fail(c, nil, err.Error())
+			return
 		}
 	}
-	status := service.BatchActionStatus(StatusCodes)
-	if dao.UpdateParentAgentLog(logParentId, status) != nil {
-		logger.Error(err.Error())
+	l := &executor.ActionLog{
+		UserName:       fb.User,
+		DepartName:     fb.UserDept,
+		Type:           service.LogTypeBroadcast,
+		Action:         service.BroadcastFile,
+		Object:         filename,
+		SuccessMessage: "配置文件下发成功",
 	}
-	if ok := service.ActionStatus(StatusCodes); !ok {
-		response.Fail(c, nil, "配置文件下发失败")
+	results, ok := executor.RunWithLog(UUIDs, l, func(agent *agentmanager.Agent) (interface{}, error) {
+		content := text
+		if fb.Template {
+			rendered, _, err := fileservice.Render(text, agent.UUID)
+			if err != nil {
+				return nil, err
+			}
+			content = rendered
+		}
+		return fileservice.Install(agent, path, filename, content, hooks)
+	})
+	if !ok {
+		response.Fail(c, results, "配置文件下发失败")
 		return
 	}
-	response.Success(c, nil, "配置文件下发完成!")
+	response.Success(c, results, "配置文件下发完成!")
 }
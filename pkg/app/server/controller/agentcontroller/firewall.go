@@ -17,6 +17,7 @@ package agentcontroller
 import (
 	"github.com/gin-gonic/gin"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/agentmanager"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/approval"
 	"openeuler.org/PilotGo/PilotGo/pkg/utils/response"
 )
 
@@ -171,6 +172,9 @@ func FirewalldRestart(c *gin.Context) {
 
 func FirewalldStop(c *gin.Context) {
 	uuid := c.Query("uuid")
+	if requireApproval(c, approval.OpFirewallStop, []string{uuid}, &firewallStopParam{UUID: uuid}) {
+		return
+	}
 
 	agent := agentmanager.GetAgent(uuid)
 	if agent == nil {
@@ -15,11 +15,13 @@
 package agentcontroller
 
 import (
+	"errors"
 	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/agentmanager"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/approval"
 	"openeuler.org/PilotGo/PilotGo/pkg/global"
 	"openeuler.org/PilotGo/PilotGo/pkg/utils/os/baseos"
 	"openeuler.org/PilotGo/PilotGo/pkg/utils/os/common"
@@ -107,6 +109,17 @@ func GetAgentNetworkConnect(c *gin.Context) {
 	response.Success(c, net, "获取到网络连接信息")
 }
 
+// 网卡配置参数
+type networkParam struct {
+	UUID      string `json:"uuid"`
+	BootProto string `json:"BOOTPROTO"`
+	IPAddr    string `json:"IPADDR"`
+	NetMask   string `json:"NETMASK"`
+	GateWay   string `json:"GATEWAY"`
+	DNS1      string `json:"DNS1"`
+	DNS2      string `json:"DNS2"`
+}
+
 func ConfigNetworkConnect(c *gin.Context) {
 	var network common.NetworkConfig
 	c.Bind(&network)
@@ -140,72 +153,66 @@ func ConfigNetworkConnect(c *gin.Context) {
 		response.Fail(c, nil, "ipv4 DNS1 不能为空")
 		return
 	}
+	if ip_assignment != "static" && ip_assignment != "dhcp" {
+		response.Fail(c, nil, "请重新检查ip分配方式")
+		return
+	}
 
-	agent := agentmanager.GetAgent(network.MachineUUID)
-	if agent == nil {
-		response.Fail(c, nil, "获取uuid失败!")
+	p := &networkParam{
+		UUID:      network.MachineUUID,
+		BootProto: ip_assignment,
+		IPAddr:    ipv4_addr,
+		NetMask:   ipv4_netmask,
+		GateWay:   ipv4_gateway,
+		DNS1:      ipv4_dns1,
+		DNS2:      network.DNS2,
+	}
+	if requireApproval(c, approval.OpNetworkConfig, []string{p.UUID}, p) {
+		return
+	}
+	if err := configNetwork(p); err != nil {
+		response.Fail(c, nil, err.Error())
 		return
 	}
+	response.Success(c, nil, "网络配置更新成功")
+}
+
+// 更新网卡配置文件并重启网络
+func configNetwork(p *networkParam) error {
+	agent := agentmanager.GetAgent(p.UUID)
+	if agent == nil {
+		return errors.New("获取uuid失败!")
+	}
 
 	nic_name, Err, err := agent.GetNICName()
 	if len(Err) != 0 || err != nil {
-		response.Fail(c, nil, Err)
-		return
+		return agentError(Err, err)
 	}
 
 	oldnet, Err, err := agent.GetNetWorkConnectInfo()
 	if len(Err) != 0 || err != nil {
-		response.Fail(c, nil, Err)
-		return
+		return agentError(Err, err)
 	}
-	// oldnets1 := oldnet.([]interface{})
-	// var oldnets2 []map[string]interface{}
-	// for _, m := range *oldnet {
-	// 	m1 := m.(map[string]interface{})
-	// 	oldnets2 = append(oldnets2, m1)
-	// }
-	// var oldnets3 []map[string]string
-	// for _, m := range *oldnet {
-	// 	for k, v := range m {
-	// 		m2 := make(map[string]string)
-	// 		m2[k] = v.(string)
-	// 		oldnets3 = append(oldnets3, m2)
-	// 	}
-	// }
 	var oldnets3 = []map[string]string{
 		*oldnet,
 	}
 
-	switch ip_assignment {
+	var text string
+	switch p.BootProto {
 	case "static":
-		text := baseos.NetworkStatic(oldnets3, ipv4_addr, ipv4_netmask, ipv4_gateway, ipv4_dns1, network.DNS2)
-		_, Err, err := agent.UpdateFile(global.NetWorkPath, nic_name, text)
-		if len(Err) != 0 || err != nil {
-			response.Fail(c, nil, Err)
-			return
-		}
-		Err, err = agent.RestartNetWork(nic_name)
-		if len(Err) != 0 || err != nil {
-			response.Fail(c, nil, Err)
-			return
-		}
-		response.Success(c, nil, "网络配置更新成功")
-
+		text = baseos.NetworkStatic(oldnets3, p.IPAddr, p.NetMask, p.GateWay, p.DNS1, p.DNS2)
 	case "dhcp":
-		text := baseos.NetworkDHCP(oldnets3)
-		_, Err, err := agent.UpdateFile(global.NetWorkPath, nic_name, text)
-		if len(Err) != 0 || err != nil {
-			response.Fail(c, nil, Err)
-			return
-		}
-		Err, err = agent.RestartNetWork(nic_name)
-		if len(Err) != 0 || err != nil {
-			response.Fail(c, nil, Err)
-			return
-		}
-		response.Success(c, nil, "网络配置更新成功")
-
+		text = baseos.NetworkDHCP(oldnets3)
 	default:
-		response.Fail(c, nil, "请重新检查ip分配方式")
+		return errors.New("请重新检查ip分配方式")
+	}
+	_, Err, err = agent.UpdateFile(global.NetWorkPath, nic_name, text)
+	if len(Err) != 0 || err != nil {
+		return agentError(Err, err)
+	}
+	Err, err = agent.RestartNetWork(nic_name)
+	if len(Err) != 0 || err != nil {
+		return agentError(Err, err)
 	}
+	return nil
 }
@@ -15,14 +15,12 @@
 package agentcontroller
 
 import (
-	"net/http"
-	"strconv"
-
 	"github.com/gin-gonic/gin"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/agentmanager"
-	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service"
-	"openeuler.org/PilotGo/PilotGo/pkg/logger"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/approval"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/auth"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/executor"
 	"openeuler.org/PilotGo/PilotGo/pkg/utils/response"
 )
 
@@ -31,6 +29,8 @@ type RPMS struct {
 	RPM          string   `json:"rpm"`
 	UserName     string   `json:"userName"`
 	UserDeptName string   `json:"userDept"`
+	// 需要审批时的申请原因
+	Reason string `json:"reason"`
 }
 
 func AllRpmHandler(c *gin.Context) {
@@ -90,150 +90,52 @@ func InstallRpmHandler(c *gin.Context) {
 	var rpm RPMS
 	c.Bind(&rpm)
 
-	logParent := dao.AgentLogParent{
-		UserName:   rpm.UserName,
-		DepartName: rpm.UserDeptName,
-		Type:       service.LogTypeRPM,
-	}
-	logParentId, err := dao.ParentAgentLog(logParent)
-	if err != nil {
-		logger.Error(err.Error())
-	}
-	StatusCodes := make([]string, 0)
-
-	for _, uuid := range rpm.UUIDs {
-		agent := agentmanager.GetAgent(uuid)
-		if agent == nil {
-			log := dao.AgentLog{
-				LogParentID:     logParentId,
-				IP:              "", // TODO
-				OperationObject: rpm.RPM,
-				Action:          service.RPMInstall,
-				StatusCode:      http.StatusBadRequest,
-				Message:         "获取uuid失败",
-			}
-			if dao.AgentLogMessage(log) != nil {
-				logger.Error(err.Error())
-			}
-
-			StatusCodes = append(StatusCodes, strconv.Itoa(http.StatusBadRequest))
-			continue
-		}
-
-		_, Err, err := agent.InstallRpm(rpm.RPM)
-		if err != nil || len(Err) != 0 {
-			log := dao.AgentLog{
-				LogParentID:     logParentId,
-				IP:              agent.IP,
-				OperationObject: rpm.RPM,
-				Action:          service.RPMInstall,
-				StatusCode:      http.StatusBadRequest,
-				Message:         Err,
-			}
-			if dao.AgentLogMessage(log) != nil {
-				logger.Error(err.Error())
-			}
-			StatusCodes = append(StatusCodes, strconv.Itoa(http.StatusBadRequest))
-			continue
-		} else {
-			log := dao.AgentLog{
-				LogParentID:     logParentId,
-				IP:              agent.IP,
-				OperationObject: rpm.RPM,
-				Action:          service.RPMInstall,
-				StatusCode:      http.StatusOK,
-				Message:         "安装成功",
-			}
-			if dao.AgentLogMessage(log) != nil {
-				logger.Error(err.Error())
-			}
-
-			StatusCodes = append(StatusCodes, strconv.Itoa(http.StatusOK))
-		}
-	}
-	status := service.BatchActionStatus(StatusCodes)
-	if dao.UpdateParentAgentLog(logParentId, status) != nil {
-		logger.Error(err.Error())
-	}
-	if ok := service.ActionStatus(StatusCodes); !ok {
-		response.Fail(c, nil, "软件包安装失败")
+	l := &executor.ActionLog{
+		UserName:       rpm.UserName,
+		DepartName:     rpm.UserDeptName,
+		Type:           service.LogTypeRPM,
+		Action:         service.RPMInstall,
+		Object:         rpm.RPM,
+		SuccessMessage: "安装成功",
+	}
+	results, ok := executor.RunWithLog(rpm.UUIDs, l, func(agent *agentmanager.Agent) (interface{}, error) {
+		return executor.Output(agent.InstallRpm(rpm.RPM))
+	})
+	if !ok {
+		response.Fail(c, results, "软件包安装失败")
 		return
 	}
-	response.Success(c, nil, "软件包安装完成!")
+	response.Success(c, results, "软件包安装完成!")
 }
 func RemoveRpmHandler(c *gin.Context) {
 	var rpm RPMS
 	c.Bind(&rpm)
-
-	logParent := dao.AgentLogParent{
-		UserName:   rpm.UserName,
-		DepartName: rpm.UserDeptName,
-		Type:       service.LogTypeRPM,
+	if u, ok := auth.LoginUser(c); ok {
+		rpm.UserName = u.Email
+		rpm.UserDeptName = u.DepartName
 	}
-	logParentId, err := dao.ParentAgentLog(logParent)
-	if err != nil {
-		logger.Error(err.Error())
-	}
-	StatusCodes := make([]string, 0)
-	for _, uuid := range rpm.UUIDs {
-		agent := agentmanager.GetAgent(uuid)
-		if agent == nil {
-			log := dao.AgentLog{
-				LogParentID:     logParentId,
-				IP:              "", // TODO
-				OperationObject: rpm.RPM,
-				Action:          service.RPMRemove,
-				StatusCode:      http.StatusBadRequest,
-				Message:         "获取uuid失败",
-			}
-			if dao.AgentLogMessage(log) != nil {
-				logger.Error(err.Error())
-			}
-
-			StatusCodes = append(StatusCodes, strconv.Itoa(http.StatusBadRequest))
-			continue
-		}
-
-		_, Err, err := agent.RemoveRpm(rpm.RPM)
-		if len(Err) != 0 || err != nil {
-			log := dao.AgentLog{
-				LogParentID:     logParentId,
-				IP:              agent.IP,
-				OperationObject: rpm.RPM,
-				Action:          service.RPMRemove,
-				StatusCode:      http.StatusBadRequest,
-				Message:         Err,
-			}
-			if dao.AgentLogMessage(log) != nil {
-				logger.Error(err.Error())
-			}
-
-			StatusCodes = append(StatusCodes, strconv.Itoa(http.StatusBadRequest))
-			continue
-		} else {
-			log := dao.AgentLog{
-				LogParentID:     logParentId,
-				IP:              agent.IP,
-				OperationObject: rpm.RPM,
-				Action:          service.RPMRemove,
-				StatusCode:      http.StatusOK,
-				Message:         "卸载成功",
-			}
-			if dao.AgentLogMessage(log) != nil {
-				logger.Error(err.Error())
-			}
 
-			StatusCodes = append(StatusCodes, strconv.Itoa(http.StatusOK))
-		}
-	}
-
-	status := service.BatchActionStatus(StatusCodes)
-	if dao.UpdateParentAgentLog(logParentId, status) != nil {
-		logger.Error(err.Error())
+	if requireApprovalBy(c, approval.OpRpmRemove, rpm.UUIDs, &rpm, rpm.Reason) {
+		return
 	}
-	if ok := service.ActionStatus(StatusCodes); !ok {
-		response.Fail(c, nil, "软件包卸载失败")
+	results, ok := removeRpm(&rpm)
+	if !ok {
+		response.Fail(c, results, "软件包卸载失败")
 		return
 	}
-	response.Success(c, nil, "软件包卸载完成!")
+	response.Success(c, results, "软件包卸载完成!")
+}
+
+func removeRpm(rpm *RPMS) ([]*executor.Result, bool) {
+	l := &executor.ActionLog{
+		UserName:       rpm.UserName,
+		DepartName:     rpm.UserDeptName,
+		Type:           service.LogTypeRPM,
+		Action:         service.RPMRemove,
+		Object:         rpm.RPM,
+		SuccessMessage: "卸载成功",
+	}
+	return executor.RunWithLog(rpm.UUIDs, l, func(agent *agentmanager.Agent) (interface{}, error) {
+		return executor.Output(agent.RemoveRpm(rpm.RPM))
+	})
 }
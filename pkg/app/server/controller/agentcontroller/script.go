@@ -15,12 +15,16 @@
 package agentcontroller
 
 import (
+	"encoding/base64"
 	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/agentmanager"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/policy"
 	"openeuler.org/PilotGo/PilotGo/pkg/logger"
+	"openeuler.org/PilotGo/PilotGo/pkg/utils/response"
 )
 
 func RunScript(c *gin.Context) {
@@ -30,6 +34,16 @@ func RunScript(c *gin.Context) {
 	cmd := c.Query("cmd")
 	fmt.Println(uuid, cmd)
 
+	content := cmd
+	if decoded, err := base64.StdEncoding.DecodeString(cmd); err == nil {
+		content = string(decoded)
+	}
+	subject := policy.LoginSubject(c)
+	if d, err := policy.Check(subject, service.RunCommand, content, c.Query("confirm")); err != nil {
+		response.Fail(c, gin.H{"decision": d}, err.Error())
+		return
+	}
+
 	agent := agentmanager.GetAgent(uuid)
 	if agent != nil {
 		data, err := agent.RunCommand(cmd)
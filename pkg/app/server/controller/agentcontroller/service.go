@@ -21,6 +21,9 @@ import (
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/agentmanager"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/approval"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/auth"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/executor"
 	"openeuler.org/PilotGo/PilotGo/pkg/global"
 	"openeuler.org/PilotGo/PilotGo/pkg/logger"
 	"openeuler.org/PilotGo/PilotGo/pkg/utils/response"
@@ -31,6 +34,8 @@ type AgentService struct {
 	Service      string `json:"service"`
 	UserName     string `json:"userName"`
 	UserDeptName string `json:"userDept"`
+	// 需要审批时的申请原因
+	Reason string `json:"reason"`
 }
 
 func ServiceListHandler(c *gin.Context) {
@@ -142,6 +147,19 @@ func ServiceStartHandler(c *gin.Context) {
 func ServiceStopHandler(c *gin.Context) {
 	var agentservice AgentService
 	c.Bind(&agentservice)
+	if u, ok := auth.LoginUser(c); ok {
+		agentservice.UserName = u.Email
+		agentservice.UserDeptName = u.DepartName
+	}
+	if approval.ServiceStopRequired(agentservice.Service) {
+		submitApproval(c, approval.OpServiceStop, []string{agentservice.UUID}, &ServiceStopParam{
+			UUIDs:    []string{agentservice.UUID},
+			Service:  agentservice.Service,
+			UserName: agentservice.UserName,
+			UserDept: agentservice.UserDeptName,
+		}, agentservice.Reason)
+		return
+	}
 
 	logParent := dao.AgentLogParent{
 		UserName:   agentservice.UserName,
@@ -285,3 +303,18 @@ func ServiceRestartHandler(c *gin.Context) {
 
 	response.Success(c, gin.H{"service_restart": service_restart}, "Success")
 }
+
+// 批量关闭服务并记录日志
+func stopService(p *ServiceStopParam) ([]*executor.Result, bool) {
+	l := &executor.ActionLog{
+		UserName:       p.UserName,
+		DepartName:     p.UserDept,
+		Type:           service.LogTypeService,
+		Action:         service.ServiceStop,
+		Object:         p.Service,
+		SuccessMessage: "关闭服务成功",
+	}
+	return executor.RunWithLog(p.UUIDs, l, func(agent *agentmanager.Agent) (interface{}, error) {
+		return executor.Output(agent.ServiceStop(p.Service))
+	})
+}
@@ -17,6 +17,7 @@ package agentcontroller
 import (
 	"github.com/gin-gonic/gin"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/agentmanager"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/approval"
 	"openeuler.org/PilotGo/PilotGo/pkg/utils/response"
 )
 
@@ -73,6 +74,10 @@ func AddLinuxUserHandler(c *gin.Context) {
 func DelUserHandler(c *gin.Context) {
 	uuid := c.Query("uuid")
 	username := c.Query("username")
+	p := &userDelParam{UUID: uuid, UserName: username}
+	if requireApproval(c, approval.OpUserDel, []string{uuid}, p) {
+		return
+	}
 
 	agent := agentmanager.GetAgent(uuid)
 	if agent == nil {
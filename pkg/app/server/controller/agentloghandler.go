@@ -15,7 +15,13 @@
 package controller
 
 import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
@@ -49,6 +55,196 @@ func ModuleLogHandler(c *gin.Context) {
 	response.Success(c, loglist, "模块审计日志查询成功!")
 }
 
+// 按条件分页查询审计日志，start、end为unix时间戳
+func AuditLogQueryHandler(c *gin.Context) {
+	query := &common.PaginationQ{}
+	if err := c.ShouldBindQuery(query); err != nil {
+		response.Fail(c, gin.H{"status": false}, err.Error())
+		return
+	}
+	f, err := auditFilter(c)
+	if err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+
+	list, tx := auditlog.Query(f)
+	total, err := common.CrudAll(query, tx, list)
+	if err != nil {
+		response.Fail(c, gin.H{"status": false}, err.Error())
+		return
+	}
+	common.JsonPagination(c, list, total, query)
+}
+
+// 按条件导出审计日志为csv文件
+func AuditLogExportHandler(c *gin.Context) {
+	f, err := auditFilter(c)
+	if err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	list, tx := auditlog.Query(f)
+	if tx.Error != nil {
+		response.Fail(c, nil, tx.Error.Error())
+		return
+	}
+
+	name := fmt.Sprintf("auditlog-%s.csv", time.Now().Format("20060102150405"))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", "attachment; filename="+name)
+	// 写入BOM以便excel正确识别中文
+	c.Writer.WriteString("\xEF\xBB\xBF")
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"时间", "模块", "操作者", "操作", "状态", "结果码", "信息", "目标机器", "参数", "来源IP", "耗时(ms)"})
+	for _, l := range *list {
+		w.Write([]string{
+			l.CreatedAt.Format("2006-01-02 15:04:05"),
+			csvText(l.Module),
+			csvText(l.Operator),
+			csvText(l.Action),
+			csvText(l.Status),
+			strconv.Itoa(l.Code),
+			csvText(l.Message),
+			csvText(l.Machines),
+			csvText(l.Params),
+			csvText(l.ClientIP),
+			strconv.FormatInt(l.Duration, 10),
+		})
+	}
+	w.Flush()
+}
+
+// 以=、+、-、@等开头的内容会被表格软件当作公式执行，导出时在前面加单引号
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// 校验审计日志哈希链及签名检查点，from、to为日志序号
+func AuditLogVerifyHandler(c *gin.Context) {
+	from, to, err := seqRange(c)
+	if err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	report, err := auditlog.Verify(from, to)
+	if err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, gin.H{"report": report}, "校验完成")
+}
+
+// 分页查询签名检查点
+func AuditCheckpointListHandler(c *gin.Context) {
+	query := &common.PaginationQ{}
+	if err := c.ShouldBindQuery(query); err != nil {
+		response.Fail(c, gin.H{"status": false}, err.Error())
+		return
+	}
+
+	list, tx := auditlog.CheckpointList()
+	total, err := common.CrudAll(query, tx, list)
+	if err != nil {
+		response.Fail(c, gin.H{"status": false}, err.Error())
+		return
+	}
+	common.JsonPagination(c, list, total, query)
+}
+
+// 立即生成签名检查点
+func AuditCheckpointHandler(c *gin.Context) {
+	checkpoint, err := auditlog.CreateCheckpoint()
+	if err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	if checkpoint == nil {
+		response.Success(c, nil, "没有新的审计日志，未生成检查点")
+		return
+	}
+	response.Success(c, gin.H{"checkpoint": checkpoint}, "检查点已生成")
+}
+
+// 验证检查点签名的当前公钥及历次使用过的公钥
+func AuditPublicKeyHandler(c *gin.Context) {
+	key, err := auditlog.PublicKey()
+	if err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	keys, err := auditlog.PublicKeys()
+	if err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, gin.H{"public_key": key, "public_keys": keys}, "Success")
+}
+
+// 导出哈希链日志、检查点及公钥，供离线校验
+func AuditChainExportHandler(c *gin.Context) {
+	from, to, err := seqRange(c)
+	if err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	export, err := auditlog.ExportChain(from, to)
+	if err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	name := fmt.Sprintf("auditchain-%s.json", time.Now().Format("20060102150405"))
+	c.Header("Content-Disposition", "attachment; filename="+name)
+	c.JSON(http.StatusOK, export)
+}
+
+func seqRange(c *gin.Context) (uint64, uint64, error) {
+	var from, to uint64
+	var err error
+	if s := c.Query("from"); s != "" {
+		if from, err = strconv.ParseUint(s, 10, 64); err != nil {
+			return 0, 0, errors.New("起始序号格式有误")
+		}
+	}
+	if s := c.Query("to"); s != "" {
+		if to, err = strconv.ParseUint(s, 10, 64); err != nil {
+			return 0, 0, errors.New("结束序号格式有误")
+		}
+	}
+	return from, to, nil
+}
+
+func auditFilter(c *gin.Context) (*auditlog.Filter, error) {
+	f := &auditlog.Filter{
+		Module:   c.Query("module"),
+		Operator: c.Query("operator"),
+		Status:   c.Query("status"),
+		Action:   c.Query("action"),
+		Path:     c.Query("path"),
+		Machine:  c.Query("machine"),
+	}
+	if s := c.Query("start"); s != "" {
+		start, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return nil, errors.New("起始时间格式有误")
+		}
+		t := time.Unix(start, 0)
+		f.Start = &t
+	}
+	if e := c.Query("end"); e != "" {
+		end, err := strconv.ParseInt(e, 10, 64)
+		if err != nil {
+			return nil, errors.New("结束时间格式有误")
+		}
+		t := time.Unix(end, 0)
+		f.End = &t
+	}
+	return f, nil
+}
+
 // 查询所有父日志
 func LogAllHandler(c *gin.Context) {
 	query := &common.PaginationQ{}
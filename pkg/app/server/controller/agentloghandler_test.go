@@ -0,0 +1,25 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCsvText(t *testing.T) {
+	cases := map[string]string{
+		"":                  "",
+		"admin@example.com": "admin@example.com",
+		"POST /api/v1/job":  "POST /api/v1/job",
+		"=HYPERLINK(\"x\")": "'=HYPERLINK(\"x\")",
+		"+1":                "'+1",
+		"-2+3":              "'-2+3",
+		"@SUM(A1)":          "'@SUM(A1)",
+		"\t=1":              "'\t=1",
+		"\r=1":              "'\r=1",
+		"a=1":               "a=1",
+	}
+	for in, out := range cases {
+		assert.Equal(t, out, csvText(in), in)
+	}
+}
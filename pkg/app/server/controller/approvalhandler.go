@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/approval"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/auth"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/common"
+	"openeuler.org/PilotGo/PilotGo/pkg/utils/response"
+)
+
+type reviewParam struct {
+	Comment string `json:"comment"`
+}
+
+// 查询审批请求，支持按状态、操作类型及申请人过滤
+func ApprovalListHandler(c *gin.Context) {
+	query := &common.PaginationQ{}
+	if err := c.ShouldBindQuery(query); err != nil {
+		response.Fail(c, gin.H{"status": false}, err.Error())
+		return
+	}
+
+	f := &approval.Filter{
+		State:     c.Query("state"),
+		Operation: c.Query("operation"),
+		Requester: c.Query("requester"),
+	}
+	list, tx := approval.Query(f)
+	total, err := common.CrudAll(query, tx, list)
+	if err != nil {
+		response.Fail(c, gin.H{"status": false}, err.Error())
+		return
+	}
+	common.JsonPagination(c, list, total, query)
+}
+
+func ApprovalInfoHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.Fail(c, nil, "审批单ID输入格式有误")
+		return
+	}
+	a, err := approval.Get(uint(id))
+	if err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, gin.H{"approval": a}, "Success")
+}
+
+// 审批通过，操作随后异步执行，审批人为当前登录用户
+func ApproveHandler(c *gin.Context) {
+	id, p, ok := bindReview(c)
+	if !ok {
+		return
+	}
+	u, _ := auth.LoginUser(c)
+	a, err := approval.Approve(id, u, p.Comment)
+	if err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, gin.H{"approval": a}, "审批通过，操作已开始执行")
+}
+
+func RejectHandler(c *gin.Context) {
+	id, p, ok := bindReview(c)
+	if !ok {
+		return
+	}
+	u, _ := auth.LoginUser(c)
+	a, err := approval.Reject(id, u, p.Comment)
+	if err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, gin.H{"approval": a}, "已拒绝")
+}
+
+func bindReview(c *gin.Context) (uint, *reviewParam, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.Fail(c, nil, "审批单ID输入格式有误")
+		return 0, nil, false
+	}
+	p := &reviewParam{}
+	if err := c.ShouldBindJSON(p); err != nil {
+		response.Fail(c, nil, "parameter error")
+		return 0, nil, false
+	}
+	return uint(id), p, true
+}
@@ -0,0 +1,85 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/common"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/policy"
+	"openeuler.org/PilotGo/PilotGo/pkg/utils/response"
+)
+
+// 分页查询命令策略
+func CommandPolicyListHandler(c *gin.Context) {
+	query := &common.PaginationQ{}
+	if err := c.ShouldBindQuery(query); err != nil {
+		response.Fail(c, gin.H{"status": false}, err.Error())
+		return
+	}
+
+	list, tx := policy.List()
+	total, err := common.CrudAll(query, tx, list)
+	if err != nil {
+		response.Fail(c, gin.H{"status": false}, err.Error())
+		return
+	}
+	common.JsonPagination(c, list, total, query)
+}
+
+func AddCommandPolicyHandler(c *gin.Context) {
+	p := &dao.CommandPolicy{}
+	if err := c.ShouldBindJSON(p); err != nil {
+		response.Fail(c, nil, "parameter error")
+		return
+	}
+	p.ID = 0
+	if err := policy.Add(p); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, gin.H{"policy": p}, "策略添加成功")
+}
+
+func UpdateCommandPolicyHandler(c *gin.Context) {
+	p := &dao.CommandPolicy{}
+	if err := c.ShouldBindJSON(p); err != nil {
+		response.Fail(c, nil, "parameter error")
+		return
+	}
+	if err := policy.Update(p); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, gin.H{"policy": p}, "策略修改成功")
+}
+
+func DeleteCommandPolicyHandler(c *gin.Context) {
+	p := struct {
+		IDs []uint `json:"ids"`
+	}{}
+	if err := c.ShouldBindJSON(&p); err != nil || len(p.IDs) == 0 {
+		response.Fail(c, nil, "parameter error")
+		return
+	}
+	if err := policy.Delete(p.IDs); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, nil, "策略删除成功")
+}
+
+// 检查命令在当前策略下的处理结果，不执行命令也不记录审计日志
+func EvaluateCommandPolicyHandler(c *gin.Context) {
+	p := struct {
+		Command string `json:"command"`
+	}{}
+	if err := c.ShouldBindJSON(&p); err != nil {
+		response.Fail(c, nil, "parameter error")
+		return
+	}
+	d, err := policy.Evaluate(policy.LoginSubject(c), p.Command, "")
+	if err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, gin.H{"decision": d}, "")
+}
@@ -19,6 +19,8 @@ import (
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/auth"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/common"
 	fileservice "openeuler.org/PilotGo/PilotGo/pkg/app/server/service/file"
 	"openeuler.org/PilotGo/PilotGo/pkg/global"
@@ -31,6 +33,10 @@ func SaveFileToDatabaseHandler(c *gin.Context) {
 		response.Fail(c, nil, "parameter error")
 		return
 	}
+	if u, ok := auth.LoginUser(c); ok {
+		file.UserUpdate = u.Email
+		file.UserDept = u.DepartName
+	}
 	err := fileservice.SaveToDatabase(&file)
 	if err != nil {
 		response.Fail(c, nil, err.Error())
@@ -55,11 +61,15 @@ func DeleteFileHandler(c *gin.Context) {
 }
 
 func UpdateFileHandler(c *gin.Context) {
-	var file fileservice.Files
+	var file fileservice.FileUpdate
 	if err := c.Bind(&file); err != nil {
 		response.Fail(c, nil, "parameter error")
 		return
 	}
+	if u, ok := auth.LoginUser(c); ok {
+		file.UserUpdate = u.Email
+		file.UserDept = u.DepartName
+	}
 	err := fileservice.Update(&file)
 	if err != nil {
 		response.Fail(c, nil, err.Error())
@@ -162,3 +172,167 @@ func LastFileRollBackHandler(c *gin.Context) {
 	}
 	response.Success(c, nil, "已回退到历史版本")
 }
+
+// 预览配置模板在指定机器上的渲染结果，id不为0时使用已保存的文件内容
+func FilePreviewHandler(c *gin.Context) {
+	p := struct {
+		UUID string `json:"uuid"`
+		ID   int    `json:"id"`
+		Text string `json:"file"`
+	}{}
+	if err := c.ShouldBindJSON(&p); err != nil || p.UUID == "" {
+		response.Fail(c, nil, "parameter error")
+		return
+	}
+	text := p.Text
+	if p.ID != 0 {
+		t, err := dao.FileText(p.ID)
+		if err != nil {
+			response.Fail(c, nil, err.Error())
+			return
+		}
+		text = t
+	}
+
+	rendered, data, err := fileservice.Render(text, p.UUID)
+	if err != nil {
+		response.Fail(c, gin.H{"data": data}, err.Error())
+		return
+	}
+	response.Success(c, gin.H{"file": rendered, "data": data}, "Success")
+}
+
+// 查询批次或部门的模板变量，scope为空时返回所有变量
+func TemplateVarListHandler(c *gin.Context) {
+	scopeID, _ := strconv.Atoi(c.Query("scope_id"))
+	list, err := fileservice.TemplateVarList(c.Query("scope"), scopeID)
+	if err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, gin.H{"vars": list}, "Success")
+}
+
+func SaveTemplateVarHandler(c *gin.Context) {
+	v := &fileservice.TemplateVar{}
+	if err := c.ShouldBindJSON(v); err != nil {
+		response.Fail(c, nil, "parameter error")
+		return
+	}
+	if err := fileservice.SaveTemplateVar(v); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, gin.H{"var": v}, "变量保存成功")
+}
+
+func DeleteTemplateVarHandler(c *gin.Context) {
+	p := struct {
+		IDs []uint `json:"ids"`
+	}{}
+	if err := c.ShouldBindJSON(&p); err != nil || len(p.IDs) == 0 {
+		response.Fail(c, nil, "parameter error")
+		return
+	}
+	if err := fileservice.DeleteTemplateVars(p.IDs); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, nil, "变量删除成功")
+}
+
+type driftParam struct {
+	ID       int      `json:"id"`
+	UUIDs    []string `json:"uuids"`
+	UUID     string   `json:"uuid"`
+	User     string   `json:"user"`
+	UserDept string   `json:"userDept"`
+}
+
+// 查询受管文件最近一次的漂移检查结果
+func FileDriftListHandler(c *gin.Context) {
+	query := &common.PaginationQ{}
+	if err := c.ShouldBindQuery(query); err != nil {
+		response.Fail(c, gin.H{"status": false}, err.Error())
+		return
+	}
+
+	id, _ := strconv.Atoi(c.Query("id"))
+	f := &fileservice.DriftFilter{
+		FileID:      id,
+		MachineUUID: c.Query("uuid"),
+		State:       c.Query("state"),
+	}
+	list, tx := fileservice.QueryDrifts(f)
+	total, err := common.CrudAll(query, tx, list)
+	if err != nil {
+		response.Fail(c, gin.H{"status": false}, err.Error())
+		return
+	}
+	common.JsonPagination(c, list, total, query)
+}
+
+// 立即检查文件漂移，id为0时检查所有受管文件并只返回存在漂移的结果
+func FileDriftScanHandler(c *gin.Context) {
+	p := &driftParam{}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(p); err != nil {
+			response.Fail(c, nil, "parameter error")
+			return
+		}
+	}
+	if p.ID == 0 {
+		response.Success(c, gin.H{"drifts": fileservice.ScanAll()}, "检查完成")
+		return
+	}
+	list, err := fileservice.ScanFile(p.ID)
+	if err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, gin.H{"drifts": list}, "检查完成")
+}
+
+// 将受管文件重新下发到存在漂移的机器
+func FileDriftReapplyHandler(c *gin.Context) {
+	p := &driftParam{}
+	if err := c.ShouldBindJSON(p); err != nil {
+		response.Fail(c, nil, "parameter error")
+		return
+	}
+	results, err := fileservice.Reapply(p.ID, p.UUIDs, p.User, p.UserDept)
+	if err != nil {
+		response.Fail(c, results, err.Error())
+		return
+	}
+	response.Success(c, results, "配置文件重新下发完成")
+}
+
+// 以机器上被修改的内容作为文件的新版本
+func FileDriftAdoptHandler(c *gin.Context) {
+	p := &driftParam{}
+	if err := c.ShouldBindJSON(p); err != nil || p.UUID == "" {
+		response.Fail(c, nil, "parameter error")
+		return
+	}
+	if err := fileservice.Adopt(p.ID, p.UUID, p.User, p.UserDept); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, nil, "已采纳为新版本")
+}
+
+// 比较文件的两个版本，from、to为current、live或历史版本id，mode为unified或side
+func FileDiffHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Query("id"))
+	if err != nil {
+		response.Fail(c, nil, "文件ID输入格式有误")
+		return
+	}
+	result, err := fileservice.Diff(id, c.Query("from"), c.Query("to"), c.Query("uuid"), c.Query("mode"))
+	if err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, gin.H{"diff": result}, "Success")
+}
@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"github.com/gin-gonic/gin"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/common"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/filewatch"
+	"openeuler.org/PilotGo/PilotGo/pkg/utils/response"
+)
+
+// 分页查询文件监控规则
+func FileWatchListHandler(c *gin.Context) {
+	query := &common.PaginationQ{}
+	if err := c.ShouldBindQuery(query); err != nil {
+		response.Fail(c, gin.H{"status": false}, err.Error())
+		return
+	}
+
+	list, tx := filewatch.List()
+	total, err := common.CrudAll(query, tx, list)
+	if err != nil {
+		response.Fail(c, gin.H{"status": false}, err.Error())
+		return
+	}
+	common.JsonPagination(c, list, total, query)
+}
+
+func AddFileWatchHandler(c *gin.Context) {
+	w := &filewatch.FileWatch{}
+	if err := c.ShouldBindJSON(w); err != nil {
+		response.Fail(c, nil, "parameter error")
+		return
+	}
+	w.ID = 0
+	if err := filewatch.Add(w); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, gin.H{"watch": w}, "监控规则添加成功")
+}
+
+func UpdateFileWatchHandler(c *gin.Context) {
+	w := &filewatch.FileWatch{}
+	if err := c.ShouldBindJSON(w); err != nil {
+		response.Fail(c, nil, "parameter error")
+		return
+	}
+	if err := filewatch.Update(w); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, gin.H{"watch": w}, "监控规则修改成功")
+}
+
+func DeleteFileWatchHandler(c *gin.Context) {
+	p := &scheduleIDs{}
+	if err := c.ShouldBindJSON(p); err != nil || len(p.IDs) == 0 {
+		response.Fail(c, nil, "parameter error")
+		return
+	}
+	if err := filewatch.Delete(p.IDs); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, nil, "监控规则删除成功")
+}
+
+// 分页查询agent上报的文件变化
+func FileWatchEventsHandler(c *gin.Context) {
+	query := &common.PaginationQ{}
+	if err := c.ShouldBindQuery(query); err != nil {
+		response.Fail(c, gin.H{"status": false}, err.Error())
+		return
+	}
+
+	f := &filewatch.EventFilter{
+		MachineUUID: c.Query("uuid"),
+		Path:        c.Query("path"),
+		Op:          c.Query("op"),
+	}
+	list, tx := filewatch.QueryEvents(f)
+	total, err := common.CrudAll(query, tx, list)
+	if err != nil {
+		response.Fail(c, gin.H{"status": false}, err.Error())
+		return
+	}
+	common.JsonPagination(c, list, total, query)
+}
@@ -0,0 +1,166 @@
+package controller
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/common"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/integrity"
+	"openeuler.org/PilotGo/PilotGo/pkg/utils/response"
+)
+
+type integrityParam struct {
+	ID    uint     `json:"id"`
+	UUID  string   `json:"uuid"`
+	UUIDs []string `json:"uuids"`
+	// 接受的变化记录id，为空时接受机器的所有变化
+	ChangeIDs []uint `json:"change_ids"`
+}
+
+// 分页查询完整性检查配置
+func IntegrityProfileListHandler(c *gin.Context) {
+	query := &common.PaginationQ{}
+	if err := c.ShouldBindQuery(query); err != nil {
+		response.Fail(c, gin.H{"status": false}, err.Error())
+		return
+	}
+
+	list, tx := integrity.ProfileList()
+	total, err := common.CrudAll(query, tx, list)
+	if err != nil {
+		response.Fail(c, gin.H{"status": false}, err.Error())
+		return
+	}
+	common.JsonPagination(c, list, total, query)
+}
+
+func AddIntegrityProfileHandler(c *gin.Context) {
+	p := &integrity.Profile{}
+	if err := c.ShouldBindJSON(p); err != nil {
+		response.Fail(c, nil, "parameter error")
+		return
+	}
+	p.ID = 0
+	if err := integrity.AddProfile(p); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, gin.H{"profile": p}, "检查配置添加成功")
+}
+
+func UpdateIntegrityProfileHandler(c *gin.Context) {
+	p := &integrity.Profile{}
+	if err := c.ShouldBindJSON(p); err != nil {
+		response.Fail(c, nil, "parameter error")
+		return
+	}
+	if err := integrity.UpdateProfile(p); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, gin.H{"profile": p}, "检查配置修改成功")
+}
+
+func DeleteIntegrityProfileHandler(c *gin.Context) {
+	p := &scheduleIDs{}
+	if err := c.ShouldBindJSON(p); err != nil || len(p.IDs) == 0 {
+		response.Fail(c, nil, "parameter error")
+		return
+	}
+	if err := integrity.DeleteProfiles(p.IDs); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, nil, "检查配置删除成功")
+}
+
+// 记录机器当前的文件状态作为基线
+func IntegrityBaselineHandler(c *gin.Context) {
+	p := &integrityParam{}
+	if err := c.ShouldBindJSON(p); err != nil {
+		response.Fail(c, nil, "parameter error")
+		return
+	}
+	scans, err := integrity.Baseline(p.ID, p.UUIDs)
+	if err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, gin.H{"scans": scans}, "基线记录完成")
+}
+
+// 立即与基线比较
+func IntegrityScanHandler(c *gin.Context) {
+	p := &integrityParam{}
+	if err := c.ShouldBindJSON(p); err != nil {
+		response.Fail(c, nil, "parameter error")
+		return
+	}
+	scans, err := integrity.ScanProfile(p.ID, p.UUIDs)
+	if err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, gin.H{"scans": scans}, "检查完成")
+}
+
+// 接受变化并将基线更新为当前状态
+func IntegrityAcceptHandler(c *gin.Context) {
+	p := &integrityParam{}
+	if err := c.ShouldBindJSON(p); err != nil || p.ID == 0 || p.UUID == "" {
+		response.Fail(c, nil, "parameter error")
+		return
+	}
+	count, err := integrity.Accept(p.ID, p.UUID, p.ChangeIDs)
+	if err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, gin.H{"accepted": count}, "基线已更新")
+}
+
+// 分页查询各机器最近一次的检查结果
+func IntegrityScansHandler(c *gin.Context) {
+	query := &common.PaginationQ{}
+	if err := c.ShouldBindQuery(query); err != nil {
+		response.Fail(c, gin.H{"status": false}, err.Error())
+		return
+	}
+
+	id, _ := strconv.Atoi(c.Query("id"))
+	f := &integrity.ScanFilter{
+		ProfileID:   uint(id),
+		MachineUUID: c.Query("uuid"),
+		State:       c.Query("state"),
+	}
+	list, tx := integrity.QueryScans(f)
+	total, err := common.CrudAll(query, tx, list)
+	if err != nil {
+		response.Fail(c, gin.H{"status": false}, err.Error())
+		return
+	}
+	common.JsonPagination(c, list, total, query)
+}
+
+// 分页查询相对基线的文件变化
+func IntegrityChangesHandler(c *gin.Context) {
+	query := &common.PaginationQ{}
+	if err := c.ShouldBindQuery(query); err != nil {
+		response.Fail(c, gin.H{"status": false}, err.Error())
+		return
+	}
+
+	id, _ := strconv.Atoi(c.Query("id"))
+	f := &integrity.ChangeFilter{
+		ProfileID:   uint(id),
+		MachineUUID: c.Query("uuid"),
+		Change:      c.Query("change"),
+	}
+	list, tx := integrity.QueryChanges(f)
+	total, err := common.CrudAll(query, tx, list)
+	if err != nil {
+		response.Fail(c, gin.H{"status": false}, err.Error())
+		return
+	}
+	common.JsonPagination(c, list, total, query)
+}
@@ -0,0 +1,195 @@
+package controller
+
+import (
+	"io"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/controller/agentcontroller"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/approval"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/auditlog"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/auth"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/job"
+	"openeuler.org/PilotGo/PilotGo/pkg/logger"
+	"openeuler.org/PilotGo/PilotGo/pkg/utils/response"
+)
+
+// 以当前登录用户的身份提交异步任务，需要审批的操作转为提交审批请求
+func SubmitJobHandler(c *gin.Context) {
+	p := &job.Param{}
+	if err := c.ShouldBindJSON(p); err != nil {
+		response.Fail(c, nil, "parameter error")
+		return
+	}
+	u, ok := auth.LoginUser(c)
+	if !ok {
+		response.Fail(c, nil, "未登录")
+		return
+	}
+	p.UserName = u.Email
+	p.UserDept = u.DepartName
+	uuids, err := p.Machines()
+	if err == nil {
+		auditlog.AddMachines(c, uuids...)
+	}
+
+	if op := p.ApprovalOperation(); op != "" {
+		if err != nil {
+			response.Fail(c, nil, err.Error())
+			return
+		}
+		agentcontroller.SubmitApproval(c, &approval.Request{
+			Operation: op,
+			Targets:   uuids,
+			Params: &agentcontroller.RPMS{
+				UUIDs:        uuids,
+				RPM:          p.Package,
+				UserName:     p.UserName,
+				UserDeptName: p.UserDept,
+			},
+			Reason:      c.Query("reason"),
+			Requester:   u.Email,
+			RequesterID: u.ID,
+		})
+		return
+	}
+
+	id, err := job.Submit(p)
+	if err != nil {
+		logger.Error("failed to submit job: %s", err.Error())
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, gin.H{"job_id": id}, "任务已提交")
+}
+
+// 查询任务进度及各机器的执行结果
+func JobInfoHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.Fail(c, nil, "任务ID输入格式有误")
+		return
+	}
+
+	parent, logs, err := job.Get(id)
+	if err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, gin.H{"job": parent, "hosts": logs}, "Success")
+}
+
+// 取消任务，uuids为空时取消整个任务，执行中的命令不会被终止
+func CancelJobHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.Fail(c, nil, "任务ID输入格式有误")
+		return
+	}
+	if !checkJobOwner(c, id) {
+		return
+	}
+	p := struct {
+		UUIDs []string `json:"uuids"`
+	}{}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&p); err != nil {
+			response.Fail(c, nil, "parameter error")
+			return
+		}
+	}
+
+	if err := job.Cancel(id, p.UUIDs); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, nil, "任务取消成功，执行中的机器已放弃等待结果")
+}
+
+// 以server-sent events方式订阅任务进度，任务结束时推送done事件
+func JobEventsHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.Fail(c, nil, "任务ID输入格式有误")
+		return
+	}
+
+	ch, unsubscribe, err := job.Subscribe(id)
+	if err != nil {
+		// 任务已结束，直接返回最终结果
+		parent, logs, err := job.Get(id)
+		if err != nil {
+			response.Fail(c, nil, err.Error())
+			return
+		}
+		c.SSEvent("done", gin.H{"job": parent, "hosts": logs})
+		return
+	}
+	defer unsubscribe()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case p, ok := <-ch:
+			if !ok {
+				parent, _, err := job.Get(id)
+				if err == nil {
+					c.SSEvent("done", gin.H{"job": parent})
+				}
+				return false
+			}
+			c.SSEvent("progress", p)
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
+
+// 继续执行暂停的分批任务
+func ResumeJobHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.Fail(c, nil, "任务ID输入格式有误")
+		return
+	}
+	if !checkJobOwner(c, id) {
+		return
+	}
+
+	if err := job.Resume(id); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, nil, "任务继续执行")
+}
+
+// 终止任务，未执行的机器全部取消
+func AbortJobHandler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		response.Fail(c, nil, "任务ID输入格式有误")
+		return
+	}
+	if !checkJobOwner(c, id) {
+		return
+	}
+
+	if err := job.Abort(id); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, nil, "任务已终止")
+}
+
+func checkJobOwner(c *gin.Context, id int) bool {
+	u, ok := auth.LoginUser(c)
+	if !ok {
+		response.Fail(c, nil, "未登录")
+		return false
+	}
+	if err := job.CheckOwner(id, u); err != nil {
+		response.Fail(c, nil, err.Error())
+		return false
+	}
+	return true
+}
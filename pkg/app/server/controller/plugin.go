@@ -2,10 +2,9 @@ package controller
 
 import (
 	"net/http"
-	"net/http/httputil"
-	"net/url"
 
 	"github.com/gin-gonic/gin"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/plugin"
 	"openeuler.org/PilotGo/PilotGo/pkg/logger"
 	"openeuler.org/PilotGo/PilotGo/pkg/utils/response"
@@ -22,7 +21,9 @@ func GetPluginsHandler(c *gin.Context) {
 // 添加插件
 func AddPluginHandler(c *gin.Context) {
 	param := struct {
-		Url string `json:"url"`
+		Url     string `json:"url"`
+		Timeout int    `json:"timeout"`
+		plugin.CredentialParam
 	}{}
 
 	if err := c.BindJSON(&param); err != nil {
@@ -30,12 +31,13 @@ func AddPluginHandler(c *gin.Context) {
 		return
 	}
 
-	if err := plugin.AddPlugin(param.Url); err != nil {
+	cred, err := plugin.AddPlugin(param.Url, param.Timeout, &param.CredentialParam)
+	if err != nil {
 		response.Fail(c, nil, "add plugin failed:"+err.Error())
 		return
 	}
 
-	response.Success(c, nil, "插件添加成功")
+	response.Success(c, gin.H{"credential": cred}, "插件添加成功")
 }
 
 // 停用/启动插件
@@ -69,21 +71,60 @@ func UnloadPluginHandler(c *gin.Context) {
 	response.Success(c, nil, "插件信息更新成功")
 }
 
+// 查询插件调用平台接口的凭证，不含密钥
+func GetPluginCredentialHandler(c *gin.Context) {
+	cred, err := plugin.GetCredential(c.Param("uuid"))
+	if err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, cred, "插件凭证查询成功")
+}
+
+// 修改插件授权范围或重新生成凭证，重新生成时返回新密钥
+func UpdatePluginCredentialHandler(c *gin.Context) {
+	param := struct {
+		Rotate bool `json:"rotate"`
+		plugin.CredentialParam
+	}{}
+	if err := c.BindJSON(&param); err != nil {
+		response.Fail(c, nil, "参数错误")
+		return
+	}
+
+	cred, err := plugin.UpdateCredential(c.Param("uuid"), &param.CredentialParam, param.Rotate)
+	if err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, cred, "插件凭证更新成功")
+}
+
+// 插件接口反向代理，去除/plugin/<name>前缀后转发给插件
 func PluginGatewayHandler(c *gin.Context) {
-	// TODO
 	name := c.Param("plugin_name")
 	p := plugin.GetPlugin(name)
 	if p == nil {
 		c.String(http.StatusNotFound, "plugin not found")
 		return
 	}
+	if p.Enabled == plugin.PluginDisabled {
+		c.String(http.StatusForbidden, "plugin disabled")
+		return
+	}
 
-	target, err := url.Parse(p.Url)
+	proxy, err := plugin.GetReverseProxy(p)
 	if err != nil {
-		// return 404
 		c.String(http.StatusNotFound, "parse plugin url error: "+err.Error())
 		return
 	}
-	proxy := httputil.NewSingleHostReverseProxy(target)
+
+	var user *dao.User
+	if u, ok := c.Get("x-user"); ok {
+		xu := u.(dao.User)
+		user = &xu
+	}
+	plugin.ForwardIdentity(c.Request, user)
+
 	proxy.ServeHTTP(c.Writer, c.Request)
 }
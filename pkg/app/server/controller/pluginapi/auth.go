@@ -0,0 +1,140 @@
+package pluginapi
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/controller/agentcontroller"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/approval"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/auditlog"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/plugin"
+	"openeuler.org/PilotGo/PilotGo/pkg/logger"
+	"openeuler.org/PilotGo/PilotGo/pkg/utils/response"
+)
+
+const (
+	pluginAPIPrefix = "/api/v1/pluginapi/"
+
+	ctxCredential = "x-plugin-credential"
+	ctxTargets    = "x-plugin-targets"
+	ctxDenied     = "x-plugin-denied"
+
+	// 审计日志中最多记录的目标机器数
+	maxAuditTargets = 20
+)
+
+// 检查plugin接口调用权限，并以插件身份记录审计日志
+func AuthCheck(c *gin.Context) {
+	endpoint := endpointName(c.FullPath())
+	action := c.Request.Method + " " + c.Request.URL.Path
+
+	cred, err := plugin.Authenticate(c.Request)
+	if err != nil {
+		logger.Warn("plugin api %s authenticate failed: %s", endpoint, err.Error())
+		log := auditlog.NewByPlugin("unknown", action, "认证失败: "+err.Error())
+		log.Status = auditlog.StatusFail
+		auditlog.Add(log)
+
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"code": http.StatusUnauthorized,
+			"msg":  "plugin authenticate failed: " + err.Error()})
+		return
+	}
+
+	if !cred.AllowEndpoint(endpoint) {
+		logger.Warn("plugin %s is not allowed to call %s", cred.PluginName, endpoint)
+		log := auditlog.NewByPlugin(cred.PluginName, action, "拒绝: 接口不在授权范围内")
+		log.Status = auditlog.StatusFail
+		auditlog.Add(log)
+
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"code": http.StatusForbidden,
+			"msg":  "endpoint " + endpoint + " is out of plugin scope"})
+		return
+	}
+
+	c.Set(ctxCredential, cred)
+	c.Next()
+
+	log := auditlog.NewByPlugin(cred.PluginName, action, auditTargets(c))
+	log.Status = auditlog.StatusSuccess
+	if reason := c.GetString(ctxDenied); reason != "" {
+		log.Status = auditlog.StatusFail
+		log.Message = "拒绝: " + reason + " " + log.Message
+	}
+	if err := auditlog.Add(log); err != nil {
+		logger.Error("failed to record plugin audit log: %s", err.Error())
+	}
+}
+
+// 从路由中解析接口名，如/api/v1/pluginapi/service/:name解析为service
+func endpointName(fullPath string) string {
+	name := strings.TrimPrefix(fullPath, pluginAPIPrefix)
+	if i := strings.Index(name, "/"); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+func auditTargets(c *gin.Context) string {
+	targets := c.GetStringSlice(ctxTargets)
+	if len(targets) == 0 {
+		return ""
+	}
+	if len(targets) > maxAuditTargets {
+		return fmt.Sprintf("targets(%d): %s...", len(targets), strings.Join(targets[:maxAuditTargets], ","))
+	}
+	return fmt.Sprintf("targets(%d): %s", len(targets), strings.Join(targets, ","))
+}
+
+// 检查插件是否有权操作目标机器，无权限时直接返回错误响应
+func checkTargets(c *gin.Context, uuids []string) bool {
+	c.Set(ctxTargets, uuids)
+
+	v, ok := c.Get(ctxCredential)
+	if !ok {
+		c.Set(ctxDenied, "missing plugin credential")
+		response.Fail(c, nil, "missing plugin credential")
+		return false
+	}
+	if err := v.(*plugin.Credential).CheckMachines(uuids); err != nil {
+		c.Set(ctxDenied, err.Error())
+		response.Fail(c, nil, err.Error())
+		return false
+	}
+	return true
+}
+
+// 按插件授权范围过滤机器，未限制范围时返回nil
+func allowedMachines(c *gin.Context) (map[string]bool, error) {
+	v, ok := c.Get(ctxCredential)
+	if !ok {
+		return map[string]bool{}, nil
+	}
+	cred := v.(*plugin.Credential)
+	if !cred.Restricted() {
+		return nil, nil
+	}
+	return cred.AllowedMachines()
+}
+
+// 插件身份，作为审批申请人及日志中的操作者
+func pluginIdentity(c *gin.Context) string {
+	if v, ok := c.Get(ctxCredential); ok {
+		return "plugin:" + v.(*plugin.Credential).PluginName
+	}
+	return "plugin:unknown"
+}
+
+// 以插件身份提交审批请求，操作在审批通过后执行
+func submitApproval(c *gin.Context, op string, targets []string, params interface{}, reason string) {
+	agentcontroller.SubmitApproval(c, &approval.Request{
+		Operation: op,
+		Targets:   targets,
+		Params:    params,
+		Reason:    reason,
+		Requester: pluginIdentity(c),
+	})
+}
@@ -0,0 +1,53 @@
+package pluginapi
+
+import (
+	"strings"
+
+	"gitee.com/openeuler/PilotGo-plugins/sdk/common"
+	"github.com/gin-gonic/gin"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/batch"
+	"openeuler.org/PilotGo/PilotGo/pkg/utils/response"
+)
+
+// 从url参数解析批次选择器，兼容旧版本的uuid参数
+func batchFromQuery(c *gin.Context) *common.Batch {
+	b := &common.Batch{
+		BatchUUID:     c.Query("batch_id"),
+		MachineUUIDs:  splitQueryArray(c.QueryArray("machine_uuids")),
+		DepartmentIDs: splitQueryArray(c.QueryArray("department_ids")),
+	}
+	if uuid := c.Query("uuid"); uuid != "" {
+		b.MachineUUIDs = append(b.MachineUUIDs, uuid)
+	}
+	return b
+}
+
+// 支持 a=1&a=2 及 a=1,2 两种形式
+func splitQueryArray(values []string) []string {
+	result := []string{}
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			if s != "" {
+				result = append(result, s)
+			}
+		}
+	}
+	return result
+}
+
+// 解析批次选择器并检查插件授权范围，失败时直接返回错误响应
+func resolveMachines(c *gin.Context, b *common.Batch) ([]string, bool) {
+	uuids, err := batch.GetMachines(b)
+	if err != nil {
+		response.Fail(c, nil, err.Error())
+		return nil, false
+	}
+	if len(uuids) == 0 {
+		response.Fail(c, nil, "no machine matched the batch selector")
+		return nil, false
+	}
+	if !checkTargets(c, uuids) {
+		return nil, false
+	}
+	return uuids, true
+}
@@ -0,0 +1,46 @@
+package pluginapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitQueryArray(t *testing.T) {
+	cases := []struct {
+		values []string
+		want   []string
+	}{
+		{nil, []string{}},
+		{[]string{"a"}, []string{"a"}},
+		{[]string{"a", "b"}, []string{"a", "b"}},
+		{[]string{"a,b", "c"}, []string{"a", "b", "c"}},
+		{[]string{"a,,b,", ""}, []string{"a", "b"}},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, splitQueryArray(c.values), "%v", c.values)
+	}
+}
+
+func TestBatchFromQuery(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet,
+		"/?batch_id=7&machine_uuids=m1,m2&machine_uuids=m3&department_ids=1&department_ids=2,3&uuid=m4", nil)
+
+	b := batchFromQuery(c)
+	assert.Equal(t, "7", b.BatchUUID)
+	assert.Equal(t, []string{"m1", "m2", "m3", "m4"}, b.MachineUUIDs)
+	assert.Equal(t, []string{"1", "2", "3"}, b.DepartmentIDs)
+
+	t.Run("legacy uuid", func(t *testing.T) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/?uuid=m1", nil)
+		b := batchFromQuery(c)
+		assert.Empty(t, b.BatchUUID)
+		assert.Equal(t, []string{"m1"}, b.MachineUUIDs)
+		assert.Empty(t, b.DepartmentIDs)
+	})
+}
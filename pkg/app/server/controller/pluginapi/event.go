@@ -3,42 +3,178 @@
 package pluginapi
 
 import (
+	"strconv"
+	"time"
+
 	"github.com/gin-gonic/gin"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/common"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/eventbus"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/plugin"
 	"openeuler.org/PilotGo/PilotGo/pkg/logger"
 	"openeuler.org/PilotGo/PilotGo/pkg/utils/response"
 )
 
+// 监听者以插件凭证中的插件名标识，不使用请求中的名称，避免插件冒用其他插件的确认记录
+func listenerName(c *gin.Context) string {
+	if v, ok := c.Get(ctxCredential); ok {
+		return v.(*plugin.Credential).PluginName
+	}
+	return ""
+}
+
+// 凭证限制了机器范围时，只返回范围内机器及与机器无关的事件
+func eventMachines(c *gin.Context) ([]string, error) {
+	allowed, err := allowedMachines(c)
+	if err != nil || allowed == nil {
+		return nil, err
+	}
+	machines := []string{}
+	for uuid := range allowed {
+		machines = append(machines, uuid)
+	}
+	return machines, nil
+}
+
+// 推送事件时按插件当前的授权范围过滤，授权范围修改后无需重新注册
+func allowEvent(pluginUUID string) func(string) bool {
+	return func(machineUUID string) bool {
+		cred, err := plugin.GetCredential(pluginUUID)
+		return err == nil && cred.AllowEvent(machineUUID)
+	}
+}
+
 func RegisterListenerHandler(c *gin.Context) {
 	p := struct {
-		Name string `json:"name"`
-		URL  string `json:"url"`
+		URL string `form:"url" json:"url"`
 	}{}
-	if err := c.ShouldBindQuery(p); err != nil {
+	if err := c.ShouldBindQuery(&p); err != nil {
 		response.Fail(c, gin.H{"status": false}, err.Error())
 		return
 	}
-
-	eventbus.AddListener(&eventbus.Listener{
-		Name: p.Name,
+	name := listenerName(c)
+	l := &eventbus.Listener{
+		Name: name,
 		URL:  p.URL,
-	})
+	}
+	if v, ok := c.Get(ctxCredential); ok {
+		l.Allow = allowEvent(v.(*plugin.Credential).PluginUUID)
+	}
 
-	logger.Info("")
+	eventbus.AddListener(l)
+
+	logger.Info("plugin %s listen events on %s", name, p.URL)
+	response.Success(c, nil, "监听注册成功")
 }
 
 func UnregisterListenerHandler(c *gin.Context) {
 	p := struct {
-		Name string `json:"name"`
-		URL  string `json:"url"`
+		URL string `form:"url" json:"url"`
 	}{}
-	if err := c.ShouldBindQuery(p); err != nil {
+	if err := c.ShouldBindQuery(&p); err != nil {
 		response.Fail(c, gin.H{"status": false}, err.Error())
 		return
 	}
 
 	eventbus.RemoveListener(&eventbus.Listener{
-		Name: p.Name,
+		Name: listenerName(c),
 		URL:  p.URL,
 	})
+	response.Success(c, nil, "监听注销成功")
+}
+
+// 分页查询事件日志，支持按类型、机器及时间范围(unix秒)过滤
+func EventListHandler(c *gin.Context) {
+	query := &common.PaginationQ{}
+	if err := c.ShouldBindQuery(query); err != nil {
+		response.Fail(c, gin.H{"status": false}, err.Error())
+		return
+	}
+
+	machines, err := eventMachines(c)
+	if err != nil {
+		response.Fail(c, gin.H{"status": false}, err.Error())
+		return
+	}
+	filter := &dao.EventFilter{
+		MachineUUID: c.Query("machine"),
+		Machines:    machines,
+	}
+	if t := c.Query("type"); t != "" {
+		msgType, err := strconv.Atoi(t)
+		if err != nil {
+			response.Fail(c, nil, "事件类型格式有误")
+			return
+		}
+		filter.MessageType = &msgType
+	}
+	if s := c.Query("start"); s != "" {
+		start, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			response.Fail(c, nil, "起始时间格式有误")
+			return
+		}
+		filter.Start = time.Unix(start, 0)
+	}
+	if e := c.Query("end"); e != "" {
+		end, err := strconv.ParseInt(e, 10, 64)
+		if err != nil {
+			response.Fail(c, nil, "结束时间格式有误")
+			return
+		}
+		filter.End = time.Unix(end, 0)
+	}
+
+	list, tx := eventbus.QueryEvents(filter)
+	total, err := common.CrudAll(query, tx, list)
+	if err != nil {
+		response.Fail(c, gin.H{"status": false}, err.Error())
+		return
+	}
+	common.JsonPagination(c, eventbus.Messages(*list), total, query)
+}
+
+// 回放插件未确认的事件
+func EventReplayHandler(c *gin.Context) {
+	name := listenerName(c)
+	after, err := strconv.ParseUint(c.DefaultQuery("after", "0"), 10, 64)
+	if err != nil {
+		response.Fail(c, nil, "序列号格式有误")
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if err != nil {
+		response.Fail(c, nil, "limit格式有误")
+		return
+	}
+
+	machines, err := eventMachines(c)
+	if err != nil {
+		response.Fail(c, gin.H{"status": false}, err.Error())
+		return
+	}
+
+	events, err := eventbus.Replay(name, uint(after), limit, machines)
+	if err != nil {
+		response.Fail(c, gin.H{"status": false}, err.Error())
+		return
+	}
+	response.Success(c, events, "事件回放成功")
+}
+
+// 插件确认已处理的事件序列号
+func EventAckHandler(c *gin.Context) {
+	p := &struct {
+		Sequence uint `json:"sequence"`
+	}{}
+	if err := c.ShouldBindJSON(p); err != nil {
+		response.Fail(c, gin.H{"status": false}, err.Error())
+		return
+	}
+
+	if err := eventbus.Ack(listenerName(c), p.Sequence); err != nil {
+		response.Fail(c, gin.H{"status": false}, err.Error())
+		return
+	}
+	response.Success(c, nil, "事件确认成功")
 }
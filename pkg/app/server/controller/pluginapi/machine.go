@@ -14,9 +14,17 @@ func MachineList(c *gin.Context) {
 		response.Fail(c, nil, err.Error())
 		return
 	}
+	allowed, err := allowedMachines(c)
+	if err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
 
 	resp := []*common.MachineNode{}
 	for _, item := range data {
+		if allowed != nil && !allowed[item.UUID] {
+			continue
+		}
 		d := &common.MachineNode{
 			UUID:       item.UUID,
 			IP:         item.IP,
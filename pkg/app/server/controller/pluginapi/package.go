@@ -3,7 +3,9 @@ package pluginapi
 import (
 	"github.com/gin-gonic/gin"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/agentmanager"
-	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/batch"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/controller/agentcontroller"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/approval"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/executor"
 	"openeuler.org/PilotGo/PilotGo/pkg/logger"
 	"openeuler.org/PilotGo/PilotGo/pkg/utils/response"
 
@@ -20,48 +22,56 @@ func InstallPackage(c *gin.Context) {
 		return
 	}
 
-	machines := batch.GetMachines(param.Batch)
-	for _, uuid := range machines {
-		// TODO: Improve error handling logic
-		agent := agentmanager.GetAgent(uuid)
-		if agent != nil {
-			logger.Error("cannot find agent %s", uuid)
-			continue
-		}
+	machines, ok := resolveMachines(c, param.Batch)
+	if !ok {
+		return
+	}
 
-		_, _, err := agent.InstallRpm(param.Package)
+	results := executor.Run(machines, func(agent *agentmanager.Agent) (interface{}, error) {
+		stdout, _, err := agent.InstallRpm(param.Package)
 		if err != nil {
-			logger.Error("agent %s install package %s failed: %s", uuid, param.Package, err)
+			logger.Error("agent %s install package %s failed: %s", agent.UUID, param.Package, err)
+			return nil, err
 		}
-	}
+		return stdout, nil
+	})
 
-	response.Success(c, nil, "软件包安装完成!")
+	response.Success(c, results, "软件包安装完成!")
 }
 
 func UninstallPackage(c *gin.Context) {
 	param := struct {
 		Batch   *common.Batch `json:"batch"`
-		Package string
+		Package string        `json:"package"`
+		// 需要审批时的申请原因
+		Reason string `json:"reason"`
 	}{}
 	if err := c.Bind(&param); err != nil {
 		response.Fail(c, gin.H{"status": false}, err.Error())
 		return
 	}
 
-	machines := batch.GetMachines(param.Batch)
-	for _, uuid := range machines {
-		// TODO: Improve error handling logic
-		agent := agentmanager.GetAgent(uuid)
-		if agent != nil {
-			logger.Error("cannot find agent %s", uuid)
-			continue
-		}
+	machines, ok := resolveMachines(c, param.Batch)
+	if !ok {
+		return
+	}
+	if approval.Required(approval.OpRpmRemove) {
+		submitApproval(c, approval.OpRpmRemove, machines, &agentcontroller.RPMS{
+			UUIDs:    machines,
+			RPM:      param.Package,
+			UserName: pluginIdentity(c),
+		}, param.Reason)
+		return
+	}
 
-		_, _, err := agent.RemoveRpm(param.Package)
+	results := executor.Run(machines, func(agent *agentmanager.Agent) (interface{}, error) {
+		stdout, _, err := agent.RemoveRpm(param.Package)
 		if err != nil {
-			logger.Error("agent %s uninstall package %s failed: %s", uuid, param.Package, err)
+			logger.Error("agent %s uninstall package %s failed: %s", agent.UUID, param.Package, err)
+			return nil, err
 		}
-	}
+		return stdout, nil
+	})
 
-	response.Success(c, nil, "软件包安装完成!")
+	response.Success(c, results, "软件包卸载完成!")
 }
@@ -3,10 +3,15 @@
 package pluginapi
 
 import (
-	"fmt"
+	"encoding/base64"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/agentmanager"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/executor"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/plugin"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/policy"
 	"openeuler.org/PilotGo/PilotGo/pkg/logger"
 	"openeuler.org/PilotGo/PilotGo/pkg/utils"
 	"openeuler.org/PilotGo/PilotGo/pkg/utils/response"
@@ -14,31 +19,61 @@ import (
 	"gitee.com/openeuler/PilotGo-plugins/sdk/common"
 )
 
-// 检查plugin接口调用权限
-func AuthCheck(c *gin.Context) {
-	// TODO
-	c.Next()
-}
-
 type RunResult struct {
 	*utils.CmdResult
 	MachineUUID string
 	MachineIP   string
+	Error       string
 }
 
-// 远程运行脚本
+func toRunResults(results []*executor.Result) []*RunResult {
+	runResults := []*RunResult{}
+	for _, r := range results {
+		rr := &RunResult{
+			MachineUUID: r.MachineUUID,
+			MachineIP:   r.MachineIP,
+			Error:       r.Error,
+		}
+		if data, ok := r.Data.(*utils.CmdResult); ok {
+			rr.CmdResult = data
+		}
+		runResults = append(runResults, rr)
+	}
+	return runResults
+}
+
+// 以插件身份进行命令策略检查，不允许执行时直接返回错误响应
+func checkPolicy(c *gin.Context, kind, content, confirm string) bool {
+	subject := policy.PluginSubject("unknown", nil)
+	if v, ok := c.Get(ctxCredential); ok {
+		cred := v.(*plugin.Credential)
+		subject = policy.PluginSubject(cred.PluginName, cred.Departments)
+	}
+	d, err := policy.Check(subject, kind, content, confirm)
+	if err != nil {
+		c.Set(ctxDenied, err.Error())
+		response.Fail(c, gin.H{"decision": d}, err.Error())
+		return false
+	}
+	return true
+}
+
+// 命令及脚本以base64编码下发，策略检查前先解码，解码失败时按原文检查
+func decodeContent(s string) string {
+	if decoded, err := base64.StdEncoding.DecodeString(s); err == nil {
+		return string(decoded)
+	}
+	return s
+}
+
+// 远程运行命令
 func RunCommandHandler(c *gin.Context) {
 	logger.Debug("process get agent request")
-	// uuid := c.Query("uuid")
-	// script := c.Query("command")
-
-	// ttcode
-	fmt.Println("\033[32mc.request.headers\033[0m: ", c.Request.Header)
-	fmt.Println("\033[32mc.request.body\033[0m: ", c.Request.Body)
 
 	d := &struct {
 		Batch   *common.Batch `json:"batch"`
 		Command string        `json:"command"`
+		Confirm string        `json:"confirm"`
 	}{}
 	err := c.ShouldBind(d)
 	if err != nil {
@@ -48,58 +83,39 @@ func RunCommandHandler(c *gin.Context) {
 		return
 	}
 
-	// ttcode
-	fmt.Println("\033[32md\033[0m: ", d)
-	fmt.Println("\033[32md.batch\033[0m: ", d.Batch)
-
-	logger.Debug("run command on agents :%v", d.Batch.MachineUUIDs)
-
-	result := []*RunResult{}
-
-	if d.Batch.MachineUUIDs != nil {
-		for _, uuid := range d.Batch.MachineUUIDs {
-			// TODO: support batch
-			agent := agentmanager.GetAgent(uuid)
-			if agent != nil {
-				data, err := agent.RunCommand(d.Command)
-				if err != nil {
-					logger.Error("run command error, agent:%s, command:%s", uuid, d.Command)
-					response.Fail(c, nil, err.Error())
-					continue
-				}
-				logger.Debug("run command on agent result:%v", data)
-
-				result = append(result, &RunResult{
-					CmdResult:   data,
-					MachineUUID: uuid,
-					MachineIP:   agent.IP,
-				})
-				// response.Success(c, data, "")
-				// return
-			} else {
-				logger.Warn("unknown agent:%s", uuid)
-			}
-		}
-
-		response.Success(c, result, "")
+	machines, ok := resolveMachines(c, d.Batch)
+	if !ok {
+		return
+	}
+	if !checkPolicy(c, service.RunCommand, decodeContent(d.Command), d.Confirm) {
 		return
-	} else {
-		response.Fail(c, nil, "empty machine uuids")
 	}
+	logger.Debug("run command on agents :%v", machines)
+
+	results := executor.Run(machines, func(agent *agentmanager.Agent) (interface{}, error) {
+		data, err := agent.RunCommand(d.Command)
+		if err != nil {
+			logger.Error("run command error, agent:%s, command:%s", agent.UUID, d.Command)
+			return nil, err
+		}
+		logger.Debug("run command on agent result:%v", data)
+		return data, nil
+	})
+
+	response.Success(c, toRunResults(results), "")
 }
 
 // 远程运行脚本
 func RunScriptHandler(c *gin.Context) {
 	logger.Debug("process get agent request")
 
-	// ttcode
-	fmt.Println("\033[32mc.request.headers\033[0m: ", c.Request.Header)
-	fmt.Println("\033[32mc.request.body\033[0m: ", c.Request.Body)
-
 	d := &struct {
-		Batch  *common.Batch `json:"batch"`
-		Script string        `json:"script"`
-		Params []string      `json:"params"`
+		Batch       *common.Batch        `json:"batch"`
+		Script      string               `json:"script"`
+		Params      []string             `json:"params"`
+		Interpreter string               `json:"interpreter"`
+		Options     *utils.ScriptOptions `json:"options"`
+		Confirm     string               `json:"confirm"`
 	}{}
 	err := c.ShouldBind(d)
 	if err != nil {
@@ -109,42 +125,25 @@ func RunScriptHandler(c *gin.Context) {
 		return
 	}
 
-	// ttcode
-	fmt.Println("\033[32md\033[0m: ", len(d.Script), len(d.Params))
-	fmt.Println("\033[32md.batch\033[0m: ", d.Batch)
-
-	logger.Debug("run script on agents :%v", d.Batch.MachineUUIDs)
-
-	result := []*RunResult{}
-
-	if d.Batch.MachineUUIDs != nil {
-		for _, uuid := range d.Batch.MachineUUIDs {
-			// TODO: support batch
-			agent := agentmanager.GetAgent(uuid)
-			if agent != nil {
-				data, err := agent.RunScript(d.Script, d.Params)
-				if err != nil {
-					logger.Error("run command error, agent:%s, command:%s", uuid, d.Script)
-					response.Fail(c, nil, err.Error())
-					continue
-				}
-				logger.Debug("run command on agent result:%v", data)
-
-				result = append(result, &RunResult{
-					CmdResult:   data,
-					MachineUUID: uuid,
-					MachineIP:   agent.IP,
-				})
-				// response.Success(c, data, "")
-				// return
-			} else {
-				logger.Warn("unknown agent:%s", uuid)
-			}
-		}
-
-		response.Success(c, result, "")
+	machines, ok := resolveMachines(c, d.Batch)
+	if !ok {
+		return
+	}
+	content := strings.TrimSpace(decodeContent(d.Script) + "\n" + strings.Join(d.Params, " "))
+	if !checkPolicy(c, service.RunScript, content, d.Confirm) {
 		return
-	} else {
-		response.Fail(c, nil, "empty machine uuids")
 	}
+	logger.Debug("run script on agents :%v", machines)
+
+	results := executor.Run(machines, func(agent *agentmanager.Agent) (interface{}, error) {
+		data, err := agent.RunScriptWithOptions(d.Script, d.Params, d.Interpreter, d.Options)
+		if err != nil {
+			logger.Error("run script error, agent:%s, script:%s", agent.UUID, d.Script)
+			return nil, err
+		}
+		logger.Debug("run script on agent result:%v", data)
+		return data, nil
+	})
+
+	response.Success(c, toRunResults(results), "")
 }
@@ -1,68 +1,102 @@
 package pluginapi
 
 import (
+	"gitee.com/openeuler/PilotGo-plugins/sdk/common"
 	"github.com/gin-gonic/gin"
 
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/agentmanager"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/controller/agentcontroller"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/approval"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/executor"
 	"openeuler.org/PilotGo/PilotGo/pkg/utils/response"
 )
 
-func Service(ctx *gin.Context) {
-	// TODO: support batch
-	uuid := ctx.Query("uuid")
-	service := ctx.Query("service")
+type serviceParam struct {
+	Batch   *common.Batch `json:"batch"`
+	Service string        `json:"service"`
+	// 需要审批时的申请原因
+	Reason string `json:"reason"`
+}
 
-	agent := agentmanager.GetAgent(uuid)
-	if agent == nil {
-		response.Fail(ctx, nil, "获取uuid失败!")
-		return
+// 解析服务操作参数，body中未指定时兼容旧版本的uuid及service url参数
+func bindServiceParam(c *gin.Context) (*serviceParam, bool) {
+	param := &serviceParam{}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(param); err != nil {
+			response.Fail(c, gin.H{"status": false}, err.Error())
+			return nil, false
+		}
+	}
+	if param.Batch == nil {
+		param.Batch = batchFromQuery(c)
+	}
+	if param.Service == "" {
+		param.Service = c.Query("service")
+	}
+	if param.Service == "" {
+		response.Fail(c, nil, "service name is empty")
+		return nil, false
+	}
+	return param, true
+}
+
+func Service(ctx *gin.Context) {
+	service := ctx.Param("name")
+	if service == "" {
+		service = ctx.Query("service")
 	}
 
-	service_status, err := agent.ServiceStatus(service)
-	if err != nil {
-		response.Fail(ctx, nil, "获取服务状态失败!")
+	machines, ok := resolveMachines(ctx, batchFromQuery(ctx))
+	if !ok {
 		return
 	}
-	response.Success(ctx, gin.H{"service_status": service_status}, "Success")
+
+	results := executor.Run(machines, func(agent *agentmanager.Agent) (interface{}, error) {
+		return agent.ServiceStatus(service)
+	})
+	response.Success(ctx, results, "Success")
 }
 
 func StartService(c *gin.Context) {
-	// TODO: support batch
-	uuid := c.Query("uuid")
-	service := c.Query("service")
-
-	agent := agentmanager.GetAgent(uuid)
-	if agent == nil {
-		response.Fail(c, nil, "获取uuid失败!")
+	param, ok := bindServiceParam(c)
+	if !ok {
 		return
 	}
 
-	service_start, Err, err := agent.ServiceStart(service)
-	if len(Err) != 0 || err != nil {
-		response.Fail(c, gin.H{"error": Err}, "Failed!")
+	machines, ok := resolveMachines(c, param.Batch)
+	if !ok {
 		return
 	}
 
-	response.Success(c, gin.H{"service_start": service_start}, "Success")
+	results := executor.Run(machines, func(agent *agentmanager.Agent) (interface{}, error) {
+		service_start, _, err := agent.ServiceStart(param.Service)
+		return service_start, err
+	})
+	response.Success(c, results, "Success")
 }
 
 func StopService(c *gin.Context) {
-	// TODO: support batch
-	uuid := c.Query("uuid")
-	service := c.Query("service")
-
-	agent := agentmanager.GetAgent(uuid)
-	if agent == nil {
-		response.Fail(c, nil, "获取uuid失败!")
+	param, ok := bindServiceParam(c)
+	if !ok {
 		return
 	}
 
-	service_stop, Err, err := agent.ServiceStop(service)
-	if len(Err) != 0 || err != nil {
-		response.Fail(c, gin.H{"error": Err}, "Failed!")
+	machines, ok := resolveMachines(c, param.Batch)
+	if !ok {
+		return
+	}
+	if approval.ServiceStopRequired(param.Service) {
+		submitApproval(c, approval.OpServiceStop, machines, &agentcontroller.ServiceStopParam{
+			UUIDs:    machines,
+			Service:  param.Service,
+			UserName: pluginIdentity(c),
+		}, param.Reason)
 		return
 	}
 
-	response.Success(c, gin.H{"service_start": service_stop}, "Success")
-
+	results := executor.Run(machines, func(agent *agentmanager.Agent) (interface{}, error) {
+		service_stop, _, err := agent.ServiceStop(param.Service)
+		return service_stop, err
+	})
+	response.Success(c, results, "Success")
 }
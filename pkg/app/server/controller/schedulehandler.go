@@ -0,0 +1,189 @@
+package controller
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/auth"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/common"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/schedule"
+	"openeuler.org/PilotGo/PilotGo/pkg/utils/response"
+)
+
+type scheduleIDs struct {
+	IDs []uint `json:"ids"`
+}
+
+func MaintenanceWindowListHandler(c *gin.Context) {
+	list, err := schedule.WindowList()
+	if err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, gin.H{"windows": list}, "Success")
+}
+
+func AddMaintenanceWindowHandler(c *gin.Context) {
+	w := &dao.MaintenanceWindow{}
+	if err := c.ShouldBindJSON(w); err != nil {
+		response.Fail(c, nil, "parameter error")
+		return
+	}
+	w.ID = 0
+	if err := schedule.AddWindow(w); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, gin.H{"window": w}, "维护窗口添加成功")
+}
+
+func UpdateMaintenanceWindowHandler(c *gin.Context) {
+	w := &dao.MaintenanceWindow{}
+	if err := c.ShouldBindJSON(w); err != nil {
+		response.Fail(c, nil, "parameter error")
+		return
+	}
+	if err := schedule.UpdateWindow(w); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, gin.H{"window": w}, "维护窗口修改成功")
+}
+
+func DeleteMaintenanceWindowHandler(c *gin.Context) {
+	p := &scheduleIDs{}
+	if err := c.ShouldBindJSON(p); err != nil || len(p.IDs) == 0 {
+		response.Fail(c, nil, "parameter error")
+		return
+	}
+	if err := schedule.DeleteWindows(p.IDs); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, nil, "维护窗口删除成功")
+}
+
+// 分页查询定时操作
+func ScheduleListHandler(c *gin.Context) {
+	query := &common.PaginationQ{}
+	if err := c.ShouldBindQuery(query); err != nil {
+		response.Fail(c, gin.H{"status": false}, err.Error())
+		return
+	}
+
+	list, tx := schedule.List()
+	total, err := common.CrudAll(query, tx, list)
+	if err != nil {
+		response.Fail(c, gin.H{"status": false}, err.Error())
+		return
+	}
+	common.JsonPagination(c, list, total, query)
+}
+
+func AddScheduleHandler(c *gin.Context) {
+	p := &schedule.Param{}
+	if err := c.ShouldBindJSON(p); err != nil {
+		response.Fail(c, nil, "parameter error")
+		return
+	}
+	u, ok := auth.LoginUser(c)
+	if !ok {
+		response.Fail(c, nil, "未登录")
+		return
+	}
+	p.UserName = u.Email
+	p.UserDept = u.DepartName
+	s, err := schedule.Add(p)
+	if err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, gin.H{"schedule": s}, "定时操作添加成功")
+}
+
+func UpdateScheduleHandler(c *gin.Context) {
+	p := &schedule.Param{}
+	if err := c.ShouldBindJSON(p); err != nil {
+		response.Fail(c, nil, "parameter error")
+		return
+	}
+	u, ok := auth.LoginUser(c)
+	if !ok {
+		response.Fail(c, nil, "未登录")
+		return
+	}
+	p.UserName = u.Email
+	p.UserDept = u.DepartName
+	s, err := schedule.Update(p)
+	if err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, gin.H{"schedule": s}, "定时操作修改成功")
+}
+
+func DeleteScheduleHandler(c *gin.Context) {
+	p := &scheduleIDs{}
+	if err := c.ShouldBindJSON(p); err != nil || len(p.IDs) == 0 {
+		response.Fail(c, nil, "parameter error")
+		return
+	}
+	if err := schedule.Delete(p.IDs); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, nil, "定时操作删除成功")
+}
+
+// 开启或关闭定时操作
+func ScheduleStatusHandler(c *gin.Context) {
+	p := struct {
+		ID      uint `json:"id"`
+		Enabled bool `json:"enabled"`
+	}{}
+	if err := c.ShouldBindJSON(&p); err != nil {
+		response.Fail(c, nil, "parameter error")
+		return
+	}
+	if err := schedule.SetEnabled(p.ID, p.Enabled); err != nil {
+		response.Fail(c, nil, err.Error())
+		return
+	}
+	response.Success(c, nil, "定时操作状态已更新")
+}
+
+// 立即执行一次定时操作
+func RunScheduleHandler(c *gin.Context) {
+	p := struct {
+		ID uint `json:"id"`
+	}{}
+	if err := c.ShouldBindJSON(&p); err != nil {
+		response.Fail(c, nil, "parameter error")
+		return
+	}
+	run, err := schedule.RunNow(p.ID)
+	if err != nil {
+		response.Fail(c, gin.H{"run": run}, err.Error())
+		return
+	}
+	response.Success(c, gin.H{"run": run}, "定时操作已触发")
+}
+
+// 查询定时操作的触发记录，各机器的执行结果通过job_id查询
+func ScheduleRunsHandler(c *gin.Context) {
+	query := &common.PaginationQ{}
+	if err := c.ShouldBindQuery(query); err != nil {
+		response.Fail(c, gin.H{"status": false}, err.Error())
+		return
+	}
+
+	id, _ := strconv.Atoi(c.Query("id"))
+	list, tx := schedule.Runs(uint(id))
+	total, err := common.CrudAll(query, tx, list)
+	if err != nil {
+		response.Fail(c, gin.H{"status": false}, err.Error())
+		return
+	}
+	common.JsonPagination(c, list, total, query)
+}
@@ -2,13 +2,20 @@ package controller
 
 import (
 	"github.com/gin-gonic/gin"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/auth"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/common"
 	scriptservice "openeuler.org/PilotGo/PilotGo/pkg/app/server/service/script"
+	"openeuler.org/PilotGo/PilotGo/pkg/logger"
 	"openeuler.org/PilotGo/PilotGo/pkg/utils/response"
 )
 
 // 存储脚本文件
 func AddScriptHandler(c *gin.Context) {
 	var script scriptservice.Script
+	if err := c.Bind(&script); err != nil {
+		response.Fail(c, nil, "parameter error")
+		return
+	}
 	err := scriptservice.AddScript(&script)
 	if err != nil {
 		response.Fail(c, gin.H{"error": err.Error()}, "脚本文件添加失败")
@@ -16,3 +23,52 @@ func AddScriptHandler(c *gin.Context) {
 	}
 	response.Success(c, nil, "脚本文件添加成功")
 }
+
+// 在机器、批次或部门上执行脚本库中指定版本的脚本
+func RunScriptHandler(c *gin.Context) {
+	p := &scriptservice.RunParam{}
+	if err := c.ShouldBindJSON(p); err != nil {
+		response.Fail(c, nil, "parameter error")
+		return
+	}
+	// 执行用户用于命令策略检查及执行记录，只取自登录用户
+	u, ok := auth.LoginUser(c)
+	if !ok {
+		response.Fail(c, nil, "未登录")
+		return
+	}
+	p.UserName = u.Email
+	p.UserDept = u.DepartName
+
+	runID, jobID, err := scriptservice.Run(p)
+	if err != nil {
+		logger.Error("failed to run script %s: %s", p.Name, err.Error())
+		response.Fail(c, gin.H{"job_id": jobID}, err.Error())
+		return
+	}
+	response.Success(c, gin.H{"run_id": runID, "job_id": jobID}, "脚本已提交执行")
+}
+
+// 查询脚本执行记录，支持按脚本名、版本、hash、执行用户及机器过滤
+func ScriptRunsHandler(c *gin.Context) {
+	query := &common.PaginationQ{}
+	if err := c.ShouldBindQuery(query); err != nil {
+		response.Fail(c, gin.H{"status": false}, err.Error())
+		return
+	}
+
+	f := &scriptservice.RunFilter{
+		ScriptName:  c.Query("name"),
+		Version:     c.Query("version"),
+		Hash:        c.Query("hash"),
+		UserName:    c.Query("user"),
+		MachineUUID: c.Query("uuid"),
+	}
+	list, tx := scriptservice.QueryRuns(f)
+	total, err := common.CrudAll(query, tx, list)
+	if err != nil {
+		response.Fail(c, gin.H{"status": false}, err.Error())
+		return
+	}
+	common.JsonPagination(c, list, total, query)
+}
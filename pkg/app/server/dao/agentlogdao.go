@@ -30,10 +30,24 @@ type AuditLog struct {
 	Module        string `gorm:"type:varchar(30);not null" json:"module"`
 	Status        string `gorm:"type:varchar(30);not null" json:"status"`
 	OperatorID    uint   `gorm:"not null" json:"operator_id"`
+	Operator      string `gorm:"type:varchar(100)" json:"operator"`
 	Action        string `gorm:"not null" json:"action"`
 	Message       string `json:"message"`
-	CreatedAt     time.Time
-	UpdateAt      time.Time
+	// 审计中间件记录的请求信息，参数中的密码等敏感字段已脱敏
+	Method   string `gorm:"type:varchar(10)" json:"method"`
+	Path     string `gorm:"type:varchar(255);index" json:"path"`
+	Params   string `gorm:"type:text" json:"params"`
+	Machines string `gorm:"type:text" json:"machines"`
+	ClientIP string `gorm:"type:varchar(64)" json:"client_ip"`
+	Code     int    `json:"code"`
+	// 请求耗时，单位毫秒
+	Duration int64 `json:"duration"`
+	// 哈希链序号，为0时尚未加入哈希链
+	Seq       uint64 `gorm:"index" json:"seq"`
+	PrevHash  string `gorm:"type:varchar(64)" json:"prev_hash"`
+	Hash      string `gorm:"type:varchar(64)" json:"hash"`
+	CreatedAt time.Time
+	UpdateAt  time.Time
 }
 
 // 存储日志
@@ -41,12 +55,6 @@ func (p *AuditLog) Record() error {
 	return mysqlmanager.MySQL().Save(p).Error
 }
 
-// 修改日志的操作状态
-func (p *AuditLog) UpdateStatus(status string) error {
-	// TODO:
-	return mysqlmanager.MySQL().Model(&p).Where("log_uuid=?", p.LogUUID).Update("status", status).Error
-}
-
 // 查询所有日志
 func GetAuditLog() ([]AuditLog, error) {
 	var list []AuditLog
@@ -57,7 +65,7 @@ func GetAuditLog() ([]AuditLog, error) {
 // 根据父UUid查询日志
 func GetAuditLogByParentId(parentUUId string) (AuditLog, error) {
 	var list AuditLog
-	tx := mysqlmanager.MySQL().Order("ID desc").Where("parent_uuid=?", parentUUId).Find(list)
+	tx := mysqlmanager.MySQL().Order("ID desc").Where("parent_log_uuid=?", parentUUId).Find(&list)
 	return list, tx.Error
 }
 
@@ -71,10 +79,52 @@ func GetAuditLogById(logUUId string) (AuditLog, error) {
 // 根据模块名字查询日志
 func GetAuditLogByModule(name string) ([]AuditLog, error) {
 	var Log []AuditLog
-	err := mysqlmanager.MySQL().Where("modulename = ?", name).Find(&Log).Error
+	err := mysqlmanager.MySQL().Where("module = ?", name).Find(&Log).Error
 	return Log, err
 }
 
+type AuditLogFilter struct {
+	Module   string
+	Operator string
+	Status   string
+	Action   string
+	Path     string
+	Machine  string
+	Start    *time.Time
+	End      *time.Time
+}
+
+func QueryAuditLogs(f *AuditLogFilter) (*[]AuditLog, *gorm.DB) {
+	list := &[]AuditLog{}
+	tx := mysqlmanager.MySQL().Model(&AuditLog{}).Order("id desc")
+	if f.Module != "" {
+		tx = tx.Where("module = ?", f.Module)
+	}
+	if f.Operator != "" {
+		tx = tx.Where("operator LIKE ?", "%"+f.Operator+"%")
+	}
+	if f.Status != "" {
+		tx = tx.Where("status = ?", f.Status)
+	}
+	if f.Action != "" {
+		tx = tx.Where("action LIKE ?", "%"+f.Action+"%")
+	}
+	if f.Path != "" {
+		tx = tx.Where("path LIKE ?", "%"+f.Path+"%")
+	}
+	if f.Machine != "" {
+		tx = tx.Where("machines LIKE ? OR agent_uuid = ?", "%"+f.Machine+"%", f.Machine)
+	}
+	if f.Start != nil {
+		tx = tx.Where("created_at >= ?", *f.Start)
+	}
+	if f.End != nil {
+		tx = tx.Where("created_at < ?", *f.End)
+	}
+	tx = tx.Find(list)
+	return list, tx
+}
+
 type AgentLogParent struct {
 	ID         int       `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
 	CreatedAt  time.Time `json:"created_at"`
@@ -82,6 +132,15 @@ type AgentLogParent struct {
 	DepartName string    `json:"departName"`
 	Type       string    `json:"type"`
 	Status     string    `json:"status"`
+	// 异步任务信息，同步操作产生的日志中为空
+	JobType    string     `gorm:"type:varchar(50)" json:"job_type"`
+	JobState   string     `gorm:"type:varchar(20);index" json:"job_state"`
+	Total      int        `json:"total"`
+	FinishedAt *time.Time `json:"finished_at"`
+	// 分批执行策略及当前批次
+	Strategy   string `gorm:"type:text" json:"strategy"`
+	Wave       int    `json:"wave"`
+	JobMessage string `json:"job_message"`
 }
 type AgentLog struct {
 	ID              int    `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
@@ -91,6 +150,14 @@ type AgentLog struct {
 	OperationObject string `json:"object"`
 	Action          string `json:"action"`
 	Message         string `json:"message"`
+	// 异步任务中单台机器的执行进度及结果
+	MachineUUID string     `gorm:"type:varchar(100);index" json:"machine_uuid"`
+	State       string     `gorm:"type:varchar(20)" json:"state"`
+	Stdout      string     `gorm:"type:longtext" json:"stdout"`
+	Stderr      string     `gorm:"type:longtext" json:"stderr"`
+	ExitCode    int        `json:"exit_code"`
+	StartedAt   *time.Time `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at"`
 }
 type AgentLogDel struct {
 	IDs []int `json:"ids"`
@@ -138,6 +205,38 @@ func Id2AgentLog(id int) ([]AgentLog, error) {
 	return Log, err
 }
 
+// 批量存储子日志，存储后回填日志ID
+func CreateAgentLogs(logs []*AgentLog) error {
+	if len(logs) == 0 {
+		return nil
+	}
+	return mysqlmanager.MySQL().Create(logs).Error
+}
+
+// 更新子日志
+func UpdateAgentLog(log *AgentLog) error {
+	return mysqlmanager.MySQL().Save(log).Error
+}
+
+// 查询父日志
+func GetAgentLogParent(id int) (*AgentLogParent, error) {
+	var PLog AgentLogParent
+	err := mysqlmanager.MySQL().Where("id = ?", id).First(&PLog).Error
+	return &PLog, err
+}
+
+// 更新父日志
+func UpdateAgentLogParent(PLog *AgentLogParent) error {
+	return mysqlmanager.MySQL().Save(PLog).Error
+}
+
+// 查询指定状态的异步任务
+func GetJobsByState(states []string) ([]AgentLogParent, error) {
+	var list []AgentLogParent
+	err := mysqlmanager.MySQL().Where("job_state in ?", states).Find(&list).Error
+	return list, err
+}
+
 // 修改父日志的操作状态
 func UpdateParentAgentLog(PLogId int, status string) error {
 	var ParentLog AgentLogParent
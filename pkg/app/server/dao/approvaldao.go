@@ -0,0 +1,100 @@
+package dao
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"openeuler.org/PilotGo/PilotGo/pkg/dbmanager/mysqlmanager"
+)
+
+// 高危操作的审批请求
+type Approval struct {
+	ID        uint   `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	Operation string `gorm:"type:varchar(50);index" json:"operation"`
+	// 操作的目标机器，多台时以逗号分隔
+	Target string `gorm:"type:text" json:"target"`
+	// json格式的操作参数
+	Params string `gorm:"type:text" json:"params"`
+	Reason string `gorm:"type:text" json:"reason"`
+	// pending、approved、rejected、expired、executed或failed
+	State       string     `gorm:"type:varchar(20);index" json:"state"`
+	Requester   string     `gorm:"type:varchar(100)" json:"requester"`
+	RequesterID uint       `json:"requester_id"`
+	Approver    string     `gorm:"type:varchar(100)" json:"approver"`
+	ApproverID  uint       `json:"approver_id"`
+	Comment     string     `gorm:"type:text" json:"comment"`
+	Result      string     `gorm:"type:longtext" json:"result"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	ApprovedAt  *time.Time `json:"approved_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+type ApprovalFilter struct {
+	State     string
+	Operation string
+	Requester string
+}
+
+func AddApproval(a *Approval) error {
+	return mysqlmanager.MySQL().Create(a).Error
+}
+
+func GetApproval(id uint) (*Approval, error) {
+	var a Approval
+	err := mysqlmanager.MySQL().Where("id = ?", id).First(&a).Error
+	return &a, err
+}
+
+func UpdateApproval(a *Approval) error {
+	return mysqlmanager.MySQL().Save(a).Error
+}
+
+// 仅当审批请求仍处于from状态时更新，返回是否更新成功，用于避免重复审批
+func UpdateApprovalState(a *Approval, from string) (bool, error) {
+	tx := mysqlmanager.MySQL().Model(&Approval{}).Where("id = ? AND state = ?", a.ID, from).Updates(map[string]interface{}{
+		"state":       a.State,
+		"approver":    a.Approver,
+		"approver_id": a.ApproverID,
+		"comment":     a.Comment,
+		"approved_at": a.ApprovedAt,
+	})
+	return tx.RowsAffected == 1, tx.Error
+}
+
+// 将已过期的待审批请求标记为expired
+func ExpireApprovals(now time.Time) ([]Approval, error) {
+	var list []Approval
+	err := mysqlmanager.MySQL().Where("state = ? AND expires_at < ?", "pending", now).Find(&list).Error
+	if err != nil || len(list) == 0 {
+		return list, err
+	}
+	ids := make([]uint, 0, len(list))
+	for _, a := range list {
+		ids = append(ids, a.ID)
+	}
+	err = mysqlmanager.MySQL().Model(&Approval{}).Where("id IN ? AND state = ?", ids, "pending").Update("state", "expired").Error
+	return list, err
+}
+
+func QueryApprovals(f *ApprovalFilter) (*[]Approval, *gorm.DB) {
+	list := &[]Approval{}
+	tx := mysqlmanager.MySQL().Model(&Approval{}).Order("id desc")
+	if f.State != "" {
+		tx = tx.Where("state = ?", f.State)
+	}
+	if f.Operation != "" {
+		tx = tx.Where("operation = ?", f.Operation)
+	}
+	if f.Requester != "" {
+		tx = tx.Where("requester = ?", f.Requester)
+	}
+	tx = tx.Find(list)
+	return list, tx
+}
+
+func GetApprovalsByState(state string) ([]Approval, error) {
+	var list []Approval
+	err := mysqlmanager.MySQL().Where("state = ?", state).Find(&list).Error
+	return list, err
+}
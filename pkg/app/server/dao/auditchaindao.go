@@ -0,0 +1,199 @@
+package dao
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"openeuler.org/PilotGo/PilotGo/pkg/dbmanager/mysqlmanager"
+)
+
+// 日志已加入哈希链，不能再修改
+var ErrAuditLogSealed = errors.New("audit log already sealed")
+
+// 加入哈希链须串行执行
+var auditChainMutex sync.Mutex
+
+// 参与哈希计算的日志内容，按字段顺序序列化为json后计算sha256
+type AuditHashContent struct {
+	Seq           uint64 `json:"seq"`
+	PrevHash      string `json:"prev_hash"`
+	LogUUID       string `json:"log_uuid"`
+	ParentLogUUID string `json:"parent_log_uuid"`
+	AgentUUID     string `json:"agent_uuid"`
+	Module        string `json:"module"`
+	Status        string `json:"status"`
+	OperatorID    uint   `json:"operator_id"`
+	Operator      string `json:"operator"`
+	Action        string `json:"action"`
+	Message       string `json:"message"`
+	Method        string `json:"method"`
+	Path          string `json:"path"`
+	Params        string `json:"params"`
+	Machines      string `json:"machines"`
+	ClientIP      string `json:"client_ip"`
+	Code          int    `json:"code"`
+	Duration      int64  `json:"duration"`
+	// unix时间戳，单位秒
+	CreatedAt int64 `json:"created_at"`
+}
+
+// 日志参与哈希计算的内容
+func (p *AuditLog) HashContent() string {
+	bs, _ := json.Marshal(&AuditHashContent{
+		Seq:           p.Seq,
+		PrevHash:      p.PrevHash,
+		LogUUID:       p.LogUUID,
+		ParentLogUUID: p.ParentLogUUID,
+		AgentUUID:     p.AgentUUID,
+		Module:        p.Module,
+		Status:        p.Status,
+		OperatorID:    p.OperatorID,
+		Operator:      p.Operator,
+		Action:        p.Action,
+		Message:       p.Message,
+		Method:        p.Method,
+		Path:          p.Path,
+		Params:        p.Params,
+		Machines:      p.Machines,
+		ClientIP:      p.ClientIP,
+		Code:          p.Code,
+		Duration:      p.Duration,
+		CreatedAt:     p.CreatedAt.Unix(),
+	})
+	return string(bs)
+}
+
+func (p *AuditLog) ComputeHash() string {
+	sum := sha256.Sum256([]byte(p.HashContent()))
+	return hex.EncodeToString(sum[:])
+}
+
+// 将日志加入哈希链并保存，链接到当前最后一条已加入的日志
+func (p *AuditLog) Seal() error {
+	auditChainMutex.Lock()
+	defer auditChainMutex.Unlock()
+
+	return mysqlmanager.MySQL().Transaction(func(tx *gorm.DB) error {
+		if p.ID != 0 {
+			var cur AuditLog
+			if err := tx.Select("seq").Where("id = ?", p.ID).First(&cur).Error; err == nil && cur.Seq != 0 {
+				return ErrAuditLogSealed
+			}
+		}
+		var last AuditLog
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("seq > ?", 0).
+			Order("seq desc").Limit(1).Find(&last).Error
+		if err != nil {
+			return err
+		}
+
+		sealed := *p
+		sealed.Seq = last.Seq + 1
+		sealed.PrevHash = last.Hash
+		if sealed.CreatedAt.IsZero() {
+			sealed.CreatedAt = time.Now()
+		}
+		// 数据库中的时间精度可能低于秒，按秒保存以免哈希不一致
+		sealed.CreatedAt = sealed.CreatedAt.Truncate(time.Second)
+		sealed.Hash = sealed.ComputeHash()
+		if err := tx.Save(&sealed).Error; err != nil {
+			return err
+		}
+		*p = sealed
+		return nil
+	})
+}
+
+// 指定时间之前创建且未加入哈希链的日志
+func UnsealedAuditLogs(before time.Time) ([]AuditLog, error) {
+	var list []AuditLog
+	err := mysqlmanager.MySQL().Where("seq = ? AND created_at < ?", 0, before).Order("id").Find(&list).Error
+	return list, err
+}
+
+func CountUnsealedAuditLogs() (int64, error) {
+	var count int64
+	err := mysqlmanager.MySQL().Model(&AuditLog{}).Where("seq = ?", 0).Count(&count).Error
+	return count, err
+}
+
+// 按序号顺序获取已加入哈希链的日志
+func SealedAuditLogs(fromSeq, toSeq uint64, limit int) ([]AuditLog, error) {
+	var list []AuditLog
+	tx := mysqlmanager.MySQL().Where("seq >= ?", fromSeq).Order("seq, id").Limit(limit)
+	if toSeq != 0 {
+		tx = tx.Where("seq <= ?", toSeq)
+	}
+	err := tx.Find(&list).Error
+	return list, err
+}
+
+func AuditLogBySeq(seq uint64) (*AuditLog, error) {
+	var log AuditLog
+	err := mysqlmanager.MySQL().Where("seq = ?", seq).First(&log).Error
+	return &log, err
+}
+
+func LastSealedAuditLog() (*AuditLog, error) {
+	var log AuditLog
+	err := mysqlmanager.MySQL().Where("seq > ?", 0).Order("seq desc").Limit(1).Find(&log).Error
+	return &log, err
+}
+
+// 审计日志的签名检查点，记录某一时刻哈希链的最后一条日志
+type AuditCheckpoint struct {
+	ID   uint   `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	Seq  uint64 `gorm:"index" json:"seq"`
+	Hash string `gorm:"type:varchar(64)" json:"hash"`
+	// 签名时间，unix时间戳
+	Timestamp int64 `json:"timestamp"`
+	// 对Payload的ed25519签名，base64编码
+	Signature string `gorm:"type:varchar(255)" json:"signature"`
+	// 签名公钥的id
+	KeyID     string    `gorm:"type:varchar(16)" json:"key_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// 检查点签名的内容
+func (c *AuditCheckpoint) Payload() string {
+	return fmt.Sprintf("pilotgo-audit-checkpoint\nseq=%d\nhash=%s\ntimestamp=%d", c.Seq, c.Hash, c.Timestamp)
+}
+
+// 为未记录公钥id的检查点补充公钥id
+func SetAuditCheckpointKeyID(keyID string) error {
+	return mysqlmanager.MySQL().Model(&AuditCheckpoint{}).Where("key_id = ? OR key_id IS NULL", "").Update("key_id", keyID).Error
+}
+
+func AddAuditCheckpoint(c *AuditCheckpoint) error {
+	return mysqlmanager.MySQL().Create(c).Error
+}
+
+func LastAuditCheckpoint() (*AuditCheckpoint, error) {
+	var c AuditCheckpoint
+	err := mysqlmanager.MySQL().Order("seq desc").Limit(1).Find(&c).Error
+	return &c, err
+}
+
+func AuditCheckpointList() (*[]AuditCheckpoint, *gorm.DB) {
+	list := &[]AuditCheckpoint{}
+	tx := mysqlmanager.MySQL().Model(&AuditCheckpoint{}).Order("seq desc").Find(list)
+	return list, tx
+}
+
+// 序号在范围内的检查点，toSeq为0时不限制上限
+func AuditCheckpoints(fromSeq, toSeq uint64) ([]AuditCheckpoint, error) {
+	var list []AuditCheckpoint
+	tx := mysqlmanager.MySQL().Where("seq >= ?", fromSeq).Order("seq")
+	if toSeq != 0 {
+		tx = tx.Where("seq <= ?", toSeq)
+	}
+	err := tx.Find(&list).Error
+	return list, err
+}
@@ -31,6 +31,11 @@ type CrontabList struct {
 	CronSpec    string `json:"spec"`
 	Command     string `json:"cmd"`
 	Status      *bool  `json:"status"`
+	// 以逗号分隔的目标批次及部门id，MachineUUID为空时按批次及部门下发
+	BatchIDs  string `json:"batch_ids"`
+	DepartIDs string `json:"depart_ids"`
+	// 最后保存任务的用户，对账时以其身份进行命令策略检查
+	UserName string `json:"userName"`
 }
 
 type CrontabUpdate struct {
@@ -41,6 +46,11 @@ type CrontabUpdate struct {
 	CronSpec    string `json:"spec"`
 	Command     string `json:"cmd"`
 	Status      bool   `json:"status"`
+	BatchIDs    []int  `json:"batch_ids"`
+	DepartIDs   []int  `json:"depart_ids"`
+	UserName    string `json:"userName"`
+	// 危险命令的确认码
+	Confirm string `json:"confirm"`
 }
 
 type DelCrons struct {
@@ -103,3 +113,84 @@ func Id2CronInfo(id int) (spec, command string, err error) {
 	err = mysqlmanager.MySQL().Where("id =?", id).Find(&cron).Error
 	return cron.CronSpec, cron.Command, err
 }
+
+// 获取机器上所有已开启的任务
+func EnabledCrons(uuid string) ([]CrontabList, error) {
+	var list []CrontabList
+	err := mysqlmanager.MySQL().Where("machine_uuid = ? AND status = ?", uuid, true).Find(&list).Error
+	return list, err
+}
+
+// 获取所有已开启的按批次或部门下发的任务
+func EnabledGroupCrons() ([]CrontabList, error) {
+	var list []CrontabList
+	err := mysqlmanager.MySQL().Where("machine_uuid = ? AND status = ?", "", true).Find(&list).Error
+	return list, err
+}
+
+// 定时任务的单次执行记录
+type CronRun struct {
+	ID          uint      `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	CronID      int       `gorm:"index" json:"cron_id"`
+	MachineUUID string    `gorm:"type:varchar(50);index" json:"uuid"`
+	Trigger     string    `gorm:"type:varchar(20)" json:"trigger"`
+	StartTime   time.Time `json:"start_time"`
+	EndTime     time.Time `json:"end_time"`
+	ExitCode    int       `json:"exit_code"`
+	Success     bool      `gorm:"index" json:"success"`
+	Stdout      string    `gorm:"type:text" json:"stdout"`
+	Stderr      string    `gorm:"type:text" json:"stderr"`
+	Error       string    `gorm:"type:text" json:"error"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type CronRunFilter struct {
+	CronID      int
+	MachineUUID string
+	// 为true时只查询失败的记录
+	FailedOnly bool
+}
+
+func AddCronRun(r *CronRun) error {
+	return mysqlmanager.MySQL().Create(r).Error
+}
+
+func QueryCronRuns(f *CronRunFilter) (*[]CronRun, *gorm.DB) {
+	list := &[]CronRun{}
+	tx := mysqlmanager.MySQL().Model(&CronRun{}).Order("start_time desc")
+	if f.CronID != 0 {
+		tx = tx.Where("cron_id = ?", f.CronID)
+	}
+	if f.MachineUUID != "" {
+		tx = tx.Where("machine_uuid = ?", f.MachineUUID)
+	}
+	if f.FailedOnly {
+		tx = tx.Where("success = ?", false)
+	}
+	tx = tx.Find(list)
+	return list, tx
+}
+
+// 根据任务id获取任务
+func GetCron(id int) (*CrontabList, error) {
+	var cron CrontabList
+	err := mysqlmanager.MySQL().Where("id = ?", id).First(&cron).Error
+	return &cron, err
+}
+
+// 单台机器上任务的执行统计
+type CronRunSummary struct {
+	MachineUUID string    `json:"uuid"`
+	Total       int       `json:"total"`
+	Failed      int       `json:"failed"`
+	LastRun     time.Time `json:"last_run"`
+}
+
+// 按机器汇总任务的执行记录
+func SummarizeCronRuns(cronID int) ([]CronRunSummary, error) {
+	var list []CronRunSummary
+	err := mysqlmanager.MySQL().Model(&CronRun{}).
+		Select("machine_uuid, count(*) as total, sum(case when success then 0 else 1 end) as failed, max(start_time) as last_run").
+		Where("cron_id = ?", cronID).Group("machine_uuid").Order("machine_uuid").Scan(&list).Error
+	return list, err
+}
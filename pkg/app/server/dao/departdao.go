@@ -137,6 +137,13 @@ func DepartIdToGetDepartName(id int) (string, error) {
 	return departNames.Depart, err
 }
 
+// 根据部门id获取部门信息
+func GetDepartNode(id int) (DepartNode, error) {
+	var depart DepartNode
+	err := mysqlmanager.MySQL().Where("id = ?", id).First(&depart).Error
+	return depart, err
+}
+
 // 根据部门ids查询所属部门
 func DepartIdsToGetDepartNames(ids []int) (names []string) {
 	for _, id := range ids {
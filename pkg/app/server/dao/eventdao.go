@@ -0,0 +1,98 @@
+package dao
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"openeuler.org/PilotGo/PilotGo/pkg/dbmanager/mysqlmanager"
+)
+
+// 持久化的事件记录，ID即为事件的全局递增序列号
+type EventRecord struct {
+	ID          uint      `gorm:"primary_key;AUTO_INCREMENT" json:"sequence"`
+	MessageType int       `gorm:"index" json:"message_type"`
+	MachineUUID string    `gorm:"type:varchar(50);index" json:"machine_uuid"`
+	MessageData string    `gorm:"type:text" json:"message_data"`
+	CreatedAt   time.Time `gorm:"index" json:"created_at"`
+}
+
+// 插件已确认接收的最后一个事件序列号
+type EventAck struct {
+	ID        int       `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	Listener  string    `gorm:"type:varchar(100);uniqueIndex" json:"listener"`
+	Sequence  uint      `json:"sequence"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type EventFilter struct {
+	MessageType *int
+	MachineUUID string
+	Start       time.Time
+	End         time.Time
+	// 非nil时只查询这些机器及与机器无关的事件
+	Machines []string
+}
+
+// 存储事件
+func RecordEvent(e *EventRecord) error {
+	return mysqlmanager.MySQL().Create(e).Error
+}
+
+// 按事件类型、机器、时间范围查询事件
+func QueryEvents(f *EventFilter) (list *[]EventRecord, tx *gorm.DB) {
+	list = &[]EventRecord{}
+	tx = mysqlmanager.MySQL().Model(&EventRecord{}).Order("id asc")
+	if f.MessageType != nil {
+		tx = tx.Where("message_type = ?", *f.MessageType)
+	}
+	if f.MachineUUID != "" {
+		tx = tx.Where("machine_uuid = ?", f.MachineUUID)
+	}
+	if f.Machines != nil {
+		tx = tx.Where("machine_uuid IN ? OR machine_uuid = ?", f.Machines, "")
+	}
+	if !f.Start.IsZero() {
+		tx = tx.Where("created_at >= ?", f.Start)
+	}
+	if !f.End.IsZero() {
+		tx = tx.Where("created_at <= ?", f.End)
+	}
+	return
+}
+
+// 查询序列号之后的事件，machines非nil时只查询这些机器及与机器无关的事件
+func EventsAfter(sequence uint, limit int, machines []string) ([]EventRecord, error) {
+	var list []EventRecord
+	tx := mysqlmanager.MySQL().Where("id > ?", sequence)
+	if machines != nil {
+		tx = tx.Where("machine_uuid IN ? OR machine_uuid = ?", machines, "")
+	}
+	err := tx.Order("id asc").Limit(limit).Find(&list).Error
+	return list, err
+}
+
+// 删除指定时间之前的事件
+func DeleteEventsBefore(t time.Time) (int64, error) {
+	tx := mysqlmanager.MySQL().Where("created_at < ?", t).Delete(&EventRecord{})
+	return tx.RowsAffected, tx.Error
+}
+
+// 获取插件已确认的序列号
+func GetEventAck(listener string) (uint, error) {
+	var ack EventAck
+	err := mysqlmanager.MySQL().Where("listener = ?", listener).Find(&ack).Error
+	return ack.Sequence, err
+}
+
+// 更新插件已确认的序列号
+func UpdateEventAck(listener string, sequence uint) error {
+	ack := &EventAck{
+		Listener: listener,
+		Sequence: sequence,
+	}
+	return mysqlmanager.MySQL().Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "listener"}},
+		DoUpdates: clause.AssignmentColumns([]string{"sequence", "updated_at"}),
+	}).Create(ack).Error
+}
@@ -34,6 +34,16 @@ type Files struct {
 	ControlledBatch string `json:"batchId"`
 	TakeEffect      string `json:"activeMode"`
 	File            string `gorm:"type:text" json:"file"`
+	// 是否按text/template对每台机器渲染后下发
+	Template bool `json:"template"`
+	// 安装前对文件执行的校验命令，{file}代表待安装文件
+	ValidateCmd string `gorm:"type:text" json:"validate"`
+	// 安装后的生效方式，reload、restart、command或为空
+	ActivateAction string `gorm:"type:varchar(20)" json:"activate"`
+	// 生效的服务名或命令
+	ActivateTarget string `gorm:"type:text" json:"activate_target"`
+	// 校验及生效命令为危险命令时的确认码
+	Confirm string `gorm:"-" json:"confirm"`
 }
 
 type HistoryFiles struct {
@@ -137,6 +147,16 @@ func UpdateFile(id int, f Files) error {
 	return mysqlmanager.MySQL().Model(&file).Where("id = ?", id).Updates(&f).Error
 }
 
+// 更新文件的模板及安装设置，Updates会忽略零值，因此单独更新以支持清空
+func UpdateFileSettings(id int, f Files) error {
+	return mysqlmanager.MySQL().Model(&Files{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"template":        f.Template,
+		"validate_cmd":    f.ValidateCmd,
+		"activate_action": f.ActivateAction,
+		"activate_target": f.ActivateTarget,
+	}).Error
+}
+
 func UpdateLastFile(id int, f HistoryFiles) error {
 	var file HistoryFiles
 	return mysqlmanager.MySQL().Model(&file).Where("id = ?", id).Updates(&f).Error
@@ -169,6 +189,25 @@ func FileText(id int) (text string, err error) {
 	return file.File, err
 }
 
+func GetFile(id int) (*Files, error) {
+	file := Files{}
+	err := mysqlmanager.MySQL().Where("id = ?", id).First(&file).Error
+	return &file, err
+}
+
+// 获取设置了管控批次的文件
+func ControlledFiles() ([]Files, error) {
+	var files []Files
+	err := mysqlmanager.MySQL().Where("controlled_batch <> ?", "").Find(&files).Error
+	return files, err
+}
+
+func GetHistoryFile(id int) (*HistoryFiles, error) {
+	file := HistoryFiles{}
+	err := mysqlmanager.MySQL().Where("id = ?", id).First(&file).Error
+	return &file, err
+}
+
 func LastFileText(id int) (text string, err error) {
 	file := HistoryFiles{}
 	err = mysqlmanager.MySQL().Where("id = ?", id).Find(&file).Error
@@ -0,0 +1,72 @@
+package dao
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"openeuler.org/PilotGo/PilotGo/pkg/dbmanager/mysqlmanager"
+)
+
+// 受管配置文件在单台机器上最近一次的漂移检查结果
+type FileDrift struct {
+	ID          uint   `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	FileID      int    `gorm:"index" json:"file_id"`
+	MachineUUID string `gorm:"type:varchar(100);index" json:"uuid"`
+	IP          string `gorm:"type:varchar(100)" json:"ip"`
+	// consistent、drifted、missing、offline或error
+	State        string    `gorm:"type:varchar(20);index" json:"state"`
+	ExpectedHash string    `gorm:"type:varchar(64)" json:"expected_hash"`
+	ActualHash   string    `gorm:"type:varchar(64)" json:"actual_hash"`
+	Diff         string    `gorm:"type:longtext" json:"diff"`
+	Error        string    `gorm:"type:text" json:"error"`
+	CheckedAt    time.Time `json:"checked_at"`
+}
+
+type FileDriftFilter struct {
+	FileID      int
+	MachineUUID string
+	State       string
+}
+
+// 以本次检查结果替换文件的检查记录，uuids不为空时只替换这些机器的记录
+func ReplaceFileDrifts(fileID int, uuids []string, list []FileDrift) error {
+	return mysqlmanager.MySQL().Transaction(func(tx *gorm.DB) error {
+		del := tx.Where("file_id = ?", fileID)
+		if len(uuids) > 0 {
+			del = del.Where("machine_uuid IN ?", uuids)
+		}
+		if err := del.Delete(&FileDrift{}).Error; err != nil {
+			return err
+		}
+		if len(list) == 0 {
+			return nil
+		}
+		return tx.Create(&list).Error
+	})
+}
+
+func DeleteFileDrifts(fileID int) error {
+	return mysqlmanager.MySQL().Where("file_id = ?", fileID).Delete(&FileDrift{}).Error
+}
+
+func GetFileDrift(fileID int, uuid string) (*FileDrift, error) {
+	var d FileDrift
+	err := mysqlmanager.MySQL().Where("file_id = ? AND machine_uuid = ?", fileID, uuid).First(&d).Error
+	return &d, err
+}
+
+func QueryFileDrifts(f *FileDriftFilter) (*[]FileDrift, *gorm.DB) {
+	list := &[]FileDrift{}
+	tx := mysqlmanager.MySQL().Model(&FileDrift{}).Order("file_id, machine_uuid")
+	if f.FileID != 0 {
+		tx = tx.Where("file_id = ?", f.FileID)
+	}
+	if f.MachineUUID != "" {
+		tx = tx.Where("machine_uuid = ?", f.MachineUUID)
+	}
+	if f.State != "" {
+		tx = tx.Where("state = ?", f.State)
+	}
+	tx = tx.Find(list)
+	return list, tx
+}
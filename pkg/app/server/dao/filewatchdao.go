@@ -0,0 +1,106 @@
+package dao
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"openeuler.org/PilotGo/PilotGo/pkg/dbmanager/mysqlmanager"
+)
+
+// 文件监控规则，按机器、批次或部门下发到agent
+type FileWatch struct {
+	ID   uint   `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	Name string `gorm:"type:varchar(100)" json:"name"`
+	// 作用范围，machine、batch或depart
+	Scope string `gorm:"type:varchar(20)" json:"scope"`
+	// 机器uuid、批次id或部门id
+	Target    string `gorm:"type:varchar(100)" json:"target"`
+	Path      string `gorm:"type:varchar(255)" json:"path"`
+	Recursive bool   `json:"recursive"`
+	// 以逗号分隔的文件名glob
+	Include string `gorm:"type:varchar(255)" json:"include"`
+	Exclude string `gorm:"type:varchar(255)" json:"exclude"`
+	// 以逗号分隔的监控操作，为空时监控所有操作
+	Ops         string    `gorm:"type:varchar(100)" json:"ops"`
+	Enabled     bool      `json:"enabled"`
+	Description string    `gorm:"type:text" json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func AddFileWatch(w *FileWatch) error {
+	return mysqlmanager.MySQL().Create(w).Error
+}
+
+func UpdateFileWatch(w *FileWatch) error {
+	return mysqlmanager.MySQL().Save(w).Error
+}
+
+func DeleteFileWatches(ids []uint) error {
+	return mysqlmanager.MySQL().Where("id IN ?", ids).Delete(&FileWatch{}).Error
+}
+
+func GetFileWatch(id uint) (*FileWatch, error) {
+	var w FileWatch
+	err := mysqlmanager.MySQL().Where("id = ?", id).First(&w).Error
+	return &w, err
+}
+
+func FileWatchList() (*[]FileWatch, *gorm.DB) {
+	list := &[]FileWatch{}
+	tx := mysqlmanager.MySQL().Model(&FileWatch{}).Order("id desc").Find(list)
+	return list, tx
+}
+
+func EnabledFileWatches() ([]FileWatch, error) {
+	var list []FileWatch
+	err := mysqlmanager.MySQL().Where("enabled = ?", true).Order("id").Find(&list).Error
+	return list, err
+}
+
+// agent上报的文件变化事件
+type FileWatchEvent struct {
+	ID          uint   `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	MachineUUID string `gorm:"type:varchar(100);index" json:"uuid"`
+	IP          string `gorm:"type:varchar(100)" json:"ip"`
+	Rule        string `gorm:"type:varchar(255)" json:"rule"`
+	Path        string `gorm:"type:varchar(255);index" json:"path"`
+	Op          string `gorm:"type:varchar(20)" json:"op"`
+	Size        int64  `json:"size"`
+	Hash        string `gorm:"type:varchar(64)" json:"hash"`
+	UID         int    `json:"uid"`
+	Diff        string `gorm:"type:longtext" json:"diff"`
+	// agent上事件发生的时间
+	Time time.Time `gorm:"index" json:"time"`
+}
+
+type FileWatchEventFilter struct {
+	MachineUUID string
+	Path        string
+	Op          string
+}
+
+func AddFileWatchEvent(e *FileWatchEvent) error {
+	return mysqlmanager.MySQL().Create(e).Error
+}
+
+func QueryFileWatchEvents(f *FileWatchEventFilter) (*[]FileWatchEvent, *gorm.DB) {
+	list := &[]FileWatchEvent{}
+	tx := mysqlmanager.MySQL().Model(&FileWatchEvent{}).Order("id desc")
+	if f.MachineUUID != "" {
+		tx = tx.Where("machine_uuid = ?", f.MachineUUID)
+	}
+	if f.Path != "" {
+		tx = tx.Where("path LIKE ?", "%"+f.Path+"%")
+	}
+	if f.Op != "" {
+		tx = tx.Where("op = ?", f.Op)
+	}
+	tx = tx.Find(list)
+	return list, tx
+}
+
+// 清理指定时间之前的文件变化事件
+func DeleteFileWatchEventsBefore(t time.Time) error {
+	return mysqlmanager.MySQL().Where("time < ?", t).Delete(&FileWatchEvent{}).Error
+}
@@ -0,0 +1,269 @@
+package dao
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"openeuler.org/PilotGo/PilotGo/pkg/dbmanager/mysqlmanager"
+)
+
+// 文件完整性检查配置
+type IntegrityProfile struct {
+	ID   uint   `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	Name string `gorm:"type:varchar(100);uniqueIndex" json:"name"`
+	// 以逗号分隔的检查路径，如"/etc,/usr/bin"
+	Paths   string `gorm:"type:text" json:"paths"`
+	Exclude string `gorm:"type:text" json:"exclude"`
+	// 以逗号分隔的机器uuid、批次id及部门id
+	UUIDs     string `gorm:"type:text" json:"uuids"`
+	BatchIDs  string `gorm:"type:varchar(255)" json:"batch_ids"`
+	DepartIDs string `gorm:"type:varchar(255)" json:"depart_ids"`
+	// 定期检查的cron表达式，为空时只手动检查
+	Spec        string     `gorm:"type:varchar(100)" json:"spec"`
+	Enabled     bool       `gorm:"index" json:"enabled"`
+	NextScanAt  *time.Time `json:"next_scan_at"`
+	LastScanAt  *time.Time `json:"last_scan_at"`
+	Description string     `gorm:"type:text" json:"description"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func AddIntegrityProfile(p *IntegrityProfile) error {
+	return mysqlmanager.MySQL().Create(p).Error
+}
+
+func UpdateIntegrityProfile(p *IntegrityProfile) error {
+	return mysqlmanager.MySQL().Save(p).Error
+}
+
+func GetIntegrityProfile(id uint) (*IntegrityProfile, error) {
+	var p IntegrityProfile
+	err := mysqlmanager.MySQL().Where("id = ?", id).First(&p).Error
+	return &p, err
+}
+
+func IntegrityProfileList() (*[]IntegrityProfile, *gorm.DB) {
+	list := &[]IntegrityProfile{}
+	tx := mysqlmanager.MySQL().Model(&IntegrityProfile{}).Order("id desc").Find(list)
+	return list, tx
+}
+
+// 获取已到定期检查时间的配置
+func DueIntegrityProfiles(now time.Time) ([]IntegrityProfile, error) {
+	var list []IntegrityProfile
+	err := mysqlmanager.MySQL().Where("enabled = ? AND spec <> ? AND next_scan_at <= ?", true, "", now).
+		Find(&list).Error
+	return list, err
+}
+
+// 删除配置及其基线、检查结果
+func DeleteIntegrityProfiles(ids []uint) error {
+	return mysqlmanager.MySQL().Transaction(func(tx *gorm.DB) error {
+		for _, m := range []interface{}{&IntegrityBaseline{}, &IntegrityChange{}, &IntegrityScan{}} {
+			if err := tx.Where("profile_id IN ?", ids).Delete(m).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Where("id IN ?", ids).Delete(&IntegrityProfile{}).Error
+	})
+}
+
+// 单台机器上单个文件的基线
+type IntegrityBaseline struct {
+	ID          uint      `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	ProfileID   uint      `gorm:"index:idx_integrity_baseline" json:"profile_id"`
+	MachineUUID string    `gorm:"type:varchar(100);index:idx_integrity_baseline" json:"uuid"`
+	Path        string    `gorm:"type:varchar(1024)" json:"path"`
+	Hash        string    `gorm:"type:varchar(64)" json:"hash"`
+	Link        string    `gorm:"type:varchar(1024)" json:"link"`
+	Mode        string    `gorm:"type:varchar(20)" json:"mode"`
+	UID         int       `json:"uid"`
+	GID         int       `json:"gid"`
+	Size        int64     `json:"size"`
+	ModTime     time.Time `json:"mtime"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func IntegrityBaselines(profileID uint, uuid string) ([]IntegrityBaseline, error) {
+	var list []IntegrityBaseline
+	err := mysqlmanager.MySQL().Where("profile_id = ? AND machine_uuid = ?", profileID, uuid).Find(&list).Error
+	return list, err
+}
+
+func CountIntegrityBaselines(profileID uint, uuid string) (int64, error) {
+	var count int64
+	err := mysqlmanager.MySQL().Model(&IntegrityBaseline{}).
+		Where("profile_id = ? AND machine_uuid = ?", profileID, uuid).Count(&count).Error
+	return count, err
+}
+
+// 以新的基线替换机器的基线，并清除已有的变化记录
+func ReplaceIntegrityBaselines(profileID uint, uuid string, list []IntegrityBaseline) error {
+	return mysqlmanager.MySQL().Transaction(func(tx *gorm.DB) error {
+		for _, m := range []interface{}{&IntegrityBaseline{}, &IntegrityChange{}} {
+			if err := tx.Where("profile_id = ? AND machine_uuid = ?", profileID, uuid).Delete(m).Error; err != nil {
+				return err
+			}
+		}
+		if len(list) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(&list, 500).Error
+	})
+}
+
+// 接受变化，将基线更新为变化后的状态并删除变化记录
+func AcceptIntegrityChanges(changes []IntegrityChange) error {
+	return mysqlmanager.MySQL().Transaction(func(tx *gorm.DB) error {
+		for _, c := range changes {
+			err := tx.Where("profile_id = ? AND machine_uuid = ? AND path = ?", c.ProfileID, c.MachineUUID, c.Path).
+				Delete(&IntegrityBaseline{}).Error
+			if err != nil {
+				return err
+			}
+			if c.Change != "removed" {
+				b := c.NewBaseline()
+				if err := tx.Create(b).Error; err != nil {
+					return err
+				}
+			}
+			if err := tx.Delete(&IntegrityChange{}, c.ID).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// 相对基线的文件变化，Change为added、removed或modified
+type IntegrityChange struct {
+	ID          uint   `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	ProfileID   uint   `gorm:"index:idx_integrity_change" json:"profile_id"`
+	MachineUUID string `gorm:"type:varchar(100);index:idx_integrity_change" json:"uuid"`
+	IP          string `gorm:"type:varchar(100)" json:"ip"`
+	Path        string `gorm:"type:varchar(1024)" json:"path"`
+	Change      string `gorm:"column:change_type;type:varchar(20)" json:"change"`
+	// 以逗号分隔的变化项，hash、link、mode、owner、size或mtime
+	Fields     string     `gorm:"type:varchar(100)" json:"fields"`
+	OldHash    string     `gorm:"type:varchar(64)" json:"old_hash"`
+	NewHash    string     `gorm:"type:varchar(64)" json:"new_hash"`
+	OldLink    string     `gorm:"type:varchar(1024)" json:"old_link"`
+	NewLink    string     `gorm:"type:varchar(1024)" json:"new_link"`
+	OldMode    string     `gorm:"type:varchar(20)" json:"old_mode"`
+	NewMode    string     `gorm:"type:varchar(20)" json:"new_mode"`
+	OldUID     int        `json:"old_uid"`
+	NewUID     int        `json:"new_uid"`
+	OldGID     int        `json:"old_gid"`
+	NewGID     int        `json:"new_gid"`
+	OldSize    int64      `json:"old_size"`
+	NewSize    int64      `json:"new_size"`
+	OldModTime *time.Time `json:"old_mtime"`
+	NewModTime *time.Time `json:"new_mtime"`
+	DetectedAt time.Time  `json:"detected_at"`
+}
+
+// 变化后的文件状态作为新的基线
+func (c *IntegrityChange) NewBaseline() *IntegrityBaseline {
+	b := &IntegrityBaseline{
+		ProfileID:   c.ProfileID,
+		MachineUUID: c.MachineUUID,
+		Path:        c.Path,
+		Hash:        c.NewHash,
+		Link:        c.NewLink,
+		Mode:        c.NewMode,
+		UID:         c.NewUID,
+		GID:         c.NewGID,
+		Size:        c.NewSize,
+	}
+	if c.NewModTime != nil {
+		b.ModTime = *c.NewModTime
+	}
+	return b
+}
+
+type IntegrityChangeFilter struct {
+	ProfileID   uint
+	MachineUUID string
+	Change      string
+	IDs         []uint
+}
+
+func QueryIntegrityChanges(f *IntegrityChangeFilter) (*[]IntegrityChange, *gorm.DB) {
+	list := &[]IntegrityChange{}
+	tx := mysqlmanager.MySQL().Model(&IntegrityChange{}).Order("profile_id, machine_uuid, path")
+	if f.ProfileID != 0 {
+		tx = tx.Where("profile_id = ?", f.ProfileID)
+	}
+	if f.MachineUUID != "" {
+		tx = tx.Where("machine_uuid = ?", f.MachineUUID)
+	}
+	if f.Change != "" {
+		tx = tx.Where("change_type = ?", f.Change)
+	}
+	if len(f.IDs) > 0 {
+		tx = tx.Where("id IN ?", f.IDs)
+	}
+	tx = tx.Find(list)
+	return list, tx
+}
+
+// 机器最近一次完整性检查的结果
+type IntegrityScan struct {
+	ID          uint   `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	ProfileID   uint   `gorm:"index" json:"profile_id"`
+	MachineUUID string `gorm:"type:varchar(100);index" json:"uuid"`
+	IP          string `gorm:"type:varchar(100)" json:"ip"`
+	// baseline、intact、changed、no_baseline、offline或error
+	State     string    `gorm:"type:varchar(20);index" json:"state"`
+	Files     int       `json:"files"`
+	Added     int       `json:"added"`
+	Removed   int       `json:"removed"`
+	Modified  int       `json:"modified"`
+	Error     string    `gorm:"type:text" json:"error"`
+	ScannedAt time.Time `json:"scanned_at"`
+}
+
+// 保存检查结果，检查成功时以本次发现的变化替换机器已有的变化记录
+func SaveIntegrityScan(s *IntegrityScan, changes []IntegrityChange, replaceChanges bool) error {
+	return mysqlmanager.MySQL().Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("profile_id = ? AND machine_uuid = ?", s.ProfileID, s.MachineUUID).
+			Delete(&IntegrityScan{}).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(s).Error; err != nil {
+			return err
+		}
+		if !replaceChanges {
+			return nil
+		}
+		err = tx.Where("profile_id = ? AND machine_uuid = ?", s.ProfileID, s.MachineUUID).
+			Delete(&IntegrityChange{}).Error
+		if err != nil || len(changes) == 0 {
+			return err
+		}
+		return tx.CreateInBatches(&changes, 500).Error
+	})
+}
+
+type IntegrityScanFilter struct {
+	ProfileID   uint
+	MachineUUID string
+	State       string
+}
+
+func QueryIntegrityScans(f *IntegrityScanFilter) (*[]IntegrityScan, *gorm.DB) {
+	list := &[]IntegrityScan{}
+	tx := mysqlmanager.MySQL().Model(&IntegrityScan{}).Order("profile_id, machine_uuid")
+	if f.ProfileID != 0 {
+		tx = tx.Where("profile_id = ?", f.ProfileID)
+	}
+	if f.MachineUUID != "" {
+		tx = tx.Where("machine_uuid = ?", f.MachineUUID)
+	}
+	if f.State != "" {
+		tx = tx.Where("state = ?", f.State)
+	}
+	tx = tx.Find(list)
+	return list, tx
+}
@@ -212,6 +212,13 @@ func MachineBasic(uuid string) (ip string, state int, dept string, err error) {
 	return machine.IP, machine.State, depart.Depart, err
 }
 
+// 根据uuid获取机器信息
+func MachineByUUID(uuid string) (MachineNode, error) {
+	var machine MachineNode
+	err := mysqlmanager.MySQL().Where("machine_uuid = ?", uuid).First(&machine).Error
+	return machine, err
+}
+
 // 使用uuid删除机器
 func DeleteMachine(machinedeluuid string) (err error) {
 	var machine MachineNode
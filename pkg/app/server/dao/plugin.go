@@ -27,6 +27,7 @@ type PluginModel struct {
 	Url         string `gorm:"type:varchar(200)"`
 	PluginType  string `gorm:"type:varchar(50)"`
 	Enabled     int    `gorm:"type:int"`
+	Timeout     int    `gorm:"type:int"`
 }
 
 func (m *PluginModel) TableName() string {
@@ -54,6 +55,19 @@ func UpdatePluginEnabled(plugin *PluginModel) error {
 	return err
 }
 
+// 更新插件基本信息
+func UpdatePluginInfo(plugin *PluginModel) error {
+	var p PluginModel
+	return mysqlmanager.MySQL().Model(&p).Where("uuid = ?", plugin.UUID).Updates(map[string]interface{}{
+		"version":     plugin.Version,
+		"description": plugin.Description,
+		"author":      plugin.Author,
+		"email":       plugin.Email,
+		"url":         plugin.Url,
+		"plugin_type": plugin.PluginType,
+	}).Error
+}
+
 // 删除插件
 func DeletePlugin(uuid string) error {
 	err := mysqlmanager.MySQL().Where("uuid=?", uuid).Delete(&PluginModel{}).Error
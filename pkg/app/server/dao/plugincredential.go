@@ -0,0 +1,67 @@
+package dao
+
+import (
+	"time"
+
+	"openeuler.org/PilotGo/PilotGo/pkg/dbmanager/mysqlmanager"
+)
+
+// 插件调用平台接口的凭证及授权范围
+type PluginCredential struct {
+	ID         int    `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	PluginUUID string `gorm:"type:varchar(50);uniqueIndex" json:"plugin_uuid"`
+	PluginName string `gorm:"type:varchar(100);index" json:"plugin_name"`
+	// token或hmac
+	Type string `gorm:"type:varchar(20)" json:"type"`
+	// token方式只保存sha256，hmac方式验签需要密钥原文
+	Secret     string `gorm:"type:varchar(100)" json:"-"`
+	SecretHash string `gorm:"type:varchar(64);index" json:"-"`
+	// 以逗号分隔的接口、部门id、批次id列表
+	Endpoints   string    `gorm:"type:text" json:"endpoints"`
+	Departments string    `gorm:"type:text" json:"departments"`
+	Batches     string    `gorm:"type:text" json:"batches"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (c *PluginCredential) TableName() string {
+	return "plugin_credential"
+}
+
+// 保存插件凭证，已存在时覆盖
+func SavePluginCredential(c *PluginCredential) error {
+	var old PluginCredential
+	if err := mysqlmanager.MySQL().Where("plugin_uuid = ?", c.PluginUUID).Find(&old).Error; err != nil {
+		return err
+	}
+	c.ID = old.ID
+	return mysqlmanager.MySQL().Save(c).Error
+}
+
+func GetPluginCredential(pluginUUID string) (*PluginCredential, error) {
+	var c PluginCredential
+	err := mysqlmanager.MySQL().Where("plugin_uuid = ?", pluginUUID).Find(&c).Error
+	return &c, err
+}
+
+func GetPluginCredentialBySecretHash(credType, hash string) (*PluginCredential, error) {
+	var c PluginCredential
+	err := mysqlmanager.MySQL().Where("type = ? AND secret_hash = ?", credType, hash).Find(&c).Error
+	return &c, err
+}
+
+func GetPluginCredentialByName(name string) (*PluginCredential, error) {
+	var c PluginCredential
+	err := mysqlmanager.MySQL().Where("plugin_name = ?", name).Find(&c).Error
+	return &c, err
+}
+
+func PluginCredentials() ([]PluginCredential, error) {
+	var list []PluginCredential
+	err := mysqlmanager.MySQL().Find(&list).Error
+	return list, err
+}
+
+func DeletePluginCredential(pluginUUID string) error {
+	return mysqlmanager.MySQL().Where("plugin_uuid = ?", pluginUUID).Delete(&PluginCredential{}).Error
+}
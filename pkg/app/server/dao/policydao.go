@@ -0,0 +1,58 @@
+package dao
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"openeuler.org/PilotGo/PilotGo/pkg/dbmanager/mysqlmanager"
+)
+
+// 远程命令及脚本的执行策略
+type CommandPolicy struct {
+	ID   uint   `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	Name string `gorm:"type:varchar(100);not null" json:"name"`
+	// 匹配命令或脚本内容的正则表达式
+	Pattern string `gorm:"type:text;not null" json:"pattern"`
+	// allow、deny或dangerous
+	Action string `gorm:"type:varchar(20);not null" json:"action"`
+	// 以逗号分隔的角色id、部门id列表，为空时对所有用户生效
+	Roles       string `gorm:"type:text" json:"roles"`
+	Departments string `gorm:"type:text" json:"departments"`
+	// 数值越大越先匹配
+	Priority    int       `json:"priority"`
+	Enabled     bool      `json:"enabled"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func AddCommandPolicy(p *CommandPolicy) error {
+	return mysqlmanager.MySQL().Create(p).Error
+}
+
+func UpdateCommandPolicy(p *CommandPolicy) error {
+	return mysqlmanager.MySQL().Save(p).Error
+}
+
+func DeleteCommandPolicy(ids []uint) error {
+	return mysqlmanager.MySQL().Where("id IN ?", ids).Delete(&CommandPolicy{}).Error
+}
+
+func GetCommandPolicy(id uint) (*CommandPolicy, error) {
+	var p CommandPolicy
+	err := mysqlmanager.MySQL().Where("id = ?", id).First(&p).Error
+	return &p, err
+}
+
+// 按匹配顺序返回所有启用的策略
+func GetEnabledCommandPolicies() ([]CommandPolicy, error) {
+	var list []CommandPolicy
+	err := mysqlmanager.MySQL().Where("enabled = ?", true).Order("priority desc, id asc").Find(&list).Error
+	return list, err
+}
+
+func CommandPolicyList() (*[]CommandPolicy, *gorm.DB) {
+	list := &[]CommandPolicy{}
+	tx := mysqlmanager.MySQL().Order("priority desc, id asc").Find(list)
+	return list, tx
+}
@@ -0,0 +1,136 @@
+package dao
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+	"openeuler.org/PilotGo/PilotGo/pkg/dbmanager/mysqlmanager"
+)
+
+// 维护窗口，窗口在spec表达式的时刻开启并持续Duration分钟
+type MaintenanceWindow struct {
+	ID   uint   `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	Name string `gorm:"type:varchar(100);uniqueIndex" json:"name"`
+	// 标准5段cron表达式，如"0 2 * * 6"表示每周六02:00
+	Spec        string    `gorm:"type:varchar(100)" json:"spec"`
+	Duration    int       `json:"duration"`
+	Description string    `gorm:"type:text" json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func AddMaintenanceWindow(w *MaintenanceWindow) error {
+	return mysqlmanager.MySQL().Create(w).Error
+}
+
+func UpdateMaintenanceWindow(w *MaintenanceWindow) error {
+	return mysqlmanager.MySQL().Save(w).Error
+}
+
+func DeleteMaintenanceWindows(ids []uint) error {
+	return mysqlmanager.MySQL().Where("id IN ?", ids).Delete(&MaintenanceWindow{}).Error
+}
+
+func GetMaintenanceWindow(id uint) (*MaintenanceWindow, error) {
+	var w MaintenanceWindow
+	err := mysqlmanager.MySQL().Where("id = ?", id).First(&w).Error
+	return &w, err
+}
+
+func MaintenanceWindowList() ([]MaintenanceWindow, error) {
+	var list []MaintenanceWindow
+	err := mysqlmanager.MySQL().Order("id").Find(&list).Error
+	return list, err
+}
+
+// 定时执行的批量操作
+type ScheduledJob struct {
+	ID   uint   `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	Name string `gorm:"type:varchar(100)" json:"name"`
+	// json格式的异步任务参数
+	Param string `gorm:"type:longtext" json:"param"`
+	// 单次执行的时间，与Spec二选一
+	RunAt *time.Time `json:"run_at"`
+	// 周期执行的cron表达式
+	Spec string `gorm:"type:varchar(100)" json:"spec"`
+	// 维护窗口，为0时不限制执行时间
+	WindowID uint `gorm:"index" json:"window_id"`
+	// 机器离线时的处理方式，skip或defer
+	Offline   string     `gorm:"type:varchar(20)" json:"offline"`
+	Enabled   bool       `gorm:"index" json:"enabled"`
+	UserName  string     `gorm:"type:varchar(100)" json:"userName"`
+	NextRunAt *time.Time `gorm:"index" json:"next_run_at"`
+	LastRunAt *time.Time `json:"last_run_at"`
+	// 以逗号分隔的待机器上线后补充执行的uuid
+	Deferred string `gorm:"type:text" json:"deferred"`
+	// 补充执行的截止时间
+	DeferUntil *time.Time `json:"defer_until"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+func AddScheduledJob(s *ScheduledJob) error {
+	return mysqlmanager.MySQL().Create(s).Error
+}
+
+func UpdateScheduledJob(s *ScheduledJob) error {
+	return mysqlmanager.MySQL().Save(s).Error
+}
+
+func DeleteScheduledJobs(ids []uint) error {
+	return mysqlmanager.MySQL().Where("id IN ?", ids).Delete(&ScheduledJob{}).Error
+}
+
+func GetScheduledJob(id uint) (*ScheduledJob, error) {
+	var s ScheduledJob
+	err := mysqlmanager.MySQL().Where("id = ?", id).First(&s).Error
+	return &s, err
+}
+
+func ScheduledJobList() (*[]ScheduledJob, *gorm.DB) {
+	list := &[]ScheduledJob{}
+	tx := mysqlmanager.MySQL().Model(&ScheduledJob{}).Order("id desc").Find(list)
+	return list, tx
+}
+
+// 获取已到执行时间或有待补充执行机器的定时操作
+func DueScheduledJobs(now time.Time) ([]ScheduledJob, error) {
+	var list []ScheduledJob
+	err := mysqlmanager.MySQL().Where("enabled = ? AND (next_run_at <= ? OR deferred <> ?)", true, now, "").
+		Find(&list).Error
+	return list, err
+}
+
+// 使用维护窗口的定时操作数量
+func CountScheduledJobsByWindow(ids []uint) (int64, error) {
+	var count int64
+	err := mysqlmanager.MySQL().Model(&ScheduledJob{}).Where("window_id IN ?", ids).Count(&count).Error
+	return count, err
+}
+
+// 定时操作的单次触发记录，各机器的执行结果见JobID对应的操作日志
+type ScheduleRun struct {
+	ID         uint   `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	ScheduleID uint   `gorm:"index" json:"schedule_id"`
+	JobID      int    `json:"job_id"`
+	Trigger    string `gorm:"type:varchar(20)" json:"trigger"`
+	// 以逗号分隔的离线而跳过或推迟执行的机器
+	Skipped   string    `gorm:"type:text" json:"skipped"`
+	Deferred  string    `gorm:"type:text" json:"deferred"`
+	Error     string    `gorm:"type:text" json:"error"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func AddScheduleRun(r *ScheduleRun) error {
+	return mysqlmanager.MySQL().Create(r).Error
+}
+
+func QueryScheduleRuns(scheduleID uint) (*[]ScheduleRun, *gorm.DB) {
+	list := &[]ScheduleRun{}
+	tx := mysqlmanager.MySQL().Model(&ScheduleRun{}).Order("id desc")
+	if scheduleID != 0 {
+		tx = tx.Where("schedule_id = ?", scheduleID)
+	}
+	tx = tx.Find(list)
+	return list, tx
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"time"
 
+	"gorm.io/gorm"
 	"openeuler.org/PilotGo/PilotGo/pkg/dbmanager/mysqlmanager"
 )
 
@@ -15,6 +16,49 @@ type Script struct {
 	UpdatedAt   time.Time
 	Version     string `gorm:"unique" json:"version"`
 	Deleted     int    `json:"deleted"` //deleted为1的时候表示删除，一般表示为0
+	// 脚本参数声明，json格式
+	Params string `gorm:"type:text" json:"params"`
+	// 脚本内容的sha256值
+	Hash string `gorm:"type:varchar(64)" json:"hash"`
+	// 脚本解释器，为空时使用bash
+	Interpreter string `gorm:"type:varchar(100)" json:"interpreter"`
+}
+
+// 脚本库中脚本的执行记录，各机器的执行结果记录在对应任务的子日志中
+type ScriptRun struct {
+	ID         uint      `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	ScriptID   uint      `json:"script_id"`
+	ScriptName string    `gorm:"type:varchar(100);index" json:"script_name"`
+	Version    string    `gorm:"type:varchar(100)" json:"version"`
+	Hash       string    `gorm:"type:varchar(64);index" json:"hash"`
+	Params     string    `gorm:"type:text" json:"params"`
+	UserName   string    `json:"user_name"`
+	UserDept   string    `json:"user_dept"`
+	JobID      int       `gorm:"index" json:"job_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// 脚本执行记录及单台机器的执行结果
+type ScriptRunRecord struct {
+	RunID       uint      `json:"run_id"`
+	ScriptName  string    `json:"script_name"`
+	Version     string    `json:"version"`
+	Hash        string    `json:"hash"`
+	UserName    string    `json:"user_name"`
+	JobID       int       `json:"job_id"`
+	MachineUUID string    `json:"machine_uuid"`
+	IP          string    `json:"ip"`
+	State       string    `json:"state"`
+	ExitCode    int       `json:"exit_code"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type ScriptRunFilter struct {
+	ScriptName  string
+	Version     string
+	Hash        string
+	UserName    string
+	MachineUUID string
 }
 
 // 添加脚本文件
@@ -56,3 +100,46 @@ func ShowScript(scriptversion string) (string, error) {
 	err := mysqlmanager.MySQL().Where("version=?", scriptversion).Find(&script).Error
 	return script.Content, err
 }
+
+// 根据脚本名及版本号查询脚本，版本号为空时返回最新版本
+func GetScript(name string, version string) (*Script, error) {
+	var script Script
+	tx := mysqlmanager.MySQL().Where("name = ? AND deleted = 0", name)
+	if version != "" {
+		tx = tx.Where("version = ?", version)
+	}
+	err := tx.Order("id desc").First(&script).Error
+	return &script, err
+}
+
+// 存储脚本执行记录
+func AddScriptRun(r *ScriptRun) error {
+	return mysqlmanager.MySQL().Create(r).Error
+}
+
+// 查询脚本在各机器上的执行记录
+func QueryScriptRuns(f *ScriptRunFilter) (list *[]ScriptRunRecord, tx *gorm.DB) {
+	list = &[]ScriptRunRecord{}
+	tx = mysqlmanager.MySQL().Table("script_run").
+		Select("script_run.id as run_id, script_run.script_name, script_run.version, script_run.hash, " +
+			"script_run.user_name, script_run.job_id, agent_log.machine_uuid, agent_log.ip, " +
+			"agent_log.state, agent_log.exit_code, script_run.created_at").
+		Joins("left join agent_log on agent_log.log_parent_id = script_run.job_id")
+	if f.ScriptName != "" {
+		tx = tx.Where("script_run.script_name = ?", f.ScriptName)
+	}
+	if f.Version != "" {
+		tx = tx.Where("script_run.version = ?", f.Version)
+	}
+	if f.Hash != "" {
+		tx = tx.Where("script_run.hash = ?", f.Hash)
+	}
+	if f.UserName != "" {
+		tx = tx.Where("script_run.user_name = ?", f.UserName)
+	}
+	if f.MachineUUID != "" {
+		tx = tx.Where("agent_log.machine_uuid = ?", f.MachineUUID)
+	}
+	tx = tx.Order("script_run.id desc").Find(list)
+	return
+}
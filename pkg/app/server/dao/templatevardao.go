@@ -0,0 +1,44 @@
+package dao
+
+import (
+	"time"
+
+	"openeuler.org/PilotGo/PilotGo/pkg/dbmanager/mysqlmanager"
+)
+
+// 配置模板的自定义变量，按批次或部门设置
+type TemplateVar struct {
+	ID uint `gorm:"primary_key;AUTO_INCREMENT" json:"id"`
+	// batch或depart
+	Scope     string    `gorm:"type:varchar(20);uniqueIndex:idx_template_var" json:"scope"`
+	ScopeID   int       `gorm:"uniqueIndex:idx_template_var" json:"scope_id"`
+	Name      string    `gorm:"type:varchar(100);uniqueIndex:idx_template_var" json:"name"`
+	Value     string    `gorm:"type:text" json:"value"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// 按范围及变量名新增或覆盖变量
+func SaveTemplateVar(v *TemplateVar) error {
+	var old TemplateVar
+	err := mysqlmanager.MySQL().Where("scope = ? AND scope_id = ? AND name = ?", v.Scope, v.ScopeID, v.Name).Find(&old).Error
+	if err != nil {
+		return err
+	}
+	v.ID = old.ID
+	return mysqlmanager.MySQL().Save(v).Error
+}
+
+func DeleteTemplateVars(ids []uint) error {
+	return mysqlmanager.MySQL().Where("id IN ?", ids).Delete(&TemplateVar{}).Error
+}
+
+// 查询变量，scope为空时返回所有变量
+func TemplateVarList(scope string, scopeID int) ([]TemplateVar, error) {
+	var list []TemplateVar
+	tx := mysqlmanager.MySQL().Order("scope, scope_id, name")
+	if scope != "" {
+		tx = tx.Where("scope = ? AND scope_id = ?", scope, scopeID)
+	}
+	err := tx.Find(&list).Error
+	return list, err
+}
@@ -23,8 +23,17 @@ import (
 	sconfig "openeuler.org/PilotGo/PilotGo/pkg/app/server/config"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/network"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/network/websocket"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/approval"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/auditlog"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/auth"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/cron"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/eventbus"
+	fileservice "openeuler.org/PilotGo/PilotGo/pkg/app/server/service/file"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/filewatch"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/integrity"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/job"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/plugin"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/schedule"
 	"openeuler.org/PilotGo/PilotGo/pkg/dbmanager"
 	"openeuler.org/PilotGo/PilotGo/pkg/dbmanager/redismanager"
 	"openeuler.org/PilotGo/PilotGo/pkg/global"
@@ -56,6 +65,40 @@ func main() {
 		os.Exit(-1)
 	}
 
+	// 事件总线初始化
+	eventbus.Init()
+
+	// 异步任务初始化
+	if err := job.Init(); err != nil {
+		logger.Error("job service init failed: %s", err)
+	}
+
+	// 审计日志签名检查点
+	if err := auditlog.Init(); err != nil {
+		logger.Error("audit log service init failed: %s", err)
+		os.Exit(-1)
+	}
+
+	// 操作审批初始化
+	if err := approval.Init(); err != nil {
+		logger.Error("approval service init failed: %s", err)
+	}
+
+	// agent上线时同步定时任务
+	cron.Init()
+
+	// 定时操作调度
+	schedule.Init()
+
+	// 受管配置文件漂移检查
+	fileservice.DriftInit()
+
+	// agent上线时下发文件监控列表
+	filewatch.Init()
+
+	// 文件完整性定期检查
+	integrity.Init()
+
 	// 鉴权模块初始化
 	global.PILOTGO_E = auth.Casbin(&sconfig.Config().MysqlDBinfo)
 
@@ -93,6 +136,7 @@ func main() {
 			logger.Info("signal interrupted: %s", s.String())
 			// TODO: DO EXIT
 
+			eventbus.Stop()
 			redismanager.Redis().Close()
 
 			goto EXIT
@@ -25,6 +25,7 @@ import (
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/controller/pluginapi"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/network/websocket"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/resource"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/auditlog"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/auth"
 	"openeuler.org/PilotGo/PilotGo/pkg/logger"
 )
@@ -61,6 +62,7 @@ func setupRouter() *gin.Engine {
 	router := gin.New()
 	router.Use(auth.LoggerDebug())
 	router.Use(auth.Recover)
+	router.Use(auditlog.Middleware())
 
 	// 绑定 http api handler
 	registerAPIs(router)
@@ -89,6 +91,8 @@ func registerAPIs(router *gin.Engine) {
 	macList := api.Group("/macList") // 机器管理
 	{
 		macList.POST("/script_save", controller.AddScriptHandler)
+		macList.POST("/script_run", auth.AuthMiddleware(), controller.RunScriptHandler)
+		macList.GET("/script_runs", controller.ScriptRunsHandler)
 		macList.POST("/deletemachine", controller.DeleteMachineHandler)
 		macList.GET("/depart", controller.DepartHandler)
 		macList.GET("/selectmachine", controller.MachineListHandler)
@@ -102,7 +106,7 @@ func registerAPIs(router *gin.Engine) {
 	{
 		macDetails.GET("/agent_info", agentcontroller.AgentInfoHandler)
 		macDetails.GET("/agent_list", agentcontroller.AgentListHandler)
-		macDetails.GET("/run_script", agentcontroller.RunScript)
+		macDetails.GET("/run_script", auth.AuthMiddleware(), agentcontroller.RunScript)
 		macDetails.GET("/os_info", agentcontroller.OSInfoHandler)
 		macDetails.GET("/cpu_info", agentcontroller.CPUInfoHandler)
 		macDetails.GET("/memory_info", agentcontroller.MemoryInfoHandler)
@@ -131,25 +135,30 @@ func registerAPIs(router *gin.Engine) {
 	macBasicModify := api.Group("/agent") // 机器配置
 	{
 		macBasicModify.GET("/sysctl_change", agentcontroller.SysctlChangeHandler)
-		macBasicModify.POST("/service_stop", agentcontroller.ServiceStopHandler)
+		macBasicModify.POST("/service_stop", auth.AuthMiddleware(), agentcontroller.ServiceStopHandler)
 		macBasicModify.POST("/service_start", agentcontroller.ServiceStartHandler)
 		macBasicModify.POST("/service_restart", agentcontroller.ServiceRestartHandler)
 		macBasicModify.POST("/rpm_install", agentcontroller.InstallRpmHandler)
-		macBasicModify.POST("/rpm_remove", agentcontroller.RemoveRpmHandler)
+		macBasicModify.POST("/rpm_remove", auth.AuthMiddleware(), agentcontroller.RemoveRpmHandler)
 		macBasicModify.GET("/disk_mount", agentcontroller.DiskMountHandler)
 		macBasicModify.GET("/disk_umount", agentcontroller.DiskUMountHandler)
-		macBasicModify.GET("/disk_format", agentcontroller.DiskFormatHandler)
+		macBasicModify.GET("/disk_format", auth.AuthMiddleware(), agentcontroller.DiskFormatHandler)
 		macBasicModify.GET("/user_add", agentcontroller.AddLinuxUserHandler)
-		macBasicModify.GET("/user_del", agentcontroller.DelUserHandler)
+		macBasicModify.GET("/user_del", auth.AuthMiddleware(), agentcontroller.DelUserHandler)
 		macBasicModify.GET("/user_ower", agentcontroller.ChangeFileOwnerHandler)
 		macBasicModify.GET("/user_per", agentcontroller.ChangePermissionHandler)
-		macBasicModify.POST("cron_new", agentcontroller.CreatCron)
+		macBasicModify.POST("cron_new", auth.AuthMiddleware(), agentcontroller.CreatCron)
 		macBasicModify.POST("/cron_del", agentcontroller.DeleteCronTask)
-		macBasicModify.POST("/cron_update", agentcontroller.UpdateCron)
-		macBasicModify.POST("/cron_status", agentcontroller.CronTaskStatus)
+		macBasicModify.POST("/cron_update", auth.AuthMiddleware(), agentcontroller.UpdateCron)
+		macBasicModify.POST("/cron_status", auth.AuthMiddleware(), agentcontroller.CronTaskStatus)
 		macBasicModify.GET("/cron_list", agentcontroller.CronTaskList)
+		macBasicModify.POST("/cron_reconcile", auth.AuthMiddleware(), agentcontroller.CronReconcileHandler)
+		macBasicModify.GET("/cron_report", agentcontroller.CronReportHandler)
+		macBasicModify.POST("/cron_run", auth.AuthMiddleware(), agentcontroller.CronRunNowHandler)
+		macBasicModify.GET("/cron_history", agentcontroller.CronHistoryHandler)
+		macBasicModify.GET("/cron_summary", agentcontroller.CronSummaryHandler)
 		macBasicModify.GET("/firewall_restart", agentcontroller.FirewalldRestart)
-		macBasicModify.GET("/firewall_stop", agentcontroller.FirewalldStop)
+		macBasicModify.GET("/firewall_stop", auth.AuthMiddleware(), agentcontroller.FirewalldStop)
 		macBasicModify.POST("/firewall_addzp", agentcontroller.FirewalldZonePortAdd)
 		macBasicModify.POST("/firewall_delzp", agentcontroller.FirewalldZonePortDel)
 		macBasicModify.POST("/firewall_default", agentcontroller.FirewalldSetDefaultZone)
@@ -157,7 +166,7 @@ func registerAPIs(router *gin.Engine) {
 		macBasicModify.POST("/firewall_serviceRemove", agentcontroller.FirewalldServiceRemove)
 		macBasicModify.POST("/firewall_sourceAdd", agentcontroller.FirewalldSourceAdd)
 		macBasicModify.POST("/firewall_sourceRemove", agentcontroller.FirewalldSourceRemove)
-		macBasicModify.POST("/network", agentcontroller.ConfigNetworkConnect)
+		macBasicModify.POST("/network", auth.AuthMiddleware(), agentcontroller.ConfigNetworkConnect)
 	}
 
 	batchmanager := api.Group("batchmanager") // 批次
@@ -185,20 +194,106 @@ func registerAPIs(router *gin.Engine) {
 	configmanager := api.Group("config") // 配置管理
 	{
 		configmanager.GET("/read_file", agentcontroller.ReadFile)
-		configmanager.POST("/fileSaveAdd", controller.SaveFileToDatabaseHandler)
+		configmanager.POST("/fileSaveAdd", auth.AuthMiddleware(), controller.SaveFileToDatabaseHandler)
 		configmanager.GET("/file_all", controller.AllFiles)
 		configmanager.POST("/file_search", controller.FileSearchHandler)
-		configmanager.POST("/file_update", controller.UpdateFileHandler)
+		configmanager.POST("/file_update", auth.AuthMiddleware(), controller.UpdateFileHandler)
 		configmanager.POST("/file_delete", controller.DeleteFileHandler)
 		configmanager.GET("/lastfile_all", controller.HistoryFilesHandler)
 		configmanager.POST("/lastfile_rollback", controller.LastFileRollBackHandler)
-		configmanager.POST("/file_broadcast", agentcontroller.FileBroadcastToAgents)
+		configmanager.GET("/file_diff", controller.FileDiffHandler)
+		configmanager.POST("/file_broadcast", auth.AuthMiddleware(), agentcontroller.FileBroadcastToAgents)
+		configmanager.POST("/file_preview", controller.FilePreviewHandler)
+		configmanager.GET("/template_vars", controller.TemplateVarListHandler)
+		configmanager.POST("/template_var_save", controller.SaveTemplateVarHandler)
+		configmanager.POST("/template_var_delete", controller.DeleteTemplateVarHandler)
+		configmanager.GET("/drift", controller.FileDriftListHandler)
+		configmanager.POST("/drift_scan", controller.FileDriftScanHandler)
+		configmanager.POST("/drift_reapply", controller.FileDriftReapplyHandler)
+		configmanager.POST("/drift_adopt", controller.FileDriftAdoptHandler)
+		configmanager.GET("/watch_list", controller.FileWatchListHandler)
+		configmanager.GET("/watch_events", controller.FileWatchEventsHandler)
+		configmanager.POST("/watch_add", auth.AuthMiddleware(), auth.CasbinHandler(), controller.AddFileWatchHandler)
+		configmanager.POST("/watch_update", auth.AuthMiddleware(), auth.CasbinHandler(), controller.UpdateFileWatchHandler)
+		configmanager.POST("/watch_delete", auth.AuthMiddleware(), auth.CasbinHandler(), controller.DeleteFileWatchHandler)
 	}
 
 	userLog := api.Group("log") // 日志管理
 	{
 		userLog.GET("/log_all", controller.LogAllHandler)
 		userLog.GET("/logs", controller.AgentLogsHandler)
+		userLog.GET("/audit", controller.AuditLogQueryHandler)
+		userLog.GET("/audit_export", controller.AuditLogExportHandler)
+		userLog.GET("/audit_verify", controller.AuditLogVerifyHandler)
+		userLog.GET("/audit_checkpoints", controller.AuditCheckpointListHandler)
+		userLog.GET("/audit_public_key", controller.AuditPublicKeyHandler)
+		userLog.GET("/audit_chain_export", controller.AuditChainExportHandler)
+		// 检查点由服务端签名，仅超级管理员可手动生成
+		userLog.POST("/audit_checkpoint", auth.AuthMiddleware(), auth.AdminMiddleware(), controller.AuditCheckpointHandler)
+	}
+
+	jobs := api.Group("job") // 异步任务
+	// 提交人取自登录用户，仅提交人及超级管理员可取消、继续或终止任务
+	jobs.Use(auth.AuthMiddleware())
+	{
+		jobs.POST("", controller.SubmitJobHandler)
+		jobs.GET("/:id", controller.JobInfoHandler)
+		jobs.POST("/:id/cancel", controller.CancelJobHandler)
+		jobs.POST("/:id/resume", controller.ResumeJobHandler)
+		jobs.POST("/:id/abort", controller.AbortJobHandler)
+		jobs.GET("/:id/events", controller.JobEventsHandler)
+	}
+
+	approvals := api.Group("approval") // 操作审批
+	// 申请人及审批人均取自登录用户
+	approvals.Use(auth.AuthMiddleware())
+	{
+		approvals.GET("", controller.ApprovalListHandler)
+		approvals.GET("/:id", controller.ApprovalInfoHandler)
+		approvals.POST("/:id/approve", controller.ApproveHandler)
+		approvals.POST("/:id/reject", controller.RejectHandler)
+	}
+
+	cmdPolicy := api.Group("command_policy") // 命令策略
+	// 按登录用户检查命令，策略的增删改仅超级管理员可操作
+	cmdPolicy.Use(auth.AuthMiddleware())
+	{
+		cmdPolicy.GET("/list", controller.CommandPolicyListHandler)
+		cmdPolicy.POST("/evaluate", controller.EvaluateCommandPolicyHandler)
+		cmdPolicy.POST("/add", auth.AdminMiddleware(), controller.AddCommandPolicyHandler)
+		cmdPolicy.POST("/update", auth.AdminMiddleware(), controller.UpdateCommandPolicyHandler)
+		cmdPolicy.POST("/delete", auth.AdminMiddleware(), controller.DeleteCommandPolicyHandler)
+	}
+
+	schedules := api.Group("schedule") // 定时操作及维护窗口
+	// 定时操作以创建人的身份执行，创建人取自登录用户
+	schedules.Use(auth.AuthMiddleware())
+	{
+		schedules.GET("/list", controller.ScheduleListHandler)
+		schedules.GET("/runs", controller.ScheduleRunsHandler)
+		schedules.GET("/window_list", controller.MaintenanceWindowListHandler)
+		schedules.POST("/add", auth.CasbinHandler(), controller.AddScheduleHandler)
+		schedules.POST("/update", auth.CasbinHandler(), controller.UpdateScheduleHandler)
+		schedules.POST("/delete", auth.CasbinHandler(), controller.DeleteScheduleHandler)
+		schedules.POST("/status", auth.CasbinHandler(), controller.ScheduleStatusHandler)
+		schedules.POST("/run", auth.CasbinHandler(), controller.RunScheduleHandler)
+		schedules.POST("/window_add", auth.CasbinHandler(), controller.AddMaintenanceWindowHandler)
+		schedules.POST("/window_update", auth.CasbinHandler(), controller.UpdateMaintenanceWindowHandler)
+		schedules.POST("/window_delete", auth.CasbinHandler(), controller.DeleteMaintenanceWindowHandler)
+	}
+
+	integrityCheck := api.Group("integrity") // 文件完整性检查
+	integrityCheck.Use(auth.AuthMiddleware())
+	{
+		integrityCheck.GET("/profiles", controller.IntegrityProfileListHandler)
+		integrityCheck.GET("/scans", controller.IntegrityScansHandler)
+		integrityCheck.GET("/changes", controller.IntegrityChangesHandler)
+		integrityCheck.POST("/profile_add", auth.CasbinHandler(), controller.AddIntegrityProfileHandler)
+		integrityCheck.POST("/profile_update", auth.CasbinHandler(), controller.UpdateIntegrityProfileHandler)
+		integrityCheck.POST("/profile_delete", auth.CasbinHandler(), controller.DeleteIntegrityProfileHandler)
+		integrityCheck.POST("/baseline", auth.CasbinHandler(), controller.IntegrityBaselineHandler)
+		integrityCheck.POST("/scan", auth.CasbinHandler(), controller.IntegrityScanHandler)
+		integrityCheck.POST("/accept", auth.CasbinHandler(), controller.IntegrityAcceptHandler)
 	}
 
 	// 此处绑定casbin过滤规则
@@ -227,9 +322,12 @@ func registerAPIs(router *gin.Engine) {
 	plugin := api.Group("plugins") // 插件
 	{
 		plugin.GET("", controller.GetPluginsHandler)
-		plugin.PUT("", controller.AddPluginHandler)
+		// 添加插件及查询、修改凭证涉及插件的授权范围，仅超级管理员可操作
+		plugin.PUT("", auth.AuthMiddleware(), auth.AdminMiddleware(), controller.AddPluginHandler)
 		plugin.POST("/:uuid", controller.TogglePluginHandler)
 		plugin.DELETE("/:uuid", controller.UnloadPluginHandler)
+		plugin.GET("/:uuid/credential", auth.AuthMiddleware(), auth.AdminMiddleware(), controller.GetPluginCredentialHandler)
+		plugin.PUT("/:uuid/credential", auth.AuthMiddleware(), auth.AdminMiddleware(), controller.UpdatePluginCredentialHandler)
 	}
 
 	// 对插件提供的api接口
@@ -259,6 +357,10 @@ func registerPluginApi(router *gin.RouterGroup) {
 		pluginAPI.PUT("/listener", pluginapi.RegisterListenerHandler)
 		pluginAPI.DELETE("/listener", pluginapi.UnregisterListenerHandler)
 
+		pluginAPI.GET("/events", pluginapi.EventListHandler)
+		pluginAPI.GET("/events/replay", pluginapi.EventReplayHandler)
+		pluginAPI.POST("/events/ack", pluginapi.EventAckHandler)
+
 		pluginAPI.PUT("/install_package", pluginapi.InstallPackage)
 		pluginAPI.PUT("/uninstall_package", pluginapi.UninstallPackage)
 
@@ -276,5 +378,7 @@ func registerPluginApi(router *gin.RouterGroup) {
 
 func registerPluginGateway(router *gin.Engine) {
 	gateway := router.Group("/plugin/:plugin_name")
+	gateway.Use(auth.GatewayAuthMiddleware())
 	gateway.Any("", controller.PluginGatewayHandler)
+	gateway.Any("/*action", controller.PluginGatewayHandler)
 }
@@ -31,6 +31,8 @@ const (
 	ServiceStop    = "关闭服务"
 	ServiceStart   = "开启服务"
 	BroadcastFile  = "文件下发"
+	RunCommand     = "执行命令"
+	RunScript      = "执行脚本"
 )
 
 // 日志存储所属模块
@@ -39,6 +41,7 @@ const (
 	LogTypeService   = "运行服务"
 	LogTypeSysctl    = "配置内核参数"
 	LogTypeBroadcast = "配置文件下发"
+	LogTypeCommand   = "远程执行"
 )
 
 // 计算批量机器操作的状态：成功数，总数目，比率
@@ -0,0 +1,330 @@
+package approval
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/config"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/auditlog"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/eventbus"
+	"openeuler.org/PilotGo/PilotGo/pkg/logger"
+)
+
+// 可配置为需要审批的操作类型
+const (
+	OpDiskFormat    = "disk_format"
+	OpUserDel       = "user_del"
+	OpFirewallStop  = "firewall_stop"
+	OpNetworkConfig = "network_config"
+	OpRpmRemove     = "rpm_remove"
+	OpServiceStop   = "service_stop"
+)
+
+// 审批请求状态
+const (
+	StatePending  = "pending"
+	StateApproved = "approved"
+	StateRejected = "rejected"
+	StateExpired  = "expired"
+	StateExecuted = "executed"
+	StateFailed   = "failed"
+)
+
+const (
+	// 审批请求默认有效期
+	defaultExpireTime = 24 * time.Hour
+	// 过期检查间隔
+	expireCheckInterval = time.Minute
+)
+
+// 审批通过后执行操作，params为提交审批时的json格式参数
+type Executor func(params string) (interface{}, error)
+
+var executors sync.Map
+
+// 注册操作类型对应的执行函数
+func Register(op string, e Executor) {
+	executors.Store(op, e)
+}
+
+// 操作是否需要审批
+func Required(op string) bool {
+	for _, o := range config.Config().Approval.Operations {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+// 停止服务是否需要审批，停止firewalld同时受firewall_stop控制
+func ServiceStopRequired(service string) bool {
+	if Required(OpServiceStop) {
+		return true
+	}
+	name := strings.TrimSuffix(strings.TrimSpace(service), ".service")
+	return name == "firewalld" && Required(OpFirewallStop)
+}
+
+// 提交审批请求的参数
+type Request struct {
+	Operation string
+	// 目标机器uuid
+	Targets []string
+	Params  interface{}
+	Reason  string
+	// 申请人，须为已登录用户的邮箱或插件身份，插件申请时RequesterID为0
+	Requester   string
+	RequesterID uint
+}
+
+type Filter = dao.ApprovalFilter
+
+func Init() error {
+	// 服务重启时正在执行的操作已中断
+	list, err := dao.GetApprovalsByState(StateApproved)
+	if err != nil {
+		return err
+	}
+	for i := range list {
+		list[i].State = StateFailed
+		list[i].Result = "server restarted"
+		if err := dao.UpdateApproval(&list[i]); err != nil {
+			logger.Error("failed to update approval %d: %s", list[i].ID, err.Error())
+		}
+	}
+
+	go expireLoop()
+	return nil
+}
+
+func Submit(r *Request) (*dao.Approval, error) {
+	if _, ok := executors.Load(r.Operation); !ok {
+		return nil, fmt.Errorf("unsupported operation: %s", r.Operation)
+	}
+	if r.Requester == "" {
+		return nil, errors.New("申请人不能为空")
+	}
+	params, err := json.Marshal(r.Params)
+	if err != nil {
+		return nil, err
+	}
+
+	expire := defaultExpireTime
+	if t := config.Config().Approval.ExpireTime; t > 0 {
+		expire = time.Duration(t) * time.Second
+	}
+	a := &dao.Approval{
+		Operation:   r.Operation,
+		Target:      strings.Join(r.Targets, ","),
+		Params:      string(params),
+		Reason:      r.Reason,
+		State:       StatePending,
+		Requester:   r.Requester,
+		RequesterID: r.RequesterID,
+		ExpiresAt:   time.Now().Add(expire),
+	}
+	if err := dao.AddApproval(a); err != nil {
+		return nil, err
+	}
+
+	audit(a, "提交审批", a.Requester, a.RequesterID, auditlog.StatusSuccess, "原因: "+a.Reason)
+	notify(eventbus.MsgApprovalRequest, a)
+	return a, nil
+}
+
+// 审批通过并异步执行操作，审批人须为申请人以外具有审批角色的已登录用户
+func Approve(id uint, approver *dao.User, comment string) (*dao.Approval, error) {
+	a, err := review(id, approver, comment)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	a.State = StateApproved
+	a.ApprovedAt = &now
+	ok, err := dao.UpdateApprovalState(a, StatePending)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("审批请求已被处理")
+	}
+	audit(a, "审批通过", approver.Email, approver.ID, auditlog.StatusSuccess, comment)
+	notify(eventbus.MsgApprovalApproved, a)
+
+	go execute(*a)
+	return a, nil
+}
+
+func Reject(id uint, approver *dao.User, comment string) (*dao.Approval, error) {
+	a, err := review(id, approver, comment)
+	if err != nil {
+		return nil, err
+	}
+
+	a.State = StateRejected
+	ok, err := dao.UpdateApprovalState(a, StatePending)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("审批请求已被处理")
+	}
+	audit(a, "审批拒绝", approver.Email, approver.ID, auditlog.StatusSuccess, comment)
+	notify(eventbus.MsgApprovalRejected, a)
+	return a, nil
+}
+
+func Get(id uint) (*dao.Approval, error) {
+	return dao.GetApproval(id)
+}
+
+func Query(f *Filter) (*[]dao.Approval, *gorm.DB) {
+	return dao.QueryApprovals(f)
+}
+
+// 检查审批请求及审批人
+func review(id uint, approver *dao.User, comment string) (*dao.Approval, error) {
+	if approver == nil || approver.ID == 0 {
+		return nil, errors.New("审批人未登录")
+	}
+	a, err := dao.GetApproval(id)
+	if err != nil {
+		return nil, fmt.Errorf("审批请求 %d 不存在", id)
+	}
+	switch err := checkReview(a, approver, time.Now()); err {
+	case nil:
+	case errExpired:
+		expireAll()
+		return nil, err
+	case errSelfApproval, errNoPermission:
+		audit(a, "审批", approver.Email, approver.ID, auditlog.StatusFail, "拒绝: "+err.Error())
+		return nil, err
+	default:
+		return nil, err
+	}
+
+	a.Approver = approver.Email
+	a.ApproverID = approver.ID
+	a.Comment = comment
+	return a, nil
+}
+
+var (
+	errExpired      = errors.New("审批请求已过期")
+	errSelfApproval = errors.New("不能审批自己提交的请求")
+	errNoPermission = errors.New("用户没有审批权限")
+)
+
+// 只有待审批且未过期的请求可以审批，审批人不能是申请人且须具有审批角色
+func checkReview(a *dao.Approval, approver *dao.User, now time.Time) error {
+	if a.State != StatePending {
+		return fmt.Errorf("审批请求状态为 %s，无法审批", a.State)
+	}
+	if now.After(a.ExpiresAt) {
+		return errExpired
+	}
+	if approver.ID == a.RequesterID || approver.Email == a.Requester {
+		return errSelfApproval
+	}
+	if !canApprove(approver) {
+		return errNoPermission
+	}
+	return nil
+}
+
+func canApprove(u *dao.User) bool {
+	roles := config.Config().Approval.ApproverRoles
+	if len(roles) == 0 {
+		return true
+	}
+	for _, r := range strings.Split(u.RoleID, ",") {
+		for _, allowed := range roles {
+			if strings.TrimSpace(r) == allowed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func execute(a dao.Approval) {
+	run(&a)
+	status := auditlog.StatusSuccess
+	if a.State != StateExecuted {
+		status = auditlog.StatusFail
+	}
+
+	if err := dao.UpdateApproval(&a); err != nil {
+		logger.Error("failed to update approval %d: %s", a.ID, err.Error())
+	}
+	audit(&a, "执行操作", a.Approver, a.ApproverID, status, a.Result)
+	notify(eventbus.MsgApprovalExecuted, &a)
+}
+
+// 执行审批通过的操作，成功时状态为executed，结果为执行函数返回值的json，失败时状态为failed
+func run(a *dao.Approval) {
+	e, ok := executors.Load(a.Operation)
+	if !ok {
+		a.State = StateFailed
+		a.Result = "unsupported operation: " + a.Operation
+		return
+	}
+	data, err := e.(Executor)(a.Params)
+	if err != nil {
+		a.State = StateFailed
+		a.Result = err.Error()
+		return
+	}
+	bs, _ := json.Marshal(data)
+	a.State = StateExecuted
+	a.Result = string(bs)
+}
+
+func expireLoop() {
+	ticker := time.NewTicker(expireCheckInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		expireAll()
+	}
+}
+
+func expireAll() {
+	list, err := dao.ExpireApprovals(time.Now())
+	if err != nil {
+		logger.Error("failed to expire approvals: %s", err.Error())
+		return
+	}
+	for i := range list {
+		list[i].State = StateExpired
+		audit(&list[i], "审批过期", list[i].Requester, list[i].RequesterID, auditlog.StatusFail, "")
+		notify(eventbus.MsgApprovalExpired, &list[i])
+	}
+}
+
+func audit(a *dao.Approval, action, operator string, operatorID uint, status, msg string) {
+	message := fmt.Sprintf("审批单:%d 操作:%s 目标:%s", a.ID, a.Operation, a.Target)
+	if msg != "" {
+		message += " " + msg
+	}
+	log := auditlog.New(auditlog.LogTypeApproval, action, message, dao.User{ID: operatorID})
+	log.Operator = operator
+	log.Status = status
+	if err := auditlog.Add(log); err != nil {
+		logger.Error("failed to record approval audit log: %s", err.Error())
+	}
+}
+
+func notify(msgType int, a *dao.Approval) {
+	eventbus.PublishEvent(&eventbus.EventMessage{
+		MessageType: msgType,
+		MessageData: a,
+	})
+}
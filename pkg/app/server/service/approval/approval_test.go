@@ -0,0 +1,109 @@
+package approval
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/config"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+)
+
+func setApprovalConf(t *testing.T, conf config.ApprovalConf) {
+	old := config.Config().Approval
+	config.Config().Approval = conf
+	t.Cleanup(func() { config.Config().Approval = old })
+}
+
+func TestCheckReview(t *testing.T) {
+	setApprovalConf(t, config.ApprovalConf{ApproverRoles: []string{"2"}})
+
+	now := time.Now()
+	pending := func() *dao.Approval {
+		return &dao.Approval{State: StatePending, Requester: "alice@example.com", RequesterID: 1, ExpiresAt: now.Add(time.Hour)}
+	}
+	approver := &dao.User{ID: 2, Email: "bob@example.com", RoleID: "3,2"}
+
+	cases := []struct {
+		name     string
+		approval func() *dao.Approval
+		approver *dao.User
+		err      error
+	}{
+		{"approve", pending, approver, nil},
+		{"self by id", pending, &dao.User{ID: 1, Email: "other@example.com", RoleID: "2"}, errSelfApproval},
+		{"self by email", pending, &dao.User{ID: 5, Email: "alice@example.com", RoleID: "2"}, errSelfApproval},
+		{"no approver role", pending, &dao.User{ID: 3, Email: "carol@example.com", RoleID: "3"}, errNoPermission},
+		{"expired", func() *dao.Approval {
+			a := pending()
+			a.ExpiresAt = now.Add(-time.Second)
+			return a
+		}, approver, errExpired},
+		{"plugin requester", func() *dao.Approval {
+			a := pending()
+			a.Requester, a.RequesterID = "plugin:demo", 0
+			return a
+		}, approver, nil},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.err, checkReview(c.approval(), c.approver, now), c.name)
+	}
+
+	// 已处理的请求不能再次审批
+	for _, state := range []string{StateApproved, StateRejected, StateExpired, StateExecuted, StateFailed} {
+		a := pending()
+		a.State = state
+		assert.NotNil(t, checkReview(a, approver, now), state)
+	}
+}
+
+func TestCanApprove(t *testing.T) {
+	setApprovalConf(t, config.ApprovalConf{})
+	assert.True(t, canApprove(&dao.User{RoleID: "3"}))
+
+	setApprovalConf(t, config.ApprovalConf{ApproverRoles: []string{"1", "2"}})
+	assert.True(t, canApprove(&dao.User{RoleID: "2"}))
+	assert.True(t, canApprove(&dao.User{RoleID: "3, 1"}))
+	assert.False(t, canApprove(&dao.User{RoleID: "12"}))
+	assert.False(t, canApprove(&dao.User{}))
+}
+
+func TestRun(t *testing.T) {
+	Register("test_ok", func(params string) (interface{}, error) {
+		return map[string]string{"params": params}, nil
+	})
+	Register("test_fail", func(params string) (interface{}, error) {
+		return nil, errors.New("agent offline")
+	})
+
+	cases := []struct {
+		op     string
+		state  string
+		result string
+	}{
+		{"test_ok", StateExecuted, `{"params":"{}"}`},
+		{"test_fail", StateFailed, "agent offline"},
+		{"test_unknown", StateFailed, "unsupported operation: test_unknown"},
+	}
+	for _, c := range cases {
+		a := &dao.Approval{Operation: c.op, Params: "{}", State: StateApproved}
+		run(a)
+		assert.Equal(t, c.state, a.State, c.op)
+		assert.Equal(t, c.result, a.Result, c.op)
+	}
+}
+
+func TestRequired(t *testing.T) {
+	setApprovalConf(t, config.ApprovalConf{Operations: []string{OpRpmRemove, OpFirewallStop}})
+	assert.True(t, Required(OpRpmRemove))
+	assert.False(t, Required(OpDiskFormat))
+
+	// 停止firewalld同时受firewall_stop控制
+	assert.True(t, ServiceStopRequired("firewalld.service"))
+	assert.True(t, ServiceStopRequired(" firewalld "))
+	assert.False(t, ServiceStopRequired("nginx"))
+
+	setApprovalConf(t, config.ApprovalConf{Operations: []string{OpServiceStop}})
+	assert.True(t, ServiceStopRequired("nginx"))
+}
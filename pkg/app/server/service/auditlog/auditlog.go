@@ -2,6 +2,7 @@ package auditlog
 
 import (
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
 )
 
@@ -21,6 +22,14 @@ const (
 	LogTypeBatch      = "批次"
 	LogTypeOrganize   = "组织"
 	LogTypeMachine    = "机器"
+	LogTypePolicy     = "命令策略"
+	LogTypeApproval   = "操作审批"
+	LogTypeConfig     = "配置文件"
+	LogTypeJob        = "异步任务"
+	LogTypeSchedule   = "定时操作"
+	LogTypeIntegrity  = "完整性检查"
+	LogTypeAudit      = "日志"
+	LogTypeOther      = "其他"
 )
 
 type AuditLog = dao.AuditLog
@@ -36,13 +45,48 @@ func New(module, action, msg string, u dao.User) *AuditLog {
 	}
 }
 
+// 插件调用平台接口的审计日志，以插件身份记录操作者
+func NewByPlugin(pluginName, action, msg string) *AuditLog {
+	return &AuditLog{
+		LogUUID:  uuid.New().String(),
+		Module:   LogTypePlugin,
+		Status:   StatusRunning,
+		Operator: "plugin:" + pluginName,
+		Action:   action,
+		Message:  msg,
+	}
+}
+
+// 保存日志，运行中的日志在状态更新后才加入哈希链
 func Add(log *dao.AuditLog) error {
-	return log.Record()
+	if log.Status == StatusRunning {
+		return log.Record()
+	}
+	return log.Seal()
 }
 
-// 修改日志的操作状态
+// 修改日志的操作状态，日志已加入哈希链时以子日志记录状态变化
 func UpdateStatus(log *dao.AuditLog, status string) error {
-	return log.UpdateStatus(status)
+	if log.Seq == 0 {
+		old := log.Status
+		log.Status = status
+		err := log.Seal()
+		if err != dao.ErrAuditLogSealed {
+			return err
+		}
+		log.Status = old
+	}
+	child := &AuditLog{
+		LogUUID:       uuid.New().String(),
+		ParentLogUUID: log.LogUUID,
+		Module:        log.Module,
+		Status:        status,
+		OperatorID:    log.OperatorID,
+		Operator:      log.Operator,
+		Action:        log.Action,
+		Message:       "状态变更为" + status,
+	}
+	return child.Seal()
 }
 
 // 查询所有日志
@@ -63,3 +107,10 @@ func GetById(logUUId string) (dao.AuditLog, error) {
 func GetByModule(name string) ([]dao.AuditLog, error) {
 	return dao.GetAuditLogByModule(name)
 }
+
+type Filter = dao.AuditLogFilter
+
+// 按条件查询审计日志
+func Query(f *Filter) (*[]dao.AuditLog, *gorm.DB) {
+	return dao.QueryAuditLogs(f)
+}
@@ -0,0 +1,502 @@
+package auditlog
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/config"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+	"openeuler.org/PilotGo/PilotGo/pkg/logger"
+)
+
+// 完整性校验发现的问题类型
+const (
+	ProblemModified   = "modified"
+	ProblemDeleted    = "deleted"
+	ProblemInserted   = "inserted"
+	ProblemBroken     = "chain_broken"
+	ProblemCheckpoint = "checkpoint_mismatch"
+	ProblemSignature  = "bad_signature"
+)
+
+const (
+	defaultKeyFile            = "/opt/PilotGo/server/audit_ed25519.key"
+	defaultCheckpointInterval = 60
+	// 公钥记录文件的后缀，保存历次使用过的签名公钥，更换私钥后旧检查点仍可校验
+	publicKeysSuffix = ".pub"
+	// 超过该时间仍未更新状态的日志直接加入哈希链
+	sealTimeout = time.Hour
+	// 校验时每次读取的日志条数
+	verifyBatch = 1000
+	// 校验结果中保留的问题条数
+	problemLimit = 100
+)
+
+type Checkpoint = dao.AuditCheckpoint
+
+var (
+	keyOnce    sync.Once
+	privateKey ed25519.PrivateKey
+	keyID      string
+	publicKeys map[string]ed25519.PublicKey
+	keyErr     error
+)
+
+// 加载签名私钥，并定期将长时间未完成的日志加入哈希链、生成签名检查点
+func Init() error {
+	if _, err := signingKey(); err != nil {
+		return err
+	}
+	interval := config.Config().Audit.CheckpointInterval
+	if interval <= 0 {
+		interval = defaultCheckpointInterval
+	}
+	go func() {
+		ticker := time.NewTicker(time.Duration(interval) * time.Minute)
+		defer ticker.Stop()
+		for range ticker.C {
+			sealStale()
+			if _, err := CreateCheckpoint(); err != nil {
+				logger.Error("failed to create audit checkpoint: %s", err.Error())
+			}
+		}
+	}()
+	return nil
+}
+
+// 读取签名私钥及公钥记录
+func signingKey() (ed25519.PrivateKey, error) {
+	keyOnce.Do(func() {
+		keyErr = loadKeys()
+	})
+	return privateKey, keyErr
+}
+
+func loadKeys() error {
+	file := config.Config().Audit.KeyFile
+	if file == "" {
+		file = defaultKeyFile
+	}
+	file, err := filepath.Abs(file)
+	if err != nil {
+		return err
+	}
+	if publicKeys, err = readPublicKeys(file + publicKeysSuffix); err != nil {
+		return err
+	}
+
+	bs, err := os.ReadFile(file)
+	switch {
+	case os.IsNotExist(err):
+		// 私钥丢失时重新生成会使已有检查点无法校验，除非公钥记录中保留了对应的公钥
+		last, err := dao.LastAuditCheckpoint()
+		if err != nil {
+			return err
+		}
+		if last.Seq != 0 && publicKeys[last.KeyID] == nil {
+			return fmt.Errorf("audit key file %s not found while signed checkpoints exist, restore the key file or its public key file %s",
+				file, file+publicKeysSuffix)
+		}
+		if privateKey, err = generateKey(file); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	default:
+		if privateKey, err = parseKey(file, bs); err != nil {
+			return err
+		}
+	}
+
+	pub := privateKey.Public().(ed25519.PublicKey)
+	keyID = publicKeyID(pub)
+	if publicKeys[keyID] == nil {
+		if err := appendPublicKey(file+publicKeysSuffix, pub); err != nil {
+			return err
+		}
+		publicKeys[keyID] = pub
+	}
+	logger.Info("audit signing key: %s, key id: %s", file, keyID)
+	// 未记录公钥id的检查点均由当前私钥签名
+	return dao.SetAuditCheckpointKeyID(keyID)
+}
+
+func parseKey(file string, bs []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(bs)
+	if block == nil {
+		return nil, fmt.Errorf("invalid audit key file: %s", file)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	k, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("audit key file %s is not an ed25519 key", file)
+	}
+	return k, nil
+}
+
+func generateKey(file string) (ed25519.PrivateKey, error) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(file, data, 0600); err != nil {
+		return nil, err
+	}
+	logger.Info("generated audit signing key: %s", file)
+	return key, nil
+}
+
+// 公钥id，为公钥sha256的前16位
+func publicKeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:])[:16]
+}
+
+func encodePublicKey(pub ed25519.PublicKey) (*pem.Block, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	return &pem.Block{Type: "PUBLIC KEY", Headers: map[string]string{"Key-Id": publicKeyID(pub)}, Bytes: der}, nil
+}
+
+// 读取公钥记录文件中的所有公钥，文件不存在时返回空记录
+func readPublicKeys(file string) (map[string]ed25519.PublicKey, error) {
+	keys := map[string]ed25519.PublicKey{}
+	bs, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return keys, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for {
+		var block *pem.Block
+		block, bs = pem.Decode(bs)
+		if block == nil {
+			return keys, nil
+		}
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid audit public key file %s: %s", file, err.Error())
+		}
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("audit public key file %s contains a non ed25519 key", file)
+		}
+		keys[publicKeyID(pub)] = pub
+	}
+}
+
+func appendPublicKey(file string, pub ed25519.PublicKey) error {
+	block, err := encodePublicKey(pub)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return pem.Encode(f, block)
+}
+
+// 验证检查点签名的当前公钥，PEM格式
+func PublicKey() (string, error) {
+	if _, err := signingKey(); err != nil {
+		return "", err
+	}
+	keys, err := PublicKeys()
+	if err != nil {
+		return "", err
+	}
+	return keys[keyID], nil
+}
+
+// 历次使用过的签名公钥，以公钥id为键，检查点按其key_id选择公钥校验
+func PublicKeys() (map[string]string, error) {
+	if _, err := signingKey(); err != nil {
+		return nil, err
+	}
+	keys := map[string]string{}
+	for id, pub := range publicKeys {
+		block, err := encodePublicKey(pub)
+		if err != nil {
+			return nil, err
+		}
+		keys[id] = string(pem.EncodeToMemory(block))
+	}
+	return keys, nil
+}
+
+func sealStale() {
+	list, err := dao.UnsealedAuditLogs(time.Now().Add(-sealTimeout))
+	if err != nil {
+		logger.Error("failed to get unsealed audit logs: %s", err.Error())
+		return
+	}
+	for i := range list {
+		if err := list[i].Seal(); err != nil && err != dao.ErrAuditLogSealed {
+			logger.Error("failed to seal audit log %d: %s", list[i].ID, err.Error())
+		}
+	}
+}
+
+// 对哈希链当前的最后一条日志签名，没有新日志时不生成
+func CreateCheckpoint() (*Checkpoint, error) {
+	key, err := signingKey()
+	if err != nil {
+		return nil, err
+	}
+	last, err := dao.LastSealedAuditLog()
+	if err != nil {
+		return nil, err
+	}
+	prev, err := dao.LastAuditCheckpoint()
+	if err != nil {
+		return nil, err
+	}
+	if last.Seq == 0 || last.Seq == prev.Seq {
+		return nil, nil
+	}
+
+	c := &Checkpoint{Seq: last.Seq, Hash: last.Hash, Timestamp: time.Now().Unix(), KeyID: keyID}
+	c.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, []byte(c.Payload())))
+	if err := dao.AddAuditCheckpoint(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func CheckpointList() (*[]Checkpoint, *gorm.DB) {
+	return dao.AuditCheckpointList()
+}
+
+// 以检查点记录的公钥id对应的公钥校验签名
+func verifySignature(c *Checkpoint) bool {
+	if _, err := signingKey(); err != nil {
+		return false
+	}
+	return checkSignature(publicKeys[c.KeyID], c)
+}
+
+func checkSignature(pub ed25519.PublicKey, c *Checkpoint) bool {
+	if len(pub) != ed25519.PublicKeySize {
+		return false
+	}
+	sig, err := base64.StdEncoding.DecodeString(c.Signature)
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(pub, []byte(c.Payload()), sig)
+}
+
+type Problem struct {
+	Seq     uint64 `json:"seq"`
+	LogUUID string `json:"log_uuid"`
+	Kind    string `json:"kind"`
+	Detail  string `json:"detail"`
+}
+
+type VerifyReport struct {
+	Valid    bool   `json:"valid"`
+	Checked  int    `json:"checked"`
+	FirstSeq uint64 `json:"first_seq"`
+	LastSeq  uint64 `json:"last_seq"`
+	LastHash string `json:"last_hash"`
+	// 尚未加入哈希链的日志数量
+	Unsealed    int64     `json:"unsealed"`
+	Checkpoints int       `json:"checkpoints"`
+	Problems    []Problem `json:"problems"`
+	// 问题过多时只保留前problemLimit条
+	Truncated  bool      `json:"truncated"`
+	VerifiedAt time.Time `json:"verified_at"`
+}
+
+func (r *VerifyReport) add(p Problem) {
+	r.Valid = false
+	if len(r.Problems) >= problemLimit {
+		r.Truncated = true
+		return
+	}
+	r.Problems = append(r.Problems, p)
+}
+
+// 校验序号范围内的哈希链及签名检查点，to为0时校验到最后一条日志
+// 在线校验以数据库中的检查点为准，末尾的日志连同其后的检查点一起被删除时无法发现，
+// 审计方应保存导出的检查点，离线比对导出时最后一个检查点的序号及哈希
+func Verify(from, to uint64) (*VerifyReport, error) {
+	if from == 0 {
+		from = 1
+	}
+	if to != 0 && to < from {
+		return nil, errors.New("校验范围错误")
+	}
+	r := &VerifyReport{Valid: true, FirstSeq: from, Problems: []Problem{}, VerifiedAt: time.Now()}
+
+	checkpoints, err := dao.AuditCheckpoints(from, to)
+	if err != nil {
+		return nil, err
+	}
+	r.Checkpoints = len(checkpoints)
+	expected := map[uint64]*Checkpoint{}
+	for i := range checkpoints {
+		c := &checkpoints[i]
+		if !verifySignature(c) {
+			r.add(Problem{Seq: c.Seq, Kind: ProblemSignature, Detail: fmt.Sprintf("检查点 %d 签名无效", c.ID)})
+			continue
+		}
+		expected[c.Seq] = c
+	}
+
+	// 起始日志之前一条日志的哈希，用于校验链接
+	prevHash := ""
+	if from > 1 {
+		prev, err := dao.AuditLogBySeq(from - 1)
+		if err != nil {
+			r.add(Problem{Seq: from - 1, Kind: ProblemDeleted, Detail: "起始日志的前一条日志不存在"})
+		} else {
+			prevHash = prev.Hash
+		}
+	}
+
+	next := from
+	for {
+		list, err := dao.SealedAuditLogs(next, to, verifyBatch)
+		if err != nil {
+			return nil, err
+		}
+		for i := range list {
+			l := &list[i]
+			r.check(l, next, prevHash, expected)
+			r.Checked++
+			if l.Seq >= next {
+				next = l.Seq + 1
+			}
+			prevHash = l.Hash
+			r.LastSeq, r.LastHash = l.Seq, l.Hash
+		}
+		if len(list) < verifyBatch {
+			break
+		}
+	}
+
+	// 检查点之后的日志被删除
+	for seq, c := range expected {
+		if seq >= next {
+			r.add(Problem{Seq: seq, Kind: ProblemDeleted,
+				Detail: fmt.Sprintf("检查点 %d 记录的日志不存在，序号 %d 之后的日志可能被删除", c.ID, next-1)})
+		}
+	}
+	if r.Unsealed, err = dao.CountUnsealedAuditLogs(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *VerifyReport) check(l *AuditLog, next uint64, prevHash string, expected map[uint64]*Checkpoint) {
+	switch {
+	case l.Seq > next:
+		r.add(Problem{Seq: l.Seq, LogUUID: l.LogUUID, Kind: ProblemDeleted,
+			Detail: fmt.Sprintf("序号 %d 至 %d 的日志缺失", next, l.Seq-1)})
+	case l.Seq < next:
+		r.add(Problem{Seq: l.Seq, LogUUID: l.LogUUID, Kind: ProblemInserted, Detail: "序号重复"})
+	}
+	if l.ComputeHash() != l.Hash {
+		r.add(Problem{Seq: l.Seq, LogUUID: l.LogUUID, Kind: ProblemModified, Detail: "日志内容与哈希不符"})
+	}
+	if l.Seq == next && (l.Seq == 1 || prevHash != "") && l.PrevHash != prevHash {
+		r.add(Problem{Seq: l.Seq, LogUUID: l.LogUUID, Kind: ProblemBroken, Detail: "与前一条日志的哈希不一致"})
+	}
+	if c, ok := expected[l.Seq]; ok {
+		if c.Hash != l.Hash {
+			r.add(Problem{Seq: l.Seq, LogUUID: l.LogUUID, Kind: ProblemCheckpoint,
+				Detail: fmt.Sprintf("与检查点 %d 记录的哈希不一致", c.ID)})
+		}
+		delete(expected, l.Seq)
+	}
+}
+
+// 供离线校验的导出内容
+type ChainExport struct {
+	// 哈希及签名算法说明
+	Algorithm string `json:"algorithm"`
+	// 当前签名公钥及历次使用过的公钥，检查点按key_id选择
+	PublicKey   string            `json:"public_key"`
+	PublicKeys  map[string]string `json:"public_keys"`
+	Checkpoints []Checkpoint      `json:"checkpoints"`
+	Records     []ExportRecord    `json:"records"`
+	ExportedAt  time.Time         `json:"exported_at"`
+}
+
+type ExportRecord struct {
+	Seq uint64 `json:"seq"`
+	// 参与哈希计算的json内容，hash为其sha256
+	Content string `json:"content"`
+	Hash    string `json:"hash"`
+}
+
+const exportAlgorithm = "hash = hex(sha256(content)), content.prev_hash = hash of previous record; " +
+	"checkpoint signature = base64(ed25519(\"pilotgo-audit-checkpoint\\nseq=<seq>\\nhash=<hash>\\ntimestamp=<timestamp>\")), " +
+	"verified with public_keys[checkpoint.key_id]"
+
+// 导出序号范围内的日志及检查点，to为0时导出到最后一条日志
+// 审计方须自行保存导出的检查点，服务端的检查点与日志可能被一并删除
+func ExportChain(from, to uint64) (*ChainExport, error) {
+	if from == 0 {
+		from = 1
+	}
+	pub, err := PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	keys, err := PublicKeys()
+	if err != nil {
+		return nil, err
+	}
+	e := &ChainExport{Algorithm: exportAlgorithm, PublicKey: pub, PublicKeys: keys, Records: []ExportRecord{}, ExportedAt: time.Now()}
+	if e.Checkpoints, err = dao.AuditCheckpoints(from, to); err != nil {
+		return nil, err
+	}
+
+	next := from
+	for {
+		list, err := dao.SealedAuditLogs(next, to, verifyBatch)
+		if err != nil {
+			return nil, err
+		}
+		for i := range list {
+			e.Records = append(e.Records, ExportRecord{Seq: list[i].Seq, Content: list[i].HashContent(), Hash: list[i].Hash})
+			if list[i].Seq >= next {
+				next = list[i].Seq + 1
+			}
+		}
+		if len(list) < verifyBatch {
+			break
+		}
+	}
+	return e, nil
+}
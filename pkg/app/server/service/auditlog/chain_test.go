@@ -0,0 +1,176 @@
+package auditlog
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 生成n条已链接的日志
+func testChain(n int) []AuditLog {
+	list := make([]AuditLog, n)
+	prevHash := ""
+	for i := range list {
+		l := &list[i]
+		l.Seq = uint64(i + 1)
+		l.PrevHash = prevHash
+		l.LogUUID = base64.StdEncoding.EncodeToString([]byte{byte(i)})
+		l.Action = "test"
+		l.CreatedAt = time.Unix(1700000000+int64(i), 0)
+		l.Hash = l.ComputeHash()
+		prevHash = l.Hash
+	}
+	return list
+}
+
+func TestComputeHash(t *testing.T) {
+	base := testChain(1)[0]
+	assert.Equal(t, base.ComputeHash(), base.ComputeHash())
+	assert.Len(t, base.ComputeHash(), 64)
+
+	cases := []struct {
+		name   string
+		modify func(l *AuditLog)
+		same   bool
+	}{
+		{"hash field", func(l *AuditLog) { l.Hash = "x" }, true},
+		{"id", func(l *AuditLog) { l.ID = 10 }, true},
+		{"sub second", func(l *AuditLog) { l.CreatedAt = l.CreatedAt.Add(time.Millisecond) }, true},
+		{"seq", func(l *AuditLog) { l.Seq++ }, false},
+		{"prev hash", func(l *AuditLog) { l.PrevHash = "x" }, false},
+		{"message", func(l *AuditLog) { l.Message = "x" }, false},
+		{"code", func(l *AuditLog) { l.Code = 1 }, false},
+		{"created at", func(l *AuditLog) { l.CreatedAt = l.CreatedAt.Add(time.Second) }, false},
+	}
+	for _, c := range cases {
+		l := base
+		c.modify(&l)
+		assert.Equal(t, c.same, l.ComputeHash() == base.Hash, c.name)
+	}
+}
+
+func TestCheckpointPayload(t *testing.T) {
+	c := &Checkpoint{ID: 3, Seq: 12, Hash: "abc", Timestamp: 1700000000, KeyID: "k", Signature: "s"}
+	assert.Equal(t, "pilotgo-audit-checkpoint\nseq=12\nhash=abc\ntimestamp=1700000000", c.Payload())
+}
+
+func TestCheckSignature(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	other, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	sign := func(c Checkpoint) Checkpoint {
+		c.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte(c.Payload())))
+		return c
+	}
+	valid := sign(Checkpoint{Seq: 5, Hash: "abc", Timestamp: 1700000000})
+	tampered := valid
+	tampered.Hash = "abd"
+	badEncoding := valid
+	badEncoding.Signature = "!" + valid.Signature
+
+	cases := []struct {
+		name string
+		pub  ed25519.PublicKey
+		c    Checkpoint
+		ok   bool
+	}{
+		{"valid", pub, valid, true},
+		{"wrong key", other, valid, false},
+		{"no key", nil, valid, false},
+		{"short key", pub[:16], valid, false},
+		{"tampered", pub, tampered, false},
+		{"bad encoding", pub, badEncoding, false},
+		{"empty signature", pub, Checkpoint{Seq: 5, Hash: "abc", Timestamp: 1700000000}, false},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.ok, checkSignature(c.pub, &c.c), c.name)
+	}
+}
+
+func TestPublicKeys(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "audit_ed25519.key"+publicKeysSuffix)
+
+	keys, err := readPublicKeys(file)
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+
+	var pubs []ed25519.PublicKey
+	for i := 0; i < 2; i++ {
+		pub, _, err := ed25519.GenerateKey(rand.Reader)
+		assert.NoError(t, err)
+		assert.NoError(t, appendPublicKey(file, pub))
+		pubs = append(pubs, pub)
+	}
+	// 重复追加的公钥读取时按id合并
+	assert.NoError(t, appendPublicKey(file, pubs[0]))
+
+	keys, err = readPublicKeys(file)
+	assert.NoError(t, err)
+	assert.Len(t, keys, 2)
+	for _, pub := range pubs {
+		id := publicKeyID(pub)
+		assert.Len(t, id, 16)
+		assert.Equal(t, pub, keys[id])
+	}
+	assert.NotEqual(t, publicKeyID(pubs[0]), publicKeyID(pubs[1]))
+}
+
+func TestVerifyReportCheck(t *testing.T) {
+	cases := []struct {
+		name   string
+		modify func(list []AuditLog) []AuditLog
+		kinds  []string
+	}{
+		{"intact", func(list []AuditLog) []AuditLog { return list }, nil},
+		{"modified", func(list []AuditLog) []AuditLog {
+			list[1].Message = "changed"
+			return list
+		}, []string{ProblemModified}},
+		{"deleted", func(list []AuditLog) []AuditLog {
+			return append(list[:1], list[2:]...)
+		}, []string{ProblemDeleted}},
+		{"rehashed", func(list []AuditLog) []AuditLog {
+			list[1].Message = "changed"
+			list[1].Hash = list[1].ComputeHash()
+			return list
+		}, []string{ProblemBroken}},
+		{"inserted", func(list []AuditLog) []AuditLog {
+			return append(list[:2], append([]AuditLog{list[1]}, list[2:]...)...)
+		}, []string{ProblemInserted}},
+		{"checkpoint mismatch", func(list []AuditLog) []AuditLog {
+			list[2].Message = "changed"
+			list[2].Hash = list[2].ComputeHash()
+			return list
+		}, []string{ProblemCheckpoint, ProblemBroken}},
+	}
+	for _, c := range cases {
+		list := c.modify(testChain(4))
+		checkpoint := testChain(4)[2]
+		expected := map[uint64]*Checkpoint{3: {ID: 1, Seq: 3, Hash: checkpoint.Hash}}
+
+		r := &VerifyReport{Valid: true, Problems: []Problem{}}
+		next, prevHash := uint64(1), ""
+		for i := range list {
+			l := &list[i]
+			r.check(l, next, prevHash, expected)
+			if l.Seq >= next {
+				next = l.Seq + 1
+			}
+			prevHash = l.Hash
+		}
+
+		var kinds []string
+		for _, p := range r.Problems {
+			kinds = append(kinds, p.Kind)
+		}
+		assert.Equal(t, c.kinds, kinds, c.name)
+		assert.Equal(t, len(c.kinds) == 0, r.Valid, c.name)
+	}
+}
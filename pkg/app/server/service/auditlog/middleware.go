@@ -0,0 +1,388 @@
+package auditlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/auth"
+	"openeuler.org/PilotGo/PilotGo/pkg/logger"
+	"openeuler.org/PilotGo/PilotGo/pkg/utils/response"
+)
+
+const (
+	// 读取并记录的请求体最大长度，超过时不记录请求体
+	bodyLimit = 64 * 1024
+	// 记录的参数最大长度
+	paramsLimit = 4096
+	// 解析返回结果时保留的响应体长度
+	responseLimit = 4096
+	// 脱敏后的占位内容
+	redacted = "******"
+	// 机器列表在上下文中的键
+	machinesKey = "x-audit-machines"
+)
+
+// 参数名中包含以下内容时脱敏
+var secretKeys = []string{"password", "passwd", "pwd", "secret", "token", "credential", "private_key", "authorization"}
+
+// 表示目标机器的参数名
+var machineKeys = map[string]bool{
+	"uuid":          true,
+	"uuids":         true,
+	"machine_uuid":  true,
+	"machine_uuids": true,
+	"agent_uuid":    true,
+	"deluuid":       true,
+}
+
+// 修改机器状态的GET接口
+var mutatingGets = map[string]bool{
+	"/api/v1/api/run_script":         true,
+	"/api/v1/agent/sysctl_change":    true,
+	"/api/v1/agent/disk_mount":       true,
+	"/api/v1/agent/disk_umount":      true,
+	"/api/v1/agent/disk_format":      true,
+	"/api/v1/agent/user_add":         true,
+	"/api/v1/agent/user_del":         true,
+	"/api/v1/agent/user_ower":        true,
+	"/api/v1/agent/user_per":         true,
+	"/api/v1/agent/firewall_restart": true,
+	"/api/v1/agent/firewall_stop":    true,
+	"/api/v1/user/logout":            true,
+}
+
+// 按路由分组划分日志模块
+var routeModules = []struct {
+	prefix string
+	module string
+}{
+	{"/api/v1/macList", LogTypeMachine},
+	{"/api/v1/api", LogTypeMachine},
+	{"/api/v1/agent", LogTypeMachine},
+	{"/api/v1/batchmanager", LogTypeBatch},
+	{"/api/v1/user", LogTypeUser},
+	{"/api/v1/casbin", LogTypePermission},
+	{"/api/v1/config", LogTypeConfig},
+	{"/api/v1/job", LogTypeJob},
+	{"/api/v1/approval", LogTypeApproval},
+	{"/api/v1/command_policy", LogTypePolicy},
+	{"/api/v1/schedule", LogTypeSchedule},
+	{"/api/v1/integrity", LogTypeIntegrity},
+	{"/api/v1/log", LogTypeAudit},
+	{"/api/v1/plugins", LogTypePlugin},
+	{"/api/v1/pluginapi", LogTypePlugin},
+	{"/plugin/", LogTypePlugin},
+}
+
+// 记录所有修改类请求的审计日志：操作者、路由、脱敏后的参数、目标机器、结果及耗时
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !audited(c) {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		params, body := readParams(c)
+		w := &bodyWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+
+		c.Next()
+
+		log := &AuditLog{
+			LogUUID:  uuid.New().String(),
+			Module:   module(c.Request.URL.Path),
+			Method:   c.Request.Method,
+			Path:     c.Request.URL.Path,
+			ClientIP: c.ClientIP(),
+			Duration: time.Since(start).Milliseconds(),
+		}
+		log.OperatorID, log.Operator = operator(c, body)
+		log.Action = c.Request.Method + " " + route(c)
+		log.Params = truncate(params, paramsLimit)
+		if log.Module != LogTypePlugin {
+			log.Machines = strings.Join(machines(c, body), ",")
+		}
+		log.Code, log.Status, log.Message = result(c, w)
+
+		if err := Add(log); err != nil {
+			logger.Error("failed to record audit log of %s: %s", log.Path, err.Error())
+		}
+	}
+}
+
+// 在审计日志中补充由批次、部门等解析出的目标机器
+func AddMachines(c *gin.Context, uuids ...string) {
+	list := c.GetStringSlice(machinesKey)
+	c.Set(machinesKey, append(list, uuids...))
+}
+
+func audited(c *gin.Context) bool {
+	switch c.Request.Method {
+	case http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodPatch:
+		return true
+	case http.MethodGet:
+		return mutatingGets[c.Request.URL.Path]
+	}
+	return false
+}
+
+func module(path string) string {
+	for _, m := range routeModules {
+		if strings.HasPrefix(path, m.prefix) {
+			return m.module
+		}
+	}
+	return LogTypeOther
+}
+
+func route(c *gin.Context) string {
+	if r := c.FullPath(); r != "" {
+		return r
+	}
+	return c.Request.URL.Path
+}
+
+// 读取查询参数及json、表单格式的请求体，返回脱敏后的参数及解析后的json请求体
+func readParams(c *gin.Context) (string, map[string]interface{}) {
+	params := map[string]interface{}{}
+	for k, v := range c.Request.URL.Query() {
+		params[k] = values(v)
+	}
+	var body map[string]interface{}
+
+	contentType := c.ContentType()
+	switch {
+	case c.Request.Body == nil || c.Request.ContentLength == 0:
+	case c.Request.ContentLength < 0 || c.Request.ContentLength > bodyLimit:
+		params["_body"] = fmt.Sprintf("<%s %d bytes omitted>", contentType, c.Request.ContentLength)
+	case contentType == gin.MIMEJSON || contentType == gin.MIMEPOSTForm:
+		bs, err := io.ReadAll(c.Request.Body)
+		c.Request.Body = io.NopCloser(bytes.NewReader(bs))
+		if err != nil {
+			break
+		}
+		if contentType == gin.MIMEPOSTForm {
+			form, _ := url.ParseQuery(string(bs))
+			for k, v := range form {
+				params[k] = values(v)
+			}
+			break
+		}
+		var data interface{}
+		if err := json.Unmarshal(bs, &data); err != nil {
+			params["_body"] = truncate(string(bs), paramsLimit)
+			break
+		}
+		if m, ok := data.(map[string]interface{}); ok {
+			body = m
+			for k, v := range m {
+				params[k] = v
+			}
+		} else {
+			params["_body"] = data
+		}
+	default:
+		params["_body"] = fmt.Sprintf("<%s body omitted>", contentType)
+	}
+
+	bs, _ := json.Marshal(redact(params))
+	return string(bs), body
+}
+
+func values(v []string) interface{} {
+	if len(v) == 1 {
+		return v[0]
+	}
+	return v
+}
+
+func isSecret(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range secretKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// 递归替换敏感字段的值
+func redact(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			if isSecret(k) {
+				m[k] = redacted
+			} else {
+				m[k] = redact(v)
+			}
+		}
+		return m
+	case []interface{}:
+		list := make([]interface{}, 0, len(t))
+		for _, v := range t {
+			list = append(list, redact(v))
+		}
+		return list
+	}
+	return v
+}
+
+// 操作者优先取自登录token，其次取自请求参数中的用户名
+func operator(c *gin.Context, body map[string]interface{}) (uint, string) {
+	if v, ok := c.Get("x-user"); ok {
+		if u, ok := v.(dao.User); ok {
+			return u.ID, u.Email
+		}
+	}
+	if u, err := auth.UserFromToken(auth.RequestToken(c)); err == nil {
+		return u.ID, u.Email
+	}
+	for _, k := range []string{"userName", "email", "username"} {
+		if name, ok := body[k].(string); ok && name != "" {
+			return 0, name
+		}
+		if name := c.Query(k); name != "" {
+			return 0, name
+		}
+	}
+	return 0, ""
+}
+
+// 从请求参数及处理函数补充的内容中收集目标机器
+func machines(c *gin.Context, body map[string]interface{}) []string {
+	seen := map[string]bool{}
+	add := func(v interface{}) {
+		switch t := v.(type) {
+		case string:
+			for _, s := range strings.Split(t, ",") {
+				if s = strings.TrimSpace(s); s != "" {
+					seen[s] = true
+				}
+			}
+		case []interface{}:
+			for _, s := range t {
+				if str, ok := s.(string); ok && str != "" {
+					seen[str] = true
+				}
+			}
+		}
+	}
+	for k, v := range c.Request.URL.Query() {
+		if machineKeys[strings.ToLower(k)] {
+			add(strings.Join(v, ","))
+		}
+	}
+	for k, v := range body {
+		if machineKeys[strings.ToLower(k)] {
+			add(v)
+		}
+	}
+	for _, u := range c.GetStringSlice(machinesKey) {
+		add(u)
+	}
+
+	list := make([]string, 0, len(seen))
+	for u := range seen {
+		list = append(list, u)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// 根据http状态码及统一响应中的code判断操作结果，优先使用response写入上下文的code
+func result(c *gin.Context, w *bodyWriter) (int, string, string) {
+	code := w.Status()
+	msg := c.GetString(response.MsgKey)
+	if v, ok := c.Get(response.CodeKey); ok {
+		code, _ = v.(int)
+	} else {
+		resp := decodeResult(w.body.Bytes())
+		if resp.Code != 0 {
+			code = resp.Code
+		}
+		msg = resp.Msg
+	}
+	status := StatusSuccess
+	if code < 200 || code >= 300 {
+		status = StatusFail
+	}
+	return code, status, msg
+}
+
+type responseResult struct {
+	Code int
+	Msg  string
+}
+
+// 逐个读取响应体中的字段，响应体被截断时仍能得到截断位置之前的code及msg
+func decodeResult(body []byte) responseResult {
+	r := responseResult{}
+	dec := json.NewDecoder(bytes.NewReader(body))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return r
+	}
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return r
+		}
+		switch t {
+		case "code":
+			err = dec.Decode(&r.Code)
+		case "msg":
+			err = dec.Decode(&r.Msg)
+		default:
+			var skip json.RawMessage
+			err = dec.Decode(&skip)
+		}
+		if err != nil {
+			return r
+		}
+	}
+	return r
+}
+
+func truncate(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	return s[:limit] + "...(truncated)"
+}
+
+// 保留响应体开头部分用于解析操作结果
+type bodyWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyWriter) Write(b []byte) (int, error) {
+	w.keep(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyWriter) WriteString(s string) (int, error) {
+	w.keep([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *bodyWriter) keep(b []byte) {
+	if n := responseLimit - w.body.Len(); n > 0 {
+		if len(b) > n {
+			b = b[:n]
+		}
+		w.body.Write(b)
+	}
+}
@@ -0,0 +1,122 @@
+package auditlog
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"openeuler.org/PilotGo/PilotGo/pkg/utils/response"
+)
+
+func TestDecodeResult(t *testing.T) {
+	cases := []struct {
+		body string
+		code int
+		msg  string
+	}{
+		{`{"code":200,"data":{"list":[1,2]},"msg":"ok"}`, 200, "ok"},
+		{`{"data":{"code":500},"msg":"失败","code":400}`, 400, "失败"},
+		// 响应体被截断时保留已读取的字段
+		{`{"code":200,"msg":"ok","data":{"list":[1,2`, 200, "ok"},
+		{`{"data":"` + strings.Repeat("x", 100), 0, ""},
+		{`[1,2]`, 0, ""},
+		{`<html>`, 0, ""},
+		{``, 0, ""},
+	}
+	for _, c := range cases {
+		r := decodeResult([]byte(c.body))
+		assert.Equal(t, c.code, r.Code, c.body)
+		assert.Equal(t, c.msg, r.Msg, c.body)
+	}
+}
+
+func TestResult(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	w := &bodyWriter{ResponseWriter: c.Writer}
+	c.Writer = w
+	response.Fail(c, nil, "no permission")
+	code, status, msg := result(c, w)
+	assert.Equal(t, 400, code)
+	assert.Equal(t, StatusFail, status)
+	assert.Equal(t, "no permission", msg)
+
+	// 未经response写入的响应从响应体解析
+	c, _ = gin.CreateTestContext(httptest.NewRecorder())
+	w = &bodyWriter{ResponseWriter: c.Writer}
+	c.Writer = w
+	c.JSON(http.StatusOK, gin.H{"code": 200, "msg": "done"})
+	code, status, msg = result(c, w)
+	assert.Equal(t, 200, code)
+	assert.Equal(t, StatusSuccess, status)
+	assert.Equal(t, "done", msg)
+
+	c, _ = gin.CreateTestContext(httptest.NewRecorder())
+	w = &bodyWriter{ResponseWriter: c.Writer}
+	c.Writer = w
+	c.String(http.StatusInternalServerError, strings.Repeat("x", responseLimit*2))
+	code, status, _ = result(c, w)
+	assert.Equal(t, http.StatusInternalServerError, code)
+	assert.Equal(t, StatusFail, status)
+	assert.Equal(t, responseLimit, w.body.Len())
+}
+
+func TestReadParams(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	body := `{"userName":"alice","password":"p@ss","uuids":["m2","m1"],"options":{"env":{"API_TOKEN":"t"}}}`
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/job/submit?uuid=m3&token=abc", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	params, parsed := readParams(c)
+	m := map[string]interface{}{}
+	assert.Nil(t, json.Unmarshal([]byte(params), &m))
+	assert.Equal(t, redacted, m["password"])
+	assert.Equal(t, redacted, m["token"])
+	assert.Equal(t, "alice", m["userName"])
+	assert.Equal(t, map[string]interface{}{"env": map[string]interface{}{"API_TOKEN": redacted}}, m["options"])
+	assert.Equal(t, "alice", parsed["userName"])
+
+	// 处理函数仍能读取请求体
+	bs, err := io.ReadAll(c.Request.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, body, string(bs))
+
+	AddMachines(c, "m4", "m1")
+	assert.Equal(t, []string{"m1", "m2", "m3", "m4"}, machines(c, parsed))
+}
+
+func TestAudited(t *testing.T) {
+	cases := []struct {
+		method string
+		path   string
+		module string
+		audit  bool
+	}{
+		{http.MethodPost, "/api/v1/job/submit", LogTypeJob, true},
+		{http.MethodDelete, "/api/v1/schedule/delete", LogTypeSchedule, true},
+		{http.MethodGet, "/api/v1/job/list", LogTypeJob, false},
+		{http.MethodGet, "/api/v1/api/run_script", LogTypeMachine, true},
+		{http.MethodGet, "/api/v1/user/logout", LogTypeUser, true},
+		{http.MethodPost, "/plugin/grafana/api", LogTypePlugin, true},
+		{http.MethodPost, "/unknown", LogTypeOther, true},
+	}
+	for _, tc := range cases {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(tc.method, tc.path, nil)
+		assert.Equal(t, tc.audit, audited(c), tc.path)
+		assert.Equal(t, tc.module, module(tc.path), tc.path)
+	}
+
+	assert.True(t, isSecret("Authorization"))
+	assert.True(t, isSecret("db_passwd"))
+	assert.False(t, isSecret("userName"))
+	assert.Equal(t, "abc...(truncated)", truncate("abcdef", 3))
+	assert.Equal(t, "abc", truncate("abc", 3))
+}
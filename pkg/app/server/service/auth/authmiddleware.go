@@ -15,44 +15,103 @@
 package auth
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
 	"openeuler.org/PilotGo/PilotGo/pkg/dbmanager/mysqlmanager"
+	"openeuler.org/PilotGo/PilotGo/pkg/global"
 )
 
+// 前端保存token的cookie名称
+const TokenCookie = "Admin-Token"
+
+var (
+	ErrMissingToken = errors.New("missing token")
+	ErrInvalidToken = errors.New("invalid token")
+	ErrUserNotFound = errors.New("user not found")
+)
+
+// 请求携带的登录token，依次检查Authorization、authToken请求头及cookie
+func RequestToken(c *gin.Context) string {
+	tokenString := c.GetHeader("Authorization")
+	if tokenString == "" {
+		tokenString = c.GetHeader("authToken")
+	}
+	if tokenString == "" {
+		tokenString, _ = c.Cookie(TokenCookie)
+	}
+	return tokenString
+}
+
+// 校验登录token并获取对应的用户
+func UserFromToken(tokenString string) (*dao.User, error) {
+	if tokenString == "" {
+		return nil, ErrMissingToken
+	}
+	token, claims, err := ParseToken(tokenString)
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	var user dao.User
+	mysqlmanager.MySQL().First(&user, claims.UserId)
+	if user.ID == 0 {
+		return nil, ErrUserNotFound
+	}
+	return &user, nil
+}
+
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		tokenString := c.GetHeader("Authorization") //Get authorization header
-		if tokenString == "" {
+		user, err := UserFromToken(RequestToken(c))
+		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"code": 401,
-				"msg":  "权限不够1"})
+				"msg":  "权限不够: " + err.Error()})
 			c.Abort()
 			return
 		}
+		c.Set("x-user", *user) // user exists, write the user's information into the context
+		c.Next()
+	}
+}
 
-		token, claims, err := ParseToken(tokenString)
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"code": 401,
-				"msg":  "权限不够2"})
+// 获取AuthMiddleware写入的当前登录用户
+func LoginUser(c *gin.Context) (*dao.User, bool) {
+	v, _ := c.Get("x-user")
+	u, ok := v.(dao.User)
+	if !ok || u.ID == 0 {
+		return nil, false
+	}
+	return &u, true
+}
+
+// 仅允许超级管理员访问，需在AuthMiddleware之后使用
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if u, ok := LoginUser(c); !ok || u.UserType != global.AdminUserType {
+			c.JSON(http.StatusForbidden, gin.H{
+				"code": 403,
+				"msg":  "需要超级管理员权限"})
 			c.Abort()
 			return
 		}
+		c.Next()
+	}
+}
 
-		userId := claims.UserId // Get the userID in claim after the verification is passed
-		var user dao.User
-		mysqlmanager.MySQL().First(&user, userId)
-		if user.ID == 0 {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"code": 401,
-				"msg":  "权限不够3"})
+// 插件网关鉴权，iframe及微前端加载插件页面时只能携带cookie，因此同时检查请求头与cookie
+func GatewayAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := UserFromToken(RequestToken(c))
+		if err != nil {
+			c.String(http.StatusUnauthorized, err.Error())
 			c.Abort()
 			return
 		}
-		c.Set("x-user", user) // user exists, write the user's information into the context
+		c.Set("x-user", *user)
 		c.Next()
 	}
 }
@@ -0,0 +1,60 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+)
+
+func TestRequestToken(t *testing.T) {
+	cases := []struct {
+		name    string
+		headers map[string]string
+		cookie  string
+		token   string
+	}{
+		{"none", nil, "", ""},
+		{"authorization", map[string]string{"Authorization": "a"}, "c", "a"},
+		{"auth token header", map[string]string{"authToken": "b"}, "c", "b"},
+		{"authorization first", map[string]string{"Authorization": "a", "authToken": "b"}, "", "a"},
+		{"cookie", nil, "c", "c"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		for k, v := range tc.headers {
+			req.Header.Set(k, v)
+		}
+		if tc.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: TokenCookie, Value: tc.cookie})
+		}
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = req
+		assert.Equal(t, tc.token, RequestToken(c), tc.name)
+	}
+}
+
+func TestUserFromToken(t *testing.T) {
+	_, err := UserFromToken("")
+	assert.Equal(t, ErrMissingToken, err)
+	_, err = UserFromToken("not-a-jwt")
+	assert.Equal(t, ErrInvalidToken, err)
+}
+
+func TestLoginUser(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	_, ok := LoginUser(c)
+	assert.False(t, ok)
+
+	c.Set("x-user", dao.User{})
+	_, ok = LoginUser(c)
+	assert.False(t, ok)
+
+	c.Set("x-user", dao.User{ID: 1, Email: "admin"})
+	u, ok := LoginUser(c)
+	assert.True(t, ok)
+	assert.Equal(t, "admin", u.Email)
+}
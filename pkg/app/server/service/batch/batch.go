@@ -189,12 +189,79 @@ func SelectBatch() ([]dao.Batch, error) {
 	return dao.GetBatch()
 }
 
-// from batch get all machines
-func GetMachines(b *scommon.Batch) []string {
-	// TODO: support batch id
+// 解析批次选择器，返回机器uuid、批次及部门(含子部门)下所有机器的并集
+func GetMachines(b *scommon.Batch) ([]string, error) {
+	if b == nil {
+		return nil, errors.New("empty batch selector")
+	}
+
+	uuids := []string{}
+	uuids = append(uuids, b.MachineUUIDs...)
+
+	if b.BatchUUID != "" {
+		batchId, err := strconv.Atoi(b.BatchUUID)
+		if err != nil {
+			// 兼容以批次名称指定批次
+			id, err := dao.GetBatchID(b.BatchUUID)
+			if err != nil {
+				return nil, err
+			}
+			batchId = int(id)
+		}
+		exist, err := dao.IsExistID(batchId)
+		if err != nil {
+			return nil, err
+		}
+		if !exist {
+			return nil, errors.Errorf("batch %s not found", b.BatchUUID)
+		}
+		uuids = append(uuids, dao.BatchIds2UUIDs([]int{batchId})...)
+	}
 
-	if b.MachineUUIDs != nil {
-		return b.MachineUUIDs
+	if len(b.DepartmentIDs) != 0 {
+		departIds := []int{}
+		for _, d := range b.DepartmentIDs {
+			id, err := strconv.Atoi(d)
+			if err != nil {
+				return nil, errors.Errorf("invalid department id: %s", d)
+			}
+			departIds = append(departIds, id)
+		}
+		departUUIDs, err := DepartMachineUUIDs(departIds)
+		if err != nil {
+			return nil, err
+		}
+		uuids = append(uuids, departUUIDs...)
+	}
+
+	// 去重并保持顺序
+	result := []string{}
+	exist := map[string]bool{}
+	for _, uuid := range uuids {
+		if uuid == "" || exist[uuid] {
+			continue
+		}
+		exist[uuid] = true
+		result = append(result, uuid)
+	}
+	return result, nil
+}
+
+// 获取部门(含子部门)下所有机器的uuid
+func DepartMachineUUIDs(departIds []int) ([]string, error) {
+	ids := []int{}
+	for _, id := range departIds {
+		ids = append(ids, id)
+		common.ReturnSpecifiedDepart(id, &ids)
+	}
+
+	machines, err := dao.MachineList(ids)
+	if err != nil {
+		return nil, err
+	}
+	uuids := []string{}
+	for _, m := range machines {
+		uuids = append(uuids, m.UUID)
 	}
-	return []string{}
+	return uuids, nil
 }
@@ -18,8 +18,17 @@ import (
 	"fmt"
 
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/agentmanager"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/policy"
 )
 
+// 审计日志中定时任务命令的操作类型
+const ActionCronCommand = "定时任务命令"
+
+// 定时任务下发前对其命令进行策略检查
+func CheckCommand(s *policy.Subject, command, confirm string) (*policy.Decision, error) {
+	return policy.Check(s, ActionCronCommand, command, confirm)
+}
+
 // 开启任务
 func CronStart(uuid string, id int, spec string, command string) (interface{}, error) {
 	agent := agentmanager.GetAgent(uuid)
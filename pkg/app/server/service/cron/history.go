@@ -0,0 +1,97 @@
+package cron
+
+import (
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/agentmanager"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/eventbus"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/policy"
+	"openeuler.org/PilotGo/PilotGo/pkg/logger"
+	"openeuler.org/PilotGo/PilotGo/pkg/utils/os/common"
+)
+
+type RunFilter = dao.CronRunFilter
+
+// 保存agent上报的执行结果，执行失败时发出告警
+func recordRun(a *agentmanager.Agent, r *common.CronRunResult) {
+	run := &dao.CronRun{
+		CronID:      r.TaskID,
+		MachineUUID: a.UUID,
+		Trigger:     r.Trigger,
+		StartTime:   r.StartTime,
+		EndTime:     r.EndTime,
+		ExitCode:    r.ExitCode,
+		Success:     r.ExitCode == 0 && r.Error == "",
+		Stdout:      r.Stdout,
+		Stderr:      r.Stderr,
+		Error:       r.Error,
+	}
+	if err := dao.AddCronRun(run); err != nil {
+		logger.Error("failed to save cron run of %s: %s", a.UUID, err.Error())
+	}
+	if !run.Success {
+		alert(a, run)
+	}
+}
+
+func alert(a *agentmanager.Agent, run *dao.CronRun) {
+	name := fmt.Sprintf("%d", run.CronID)
+	if c, err := dao.GetCron(run.CronID); err == nil {
+		name = c.TaskName
+	}
+	msg := fmt.Sprintf("agent机器%s定时任务%s执行失败，退出码:%d", a.IP, name, run.ExitCode)
+	logger.Warn(msg)
+
+	// 没有前端连接时不阻塞
+	select {
+	case agentmanager.WARN_MSG <- msg:
+	default:
+	}
+	eventbus.PublishEvent(&eventbus.EventMessage{
+		MessageType: eventbus.MsgCronFailed,
+		MachineUUID: a.UUID,
+		MessageData: run,
+	})
+}
+
+// 立即在任务的目标机器上执行一次，执行结果随后上报
+func RunNow(id int, s *policy.Subject, confirm string) error {
+	c, err := dao.GetCron(id)
+	if err != nil {
+		return fmt.Errorf("任务 %d 不存在", id)
+	}
+	if _, err := CheckCommand(s, c.Command, confirm); err != nil {
+		return err
+	}
+	members, err := Members(c)
+	if err != nil {
+		return err
+	}
+	if len(members) == 0 {
+		return fmt.Errorf("任务 %d 没有目标机器", id)
+	}
+
+	failed := []string{}
+	for _, uuid := range members {
+		agent := agentmanager.GetAgent(uuid)
+		if agent == nil {
+			failed = append(failed, uuid+": 机器不在线")
+			continue
+		}
+		if _, err := agent.CronRunNow(c.ID, c.CronSpec, c.Command); err != nil {
+			failed = append(failed, uuid+": "+err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("以下机器触发失败：%s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// 查询定时任务的执行记录
+func QueryRuns(f *RunFilter) (*[]dao.CronRun, *gorm.DB) {
+	return dao.QueryCronRuns(f)
+}
@@ -0,0 +1,209 @@
+package cron
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/agentmanager"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/policy"
+	"openeuler.org/PilotGo/PilotGo/pkg/logger"
+	"openeuler.org/PilotGo/PilotGo/pkg/utils/message/protocol"
+	"openeuler.org/PilotGo/PilotGo/pkg/utils/os/common"
+)
+
+// 单台机器的定时任务对账结果，记录对账前发现的差异
+type Report struct {
+	MachineUUID string `json:"machine_uuid"`
+	IP          string `json:"ip"`
+	// 数据库中已开启但agent上未运行的任务
+	Missing []int `json:"missing"`
+	// agent上运行但数据库中不存在或未开启的任务
+	Extra []int `json:"extra"`
+	// agent上的定义与数据库不一致的任务
+	Changed []int `json:"changed"`
+	// 命令被策略禁止而未下发的任务
+	Blocked []int `json:"blocked"`
+	// 修复差异时的错误
+	Errors []string  `json:"errors"`
+	Time   time.Time `json:"time"`
+}
+
+// 是否无需修复
+func (r *Report) Consistent() bool {
+	return len(r.Missing) == 0 && len(r.Extra) == 0 && len(r.Changed) == 0 && len(r.Blocked) == 0
+}
+
+// 各机器最近一次的对账结果
+var reports sync.Map
+
+// agent上线及定期以数据库为准同步定时任务，并记录agent上报的执行结果
+func Init() {
+	agentmanager.SetCronResultHandler(recordRun)
+	agentmanager.AddOnlineHandler(func(a *agentmanager.Agent) {
+		// 旧版本agent不支持查询定时任务列表，无法对账
+		if a == nil || a.UUID == "" || !a.Supports(protocol.CronList) {
+			return
+		}
+		if _, err := Reconcile(a.UUID); err != nil {
+			logger.Error("failed to reconcile crontab of %s: %s", a.UUID, err.Error())
+		}
+	})
+	go reconcileLoop()
+}
+
+// 以数据库为准修复agent上的定时任务
+func Reconcile(uuid string) (*Report, error) {
+	groups, err := loadGroupCrons()
+	if err != nil {
+		return nil, err
+	}
+	return reconcile(uuid, groups)
+}
+
+func reconcile(uuid string, groups []groupCron) (*Report, error) {
+	agent := agentmanager.GetAgent(uuid)
+	if agent == nil {
+		return nil, fmt.Errorf("机器 %s 不在线", uuid)
+	}
+	expected, err := expectedCrons(uuid, groups)
+	if err != nil {
+		return nil, err
+	}
+	actual, err := agent.CronList()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		MachineUUID: uuid,
+		IP:          agent.IP,
+		Missing:     []int{},
+		Extra:       []int{},
+		Changed:     []int{},
+		Blocked:     []int{},
+		Errors:      []string{},
+		Time:        time.Now(),
+	}
+	diffCrons(report, expected, actual, blocked)
+
+	running := map[int]bool{}
+	for _, t := range actual {
+		running[t.ID] = true
+	}
+	crons := map[int]*dao.CrontabList{}
+	for i := range expected {
+		crons[expected[i].ID] = &expected[i]
+	}
+	for _, id := range report.Blocked {
+		if running[id] {
+			report.fix(agent.CronStopAndDel(id))
+		}
+	}
+	for _, id := range report.Changed {
+		if _, err := agent.CronStopAndDel(id); err != nil {
+			report.fix(nil, err)
+			continue
+		}
+		report.start(agent, crons[id])
+	}
+	for _, id := range report.Missing {
+		report.start(agent, crons[id])
+	}
+	for _, id := range report.Extra {
+		report.fix(agent.CronStopAndDel(id))
+	}
+
+	if !report.Consistent() {
+		logger.Warn("crontab of %s reconciled, missing:%v extra:%v changed:%v blocked:%v",
+			uuid, report.Missing, report.Extra, report.Changed, report.Blocked)
+	}
+	reports.Store(uuid, report)
+	return report, nil
+}
+
+// 对所有在线机器进行对账
+func ReconcileAll() []*Report {
+	result := []*Report{}
+	groups, err := loadGroupCrons()
+	if err != nil {
+		logger.Error("failed to load group crontab: %s", err.Error())
+		return result
+	}
+	for _, a := range agentmanager.GetAgentList() {
+		uuid := a["agent_uuid"]
+		report, err := reconcile(uuid, groups)
+		if err != nil {
+			report = &Report{MachineUUID: uuid, Errors: []string{err.Error()}, Time: time.Now()}
+			reports.Store(uuid, report)
+		}
+		result = append(result, report)
+	}
+	return result
+}
+
+// 返回各机器最近一次的对账结果，onlyInconsistent为true时只返回存在差异的机器
+func Reports(onlyInconsistent bool) []*Report {
+	result := []*Report{}
+	reports.Range(func(_, v interface{}) bool {
+		r := v.(*Report)
+		if !onlyInconsistent || !r.Consistent() || len(r.Errors) > 0 {
+			result = append(result, r)
+		}
+		return true
+	})
+	sort.Slice(result, func(i, j int) bool { return result[i].MachineUUID < result[j].MachineUUID })
+	return result
+}
+
+// 比较数据库中应运行的任务与agent上实际运行的任务，将差异记录到report中
+func diffCrons(report *Report, expected []dao.CrontabList, actual []common.CronTask, blocked func(*dao.CrontabList) bool) {
+	running := map[int]common.CronTask{}
+	for _, t := range actual {
+		running[t.ID] = t
+	}
+	for i := range expected {
+		c := &expected[i]
+		t, ok := running[c.ID]
+		delete(running, c.ID)
+
+		switch {
+		case blocked(c):
+			report.Blocked = append(report.Blocked, c.ID)
+		case !ok:
+			report.Missing = append(report.Missing, c.ID)
+		case t.Spec != c.CronSpec || t.Command != c.Command:
+			report.Changed = append(report.Changed, c.ID)
+		}
+	}
+	for id := range running {
+		report.Extra = append(report.Extra, id)
+	}
+	sort.Ints(report.Extra)
+}
+
+func (r *Report) start(agent *agentmanager.Agent, c *dao.CrontabList) {
+	_, Err, err := agent.CronStart(c.ID, c.CronSpec, c.Command)
+	if Err != "" {
+		err = fmt.Errorf("%s", Err)
+	}
+	r.fix(nil, err)
+}
+
+func (r *Report) fix(_ interface{}, err error) {
+	if err != nil {
+		r.Errors = append(r.Errors, err.Error())
+	}
+}
+
+// 命令是否被策略禁止执行，以最后保存任务的用户身份检查
+func blocked(c *dao.CrontabList) bool {
+	d, err := policy.Evaluate(policy.UserSubjectByName(c.UserName), c.Command, "")
+	if err != nil {
+		logger.Error("failed to evaluate command policy: %s", err.Error())
+		return false
+	}
+	return d.Action == policy.ActionDeny
+}
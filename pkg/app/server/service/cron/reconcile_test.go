@@ -0,0 +1,71 @@
+package cron
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+	"openeuler.org/PilotGo/PilotGo/pkg/utils/os/common"
+)
+
+func newReport(uuid string) *Report {
+	return &Report{MachineUUID: uuid, Missing: []int{}, Extra: []int{}, Changed: []int{}, Blocked: []int{}, Errors: []string{}}
+}
+
+func TestDiffCrons(t *testing.T) {
+	expected := []dao.CrontabList{
+		{ID: 1, CronSpec: "* * * * *", Command: "date"},
+		{ID: 2, CronSpec: "0 * * * *", Command: "sync"},
+		{ID: 3, CronSpec: "0 0 * * *", Command: "backup.sh"},
+		{ID: 4, CronSpec: "*/5 * * * *", Command: "rm -rf /"},
+		{ID: 5, CronSpec: "*/5 * * * *", Command: "rm -rf /tmp/x"},
+	}
+	actual := []common.CronTask{
+		{ID: 9, Spec: "* * * * *", Command: "orphan"},
+		{ID: 1, Spec: "* * * * *", Command: "date"},
+		{ID: 2, Spec: "30 * * * *", Command: "sync"},
+		{ID: 4, Spec: "*/5 * * * *", Command: "rm -rf /"},
+		{ID: 7, Spec: "* * * * *", Command: "orphan"},
+	}
+	deny := func(c *dao.CrontabList) bool { return c.ID == 4 || c.ID == 5 }
+
+	report := newReport("m1")
+	diffCrons(report, expected, actual, deny)
+	assert.Equal(t, []int{3}, report.Missing)
+	assert.Equal(t, []int{2}, report.Changed)
+	assert.Equal(t, []int{4, 5}, report.Blocked)
+	assert.Equal(t, []int{7, 9}, report.Extra)
+	assert.False(t, report.Consistent())
+
+	allow := func(*dao.CrontabList) bool { return false }
+	report = newReport("m1")
+	diffCrons(report, expected[:2], []common.CronTask{
+		{ID: 1, Spec: "* * * * *", Command: "date"},
+		{ID: 2, Spec: "0 * * * *", Command: "sync"},
+	}, allow)
+	assert.True(t, report.Consistent())
+
+	// 命令变化同样需要重新下发
+	report = newReport("m1")
+	diffCrons(report, expected[:1], []common.CronTask{{ID: 1, Spec: "* * * * *", Command: "uptime"}}, allow)
+	assert.Equal(t, []int{1}, report.Changed)
+}
+
+func TestReports(t *testing.T) {
+	ok := newReport("b")
+	failed := newReport("c")
+	failed.Errors = append(failed.Errors, "agent disconnected")
+	drift := newReport("a")
+	drift.Extra = append(drift.Extra, 1)
+	for _, r := range []*Report{ok, failed, drift} {
+		reports.Store(r.MachineUUID, r)
+	}
+	t.Cleanup(func() {
+		for _, uuid := range []string{"a", "b", "c"} {
+			reports.Delete(uuid)
+		}
+	})
+
+	assert.Equal(t, []*Report{drift, ok, failed}, Reports(false))
+	assert.Equal(t, []*Report{drift, failed}, Reports(true))
+}
@@ -0,0 +1,170 @@
+package cron
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/batch"
+	"openeuler.org/PilotGo/PilotGo/pkg/logger"
+)
+
+// 定期对账以同步批次及部门成员的变化
+const reconcileInterval = 5 * time.Minute
+
+// 是否为按批次或部门下发的任务
+func IsGroup(c *dao.CrontabList) bool {
+	return c.MachineUUID == ""
+}
+
+// 检查任务的目标，须指定单台机器或批次及部门之一
+func ValidateTarget(c *dao.CrontabUpdate) error {
+	group := len(c.BatchIDs) > 0 || len(c.DepartIDs) > 0
+	if c.MachineUUID == "" && !group {
+		return errors.New("请指定执行任务的机器、批次或部门")
+	}
+	if c.MachineUUID != "" && group {
+		return errors.New("不能同时指定机器与批次或部门")
+	}
+	return nil
+}
+
+func JoinIDs(ids []int) string {
+	s := make([]string, 0, len(ids))
+	for _, id := range ids {
+		s = append(s, strconv.Itoa(id))
+	}
+	return strings.Join(s, ",")
+}
+
+func splitIDs(s string) []int {
+	ids := []int{}
+	for _, v := range strings.Split(s, ",") {
+		if id, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// 任务当前的目标机器，批次及部门成员变化时随之变化
+func Members(c *dao.CrontabList) ([]string, error) {
+	if !IsGroup(c) {
+		return []string{c.MachineUUID}, nil
+	}
+	uuids := dao.BatchIds2UUIDs(splitIDs(c.BatchIDs))
+	if departs := splitIDs(c.DepartIDs); len(departs) > 0 {
+		list, err := batch.DepartMachineUUIDs(departs)
+		if err != nil {
+			return nil, err
+		}
+		uuids = append(uuids, list...)
+	}
+	return dedup(uuids), nil
+}
+
+func dedup(uuids []string) []string {
+	seen := map[string]bool{}
+	result := []string{}
+	for _, u := range uuids {
+		if u != "" && !seen[u] {
+			seen[u] = true
+			result = append(result, u)
+		}
+	}
+	return result
+}
+
+// 已开启的批次及部门任务与其成员
+type groupCron struct {
+	cron    dao.CrontabList
+	members map[string]bool
+}
+
+func loadGroupCrons() ([]groupCron, error) {
+	list, err := dao.EnabledGroupCrons()
+	if err != nil {
+		return nil, err
+	}
+	result := []groupCron{}
+	for _, c := range list {
+		uuids, err := Members(&c)
+		if err != nil {
+			return nil, err
+		}
+		g := groupCron{cron: c, members: map[string]bool{}}
+		for _, u := range uuids {
+			g.members[u] = true
+		}
+		result = append(result, g)
+	}
+	return result, nil
+}
+
+// 机器上应运行的任务，包括单机任务及机器所在批次、部门的任务
+func expectedCrons(uuid string, groups []groupCron) ([]dao.CrontabList, error) {
+	list, err := dao.EnabledCrons(uuid)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range groups {
+		if g.members[uuid] {
+			list = append(list, g.cron)
+		}
+	}
+	return list, nil
+}
+
+// 批次及部门任务变化后异步同步所有在线机器
+func SyncGroups() {
+	go ReconcileAll()
+}
+
+func reconcileLoop() {
+	ticker := time.NewTicker(reconcileInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		for _, r := range ReconcileAll() {
+			if len(r.Errors) > 0 {
+				logger.Error("failed to reconcile crontab of %s: %v", r.MachineUUID, r.Errors)
+			}
+		}
+	}
+}
+
+// 单个任务在各目标机器上的执行统计
+type Summary struct {
+	Cron     *dao.CrontabList     `json:"cron"`
+	Machines []dao.CronRunSummary `json:"machines"`
+	// 当前目标机器中尚无执行记录的机器
+	NeverRun []string `json:"never_run"`
+}
+
+// 汇总任务在各目标机器上的执行记录
+func Summarize(id int) (*Summary, error) {
+	c, err := dao.GetCron(id)
+	if err != nil {
+		return nil, err
+	}
+	runs, err := dao.SummarizeCronRuns(id)
+	if err != nil {
+		return nil, err
+	}
+	members, err := Members(c)
+	if err != nil {
+		return nil, err
+	}
+	ran := map[string]bool{}
+	for _, r := range runs {
+		ran[r.MachineUUID] = true
+	}
+	never := []string{}
+	for _, u := range members {
+		if !ran[u] {
+			never = append(never, u)
+		}
+	}
+	return &Summary{Cron: c, Machines: runs, NeverRun: never}, nil
+}
@@ -0,0 +1,48 @@
+package cron
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+)
+
+func TestValidateTarget(t *testing.T) {
+	cases := []struct {
+		name  string
+		cron  dao.CrontabUpdate
+		valid bool
+	}{
+		{"machine", dao.CrontabUpdate{MachineUUID: "m1"}, true},
+		{"batches", dao.CrontabUpdate{BatchIDs: []int{1}}, true},
+		{"departs", dao.CrontabUpdate{DepartIDs: []int{2}}, true},
+		{"batches and departs", dao.CrontabUpdate{BatchIDs: []int{1}, DepartIDs: []int{2}}, true},
+		{"no target", dao.CrontabUpdate{}, false},
+		{"machine and batch", dao.CrontabUpdate{MachineUUID: "m1", BatchIDs: []int{1}}, false},
+	}
+	for _, c := range cases {
+		err := ValidateTarget(&c.cron)
+		assert.Equal(t, c.valid, err == nil, c.name)
+	}
+}
+
+func TestGroupIDs(t *testing.T) {
+	assert.Equal(t, "", JoinIDs(nil))
+	assert.Equal(t, "3,1,20", JoinIDs([]int{3, 1, 20}))
+
+	assert.Equal(t, []int{}, splitIDs(""))
+	assert.Equal(t, []int{3, 1, 20}, splitIDs("3,1,20"))
+	assert.Equal(t, []int{3, 20}, splitIDs(" 3 ,x,,20"))
+
+	assert.True(t, IsGroup(&dao.CrontabList{BatchIDs: "1"}))
+	assert.False(t, IsGroup(&dao.CrontabList{MachineUUID: "m1"}))
+}
+
+func TestMembers(t *testing.T) {
+	uuids, err := Members(&dao.CrontabList{MachineUUID: "m1", BatchIDs: "1"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"m1"}, uuids)
+
+	assert.Equal(t, []string{"a", "b", "c"}, dedup([]string{"a", "", "b", "a", "c", "b"}))
+	assert.Equal(t, []string{}, dedup(nil))
+}
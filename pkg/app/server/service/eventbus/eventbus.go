@@ -1,14 +1,25 @@
 package eventbus
 
 import (
+	"bytes"
+	"encoding/json"
+	"net/http"
 	"sync"
+	"time"
 
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/config"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
 	"openeuler.org/PilotGo/PilotGo/pkg/logger"
 )
 
 type Listener struct {
 	Name string
 	URL  string
+	// 可选，按插件授权范围过滤推送的事件，参数为事件的机器uuid
+	Allow func(machineUUID string) bool
+	// 待推送的事件，由每个监听者各自的goroutine按序列号顺序推送
+	queue chan *EventMessage
+	stop  chan struct{}
 }
 
 const (
@@ -30,26 +41,76 @@ const (
 	MsgPluginAdd = 20
 	// 插件卸载
 	MsgPluginRemove = 21
+	// 插件上线
+	MsgPluginOnline = 22
+	// 插件离线
+	MsgPluginOffline = 23
+	// 插件版本变更
+	MsgPluginUpdate = 24
+
+	// 新的审批请求
+	MsgApprovalRequest = 30
+	// 审批通过
+	MsgApprovalApproved = 31
+	// 审批被拒绝
+	MsgApprovalRejected = 32
+	// 审批请求过期
+	MsgApprovalExpired = 33
+	// 审批通过的操作执行完成
+	MsgApprovalExecuted = 34
+
+	// 定时任务执行失败
+	MsgCronFailed = 40
+	// 受管配置文件被修改
+	MsgConfigDrift = 41
+	// 监控的文件发生变化
+	MsgFileChanged = 42
+	// 文件完整性检查发现变化
+	MsgIntegrityChanged = 43
+)
+
+const (
+	// 事件日志默认保留天数
+	defaultRetentionDays = 30
+	// 事件推送超时时间
+	broadcastTimeout = 5 * time.Second
+	// 每个监听者待推送事件的队列长度，队列满时丢弃事件，由插件通过回放接口补齐
+	listenerQueueSize = 100
 )
 
 type EventMessage struct {
-	MessageType int
-	MessageData interface{}
+	Sequence    uint        `json:"sequence"`
+	MessageType int         `json:"message_type"`
+	MachineUUID string      `json:"machine_uuid"`
+	MessageData interface{} `json:"message_data"`
+	Timestamp   time.Time   `json:"timestamp"`
 }
 
 type EventBus struct {
 	sync.Mutex
 	listeners []*Listener
-	stop      chan struct{}
-	wait      sync.WaitGroup
-	event     chan *EventMessage
+	// 保证事件按序列号顺序进入各监听者的队列
+	publishLock sync.Mutex
+	wait        sync.WaitGroup
+	client      *http.Client
 }
 
+// 添加监听者并启动其推送goroutine，相同的监听者重复注册时忽略
 func (e *EventBus) AddListener(l *Listener) {
 	e.Lock()
 	defer e.Unlock()
 
+	for _, v := range e.listeners {
+		if v.Name == l.Name && v.URL == l.URL {
+			return
+		}
+	}
+	l.queue = make(chan *EventMessage, listenerQueueSize)
+	l.stop = make(chan struct{})
 	e.listeners = append(e.listeners, l)
+
+	e.wait.Add(1)
+	go e.deliver(l)
 }
 
 func (e *EventBus) RemoveListener(l *Listener) {
@@ -57,46 +118,94 @@ func (e *EventBus) RemoveListener(l *Listener) {
 	defer e.Unlock()
 
 	for index, v := range e.listeners {
-		if v.Name == l.Name && v.URL == v.URL {
+		if v.Name == l.Name && v.URL == l.URL {
+			close(v.stop)
 			e.listeners = append(e.listeners[:index], e.listeners[index+1:]...)
+			break
 		}
 	}
 }
 
-func (e *EventBus) Run() {
-	go func(e *EventBus) {
-		for {
-			select {
-			case <-e.stop:
-				logger.Info("event bus exit")
-				e.wait.Done()
-				break
-			case <-e.event:
-				e.broadcast()
-			}
-		}
-	}(e)
-}
-
 func (e *EventBus) Stop() {
-	e.wait.Add(1)
-	e.stop <- struct{}{}
+	e.Lock()
+	for _, l := range e.listeners {
+		close(l.stop)
+	}
+	e.listeners = nil
+	e.Unlock()
+
 	e.wait.Wait()
+	logger.Info("event bus exit")
 }
 
+// 先持久化事件并分配序列号，再放入各监听者的队列，不等待推送完成
 func (e *EventBus) publish(m *EventMessage) {
-	e.event <- m
+	data, err := json.Marshal(m.MessageData)
+	if err != nil {
+		logger.Error("marshal event message data failed: %s", err.Error())
+		return
+	}
+
+	e.publishLock.Lock()
+	defer e.publishLock.Unlock()
+
+	record := &dao.EventRecord{
+		MessageType: m.MessageType,
+		MachineUUID: m.MachineUUID,
+		MessageData: string(data),
+	}
+	if err := dao.RecordEvent(record); err != nil {
+		logger.Error("failed to record event: %s", err.Error())
+		return
+	}
+	m.Sequence = record.ID
+	m.Timestamp = record.CreatedAt
+
+	e.Lock()
+	defer e.Unlock()
+	for _, l := range e.listeners {
+		select {
+		case l.queue <- m:
+		default:
+			logger.Warn("event queue of listener %s is full, drop event %d", l.Name, m.Sequence)
+		}
+	}
 }
 
-func (e *EventBus) broadcast() {
-	// TODO：将event message发送给监听的listener
+// 按顺序将事件推送给监听者，推送失败的事件可通过replay接口补齐
+func (e *EventBus) deliver(l *Listener) {
+	defer e.wait.Done()
+	for {
+		select {
+		case <-l.stop:
+			return
+		case m := <-l.queue:
+			if l.Allow != nil && !l.Allow(m.MachineUUID) {
+				continue
+			}
+			body, err := json.Marshal(m)
+			if err != nil {
+				logger.Error("marshal event message failed: %s", err.Error())
+				continue
+			}
+			resp, err := e.client.Post(l.URL, "application/json", bytes.NewReader(body))
+			if err != nil {
+				logger.Warn("send event %d to listener %s failed: %s", m.Sequence, l.Name, err.Error())
+				continue
+			}
+			resp.Body.Close()
+		}
+	}
 }
 
 var globalEventBus *EventBus
 
 func Init() {
-	globalEventBus = &EventBus{}
-	globalEventBus.Run()
+	globalEventBus = &EventBus{
+		client: &http.Client{Timeout: broadcastTimeout},
+	}
+
+	go retentionCleaner()
 }
 
 func Stop() {
@@ -114,3 +223,23 @@ func RemoveListener(l *Listener) {
 func PublishEvent(m *EventMessage) {
 	globalEventBus.publish(m)
 }
+
+// 按照保留策略定期清理过期事件
+func retentionCleaner() {
+	days := config.Config().Event.RetentionDays
+	if days <= 0 {
+		days = defaultRetentionDays
+	}
+
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		n, err := dao.DeleteEventsBefore(time.Now().AddDate(0, 0, -days))
+		if err != nil {
+			logger.Error("failed to clean expired events: %s", err.Error())
+		} else if n > 0 {
+			logger.Info("cleaned %d expired events", n)
+		}
+		<-ticker.C
+	}
+}
@@ -0,0 +1,82 @@
+package eventbus
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+)
+
+func TestMessages(t *testing.T) {
+	now := time.Now()
+	records := []dao.EventRecord{
+		{ID: 3, MessageType: MsgHostAdd, MachineUUID: "m1", MessageData: `{"ip":"10.0.0.1"}`, CreatedAt: now},
+		{ID: 4, MessageType: MsgPluginAdd, MessageData: `null`, CreatedAt: now},
+	}
+
+	list := Messages(records)
+	assert.Len(t, list, 2)
+	assert.Equal(t, uint(3), list[0].Sequence)
+	assert.Equal(t, "m1", list[0].MachineUUID)
+	assert.Equal(t, now, list[0].Timestamp)
+
+	// 回放的事件与实时推送的事件序列化结果一致
+	bs, err := json.Marshal(list[0])
+	assert.Nil(t, err)
+	live, err := json.Marshal(&EventMessage{
+		Sequence:    3,
+		MessageType: MsgHostAdd,
+		MachineUUID: "m1",
+		MessageData: map[string]string{"ip": "10.0.0.1"},
+		Timestamp:   now,
+	})
+	assert.Nil(t, err)
+	assert.JSONEq(t, string(live), string(bs))
+
+	assert.Empty(t, Messages(nil))
+}
+
+func TestDeliver(t *testing.T) {
+	received := make(chan uint, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m := &EventMessage{}
+		if err := json.NewDecoder(r.Body).Decode(m); err == nil {
+			received <- m.Sequence
+		}
+	}))
+	defer server.Close()
+
+	bus := &EventBus{client: server.Client()}
+	l := &Listener{
+		Name:  "test",
+		URL:   server.URL,
+		Allow: func(machineUUID string) bool { return machineUUID != "hidden" },
+	}
+	bus.AddListener(l)
+	// 重复注册的监听者被忽略
+	bus.AddListener(&Listener{Name: "test", URL: server.URL})
+	assert.Len(t, bus.listeners, 1)
+
+	for i, uuid := range []string{"m1", "hidden", "", "m2"} {
+		l.queue <- &EventMessage{Sequence: uint(i + 1), MachineUUID: uuid}
+	}
+
+	var got []uint
+	for len(got) < 3 {
+		select {
+		case seq := <-received:
+			got = append(got, seq)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("events not delivered, got %v", got)
+		}
+	}
+	assert.Equal(t, []uint{1, 3, 4}, got)
+
+	bus.RemoveListener(l)
+	assert.Empty(t, bus.listeners)
+	bus.Stop()
+}
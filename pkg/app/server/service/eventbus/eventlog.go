@@ -0,0 +1,79 @@
+package eventbus
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+)
+
+const (
+	// 单次回放的默认及最大事件数
+	defaultReplayLimit = 100
+	maxReplayLimit     = 1000
+)
+
+// 按事件类型、机器、时间范围查询事件，返回结果供分页使用
+func QueryEvents(f *dao.EventFilter) (*[]dao.EventRecord, *gorm.DB) {
+	return dao.QueryEvents(f)
+}
+
+// 将事件日志转换为与实时推送相同的格式
+func Messages(records []dao.EventRecord) []*EventMessage {
+	list := make([]*EventMessage, 0, len(records))
+	for _, r := range records {
+		list = append(list, &EventMessage{
+			Sequence:    r.ID,
+			MessageType: r.MessageType,
+			MachineUUID: r.MachineUUID,
+			MessageData: json.RawMessage(r.MessageData),
+			Timestamp:   r.CreatedAt,
+		})
+	}
+	return list
+}
+
+// 回放插件尚未确认的事件，after为0时从插件上次确认的序列号开始，
+// machines非nil时只回放这些机器及与机器无关的事件
+func Replay(listener string, after uint, limit int, machines []string) ([]*EventMessage, error) {
+	if listener == "" {
+		return nil, errors.New("listener name is empty")
+	}
+	if limit <= 0 {
+		limit = defaultReplayLimit
+	}
+	if limit > maxReplayLimit {
+		limit = maxReplayLimit
+	}
+
+	if after == 0 {
+		acked, err := dao.GetEventAck(listener)
+		if err != nil {
+			return nil, err
+		}
+		after = acked
+	}
+
+	records, err := dao.EventsAfter(after, limit, machines)
+	if err != nil {
+		return nil, err
+	}
+	return Messages(records), nil
+}
+
+// 记录插件已确认的最后一个事件序列号，序列号不能小于已确认的序列号
+func Ack(listener string, sequence uint) error {
+	if listener == "" {
+		return errors.New("listener name is empty")
+	}
+	acked, err := dao.GetEventAck(listener)
+	if err != nil {
+		return err
+	}
+	if sequence < acked {
+		return fmt.Errorf("序列号 %d 小于已确认的序列号 %d", sequence, acked)
+	}
+	return dao.UpdateEventAck(listener, sequence)
+}
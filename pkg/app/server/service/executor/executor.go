@@ -0,0 +1,248 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/agentmanager"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/config"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service"
+	"openeuler.org/PilotGo/PilotGo/pkg/logger"
+	"openeuler.org/PilotGo/PilotGo/pkg/utils"
+)
+
+const (
+	DefaultConcurrency = 50
+	DefaultHostTimeout = 300 * time.Second
+
+	ErrAgentOffline = "agent is offline or unreachable"
+	ErrCancelled    = "cancelled"
+	// 执行中被取消，server不再等待结果，但agent上的命令不会被终止
+	ErrAbandoned = "abandoned, the command may still be running on the agent"
+)
+
+// 在单台机器上执行的操作
+type Task func(agent *agentmanager.Agent) (interface{}, error)
+
+// 单台机器的执行结果
+type Result struct {
+	MachineUUID string      `json:"machine_uuid"`
+	MachineIP   string      `json:"machine_ip"`
+	Data        interface{} `json:"data"`
+	Error       string      `json:"error"`
+}
+
+func (r *Result) OK() bool {
+	return r.Error == ""
+}
+
+type Options struct {
+	// 同时执行的最大机器数
+	Concurrency int
+	// 单台机器的超时时间
+	Timeout time.Duration
+	// 可选，返回单台机器的执行上下文，取消后未开始的机器不再执行，执行中的机器不再等待结果
+	HostContext func(uuid string) context.Context
+	// 可选，单台机器开始执行及执行结束时回调
+	OnStart  func(r *Result)
+	OnFinish func(r *Result)
+}
+
+// 从配置文件中读取默认执行参数
+func DefaultOptions() *Options {
+	opts := &Options{
+		Concurrency: DefaultConcurrency,
+		Timeout:     DefaultHostTimeout,
+	}
+	conf := config.Config().Executor
+	if conf.MaxConcurrency > 0 {
+		opts.Concurrency = conf.MaxConcurrency
+	}
+	if conf.HostTimeout > 0 {
+		opts.Timeout = time.Duration(conf.HostTimeout) * time.Second
+	}
+	return opts
+}
+
+// 使用默认参数在多台机器上并发执行操作
+func Run(uuids []string, task Task) []*Result {
+	return RunWithOptions(uuids, DefaultOptions(), task)
+}
+
+// 在多台机器上并发执行操作，结果顺序与uuids一致，单台机器失败、离线或超时不影响其他机器
+func RunWithOptions(uuids []string, opts *Options, task Task) []*Result {
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	results := make([]*Result, len(uuids))
+	sem := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+	for i, uuid := range uuids {
+		results[i] = &Result{MachineUUID: uuid}
+
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(r *Result) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			runOne(r, opts, task)
+			if opts.OnFinish != nil {
+				opts.OnFinish(r)
+			}
+		}(results[i])
+	}
+	wg.Wait()
+
+	return results
+}
+
+func runOne(r *Result, opts *Options, task Task) {
+	ctx := context.Background()
+	if opts.HostContext != nil {
+		ctx = opts.HostContext(r.MachineUUID)
+	}
+	if ctx.Err() != nil {
+		r.Error = ErrCancelled
+		return
+	}
+
+	agent := agentmanager.GetAgent(r.MachineUUID)
+	if agent == nil {
+		r.Error = ErrAgentOffline
+		return
+	}
+	r.MachineIP = agent.IP
+	if opts.OnStart != nil {
+		opts.OnStart(r)
+	}
+
+	timeout := opts.Timeout
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	type output struct {
+		data interface{}
+		err  error
+	}
+	done := make(chan output, 1)
+	go func() {
+		defer func() {
+			if e := recover(); e != nil {
+				done <- output{err: fmt.Errorf("panic: %v", e)}
+			}
+		}()
+		// 超时或取消后agent请求随之返回，不会一直阻塞
+		data, err := task(agent.WithContext(ctx))
+		done <- output{data: data, err: err}
+	}()
+
+	select {
+	case o := <-done:
+		r.Data = o.data
+		if o.err != nil {
+			r.Error = o.err.Error()
+		}
+	case <-ctx.Done():
+		// agent可能仍在执行，此处只放弃等待
+		if ctx.Err() == context.DeadlineExceeded {
+			logger.Warn("agent %s execute timeout after %s", r.MachineUUID, timeout)
+			r.Error = "execute timeout after " + timeout.String()
+		} else {
+			r.Error = ErrAbandoned
+		}
+	}
+}
+
+// 合并agent接口返回的错误，接口返回的第二个值为agent上报的错误信息，失败时已包含在err中
+func Output(data interface{}, stderr string, err error) (interface{}, error) {
+	return data, err
+}
+
+// 命令或脚本以返回码判断是否执行成功，stderr有输出不视为失败
+func CommandOutput(result *utils.CmdResult, err error) (interface{}, error) {
+	if err != nil {
+		return result, err
+	}
+	if result != nil && result.TimedOut {
+		return result, errors.New("script execution timeout")
+	}
+	if result != nil && result.OOMKilled {
+		return result, errors.New("script killed by memory limit")
+	}
+	if result != nil && result.RetCode != 0 {
+		return result, fmt.Errorf("exit code %d", result.RetCode)
+	}
+	return result, nil
+}
+
+// 批量操作的机器日志
+type ActionLog struct {
+	UserName   string
+	DepartName string
+	// 日志所属模块，如service.LogTypeRPM
+	Type string
+	// 操作动作，如service.RPMInstall
+	Action string
+	// 操作对象，如软件包名
+	Object string
+	// 单台机器执行成功时记录的信息
+	SuccessMessage string
+}
+
+// 执行批量操作并记录AgentLogParent及AgentLog，返回各机器结果及是否全部成功
+func RunWithLog(uuids []string, l *ActionLog, task Task) ([]*Result, bool) {
+	parentId, err := dao.ParentAgentLog(dao.AgentLogParent{
+		UserName:   l.UserName,
+		DepartName: l.DepartName,
+		Type:       l.Type,
+	})
+	if err != nil {
+		logger.Error("failed to save agent parent log: %s", err.Error())
+	}
+
+	results := Run(uuids, task)
+
+	statusCodes := make([]string, 0, len(results))
+	for _, r := range results {
+		log := dao.AgentLog{
+			LogParentID:     parentId,
+			IP:              r.MachineIP,
+			MachineUUID:     r.MachineUUID,
+			OperationObject: l.Object,
+			Action:          l.Action,
+			StatusCode:      http.StatusOK,
+			Message:         l.SuccessMessage,
+		}
+		if !r.OK() {
+			log.StatusCode = http.StatusBadRequest
+			log.Message = r.Error
+			if r.MachineIP == "" {
+				log.Message = r.MachineUUID + ": " + r.Error
+			}
+		}
+		if err := dao.AgentLogMessage(log); err != nil {
+			logger.Error("failed to save agent log: %s", err.Error())
+		}
+		statusCodes = append(statusCodes, strconv.Itoa(log.StatusCode))
+	}
+
+	if len(statusCodes) > 0 {
+		if err := dao.UpdateParentAgentLog(parentId, service.BatchActionStatus(statusCodes)); err != nil {
+			logger.Error("failed to update agent parent log: %s", err.Error())
+		}
+	}
+	return results, service.ActionStatus(statusCodes)
+}
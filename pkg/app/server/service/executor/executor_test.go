@@ -0,0 +1,140 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/agentmanager"
+	"openeuler.org/PilotGo/PilotGo/pkg/utils"
+)
+
+func addAgents(t *testing.T, uuids ...string) {
+	for _, uuid := range uuids {
+		agentmanager.AddAgent(&agentmanager.Agent{UUID: uuid, IP: "ip-" + uuid})
+	}
+	t.Cleanup(func() {
+		for _, uuid := range uuids {
+			agentmanager.DeleteAgent(uuid)
+		}
+	})
+}
+
+func TestRunWithOptions(t *testing.T) {
+	addAgents(t, "exec-ok", "exec-fail", "exec-panic", "exec-slow")
+
+	task := func(a *agentmanager.Agent) (interface{}, error) {
+		switch a.UUID {
+		case "exec-fail":
+			return nil, errors.New("failed")
+		case "exec-panic":
+			panic("boom")
+		case "exec-slow":
+			time.Sleep(time.Second)
+		}
+		return a.UUID, nil
+	}
+
+	var finished int32
+	opts := &Options{
+		Concurrency: 2,
+		Timeout:     200 * time.Millisecond,
+		OnFinish:    func(r *Result) { atomic.AddInt32(&finished, 1) },
+	}
+	uuids := []string{"exec-ok", "exec-offline", "exec-fail", "exec-panic", "exec-slow"}
+	results := RunWithOptions(uuids, opts, task)
+
+	assert.Len(t, results, len(uuids))
+	for i, r := range results {
+		// 结果顺序与输入一致
+		assert.Equal(t, uuids[i], r.MachineUUID)
+	}
+	assert.True(t, results[0].OK())
+	assert.Equal(t, "exec-ok", results[0].Data)
+	assert.Equal(t, "ip-exec-ok", results[0].MachineIP)
+	assert.Equal(t, ErrAgentOffline, results[1].Error)
+	assert.Equal(t, "failed", results[2].Error)
+	assert.Equal(t, "panic: boom", results[3].Error)
+	assert.Contains(t, results[4].Error, "execute timeout")
+	assert.Equal(t, int32(len(uuids)), finished)
+}
+
+func TestRunConcurrency(t *testing.T) {
+	uuids := []string{"exec-c1", "exec-c2", "exec-c3", "exec-c4", "exec-c5"}
+	addAgents(t, uuids...)
+
+	var running, peak int32
+	task := func(a *agentmanager.Agent) (interface{}, error) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return nil, nil
+	}
+	RunWithOptions(uuids, &Options{Concurrency: 2}, task)
+	assert.LessOrEqual(t, peak, int32(2))
+}
+
+func TestRunCancelled(t *testing.T) {
+	addAgents(t, "exec-pending", "exec-running")
+
+	// 未开始执行时已取消的机器不再执行，执行中取消的机器放弃等待结果
+	pending, cancelPending := context.WithCancel(context.Background())
+	cancelPending()
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	opts := &Options{
+		HostContext: func(uuid string) context.Context {
+			if uuid == "exec-pending" {
+				return pending
+			}
+			return ctx
+		},
+		OnStart: func(r *Result) { close(started) },
+	}
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	results := RunWithOptions([]string{"exec-pending", "exec-running"}, opts, func(a *agentmanager.Agent) (interface{}, error) {
+		time.Sleep(time.Second)
+		return nil, nil
+	})
+	assert.Equal(t, ErrCancelled, results[0].Error)
+	assert.Equal(t, ErrAbandoned, results[1].Error)
+}
+
+func TestCommandOutput(t *testing.T) {
+	cases := []struct {
+		name   string
+		result *utils.CmdResult
+		err    error
+		msg    string
+	}{
+		{"success", &utils.CmdResult{Stdout: "ok"}, nil, ""},
+		{"stderr only", &utils.CmdResult{Stderr: "warning"}, nil, ""},
+		{"exit code", &utils.CmdResult{RetCode: 2}, nil, "exit code 2"},
+		{"timeout", &utils.CmdResult{RetCode: -1, TimedOut: true}, nil, "script execution timeout"},
+		{"oom", &utils.CmdResult{RetCode: 137, OOMKilled: true}, nil, "script killed by memory limit"},
+		{"send error", nil, errors.New("agent disconnected"), "agent disconnected"},
+		{"no result", nil, nil, ""},
+	}
+	for _, c := range cases {
+		data, err := CommandOutput(c.result, c.err)
+		assert.Equal(t, c.result, data, c.name)
+		if c.msg == "" {
+			assert.Nil(t, err, c.name)
+		} else if assert.NotNil(t, err, c.name) {
+			assert.Equal(t, c.msg, err.Error(), c.name)
+		}
+	}
+}
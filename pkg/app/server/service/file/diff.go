@@ -0,0 +1,243 @@
+package file
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/agentmanager"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+)
+
+// 比较的版本
+const (
+	// 受管文件的当前版本
+	VersionCurrent = "current"
+	// agent上实际的文件
+	VersionLive = "live"
+)
+
+// 比较结果的格式
+const (
+	DiffUnified    = "unified"
+	DiffSideBySide = "side"
+)
+
+// 参与比较的文件版本信息
+type VersionInfo struct {
+	Version     string    `json:"version"`
+	Name        string    `json:"name"`
+	User        string    `json:"user"`
+	UserDept    string    `json:"userDept"`
+	Description string    `json:"description"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	MachineUUID string    `json:"uuid,omitempty"`
+	Hash        string    `json:"hash"`
+}
+
+// 并排比较中的一行，行号从1开始，为0表示该侧无对应行
+type DiffLine struct {
+	Op      string `json:"op"`
+	LeftNo  int    `json:"left_no"`
+	Left    string `json:"left"`
+	RightNo int    `json:"right_no"`
+	Right   string `json:"right"`
+}
+
+type DiffResult struct {
+	From    *VersionInfo `json:"from"`
+	To      *VersionInfo `json:"to"`
+	Same    bool         `json:"same"`
+	Unified string       `json:"unified,omitempty"`
+	Lines   []DiffLine   `json:"lines,omitempty"`
+}
+
+// 比较文件的两个版本，版本为current、live或历史版本id，live时需指定机器uuid
+func Diff(id int, from, to, uuid, mode string) (*DiffResult, error) {
+	f, err := dao.GetFile(id)
+	if err != nil {
+		return nil, fmt.Errorf("文件 %d 不存在", id)
+	}
+	if from == "" {
+		from = VersionCurrent
+	}
+	if to == "" {
+		to = VersionCurrent
+	}
+	if mode == "" {
+		mode = DiffUnified
+	}
+	if mode != DiffUnified && mode != DiffSideBySide {
+		return nil, fmt.Errorf("unsupported diff mode: %s", mode)
+	}
+
+	a, aInfo, err := version(f, from, uuid)
+	if err != nil {
+		return nil, err
+	}
+	b, bInfo, err := version(f, to, uuid)
+	if err != nil {
+		return nil, err
+	}
+	// 与机器上的文件比较时，模板文件按该机器的变量渲染后再比较
+	if f.Template && (from == VersionLive || to == VersionLive) {
+		if a, err = renderFor(a, aInfo, uuid); err != nil {
+			return nil, err
+		}
+		if b, err = renderFor(b, bInfo, uuid); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &DiffResult{From: aInfo, To: bInfo, Same: aInfo.Hash == bInfo.Hash}
+	if mode == DiffSideBySide {
+		result.Lines = sideBySide(a, b)
+		return result, nil
+	}
+	result.Unified, err = unifiedDiff(a, b, label(f, aInfo), label(f, bInfo))
+	return result, err
+}
+
+// 获取文件指定版本的内容及版本信息
+func version(f *Files, v string, uuid string) (string, *VersionInfo, error) {
+	switch v {
+	case VersionCurrent:
+		return f.File, &VersionInfo{
+			Version:     v,
+			Name:        f.FileName,
+			User:        f.UserUpdate,
+			UserDept:    f.UserDept,
+			Description: f.Description,
+			UpdatedAt:   f.UpdatedAt,
+			Hash:        hash(f.File),
+		}, nil
+	case VersionLive:
+		if uuid == "" {
+			return "", nil, fmt.Errorf("比较机器上的文件时须指定uuid")
+		}
+		agent := agentmanager.GetAgent(uuid)
+		if agent == nil {
+			return "", nil, fmt.Errorf("机器 %s 不在线", uuid)
+		}
+		text, Err, err := agent.ReadFile(fullPath(f))
+		if err != nil {
+			return "", nil, fmt.Errorf("读取文件失败: %s", Err)
+		}
+		return text, &VersionInfo{
+			Version:     v,
+			Name:        f.FileName,
+			UpdatedAt:   time.Now(),
+			MachineUUID: uuid,
+			Hash:        hash(text),
+		}, nil
+	}
+
+	historyID, err := strconv.Atoi(v)
+	if err != nil {
+		return "", nil, fmt.Errorf("版本 %s 格式有误", v)
+	}
+	h, err := dao.GetHistoryFile(historyID)
+	if err != nil || h.FileID != f.ID {
+		return "", nil, fmt.Errorf("文件 %d 不存在历史版本 %s", f.ID, v)
+	}
+	return h.File, &VersionInfo{
+		Version:     v,
+		Name:        h.FileName,
+		User:        h.UserUpdate,
+		UserDept:    h.UserDept,
+		Description: h.Description,
+		UpdatedAt:   h.UpdatedAt,
+		Hash:        hash(h.File),
+	}, nil
+}
+
+// 渲染保存的模板版本，机器上的实际文件保持不变
+func renderFor(text string, v *VersionInfo, uuid string) (string, error) {
+	if v.Version == VersionLive {
+		return text, nil
+	}
+	rendered, _, err := Render(text, uuid)
+	if err != nil {
+		return "", err
+	}
+	v.Hash = hash(rendered)
+	return rendered, nil
+}
+
+func label(f *Files, v *VersionInfo) string {
+	if v.MachineUUID != "" {
+		return v.MachineUUID + ":" + fullPath(f)
+	}
+	return f.FileName + "@" + v.Version
+}
+
+func unifiedDiff(a, b, fromFile, toFile string) (string, error) {
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(a),
+		B:        splitLines(b),
+		FromFile: fromFile,
+		ToFile:   toFile,
+		Context:  3,
+	})
+}
+
+// 按行并排比较，op为equal、replace、delete或insert
+func sideBySide(a, b string) []DiffLine {
+	left := splitLines(a)
+	right := splitLines(b)
+	lines := []DiffLine{}
+	for _, op := range difflib.NewMatcher(left, right).GetOpCodes() {
+		n := op.I2 - op.I1
+		if m := op.J2 - op.J1; m > n {
+			n = m
+		}
+		for k := 0; k < n; k++ {
+			l := DiffLine{Op: opName(op.Tag)}
+			if i := op.I1 + k; i < op.I2 {
+				l.LeftNo = i + 1
+				l.Left = trimNewline(left[i])
+			}
+			if j := op.J1 + k; j < op.J2 {
+				l.RightNo = j + 1
+				l.Right = trimNewline(right[j])
+			}
+			lines = append(lines, l)
+		}
+	}
+	return lines
+}
+
+// 按行切分并保留换行符，最后一行缺少换行符时补齐
+func splitLines(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if last := lines[len(lines)-1]; last == "" {
+		lines = lines[:len(lines)-1]
+	} else {
+		lines[len(lines)-1] = last + "\n"
+	}
+	return lines
+}
+
+func opName(tag byte) string {
+	switch tag {
+	case 'r':
+		return "replace"
+	case 'd':
+		return "delete"
+	case 'i':
+		return "insert"
+	}
+	return "equal"
+}
+
+func trimNewline(s string) string {
+	if len(s) > 0 && s[len(s)-1] == '\n' {
+		return s[:len(s)-1]
+	}
+	return s
+}
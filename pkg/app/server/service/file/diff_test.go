@@ -0,0 +1,62 @@
+package file
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitLines(t *testing.T) {
+	cases := []struct {
+		text  string
+		lines []string
+	}{
+		{"", []string{}},
+		{"a", []string{"a\n"}},
+		{"a\n", []string{"a\n"}},
+		{"a\nb", []string{"a\n", "b\n"}},
+		{"a\n\nb\n", []string{"a\n", "\n", "b\n"}},
+		{"\n", []string{"\n"}},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.lines, splitLines(c.text), "%q", c.text)
+	}
+}
+
+func TestSideBySide(t *testing.T) {
+	cases := []struct {
+		name  string
+		a, b  string
+		lines []DiffLine
+	}{
+		{"same", "a\nb\n", "a\nb\n", []DiffLine{
+			{Op: "equal", LeftNo: 1, Left: "a", RightNo: 1, Right: "a"},
+			{Op: "equal", LeftNo: 2, Left: "b", RightNo: 2, Right: "b"},
+		}},
+		{"missing trailing newline", "a\nb", "a\nb\n", []DiffLine{
+			{Op: "equal", LeftNo: 1, Left: "a", RightNo: 1, Right: "a"},
+			{Op: "equal", LeftNo: 2, Left: "b", RightNo: 2, Right: "b"},
+		}},
+		{"replace", "a\nb\nc\n", "a\nx\nc\n", []DiffLine{
+			{Op: "equal", LeftNo: 1, Left: "a", RightNo: 1, Right: "a"},
+			{Op: "replace", LeftNo: 2, Left: "b", RightNo: 2, Right: "x"},
+			{Op: "equal", LeftNo: 3, Left: "c", RightNo: 3, Right: "c"},
+		}},
+		{"insert", "a\n", "a\nb\n", []DiffLine{
+			{Op: "equal", LeftNo: 1, Left: "a", RightNo: 1, Right: "a"},
+			{Op: "insert", RightNo: 2, Right: "b"},
+		}},
+		{"delete", "a\nb\n", "b\n", []DiffLine{
+			{Op: "delete", LeftNo: 1, Left: "a"},
+			{Op: "equal", LeftNo: 2, Left: "b", RightNo: 1, Right: "b"},
+		}},
+		{"replace with more lines", "a\n", "x\ny\n", []DiffLine{
+			{Op: "replace", LeftNo: 1, Left: "a", RightNo: 1, Right: "x"},
+			{Op: "replace", RightNo: 2, Right: "y"},
+		}},
+		{"empty", "", "", []DiffLine{}},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.lines, sideBySide(c.a, c.b), c.name)
+	}
+}
@@ -0,0 +1,290 @@
+package file
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/agentmanager"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/eventbus"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/executor"
+	"openeuler.org/PilotGo/PilotGo/pkg/logger"
+)
+
+// 漂移检查结果
+const (
+	DriftConsistent = "consistent"
+	DriftDrifted    = "drifted"
+	DriftMissing    = "missing"
+	DriftOffline    = "offline"
+	DriftError      = "error"
+)
+
+// 定期检查受管配置文件的间隔
+const driftScanInterval = time.Hour
+
+type FileDrift = dao.FileDrift
+type DriftFilter = dao.FileDriftFilter
+
+// 定期检查所有设置了管控批次的文件
+func DriftInit() {
+	go func() {
+		ticker := time.NewTicker(driftScanInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			ScanAll()
+		}
+	}()
+}
+
+// 检查所有受管文件，返回存在漂移的检查结果
+func ScanAll() []FileDrift {
+	result := []FileDrift{}
+	files, err := dao.ControlledFiles()
+	if err != nil {
+		logger.Error("failed to get controlled files: %s", err.Error())
+		return result
+	}
+	for i := range files {
+		list, err := scan(&files[i], nil)
+		if err != nil {
+			logger.Error("failed to scan drift of file %d: %s", files[i].ID, err.Error())
+			continue
+		}
+		for _, d := range list {
+			if d.State != DriftConsistent {
+				result = append(result, d)
+			}
+		}
+	}
+	return result
+}
+
+// 检查文件在管控批次各机器上是否被修改
+func ScanFile(id int) ([]FileDrift, error) {
+	f, err := dao.GetFile(id)
+	if err != nil {
+		return nil, fmt.Errorf("文件 %d 不存在", id)
+	}
+	return scan(f, nil)
+}
+
+func QueryDrifts(f *DriftFilter) (*[]FileDrift, *gorm.DB) {
+	return dao.QueryFileDrifts(f)
+}
+
+// 检查文件在指定机器上的状态，uuids为空时检查管控批次的所有机器
+func scan(f *Files, uuids []string) ([]FileDrift, error) {
+	targets := uuids
+	if len(targets) == 0 {
+		var err error
+		if targets, err = ControlledUUIDs(f.ControlledBatch); err != nil {
+			return nil, err
+		}
+	}
+
+	list := make([]FileDrift, 0, len(targets))
+	for _, uuid := range targets {
+		d := check(f, uuid)
+		if d.State == DriftDrifted || d.State == DriftMissing {
+			logger.Warn("config file %s drifted on %s: %s", fullPath(f), uuid, d.State)
+			eventbus.PublishEvent(&eventbus.EventMessage{
+				MessageType: eventbus.MsgConfigDrift,
+				MachineUUID: uuid,
+				MessageData: d,
+			})
+		}
+		list = append(list, d)
+	}
+	if err := dao.ReplaceFileDrifts(f.ID, uuids, list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// 读取机器上的文件，与应下发的内容按hash比较
+func check(f *Files, uuid string) FileDrift {
+	d := FileDrift{FileID: f.ID, MachineUUID: uuid, CheckedAt: time.Now()}
+	agent := agentmanager.GetAgent(uuid)
+	if agent == nil {
+		d.State = DriftOffline
+		return d
+	}
+	d.IP = agent.IP
+
+	expected, err := expectedContent(f, uuid)
+	if err != nil {
+		d.State = DriftError
+		d.Error = err.Error()
+		return d
+	}
+	d.ExpectedHash = hash(expected)
+
+	actual, Err, err := agent.ReadFile(fullPath(f))
+	if err != nil {
+		d.State = DriftError
+		d.Error = err.Error()
+		if strings.Contains(Err, "no such file") {
+			d.State = DriftMissing
+		}
+		return d
+	}
+	compare(&d, f, expected, actual)
+	return d
+}
+
+// 按hash比较应下发的内容与机器上的内容，不一致时记录差异
+func compare(d *FileDrift, f *Files, expected, actual string) {
+	d.ExpectedHash = hash(expected)
+	d.ActualHash = hash(actual)
+	if d.ActualHash == d.ExpectedHash {
+		d.State = DriftConsistent
+		return
+	}
+
+	d.State = DriftDrifted
+	diff, err := unifiedDiff(expected, actual, "pilotgo/"+f.FileName, d.MachineUUID+":"+fullPath(f))
+	d.Diff = diff
+	if err != nil {
+		d.Error = err.Error()
+	}
+}
+
+// 将受管文件重新下发到机器，uuids为空时下发到所有存在漂移的机器
+func Reapply(id int, uuids []string, user, userDept string) ([]*executor.Result, error) {
+	f, err := dao.GetFile(id)
+	if err != nil {
+		return nil, fmt.Errorf("文件 %d 不存在", id)
+	}
+	if len(uuids) == 0 {
+		uuids = driftedUUIDs(id)
+	}
+	if len(uuids) == 0 {
+		return nil, errors.New("没有需要重新下发的机器")
+	}
+
+	l := &executor.ActionLog{
+		UserName:       user,
+		DepartName:     userDept,
+		Type:           service.LogTypeBroadcast,
+		Action:         service.BroadcastFile,
+		Object:         f.FileName,
+		SuccessMessage: "配置文件重新下发成功",
+	}
+	results, ok := executor.RunWithLog(uuids, l, func(agent *agentmanager.Agent) (interface{}, error) {
+		text, err := expectedContent(f, agent.UUID)
+		if err != nil {
+			return nil, err
+		}
+		return Install(agent, f.FilePath, f.FileName, text, FileHooks(f))
+	})
+	if _, err := scan(f, uuids); err != nil {
+		logger.Error("failed to rescan file %d: %s", id, err.Error())
+	}
+	if !ok {
+		return results, errors.New("配置文件重新下发失败")
+	}
+	return results, nil
+}
+
+// 以机器上的文件内容作为受管文件的新版本
+func Adopt(id int, uuid string, user, userDept string) error {
+	f, err := dao.GetFile(id)
+	if err != nil {
+		return fmt.Errorf("文件 %d 不存在", id)
+	}
+	if f.Template {
+		return errors.New("模板文件不能采纳渲染后的内容，请手动修改模板")
+	}
+	agent := agentmanager.GetAgent(uuid)
+	if agent == nil {
+		return fmt.Errorf("机器 %s 不在线", uuid)
+	}
+	actual, Err, err := agent.ReadFile(fullPath(f))
+	if err != nil {
+		return fmt.Errorf("读取文件失败: %s", Err)
+	}
+
+	f.File = actual
+	f.UserUpdate = user
+	f.UserDept = userDept
+	if err := Update(&FileUpdate{Files: *f}); err != nil {
+		return err
+	}
+	_, err = scan(f, nil)
+	return err
+}
+
+func driftedUUIDs(id int) []string {
+	uuids := []string{}
+	list, _ := dao.QueryFileDrifts(&DriftFilter{FileID: id})
+	for _, d := range *list {
+		if d.State == DriftDrifted || d.State == DriftMissing {
+			uuids = append(uuids, d.MachineUUID)
+		}
+	}
+	return uuids
+}
+
+// 解析管控批次，支持以逗号分隔的批次id或批次名称
+func ControlledUUIDs(controlled string) ([]string, error) {
+	ids := []int{}
+	names := map[string]bool{}
+	for _, v := range strings.Split(controlled, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if id, err := strconv.Atoi(v); err == nil {
+			ids = append(ids, id)
+		} else {
+			names[v] = true
+		}
+	}
+	if len(names) > 0 {
+		batches, err := dao.GetBatch()
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range batches {
+			if names[b.Name] {
+				ids = append(ids, int(b.ID))
+			}
+		}
+	}
+
+	uuids := []string{}
+	exist := map[string]bool{}
+	for _, uuid := range dao.BatchIds2UUIDs(ids) {
+		if uuid != "" && !exist[uuid] {
+			exist[uuid] = true
+			uuids = append(uuids, uuid)
+		}
+	}
+	return uuids, nil
+}
+
+// 机器上应有的文件内容，模板文件按机器渲染
+func expectedContent(f *Files, uuid string) (string, error) {
+	if !f.Template {
+		return f.File, nil
+	}
+	text, _, err := Render(f.File, uuid)
+	return text, err
+}
+
+func fullPath(f *Files) string {
+	return f.FilePath + "/" + f.FileName
+}
+
+func hash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
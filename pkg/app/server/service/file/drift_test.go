@@ -0,0 +1,46 @@
+package file
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompare(t *testing.T) {
+	f := &Files{ID: 1, FilePath: "/etc/nginx", FileName: "nginx.conf", File: "worker_processes 4;\n"}
+
+	d := FileDrift{FileID: f.ID, MachineUUID: "m1"}
+	compare(&d, f, f.File, "worker_processes 4;\n")
+	assert.Equal(t, DriftConsistent, d.State)
+	assert.Equal(t, d.ExpectedHash, d.ActualHash)
+	assert.Equal(t, "", d.Diff)
+
+	d = FileDrift{FileID: f.ID, MachineUUID: "m1"}
+	compare(&d, f, f.File, "worker_processes 8;\n")
+	assert.Equal(t, DriftDrifted, d.State)
+	assert.NotEqual(t, d.ExpectedHash, d.ActualHash)
+	assert.True(t, strings.HasPrefix(d.Diff, "--- pilotgo/nginx.conf\n+++ m1:/etc/nginx/nginx.conf\n"), d.Diff)
+	assert.Contains(t, d.Diff, "-worker_processes 4;")
+	assert.Contains(t, d.Diff, "+worker_processes 8;")
+	assert.Equal(t, "", d.Error)
+}
+
+func TestCheckOffline(t *testing.T) {
+	f := &Files{ID: 1, FilePath: "/etc", FileName: "motd"}
+	d := check(f, "drift-offline")
+	assert.Equal(t, DriftOffline, d.State)
+	assert.Equal(t, 1, d.FileID)
+	assert.Equal(t, "drift-offline", d.MachineUUID)
+	assert.False(t, d.CheckedAt.IsZero())
+}
+
+func TestExpectedContent(t *testing.T) {
+	f := &Files{FilePath: "/etc", FileName: "motd", File: "hello {{ .Hostname }}"}
+	text, err := expectedContent(f, "m1")
+	assert.Nil(t, err)
+	assert.Equal(t, "hello {{ .Hostname }}", text)
+	assert.Equal(t, "/etc/motd", fullPath(f))
+	assert.Equal(t, hash("a"), hash("a"))
+	assert.NotEqual(t, hash("a"), hash("a\n"))
+}
@@ -21,6 +21,7 @@ import (
 	"time"
 
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/policy"
 	"openeuler.org/PilotGo/PilotGo/pkg/logger"
 )
 
@@ -28,6 +29,15 @@ type Files = dao.Files
 type SearchFile = dao.SearchFile
 type HistoryFiles = dao.HistoryFiles
 
+// 修改受管文件的参数，模板及校验、生效设置未提供时保持不变
+type FileUpdate struct {
+	Files
+	Template       *bool   `json:"template"`
+	ValidateCmd    *string `json:"validate"`
+	ActivateAction *string `json:"activate"`
+	ActivateTarget *string `json:"activate_target"`
+}
+
 type DeleteFiles struct {
 	FileIDs []int `json:"ids"`
 }
@@ -46,6 +56,14 @@ type FileBroadcast struct {
 	User     string `json:"user"`
 	UserDept string `json:"userDept"`
 	Text     string `json:"file"`
+	// 是否按机器渲染模板后下发
+	Template bool `json:"template"`
+	// 可选，受管文件id，不为0时使用该文件的模板及校验、生效设置
+	ID int `json:"id"`
+	// 可选，校验命令及生效方式
+	Hooks *Hooks `json:"hooks"`
+	// 校验及生效命令为危险命令时的确认码
+	Confirm string `json:"confirm"`
 }
 
 // 获取时间的日期函数 => 20200426-17:36:04
@@ -95,6 +113,14 @@ func SaveToDatabase(file *Files) error {
 	if len(text) == 0 {
 		return errors.New("请重新检查文件内容")
 	}
+	if file.Template {
+		if _, err := ParseTemplate(text); err != nil {
+			return err
+		}
+	}
+	if err := FileHooks(file).Check(policy.UserSubjectByName(file.UserUpdate), file.Confirm); err != nil {
+		return err
+	}
 
 	fd := Files{
 		UserUpdate:      file.UserUpdate,
@@ -106,6 +132,10 @@ func SaveToDatabase(file *Files) error {
 		ControlledBatch: batchId,
 		TakeEffect:      file.TakeEffect,
 		File:            text,
+		Template:        file.Template,
+		ValidateCmd:     file.ValidateCmd,
+		ActivateAction:  file.ActivateAction,
+		ActivateTarget:  file.ActivateTarget,
 	}
 	return dao.SaveFile(fd)
 }
@@ -120,12 +150,46 @@ func Delete(fileIds []int) error {
 		if err != nil {
 			logger.Error(err.Error())
 		}
+		err = dao.DeleteFileDrifts(fileId)
+		if err != nil {
+			logger.Error(err.Error())
+		}
 	}
 	return nil
 }
-func Update(file *Files) error {
+func Update(req *FileUpdate) error {
+	file := &req.Files
 	id := file.ID
-	err := dao.SaveHistoryFile(id)
+	old, err := dao.GetFile(id)
+	if err != nil {
+		return errors.New("id有误,请重新确认该文件是否存在")
+	}
+	file.Template = old.Template
+	if req.Template != nil {
+		file.Template = *req.Template
+	}
+	file.ValidateCmd = old.ValidateCmd
+	if req.ValidateCmd != nil {
+		file.ValidateCmd = *req.ValidateCmd
+	}
+	file.ActivateAction = old.ActivateAction
+	if req.ActivateAction != nil {
+		file.ActivateAction = *req.ActivateAction
+	}
+	file.ActivateTarget = old.ActivateTarget
+	if req.ActivateTarget != nil {
+		file.ActivateTarget = *req.ActivateTarget
+	}
+
+	if file.Template {
+		if _, err := ParseTemplate(file.File); err != nil {
+			return err
+		}
+	}
+	if err := FileHooks(file).Check(policy.UserSubjectByName(file.UserUpdate), file.Confirm); err != nil {
+		return err
+	}
+	err = dao.SaveHistoryFile(id)
 	if err != nil {
 		return err
 	}
@@ -166,7 +230,10 @@ func Update(file *Files) error {
 		TakeEffect:      file.TakeEffect,
 		File:            text,
 	}
-	return dao.UpdateFile(id, f)
+	if err := dao.UpdateFile(id, f); err != nil {
+		return err
+	}
+	return dao.UpdateFileSettings(id, *file)
 }
 
 func LastFileRollBack(file *RollBackFiles) error {
@@ -0,0 +1,89 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/agentmanager"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/executor"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/policy"
+	"openeuler.org/PilotGo/PilotGo/pkg/utils/os/common"
+)
+
+// 审计日志中配置文件校验及生效命令的操作类型
+const ActionFileHook = "配置文件校验及生效命令"
+
+// 配置文件安装前的校验命令及安装后的生效方式
+type Hooks struct {
+	Validate       string `json:"validate"`
+	Activate       string `json:"activate"`
+	ActivateTarget string `json:"activate_target"`
+}
+
+func FileHooks(f *Files) *Hooks {
+	return &Hooks{
+		Validate:       f.ValidateCmd,
+		Activate:       f.ActivateAction,
+		ActivateTarget: f.ActivateTarget,
+	}
+}
+
+func (h *Hooks) Empty() bool {
+	return h == nil || (h.Validate == "" && h.Activate == common.ActivateNone)
+}
+
+// 检查生效方式，校验及执行的命令须通过命令策略检查
+func (h *Hooks) Check(subject *policy.Subject, confirm string) error {
+	if h.Empty() {
+		return nil
+	}
+	switch h.Activate {
+	case common.ActivateNone:
+	case common.ActivateReload, common.ActivateRestart, common.ActivateCommand:
+		if h.ActivateTarget == "" {
+			return errors.New("请指定生效的服务或命令")
+		}
+		if h.Activate != common.ActivateCommand && !common.ValidUnitName(h.ActivateTarget) {
+			return fmt.Errorf("服务名不合法: %s", h.ActivateTarget)
+		}
+	default:
+		return fmt.Errorf("unsupported activate action: %s", h.Activate)
+	}
+
+	if h.Validate != "" {
+		if _, err := policy.Check(subject, ActionFileHook, h.Validate, confirm); err != nil {
+			return err
+		}
+	}
+	if h.Activate == common.ActivateCommand {
+		if _, err := policy.Check(subject, ActionFileHook, h.ActivateTarget, confirm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 下发文件，设置了校验或生效方式时由agent校验通过后安装，生效失败时agent自动恢复原文件
+func Install(agent *agentmanager.Agent, path, name, text string, h *Hooks) (interface{}, error) {
+	if h.Empty() {
+		return executor.Output(agent.UpdateFile(path, name, text))
+	}
+	result, Err, err := agent.ApplyFile(&common.FileApply{
+		FilePath:       path,
+		FileName:       name,
+		FileText:       text,
+		Validate:       h.Validate,
+		Activate:       h.Activate,
+		ActivateTarget: h.ActivateTarget,
+	})
+	if err != nil {
+		if Err != "" {
+			return nil, errors.New(Err)
+		}
+		return nil, err
+	}
+	if result.Error != "" {
+		return result, errors.New(result.Error)
+	}
+	return result, nil
+}
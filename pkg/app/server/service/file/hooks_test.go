@@ -0,0 +1,32 @@
+package file
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"openeuler.org/PilotGo/PilotGo/pkg/utils/os/common"
+)
+
+func TestHooksCheck(t *testing.T) {
+	var none *Hooks
+	assert.True(t, none.Empty())
+	assert.True(t, FileHooks(&Files{}).Empty())
+	assert.False(t, FileHooks(&Files{ActivateAction: common.ActivateReload, ActivateTarget: "nginx"}).Empty())
+
+	cases := []struct {
+		hooks Hooks
+		valid bool
+	}{
+		{Hooks{}, true},
+		{Hooks{Activate: common.ActivateReload, ActivateTarget: "nginx"}, true},
+		{Hooks{Activate: common.ActivateRestart, ActivateTarget: "getty@tty1.service"}, true},
+		{Hooks{Activate: common.ActivateReload}, false},
+		{Hooks{Activate: common.ActivateCommand}, false},
+		{Hooks{Activate: common.ActivateRestart, ActivateTarget: "nginx && reboot"}, false},
+		{Hooks{Activate: "kill", ActivateTarget: "nginx"}, false},
+	}
+	for _, c := range cases {
+		err := c.hooks.Check(nil, "")
+		assert.Equal(t, c.valid, err == nil, "%+v", c.hooks)
+	}
+}
@@ -0,0 +1,190 @@
+package file
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/agentmanager"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+	"openeuler.org/PilotGo/PilotGo/pkg/logger"
+	"openeuler.org/PilotGo/PilotGo/pkg/utils"
+)
+
+// 模板变量的设置范围
+const (
+	ScopeBatch  = "batch"
+	ScopeDepart = "depart"
+)
+
+// 部门层级的最大深度，防止数据异常时死循环
+const maxDepartDepth = 32
+
+type TemplateVar = dao.TemplateVar
+
+// 渲染配置模板时可用的机器信息，如{{.Hostname}}、{{.Vars.port}}
+type TemplateData struct {
+	UUID         string
+	IP           string
+	Hostname     string
+	OS           string
+	OSVersion    string
+	Kernel       string
+	Arch         string
+	Department   string
+	DepartmentID int
+	Batches      []string
+	// 自定义变量，批次变量覆盖部门变量，下级部门覆盖上级部门
+	Vars map[string]string
+}
+
+// 检查模板语法
+func ParseTemplate(text string) (*template.Template, error) {
+	t, err := template.New("config").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("模板解析失败: %s", err.Error())
+	}
+	return t, nil
+}
+
+// 使用机器信息及变量渲染配置模板
+func Render(text string, uuid string) (string, *TemplateData, error) {
+	t, err := ParseTemplate(text)
+	if err != nil {
+		return "", nil, err
+	}
+	data, err := MachineTemplateData(uuid)
+	if err != nil {
+		return "", nil, err
+	}
+	out, err := execute(t, data)
+	if err != nil {
+		return "", data, err
+	}
+	return out, data, nil
+}
+
+// 以模板数据渲染已解析的模板，引用不存在的变量时报错
+func execute(t *template.Template, data *TemplateData) (string, error) {
+	buf := &bytes.Buffer{}
+	if err := t.Execute(buf, data); err != nil {
+		return "", fmt.Errorf("模板渲染失败: %s", err.Error())
+	}
+	return buf.String(), nil
+}
+
+// 获取机器的模板数据，机器在线时从agent获取主机名及系统信息
+func MachineTemplateData(uuid string) (*TemplateData, error) {
+	machine, err := dao.MachineByUUID(uuid)
+	if err != nil {
+		return nil, fmt.Errorf("机器 %s 不存在", uuid)
+	}
+	data := &TemplateData{
+		UUID:         uuid,
+		IP:           machine.IP,
+		OS:           machine.Systeminfo,
+		DepartmentID: machine.DepartId,
+		Batches:      []string{},
+		Vars:         map[string]string{},
+	}
+	if agent := agentmanager.GetAgent(uuid); agent != nil {
+		if info, err := agent.GetOSInfo(); err == nil {
+			data.Hostname = info.Hostname
+			data.OS = info.Platform
+			data.OSVersion = info.PlatformVersion
+			data.Kernel = info.KernelVersion
+			data.Arch = info.KernelArch
+		} else {
+			logger.Error("failed to get os info of %s: %s", uuid, err.Error())
+		}
+	}
+
+	// 从上级部门到下级部门依次合并变量
+	departs := departChain(machine.DepartId)
+	if len(departs) > 0 {
+		data.Department = departs[0].Depart
+	}
+	for i := len(departs) - 1; i >= 0; i-- {
+		if err := mergeVars(data.Vars, ScopeDepart, departs[i].ID); err != nil {
+			return nil, err
+		}
+	}
+
+	batches, err := dao.GetBatch()
+	if err != nil {
+		return nil, err
+	}
+	// 按批次id依次合并，机器属于多个批次时id较大的批次变量优先
+	sort.Slice(batches, func(i, j int) bool { return batches[i].ID < batches[j].ID })
+	for _, b := range batches {
+		if !containsMachine(b.Machinelist, machine.ID) {
+			continue
+		}
+		data.Batches = append(data.Batches, b.Name)
+		if err := mergeVars(data.Vars, ScopeBatch, int(b.ID)); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// 机器所在部门及其上级部门，下级部门在前
+func departChain(id int) []dao.DepartNode {
+	chain := []dao.DepartNode{}
+	for i := 0; i < maxDepartDepth && id != 0; i++ {
+		depart, err := dao.GetDepartNode(id)
+		if err != nil {
+			break
+		}
+		chain = append(chain, depart)
+		if depart.PID == depart.ID {
+			break
+		}
+		id = depart.PID
+	}
+	return chain
+}
+
+func containsMachine(machinelist string, id int) bool {
+	for _, macId := range utils.String2Int(strings.Split(machinelist, ",")) {
+		if macId == id {
+			return true
+		}
+	}
+	return false
+}
+
+func mergeVars(vars map[string]string, scope string, id int) error {
+	list, err := dao.TemplateVarList(scope, id)
+	if err != nil {
+		return err
+	}
+	for _, v := range list {
+		vars[v.Name] = v.Value
+	}
+	return nil
+}
+
+func SaveTemplateVar(v *TemplateVar) error {
+	if v.Scope != ScopeBatch && v.Scope != ScopeDepart {
+		return fmt.Errorf("unsupported scope: %s", v.Scope)
+	}
+	if v.ScopeID == 0 {
+		return errors.New("请选择批次或部门")
+	}
+	if strings.TrimSpace(v.Name) == "" {
+		return errors.New("变量名不能为空")
+	}
+	return dao.SaveTemplateVar(v)
+}
+
+func DeleteTemplateVars(ids []uint) error {
+	return dao.DeleteTemplateVars(ids)
+}
+
+func TemplateVarList(scope string, scopeID int) ([]TemplateVar, error) {
+	return dao.TemplateVarList(scope, scopeID)
+}
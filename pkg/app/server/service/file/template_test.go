@@ -0,0 +1,64 @@
+package file
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTemplate(t *testing.T) {
+	for _, text := range []string{"", "plain text", "listen {{ .IP }}:{{ .Vars.port }}", "{{ range .Batches }}{{ . }} {{ end }}"} {
+		_, err := ParseTemplate(text)
+		assert.Nil(t, err, text)
+	}
+	for _, text := range []string{"{{ .IP ", "{{ end }}", "{{ if .IP }}"} {
+		_, err := ParseTemplate(text)
+		assert.NotNil(t, err, text)
+	}
+}
+
+func TestExecuteTemplate(t *testing.T) {
+	data := &TemplateData{
+		UUID:       "m1",
+		IP:         "10.0.0.1",
+		Hostname:   "web-1",
+		Department: "ops",
+		Batches:    []string{"web", "prod"},
+		Vars:       map[string]string{"port": "8080"},
+	}
+	cases := []struct {
+		text string
+		out  string
+		ok   bool
+	}{
+		{"server_name {{ .Hostname }};", "server_name web-1;", true},
+		{"listen {{ .IP }}:{{ .Vars.port }}", "listen 10.0.0.1:8080", true},
+		{"{{ range .Batches }}[{{ . }}]{{ end }}", "[web][prod]", true},
+		{`{{ index .Vars "port" }}`, "8080", true},
+		{"{{ .Vars.missing }}", "", false},
+		{"{{ .Unknown }}", "", false},
+	}
+	for _, c := range cases {
+		tmpl, err := ParseTemplate(c.text)
+		assert.Nil(t, err, c.text)
+		out, err := execute(tmpl, data)
+		assert.Equal(t, c.ok, err == nil, c.text)
+		assert.Equal(t, c.out, out, c.text)
+	}
+}
+
+func TestContainsMachine(t *testing.T) {
+	assert.True(t, containsMachine("1,12,3", 12))
+	assert.False(t, containsMachine("1,12,3", 2))
+	assert.False(t, containsMachine("", 1))
+}
+
+func TestSaveTemplateVarValidate(t *testing.T) {
+	for _, v := range []TemplateVar{
+		{Scope: "machine", ScopeID: 1, Name: "port"},
+		{Scope: ScopeBatch, Name: "port"},
+		{Scope: ScopeDepart, ScopeID: 1, Name: "  "},
+	} {
+		assert.NotNil(t, SaveTemplateVar(&v), "%+v", v)
+	}
+}
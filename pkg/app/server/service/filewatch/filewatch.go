@@ -0,0 +1,294 @@
+package filewatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/agentmanager"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/batch"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/eventbus"
+	"openeuler.org/PilotGo/PilotGo/pkg/logger"
+	"openeuler.org/PilotGo/PilotGo/pkg/utils/message/protocol"
+	"openeuler.org/PilotGo/PilotGo/pkg/utils/os/common"
+)
+
+// 监控规则的作用范围
+const (
+	ScopeMachine = "machine"
+	ScopeBatch   = "batch"
+	ScopeDepart  = "depart"
+)
+
+const (
+	// 定期重新下发以同步批次及部门成员的变化
+	syncInterval = 10 * time.Minute
+	// 文件变化事件保留天数
+	eventRetentionDays = 30
+)
+
+type FileWatch = dao.FileWatch
+type EventFilter = dao.FileWatchEventFilter
+
+// 各机器最近一次下发成功的监控列表，列表未变化时不重复下发，避免agent重建监控及丢弃差异缓存
+var pushed sync.Map
+
+// agent上线及定期下发监控列表，并记录agent上报的文件变化
+func Init() {
+	agentmanager.SetFileWatchEventHandler(record)
+	agentmanager.AddOnlineHandler(func(a *agentmanager.Agent) {
+		// 旧版本agent不支持文件监控
+		if a == nil || a.UUID == "" || !a.Supports(protocol.FileWatchSet) {
+			return
+		}
+		// agent重新上线后监控列表需重新下发
+		pushed.Delete(a.UUID)
+		if err := Sync(a.UUID); err != nil {
+			logger.Error("failed to set file watch list of %s: %s", a.UUID, err.Error())
+		}
+	})
+	go func() {
+		ticker := time.NewTicker(syncInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			before := time.Now().AddDate(0, 0, -eventRetentionDays)
+			if err := dao.DeleteFileWatchEventsBefore(before); err != nil {
+				logger.Error("failed to clean file watch events: %s", err.Error())
+			}
+			SyncAll()
+		}
+	}()
+}
+
+func List() (*[]FileWatch, *gorm.DB) {
+	return dao.FileWatchList()
+}
+
+func Add(w *FileWatch) error {
+	if err := validate(w); err != nil {
+		return err
+	}
+	if err := dao.AddFileWatch(w); err != nil {
+		return err
+	}
+	go SyncAll()
+	return nil
+}
+
+func Update(w *FileWatch) error {
+	old, err := dao.GetFileWatch(w.ID)
+	if err != nil {
+		return fmt.Errorf("监控规则 %d 不存在", w.ID)
+	}
+	if err := validate(w); err != nil {
+		return err
+	}
+	w.CreatedAt = old.CreatedAt
+	if err := dao.UpdateFileWatch(w); err != nil {
+		return err
+	}
+	go SyncAll()
+	return nil
+}
+
+func Delete(ids []uint) error {
+	if err := dao.DeleteFileWatches(ids); err != nil {
+		return err
+	}
+	go SyncAll()
+	return nil
+}
+
+func QueryEvents(f *EventFilter) (*[]dao.FileWatchEvent, *gorm.DB) {
+	return dao.QueryFileWatchEvents(f)
+}
+
+func validate(w *FileWatch) error {
+	switch w.Scope {
+	case ScopeMachine:
+		if w.Target == "" {
+			return errors.New("请指定监控的机器")
+		}
+	case ScopeBatch, ScopeDepart:
+		if _, err := strconv.Atoi(w.Target); err != nil {
+			return errors.New("批次或部门id错误")
+		}
+	default:
+		return fmt.Errorf("unsupported scope: %s", w.Scope)
+	}
+	if !filepath.IsAbs(w.Path) {
+		return errors.New("监控路径须为绝对路径")
+	}
+	w.Path = filepath.Clean(w.Path)
+	for _, p := range append(split(w.Include), split(w.Exclude)...) {
+		if _, err := filepath.Match(p, ""); err != nil {
+			return fmt.Errorf("文件名匹配规则 %s 错误", p)
+		}
+	}
+	for _, op := range split(w.Ops) {
+		if !contains(common.WatchOps, op) {
+			return fmt.Errorf("不支持的监控操作: %s", op)
+		}
+	}
+	return nil
+}
+
+// 向机器下发其当前适用的监控列表
+func Sync(uuid string) error {
+	agent := agentmanager.GetAgent(uuid)
+	if agent == nil {
+		return fmt.Errorf("机器 %s 不在线", uuid)
+	}
+	lists, err := watchLists()
+	if err != nil {
+		return err
+	}
+	return push(agent, lists[uuid])
+}
+
+// 向所有在线机器并发下发监控列表，返回下发失败的机器及原因
+func SyncAll() map[string]error {
+	failed := map[string]error{}
+	lists, err := watchLists()
+	if err != nil {
+		logger.Error("failed to load file watch rules: %s", err.Error())
+		return failed
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, a := range agentmanager.GetAgentList() {
+		agent := agentmanager.GetAgent(a["agent_uuid"])
+		if agent == nil || !agent.Supports(protocol.FileWatchSet) {
+			continue
+		}
+		wg.Add(1)
+		go func(agent *agentmanager.Agent) {
+			defer wg.Done()
+			if err := push(agent, lists[agent.UUID]); err != nil {
+				logger.Error("failed to set file watch list of %s: %s", agent.UUID, err.Error())
+				mu.Lock()
+				failed[agent.UUID] = err
+				mu.Unlock()
+			}
+		}(agent)
+	}
+	wg.Wait()
+	return failed
+}
+
+// 未配置规则的机器下发空列表，由agent使用默认监控，与上次下发成功的列表相同时跳过
+func push(agent *agentmanager.Agent, list *common.WatchList) error {
+	if list == nil {
+		list = &common.WatchList{Rules: []common.WatchRule{}}
+	}
+	bs, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	if last, ok := pushed.Load(agent.UUID); ok && last.(string) == string(bs) {
+		return nil
+	}
+	if _, err := agent.FileWatchSet(list); err != nil {
+		pushed.Delete(agent.UUID)
+		return err
+	}
+	pushed.Store(agent.UUID, string(bs))
+	return nil
+}
+
+// 按机器汇总已开启的监控规则
+func watchLists() (map[string]*common.WatchList, error) {
+	rules, err := dao.EnabledFileWatches()
+	if err != nil {
+		return nil, err
+	}
+	lists := map[string]*common.WatchList{}
+	for _, w := range rules {
+		uuids, err := members(&w)
+		if err != nil {
+			return nil, err
+		}
+		rule := common.WatchRule{
+			Path:      w.Path,
+			Recursive: w.Recursive,
+			Include:   split(w.Include),
+			Exclude:   split(w.Exclude),
+			Ops:       split(w.Ops),
+		}
+		for _, uuid := range uuids {
+			if lists[uuid] == nil {
+				lists[uuid] = &common.WatchList{}
+			}
+			lists[uuid].Rules = append(lists[uuid].Rules, rule)
+		}
+	}
+	return lists, nil
+}
+
+// 规则当前作用的机器
+func members(w *FileWatch) ([]string, error) {
+	id, _ := strconv.Atoi(w.Target)
+	switch w.Scope {
+	case ScopeBatch:
+		return dao.BatchIds2UUIDs([]int{id}), nil
+	case ScopeDepart:
+		return batch.DepartMachineUUIDs([]int{id})
+	}
+	return []string{w.Target}, nil
+}
+
+func record(a *agentmanager.Agent, e *common.FileWatchEvent) {
+	event := &dao.FileWatchEvent{
+		MachineUUID: a.UUID,
+		IP:          a.IP,
+		Rule:        e.Rule,
+		Path:        e.Path,
+		Op:          e.Op,
+		Size:        e.Size,
+		Hash:        e.Hash,
+		UID:         e.UID,
+		Diff:        e.Diff,
+		Time:        e.Time,
+	}
+	if err := dao.AddFileWatchEvent(event); err != nil {
+		logger.Error("failed to save file event of %s: %s", a.UUID, err.Error())
+	}
+
+	// 没有前端连接时不阻塞
+	select {
+	case agentmanager.WARN_MSG <- fmt.Sprintf("机器 %s 上的文件 %s 发生变化: %s", a.IP, e.Path, e.Op):
+	default:
+	}
+	eventbus.PublishEvent(&eventbus.EventMessage{
+		MessageType: eventbus.MsgFileChanged,
+		MachineUUID: a.UUID,
+		MessageData: event,
+	})
+}
+
+func split(s string) []string {
+	list := []string{}
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,483 @@
+package integrity
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	cron "github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/agentmanager"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/batch"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/eventbus"
+	"openeuler.org/PilotGo/PilotGo/pkg/logger"
+	"openeuler.org/PilotGo/PilotGo/pkg/utils/os/common"
+)
+
+// 文件相对基线的变化
+const (
+	ChangeAdded    = "added"
+	ChangeRemoved  = "removed"
+	ChangeModified = "modified"
+)
+
+// 机器的检查状态
+const (
+	StateBaseline   = "baseline"
+	StateIntact     = "intact"
+	StateChanged    = "changed"
+	StateNoBaseline = "no_baseline"
+	StateOffline    = "offline"
+	StateError      = "error"
+)
+
+const (
+	// 检查定期扫描是否到期的间隔
+	checkInterval = time.Minute
+	// 检查结果中保留的错误条数
+	errorLimit = 20
+)
+
+type Profile = dao.IntegrityProfile
+type Scan = dao.IntegrityScan
+type ScanFilter = dao.IntegrityScanFilter
+type Change = dao.IntegrityChange
+type ChangeFilter = dao.IntegrityChangeFilter
+
+// 按配置的cron表达式定期检查
+func Init() {
+	go func() {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			runDue()
+		}
+	}()
+}
+
+func runDue() {
+	now := time.Now()
+	list, err := dao.DueIntegrityProfiles(now)
+	if err != nil {
+		logger.Error("failed to get due integrity profiles: %s", err.Error())
+		return
+	}
+	for i := range list {
+		p := &list[i]
+		p.LastScanAt = &now
+		p.NextScanAt = nextScan(p, now)
+		if err := dao.UpdateIntegrityProfile(p); err != nil {
+			logger.Error("failed to update integrity profile %d: %s", p.ID, err.Error())
+			continue
+		}
+		go func(p *Profile) {
+			if _, err := scan(p, nil); err != nil {
+				logger.Error("failed to scan integrity profile %d: %s", p.ID, err.Error())
+			}
+		}(p)
+	}
+}
+
+func nextScan(p *Profile, now time.Time) *time.Time {
+	if !p.Enabled || p.Spec == "" {
+		return nil
+	}
+	sched, err := cron.ParseStandard(p.Spec)
+	if err != nil {
+		return nil
+	}
+	next := sched.Next(now)
+	return &next
+}
+
+func ProfileList() (*[]Profile, *gorm.DB) {
+	return dao.IntegrityProfileList()
+}
+
+func AddProfile(p *Profile) error {
+	if err := validate(p); err != nil {
+		return err
+	}
+	p.NextScanAt = nextScan(p, time.Now())
+	return dao.AddIntegrityProfile(p)
+}
+
+func UpdateProfile(p *Profile) error {
+	old, err := dao.GetIntegrityProfile(p.ID)
+	if err != nil {
+		return fmt.Errorf("检查配置 %d 不存在", p.ID)
+	}
+	if err := validate(p); err != nil {
+		return err
+	}
+	p.CreatedAt = old.CreatedAt
+	p.LastScanAt = old.LastScanAt
+	p.NextScanAt = nextScan(p, time.Now())
+	return dao.UpdateIntegrityProfile(p)
+}
+
+func DeleteProfiles(ids []uint) error {
+	return dao.DeleteIntegrityProfiles(ids)
+}
+
+func QueryScans(f *ScanFilter) (*[]Scan, *gorm.DB) {
+	return dao.QueryIntegrityScans(f)
+}
+
+func QueryChanges(f *ChangeFilter) (*[]Change, *gorm.DB) {
+	return dao.QueryIntegrityChanges(f)
+}
+
+func validate(p *Profile) error {
+	if p.Name == "" {
+		return errors.New("配置名称不能为空")
+	}
+	paths := split(p.Paths)
+	if len(paths) == 0 {
+		return errors.New("请指定检查的路径")
+	}
+	for _, path := range paths {
+		if !filepath.IsAbs(path) {
+			return fmt.Errorf("检查路径 %s 须为绝对路径", path)
+		}
+	}
+	for _, e := range split(p.Exclude) {
+		if _, err := filepath.Match(e, ""); err != nil {
+			return fmt.Errorf("排除规则 %s 错误", e)
+		}
+	}
+	if p.Spec != "" {
+		if _, err := cron.ParseStandard(p.Spec); err != nil {
+			return fmt.Errorf("cron表达式错误: %s", err.Error())
+		}
+	}
+	if p.UUIDs == "" && p.BatchIDs == "" && p.DepartIDs == "" {
+		return errors.New("请指定检查的机器、批次或部门")
+	}
+	return nil
+}
+
+// 配置当前作用的机器
+func Members(p *Profile) ([]string, error) {
+	uuids := split(p.UUIDs)
+	uuids = append(uuids, dao.BatchIds2UUIDs(splitIDs(p.BatchIDs))...)
+	if departs := splitIDs(p.DepartIDs); len(departs) > 0 {
+		list, err := batch.DepartMachineUUIDs(departs)
+		if err != nil {
+			return nil, err
+		}
+		uuids = append(uuids, list...)
+	}
+
+	result := []string{}
+	seen := map[string]bool{}
+	for _, u := range uuids {
+		if u != "" && !seen[u] {
+			seen[u] = true
+			result = append(result, u)
+		}
+	}
+	return result, nil
+}
+
+func param(p *Profile) *common.IntegrityParam {
+	return &common.IntegrityParam{Paths: split(p.Paths), Exclude: split(p.Exclude)}
+}
+
+// 记录机器当前的文件状态作为基线，uuids为空时记录配置的所有机器
+func Baseline(id uint, uuids []string) ([]*Scan, error) {
+	p, err := dao.GetIntegrityProfile(id)
+	if err != nil {
+		return nil, fmt.Errorf("检查配置 %d 不存在", id)
+	}
+	if len(uuids) == 0 {
+		if uuids, err = Members(p); err != nil {
+			return nil, err
+		}
+	}
+
+	result := []*Scan{}
+	for _, uuid := range uuids {
+		s, snapshot := snapshot(p, uuid)
+		if snapshot != nil {
+			list := make([]dao.IntegrityBaseline, 0, len(snapshot.Files))
+			for _, f := range snapshot.Files {
+				list = append(list, dao.IntegrityBaseline{
+					ProfileID:   p.ID,
+					MachineUUID: uuid,
+					Path:        f.Path,
+					Hash:        f.Hash,
+					Link:        f.Link,
+					Mode:        f.Mode,
+					UID:         f.UID,
+					GID:         f.GID,
+					Size:        f.Size,
+					ModTime:     f.ModTime,
+				})
+			}
+			if err := dao.ReplaceIntegrityBaselines(p.ID, uuid, list); err != nil {
+				s.State = StateError
+				s.Error = err.Error()
+			} else {
+				s.State = StateBaseline
+				s.Files = len(list)
+			}
+		}
+		if err := dao.SaveIntegrityScan(s, nil, false); err != nil {
+			logger.Error("failed to save integrity scan of %s: %s", uuid, err.Error())
+		}
+		result = append(result, s)
+	}
+	return result, nil
+}
+
+// 与基线比较，uuids为空时检查配置的所有机器
+func ScanProfile(id uint, uuids []string) ([]*Scan, error) {
+	p, err := dao.GetIntegrityProfile(id)
+	if err != nil {
+		return nil, fmt.Errorf("检查配置 %d 不存在", id)
+	}
+	return scan(p, uuids)
+}
+
+func scan(p *Profile, uuids []string) ([]*Scan, error) {
+	if len(uuids) == 0 {
+		var err error
+		if uuids, err = Members(p); err != nil {
+			return nil, err
+		}
+	}
+	result := []*Scan{}
+	for _, uuid := range uuids {
+		result = append(result, scanMachine(p, uuid))
+	}
+	return result, nil
+}
+
+func scanMachine(p *Profile, uuid string) *Scan {
+	s, snapshot := snapshot(p, uuid)
+	changes := []Change{}
+	if snapshot != nil {
+		changes = compareBaseline(p, s, snapshot)
+	}
+	// 未取得快照时保留上次发现的变化
+	if err := dao.SaveIntegrityScan(s, changes, snapshot != nil && s.State != StateError); err != nil {
+		logger.Error("failed to save integrity scan of %s: %s", uuid, err.Error())
+	}
+	if s.State == StateChanged {
+		alert(p, s)
+	}
+	return s
+}
+
+// 获取机器的快照，机器离线或获取失败时返回的快照为nil
+func snapshot(p *Profile, uuid string) (*Scan, *common.IntegritySnapshot) {
+	s := &Scan{ProfileID: p.ID, MachineUUID: uuid, ScannedAt: time.Now()}
+	agent := agentmanager.GetAgent(uuid)
+	if agent == nil {
+		s.State = StateOffline
+		return s, nil
+	}
+	s.IP = agent.IP
+	snapshot, err := agent.IntegritySnapshot(param(p))
+	if err != nil {
+		s.State = StateError
+		s.Error = err.Error()
+		return s, nil
+	}
+	errs := snapshot.Errors
+	if len(errs) > errorLimit {
+		errs = append(errs[:errorLimit], fmt.Sprintf("... 共%d条错误", len(snapshot.Errors)))
+	}
+	s.Error = strings.Join(errs, "\n")
+	return s, snapshot
+}
+
+func compareBaseline(p *Profile, s *Scan, snapshot *common.IntegritySnapshot) []Change {
+	changes := []Change{}
+	baselines, err := dao.IntegrityBaselines(p.ID, s.MachineUUID)
+	if err != nil {
+		s.State = StateError
+		s.Error = err.Error()
+		return changes
+	}
+	return diffBaseline(s, baselines, snapshot)
+}
+
+// 比较快照与基线，统计新增、删除及修改的文件并设置检查状态
+func diffBaseline(s *Scan, baselines []dao.IntegrityBaseline, snapshot *common.IntegritySnapshot) []Change {
+	changes := []Change{}
+	if len(baselines) == 0 {
+		s.State = StateNoBaseline
+		return changes
+	}
+	s.Files = len(snapshot.Files)
+
+	expected := map[string]*dao.IntegrityBaseline{}
+	for i := range baselines {
+		expected[baselines[i].Path] = &baselines[i]
+	}
+	for i := range snapshot.Files {
+		f := &snapshot.Files[i]
+		b, ok := expected[f.Path]
+		delete(expected, f.Path)
+		if !ok {
+			c := newChange(s, f.Path, ChangeAdded)
+			setNew(&c, f)
+			changes = append(changes, c)
+			s.Added++
+			continue
+		}
+		if fields := diffFields(b, f); len(fields) > 0 {
+			c := newChange(s, f.Path, ChangeModified)
+			c.Fields = strings.Join(fields, ",")
+			setOld(&c, b)
+			setNew(&c, f)
+			changes = append(changes, c)
+			s.Modified++
+		}
+	}
+	// 快照不完整时无法判断文件是否被删除
+	if !snapshot.Truncated {
+		for _, b := range expected {
+			c := newChange(s, b.Path, ChangeRemoved)
+			setOld(&c, b)
+			changes = append(changes, c)
+			s.Removed++
+		}
+	}
+
+	s.State = StateIntact
+	if len(changes) > 0 {
+		s.State = StateChanged
+	}
+	return changes
+}
+
+func diffFields(b *dao.IntegrityBaseline, f *common.FileBaseline) []string {
+	fields := []string{}
+	if b.Hash != f.Hash {
+		fields = append(fields, "hash")
+	}
+	if b.Link != f.Link {
+		fields = append(fields, "link")
+	}
+	if b.Mode != f.Mode {
+		fields = append(fields, "mode")
+	}
+	if b.UID != f.UID || b.GID != f.GID {
+		fields = append(fields, "owner")
+	}
+	if b.Size != f.Size {
+		fields = append(fields, "size")
+	}
+	// 数据库中的时间精度低于文件系统，按秒比较
+	if b.ModTime.Unix() != f.ModTime.Unix() {
+		fields = append(fields, "mtime")
+	}
+	return fields
+}
+
+func newChange(s *Scan, path, change string) Change {
+	return Change{
+		ProfileID:   s.ProfileID,
+		MachineUUID: s.MachineUUID,
+		IP:          s.IP,
+		Path:        path,
+		Change:      change,
+		DetectedAt:  s.ScannedAt,
+	}
+}
+
+func setOld(c *Change, b *dao.IntegrityBaseline) {
+	t := b.ModTime
+	c.OldHash, c.OldLink, c.OldMode = b.Hash, b.Link, b.Mode
+	c.OldUID, c.OldGID, c.OldSize, c.OldModTime = b.UID, b.GID, b.Size, &t
+}
+
+func setNew(c *Change, f *common.FileBaseline) {
+	t := f.ModTime
+	c.NewHash, c.NewLink, c.NewMode = f.Hash, f.Link, f.Mode
+	c.NewUID, c.NewGID, c.NewSize, c.NewModTime = f.UID, f.GID, f.Size, &t
+}
+
+func alert(p *Profile, s *Scan) {
+	msg := fmt.Sprintf("机器 %s 文件完整性检查(%s)发现变化，新增:%d 删除:%d 修改:%d",
+		s.IP, p.Name, s.Added, s.Removed, s.Modified)
+	logger.Warn(msg)
+
+	// 没有前端连接时不阻塞
+	select {
+	case agentmanager.WARN_MSG <- msg:
+	default:
+	}
+	eventbus.PublishEvent(&eventbus.EventMessage{
+		MessageType: eventbus.MsgIntegrityChanged,
+		MachineUUID: s.MachineUUID,
+		MessageData: s,
+	})
+}
+
+// 接受机器上的变化并更新基线，ids为空时接受该机器的所有变化
+func Accept(id uint, uuid string, ids []uint) (int, error) {
+	list, _ := dao.QueryIntegrityChanges(&ChangeFilter{ProfileID: id, MachineUUID: uuid, IDs: ids})
+	if len(*list) == 0 {
+		return 0, errors.New("没有需要接受的变化")
+	}
+	if err := dao.AcceptIntegrityChanges(*list); err != nil {
+		return 0, err
+	}
+	if err := refreshScan(id, uuid); err != nil {
+		logger.Error("failed to refresh integrity scan of %s: %s", uuid, err.Error())
+	}
+	return len(*list), nil
+}
+
+// 按剩余的变化更新机器的检查结果
+func refreshScan(id uint, uuid string) error {
+	scans, _ := dao.QueryIntegrityScans(&ScanFilter{ProfileID: id, MachineUUID: uuid})
+	if len(*scans) == 0 {
+		return nil
+	}
+	s := (*scans)[0]
+	changes, _ := dao.QueryIntegrityChanges(&ChangeFilter{ProfileID: id, MachineUUID: uuid})
+	s.ID, s.Added, s.Removed, s.Modified = 0, 0, 0, 0
+	for _, c := range *changes {
+		switch c.Change {
+		case ChangeAdded:
+			s.Added++
+		case ChangeRemoved:
+			s.Removed++
+		case ChangeModified:
+			s.Modified++
+		}
+	}
+	if s.State == StateChanged && len(*changes) == 0 {
+		s.State = StateIntact
+	}
+	return dao.SaveIntegrityScan(&s, nil, false)
+}
+
+func split(s string) []string {
+	list := []string{}
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func splitIDs(s string) []int {
+	ids := []int{}
+	for _, v := range split(s) {
+		if id, err := strconv.Atoi(v); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
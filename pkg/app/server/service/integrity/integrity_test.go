@@ -0,0 +1,91 @@
+package integrity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+	"openeuler.org/PilotGo/PilotGo/pkg/utils/os/common"
+)
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name    string
+		profile Profile
+		valid   bool
+	}{
+		{"machines", Profile{Name: "etc", Paths: "/etc, /usr/bin", UUIDs: "m1"}, true},
+		{"scheduled batch", Profile{Name: "etc", Paths: "/etc", Exclude: "*.swp", Spec: "0 3 * * *", BatchIDs: "1"}, true},
+		{"no name", Profile{Paths: "/etc", UUIDs: "m1"}, false},
+		{"no paths", Profile{Name: "etc", Paths: " , ", UUIDs: "m1"}, false},
+		{"relative path", Profile{Name: "etc", Paths: "/etc,usr/bin", UUIDs: "m1"}, false},
+		{"bad exclude", Profile{Name: "etc", Paths: "/etc", Exclude: "[a", UUIDs: "m1"}, false},
+		{"bad spec", Profile{Name: "etc", Paths: "/etc", Spec: "daily", UUIDs: "m1"}, false},
+		{"no target", Profile{Name: "etc", Paths: "/etc"}, false},
+	}
+	for _, c := range cases {
+		err := validate(&c.profile)
+		assert.Equal(t, c.valid, err == nil, c.name)
+	}
+}
+
+func TestNextScan(t *testing.T) {
+	now := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+	assert.Nil(t, nextScan(&Profile{Spec: "0 3 * * *"}, now))
+	assert.Nil(t, nextScan(&Profile{Enabled: true}, now))
+	want := time.Date(2026, 10, 20, 3, 0, 0, 0, time.UTC)
+	assert.Equal(t, &want, nextScan(&Profile{Enabled: true, Spec: "0 3 * * *"}, now))
+
+	assert.Equal(t, []string{"/etc", "/usr/bin"}, split(" /etc,,/usr/bin "))
+	assert.Equal(t, []int{1, 3}, splitIDs("1,x,3"))
+	assert.Equal(t, &common.IntegrityParam{Paths: []string{"/etc"}, Exclude: []string{"*.swp"}},
+		param(&Profile{Paths: "/etc", Exclude: "*.swp"}))
+}
+
+func TestDiffBaseline(t *testing.T) {
+	mtime := time.Date(2026, 10, 1, 8, 0, 0, 0, time.UTC)
+	baselines := []dao.IntegrityBaseline{
+		{Path: "/etc/hosts", Hash: "h1", Mode: "-rw-r--r--", Size: 10, ModTime: mtime},
+		{Path: "/etc/passwd", Hash: "h2", Mode: "-rw-r--r--", Size: 20, ModTime: mtime},
+		{Path: "/etc/shadow", Hash: "h3", Mode: "----------", Size: 30, ModTime: mtime},
+	}
+	snapshot := &common.IntegritySnapshot{Files: []common.FileBaseline{
+		// 数据库中的时间精度较低，秒内的差异不算修改
+		{Path: "/etc/hosts", Hash: "h1", Mode: "-rw-r--r--", Size: 10, ModTime: mtime.Add(300 * time.Millisecond)},
+		{Path: "/etc/passwd", Hash: "h2x", Mode: "-rw-rw-rw-", UID: 1000, Size: 20, ModTime: mtime.Add(time.Hour)},
+		{Path: "/etc/sudoers.d/x", Hash: "h4", Mode: "-r--r-----", Size: 5, ModTime: mtime},
+	}}
+
+	s := &Scan{ProfileID: 1, MachineUUID: "m1", IP: "10.0.0.1", ScannedAt: mtime}
+	changes := diffBaseline(s, baselines, snapshot)
+	assert.Equal(t, StateChanged, s.State)
+	assert.Equal(t, 3, s.Files)
+	assert.Equal(t, []int{1, 1, 1}, []int{s.Added, s.Modified, s.Removed})
+	assert.Equal(t, 3, len(changes))
+
+	byPath := map[string]Change{}
+	for _, c := range changes {
+		assert.Equal(t, "m1", c.MachineUUID)
+		assert.Equal(t, uint(1), c.ProfileID)
+		byPath[c.Path] = c
+	}
+	assert.Equal(t, ChangeModified, byPath["/etc/passwd"].Change)
+	assert.Equal(t, "hash,mode,owner,mtime", byPath["/etc/passwd"].Fields)
+	assert.Equal(t, "h2", byPath["/etc/passwd"].OldHash)
+	assert.Equal(t, "h2x", byPath["/etc/passwd"].NewHash)
+	assert.Equal(t, ChangeAdded, byPath["/etc/sudoers.d/x"].Change)
+	assert.Equal(t, "h4", byPath["/etc/sudoers.d/x"].NewHash)
+	assert.Equal(t, ChangeRemoved, byPath["/etc/shadow"].Change)
+	assert.Equal(t, "h3", byPath["/etc/shadow"].OldHash)
+
+	// 快照被截断时不报告删除
+	s = &Scan{MachineUUID: "m1"}
+	changes = diffBaseline(s, baselines, &common.IntegritySnapshot{Files: snapshot.Files[:1], Truncated: true})
+	assert.Equal(t, StateIntact, s.State)
+	assert.Empty(t, changes)
+
+	s = &Scan{MachineUUID: "m1"}
+	assert.Empty(t, diffBaseline(s, nil, snapshot))
+	assert.Equal(t, StateNoBaseline, s.State)
+}
@@ -0,0 +1,623 @@
+package job
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/agentmanager"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/approval"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/batch"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/executor"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/file"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/policy"
+	"openeuler.org/PilotGo/PilotGo/pkg/global"
+	"openeuler.org/PilotGo/PilotGo/pkg/logger"
+	"openeuler.org/PilotGo/PilotGo/pkg/utils"
+)
+
+// 任务类型
+const (
+	TypeCommand        = "command"
+	TypeScript         = "script"
+	TypePackageInstall = "package_install"
+	TypePackageRemove  = "package_remove"
+	TypeFileBroadcast  = "file_broadcast"
+)
+
+// 任务及单台机器的执行状态
+const (
+	StatePending     = "pending"
+	StateRunning     = "running"
+	StatePaused      = "paused"
+	StateSuccess     = "success"
+	StateFailed      = "failed"
+	StateCancelled   = "cancelled"
+	StateFinished    = "finished"
+	StateInterrupted = "interrupted"
+	// 执行中被取消，已放弃等待结果，命令可能仍在机器上执行
+	StateAbandoned = "abandoned"
+)
+
+// 提交任务的参数
+type Param struct {
+	Type      string   `json:"type"`
+	UUIDs     []string `json:"uuids"`
+	BatchIDs  []int    `json:"batch_ids"`
+	DepartIDs []int    `json:"depart_ids"`
+	UserName  string   `json:"userName"`
+	UserDept  string   `json:"userDept"`
+	// 可选，分批执行策略
+	Strategy *Strategy `json:"strategy"`
+	// 可选，日志中记录的操作对象，默认由任务类型决定
+	Object string `json:"-"`
+	// 可选，命令策略检查的操作者，默认按UserName查找
+	Subject *policy.Subject `json:"-"`
+	// 危险命令的确认码
+	Confirm string `json:"confirm"`
+
+	Command string   `json:"command"`
+	Script  string   `json:"script"`
+	Params  []string `json:"params"`
+	// 可选，脚本解释器，支持bash、sh、python3、perl或shebang行，默认为bash
+	Interpreter string `json:"interpreter"`
+	// 可选，脚本的执行用户、工作目录、环境变量、超时及资源限制
+	Options *utils.ScriptOptions `json:"options"`
+
+	Package  string `json:"package"`
+	Path     string `json:"path"`
+	FileName string `json:"filename"`
+	Text     string `json:"text"`
+	// 可选，是否按机器渲染配置模板
+	Template bool `json:"template"`
+	// 可选，配置文件的校验命令及生效方式
+	Hooks *file.Hooks `json:"hooks"`
+}
+
+// 单台机器的执行进度，用于订阅推送
+type Progress struct {
+	JobID       int    `json:"job_id"`
+	MachineUUID string `json:"machine_uuid"`
+	IP          string `json:"ip"`
+	State       string `json:"state"`
+	ExitCode    int    `json:"exit_code"`
+	Message     string `json:"message"`
+	Wave        int    `json:"wave"`
+}
+
+type host struct {
+	log    *dao.AgentLog
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+type job struct {
+	id       int
+	parent   *dao.AgentLogParent
+	strategy *Strategy
+	ctx      context.Context
+	cancel   context.CancelFunc
+	resume   chan struct{}
+
+	lock        sync.Mutex
+	done        bool
+	hosts       map[string]*host
+	subscribers map[chan *Progress]struct{}
+}
+
+// 正在执行的任务，key为任务ID
+var jobs sync.Map
+
+// 服务启动时将上次未执行完的任务标记为中断
+func Init() error {
+	list, err := dao.GetJobsByState([]string{StatePending, StateRunning, StatePaused})
+	if err != nil {
+		return err
+	}
+	for i := range list {
+		parent := &list[i]
+		logs, err := dao.Id2AgentLog(parent.ID)
+		if err != nil {
+			logger.Error("failed to get logs of job %d: %s", parent.ID, err.Error())
+			continue
+		}
+		for j := range logs {
+			if logs[j].State != StatePending && logs[j].State != StateRunning {
+				continue
+			}
+			logs[j].State = StateFailed
+			logs[j].StatusCode = http.StatusBadRequest
+			logs[j].Message = "server restarted"
+			if err := dao.UpdateAgentLog(&logs[j]); err != nil {
+				logger.Error("failed to update log of job %d: %s", parent.ID, err.Error())
+			}
+		}
+
+		now := time.Now()
+		parent.JobState = StateInterrupted
+		parent.FinishedAt = &now
+		if err := dao.UpdateAgentLogParent(parent); err != nil {
+			logger.Error("failed to update job %d: %s", parent.ID, err.Error())
+		}
+	}
+	return nil
+}
+
+// 提交任务，立即返回任务ID，任务在后台执行
+func Submit(p *Param) (int, error) {
+	task, logType, action, object, err := p.task()
+	if err != nil {
+		return 0, err
+	}
+	if p.Object != "" {
+		object = p.Object
+	}
+	uuids, err := p.machines()
+	if err != nil {
+		return 0, err
+	}
+	if len(uuids) == 0 {
+		return 0, errors.New("no machine selected")
+	}
+	if err := p.checkPolicy(action); err != nil {
+		return 0, err
+	}
+	if err := p.checkApproval(); err != nil {
+		return 0, err
+	}
+	strategy := ""
+	if p.Strategy != nil {
+		if err := p.Strategy.validate(); err != nil {
+			return 0, err
+		}
+		bs, _ := json.Marshal(p.Strategy)
+		strategy = string(bs)
+	}
+
+	parent := &dao.AgentLogParent{
+		UserName:   p.UserName,
+		DepartName: p.UserDept,
+		Type:       logType,
+		JobType:    p.Type,
+		JobState:   StateRunning,
+		Total:      len(uuids),
+		Strategy:   strategy,
+	}
+	if err := dao.UpdateAgentLogParent(parent); err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{
+		id:          parent.ID,
+		parent:      parent,
+		strategy:    p.Strategy,
+		ctx:         ctx,
+		cancel:      cancel,
+		resume:      make(chan struct{}, 1),
+		hosts:       map[string]*host{},
+		subscribers: map[chan *Progress]struct{}{},
+	}
+	logs := make([]*dao.AgentLog, 0, len(uuids))
+	for _, uuid := range uuids {
+		log := &dao.AgentLog{
+			LogParentID:     parent.ID,
+			MachineUUID:     uuid,
+			OperationObject: object,
+			Action:          action,
+			State:           StatePending,
+		}
+		if agent := agentmanager.GetAgent(uuid); agent != nil {
+			log.IP = agent.IP
+		}
+		logs = append(logs, log)
+
+		hctx, hcancel := context.WithCancel(ctx)
+		j.hosts[uuid] = &host{log: log, ctx: hctx, cancel: hcancel}
+	}
+	if err := dao.CreateAgentLogs(logs); err != nil {
+		cancel()
+		return 0, err
+	}
+
+	jobs.Store(j.id, j)
+	go j.run(uuids, task)
+
+	return j.id, nil
+}
+
+// 取消任务中未完成的机器，uuids为空时取消整个任务
+// 未开始的机器不再执行，执行中的机器只放弃等待结果并标记为abandoned，agent上的命令不会被终止
+func Cancel(id int, uuids []string) error {
+	v, ok := jobs.Load(id)
+	if !ok {
+		return fmt.Errorf("job %d is not running", id)
+	}
+	j := v.(*job)
+
+	if len(uuids) == 0 {
+		j.cancel()
+		return nil
+	}
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	for _, uuid := range uuids {
+		h, ok := j.hosts[uuid]
+		if !ok {
+			return fmt.Errorf("machine %s is not in job %d", uuid, id)
+		}
+		h.cancel()
+	}
+	return nil
+}
+
+// 仅任务提交人及超级管理员可以取消、继续或终止任务
+func CheckOwner(id int, u *dao.User) error {
+	parent, err := dao.GetAgentLogParent(id)
+	if err != nil {
+		return err
+	}
+	if parent.JobType == "" {
+		return fmt.Errorf("log %d is not a job", id)
+	}
+	if u.UserType != global.AdminUserType && parent.UserName != u.Email {
+		return fmt.Errorf("job %d is not submitted by %s", id, u.Email)
+	}
+	return nil
+}
+
+// 查询任务及各机器的执行结果
+func Get(id int) (*dao.AgentLogParent, []dao.AgentLog, error) {
+	parent, err := dao.GetAgentLogParent(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if parent.JobType == "" {
+		return nil, nil, fmt.Errorf("log %d is not a job", id)
+	}
+	logs, err := dao.Id2AgentLog(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return parent, logs, nil
+}
+
+// 订阅任务进度，任务结束时channel关闭；任务已结束时返回错误
+func Subscribe(id int) (<-chan *Progress, func(), error) {
+	v, ok := jobs.Load(id)
+	if !ok {
+		return nil, nil, fmt.Errorf("job %d is not running", id)
+	}
+	j := v.(*job)
+
+	ch := make(chan *Progress, 100)
+	j.lock.Lock()
+	if j.done {
+		j.lock.Unlock()
+		return nil, nil, fmt.Errorf("job %d is not running", id)
+	}
+	j.subscribers[ch] = struct{}{}
+	j.lock.Unlock()
+
+	unsubscribe := func() {
+		j.lock.Lock()
+		defer j.lock.Unlock()
+		if _, ok := j.subscribers[ch]; ok {
+			delete(j.subscribers, ch)
+			close(ch)
+		}
+	}
+	return ch, unsubscribe, nil
+}
+
+func (j *job) run(uuids []string, task executor.Task) {
+	defer jobs.Delete(j.id)
+
+	opts := executor.DefaultOptions()
+	opts.HostContext = func(uuid string) context.Context {
+		return j.hosts[uuid].ctx
+	}
+	opts.OnStart = func(r *executor.Result) {
+		j.update(r.MachineUUID, func(log *dao.AgentLog) {
+			now := time.Now()
+			log.State = StateRunning
+			log.IP = r.MachineIP
+			log.StartedAt = &now
+		})
+	}
+	opts.OnFinish = func(r *executor.Result) {
+		j.update(r.MachineUUID, func(log *dao.AgentLog) {
+			finishLog(log, r)
+		})
+	}
+	waves := j.strategy.waves(uuids)
+	for i, wave := range waves {
+		if i > 0 {
+			j.betweenWaves()
+		}
+		j.setWave(i + 1)
+		executor.RunWithOptions(wave, opts, task)
+	}
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	statusCodes := make([]string, 0, len(j.hosts))
+	cancelled := false
+	for _, h := range j.hosts {
+		statusCodes = append(statusCodes, strconv.Itoa(h.log.StatusCode))
+		if h.log.State == StateCancelled || h.log.State == StateAbandoned {
+			cancelled = true
+		}
+		h.cancel()
+	}
+	j.cancel()
+
+	now := time.Now()
+	j.parent.Status = service.BatchActionStatus(statusCodes)
+	j.parent.JobState = StateFinished
+	if cancelled {
+		j.parent.JobState = StateCancelled
+	}
+	j.parent.FinishedAt = &now
+	j.saveParent()
+
+	j.done = true
+	for ch := range j.subscribers {
+		close(ch)
+		delete(j.subscribers, ch)
+	}
+}
+
+// 更新任务状态并通知订阅者
+func (j *job) setState(state string, message string) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	j.parent.JobState = state
+	j.parent.JobMessage = message
+	j.saveParent()
+	j.notify(&Progress{JobID: j.id, State: state, Message: message, Wave: j.parent.Wave})
+}
+
+func (j *job) setWave(wave int) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	j.parent.Wave = wave
+	j.saveParent()
+}
+
+func (j *job) saveParent() {
+	if err := dao.UpdateAgentLogParent(j.parent); err != nil {
+		logger.Error("failed to update job %d: %s", j.id, err.Error())
+	}
+}
+
+func (j *job) notify(p *Progress) {
+	for ch := range j.subscribers {
+		select {
+		case ch <- p:
+		default:
+			// 订阅者处理过慢时丢弃进度，可通过查询接口获取最新结果
+		}
+	}
+}
+
+// 更新单台机器的执行状态并通知订阅者
+func (j *job) update(uuid string, f func(log *dao.AgentLog)) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	h, ok := j.hosts[uuid]
+	if !ok {
+		return
+	}
+	f(h.log)
+	if err := dao.UpdateAgentLog(h.log); err != nil {
+		logger.Error("failed to update log of job %d: %s", j.id, err.Error())
+	}
+
+	j.notify(&Progress{
+		JobID:       j.id,
+		MachineUUID: uuid,
+		IP:          h.log.IP,
+		State:       h.log.State,
+		ExitCode:    h.log.ExitCode,
+		Message:     h.log.Message,
+		Wave:        j.parent.Wave,
+	})
+}
+
+// 将执行结果写入子日志
+func finishLog(log *dao.AgentLog, r *executor.Result) {
+	switch d := r.Data.(type) {
+	case nil:
+	case *utils.CmdResult:
+		log.Stdout = d.Stdout
+		log.Stderr = d.Stderr
+		log.ExitCode = d.RetCode
+	case string:
+		log.Stdout = d
+	default:
+		bs, err := json.Marshal(d)
+		if err == nil {
+			log.Stdout = string(bs)
+		}
+	}
+
+	now := time.Now()
+	log.FinishedAt = &now
+	switch {
+	case r.OK():
+		log.State = StateSuccess
+		log.StatusCode = http.StatusOK
+		log.Message = "执行成功"
+	case r.Error == executor.ErrCancelled:
+		log.State = StateCancelled
+		log.StatusCode = http.StatusBadRequest
+		log.Message = r.Error
+	case r.Error == executor.ErrAbandoned:
+		log.State = StateAbandoned
+		log.StatusCode = http.StatusBadRequest
+		log.Message = r.Error
+	default:
+		log.State = StateFailed
+		log.StatusCode = http.StatusBadRequest
+		log.Message = r.Error
+		if log.Stderr == "" {
+			log.Stderr = r.Error
+		}
+		if log.ExitCode == 0 {
+			log.ExitCode = -1
+		}
+	}
+}
+
+// 检查任务参数，用于提交前校验
+func (p *Param) Validate() error {
+	if _, _, _, _, err := p.task(); err != nil {
+		return err
+	}
+	if err := p.checkApproval(); err != nil {
+		return err
+	}
+	if p.Strategy != nil {
+		return p.Strategy.validate()
+	}
+	return nil
+}
+
+// 任务对应的需审批操作，不需要审批时为空
+func (p *Param) ApprovalOperation() string {
+	if p.Type == TypePackageRemove && approval.Required(approval.OpRpmRemove) {
+		return approval.OpRpmRemove
+	}
+	return ""
+}
+
+// 需要审批的操作不能直接作为任务执行，须通过审批流程提交
+func (p *Param) checkApproval() error {
+	if op := p.ApprovalOperation(); op != "" {
+		return fmt.Errorf("操作 %s 需要审批，不能直接提交任务", op)
+	}
+	return nil
+}
+
+// 任务选择的所有机器
+func (p *Param) Machines() ([]string, error) {
+	return p.machines()
+}
+
+// 合并机器、批次及部门选择的机器
+func (p *Param) machines() ([]string, error) {
+	uuids := append([]string{}, p.UUIDs...)
+	if len(p.BatchIDs) > 0 {
+		uuids = append(uuids, dao.BatchIds2UUIDs(p.BatchIDs)...)
+	}
+	if len(p.DepartIDs) > 0 {
+		departUUIDs, err := batch.DepartMachineUUIDs(p.DepartIDs)
+		if err != nil {
+			return nil, err
+		}
+		uuids = append(uuids, departUUIDs...)
+	}
+
+	result := []string{}
+	exist := map[string]bool{}
+	for _, uuid := range uuids {
+		if uuid == "" || exist[uuid] {
+			continue
+		}
+		exist[uuid] = true
+		result = append(result, uuid)
+	}
+	return result, nil
+}
+
+// 命令、脚本任务及配置文件的校验、生效命令需通过命令策略检查
+func (p *Param) checkPolicy(action string) error {
+	subject := p.Subject
+	if subject == nil {
+		subject = policy.UserSubjectByName(p.UserName)
+	}
+	var content string
+	switch p.Type {
+	case TypeCommand:
+		content = p.Command
+	case TypeScript:
+		content = strings.TrimSpace(p.Script + "\n" + strings.Join(p.Params, " "))
+	case TypeFileBroadcast:
+		return p.Hooks.Check(subject, p.Confirm)
+	default:
+		return nil
+	}
+	_, err := policy.Check(subject, action, content, p.Confirm)
+	return err
+}
+
+// 根据任务类型构造在单台机器上执行的操作
+func (p *Param) task() (task executor.Task, logType string, action string, object string, err error) {
+	switch p.Type {
+	case TypeCommand:
+		if p.Command == "" {
+			return nil, "", "", "", errors.New("command is empty")
+		}
+		return func(agent *agentmanager.Agent) (interface{}, error) {
+			return executor.CommandOutput(agent.RunCommand(base64.StdEncoding.EncodeToString([]byte(p.Command))))
+		}, service.LogTypeCommand, service.RunCommand, p.Command, nil
+	case TypeScript:
+		if p.Script == "" {
+			return nil, "", "", "", errors.New("script is empty")
+		}
+		return func(agent *agentmanager.Agent) (interface{}, error) {
+			script := base64.StdEncoding.EncodeToString([]byte(p.Script))
+			return executor.CommandOutput(agent.RunScriptWithOptions(script, p.Params, p.Interpreter, p.Options))
+		}, service.LogTypeCommand, service.RunScript, "script", nil
+	case TypePackageInstall:
+		if p.Package == "" {
+			return nil, "", "", "", errors.New("package is empty")
+		}
+		return func(agent *agentmanager.Agent) (interface{}, error) {
+			return executor.Output(agent.InstallRpm(p.Package))
+		}, service.LogTypeRPM, service.RPMInstall, p.Package, nil
+	case TypePackageRemove:
+		if p.Package == "" {
+			return nil, "", "", "", errors.New("package is empty")
+		}
+		return func(agent *agentmanager.Agent) (interface{}, error) {
+			return executor.Output(agent.RemoveRpm(p.Package))
+		}, service.LogTypeRPM, service.RPMRemove, p.Package, nil
+	case TypeFileBroadcast:
+		if p.Path == "" || p.FileName == "" || p.Text == "" {
+			return nil, "", "", "", errors.New("path, filename and text are required")
+		}
+		if p.Template {
+			if _, err := file.ParseTemplate(p.Text); err != nil {
+				return nil, "", "", "", err
+			}
+		}
+		return func(agent *agentmanager.Agent) (interface{}, error) {
+			text := p.Text
+			if p.Template {
+				rendered, _, err := file.Render(p.Text, agent.UUID)
+				if err != nil {
+					return nil, err
+				}
+				text = rendered
+			}
+			return file.Install(agent, p.Path, p.FileName, text, p.Hooks)
+		}, service.LogTypeBroadcast, service.BroadcastFile, p.FileName, nil
+	}
+	return nil, "", "", "", fmt.Errorf("unknown job type: %s", p.Type)
+}
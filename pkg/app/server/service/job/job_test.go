@@ -0,0 +1,95 @@
+package job
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/executor"
+	"openeuler.org/PilotGo/PilotGo/pkg/utils"
+)
+
+func TestFinishLog(t *testing.T) {
+	cases := []struct {
+		name     string
+		result   *executor.Result
+		state    string
+		code     int
+		exitCode int
+		stdout   string
+		stderr   string
+	}{
+		{"success", &executor.Result{Data: &utils.CmdResult{Stdout: "ok"}}, StateSuccess, http.StatusOK, 0, "ok", ""},
+		{"string output", &executor.Result{Data: "installed"}, StateSuccess, http.StatusOK, 0, "installed", ""},
+		{"json output", &executor.Result{Data: map[string]int{"n": 1}}, StateSuccess, http.StatusOK, 0, `{"n":1}`, ""},
+		{"exit code", &executor.Result{Data: &utils.CmdResult{RetCode: 2, Stderr: "no such file"}, Error: "exit code 2"},
+			StateFailed, http.StatusBadRequest, 2, "", "no such file"},
+		{"offline", &executor.Result{Error: executor.ErrAgentOffline}, StateFailed, http.StatusBadRequest, -1, "", executor.ErrAgentOffline},
+		{"cancelled", &executor.Result{Error: executor.ErrCancelled}, StateCancelled, http.StatusBadRequest, 0, "", ""},
+		{"abandoned", &executor.Result{Error: executor.ErrAbandoned}, StateAbandoned, http.StatusBadRequest, 0, "", ""},
+	}
+	for _, c := range cases {
+		log := &dao.AgentLog{State: StateRunning}
+		finishLog(log, c.result)
+		assert.Equal(t, c.state, log.State, c.name)
+		assert.Equal(t, c.code, log.StatusCode, c.name)
+		assert.Equal(t, c.exitCode, log.ExitCode, c.name)
+		assert.Equal(t, c.stdout, log.Stdout, c.name)
+		assert.Equal(t, c.stderr, log.Stderr, c.name)
+		assert.NotNil(t, log.FinishedAt, c.name)
+	}
+}
+
+func TestParamTask(t *testing.T) {
+	cases := []struct {
+		param  Param
+		action string
+		valid  bool
+	}{
+		{Param{Type: TypeCommand, Command: "uptime"}, "uptime", true},
+		{Param{Type: TypeCommand}, "", false},
+		{Param{Type: TypeScript, Script: "echo 1"}, "script", true},
+		{Param{Type: TypeScript}, "", false},
+		{Param{Type: TypePackageInstall, Package: "nginx"}, "nginx", true},
+		{Param{Type: TypePackageRemove}, "", false},
+		{Param{Type: TypeFileBroadcast, Path: "/etc", FileName: "motd", Text: "hi"}, "motd", true},
+		{Param{Type: TypeFileBroadcast, Path: "/etc", FileName: "motd"}, "", false},
+		{Param{Type: TypeFileBroadcast, Path: "/etc", FileName: "motd", Text: "{{ .Hostname", Template: true}, "", false},
+		{Param{Type: "reboot"}, "", false},
+	}
+	for _, c := range cases {
+		task, _, _, object, err := c.param.task()
+		if !c.valid {
+			assert.NotNil(t, err, "%+v", c.param)
+			continue
+		}
+		assert.Nil(t, err, "%+v", c.param)
+		assert.NotNil(t, task)
+		assert.Equal(t, c.action, object)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	assert.NotNil(t, Cancel(-1, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	j := &job{id: -2, ctx: ctx, cancel: cancel, hosts: map[string]*host{}}
+	for _, uuid := range []string{"m1", "m2"} {
+		hctx, hcancel := context.WithCancel(ctx)
+		j.hosts[uuid] = &host{log: &dao.AgentLog{}, ctx: hctx, cancel: hcancel}
+	}
+	jobs.Store(j.id, j)
+	defer jobs.Delete(j.id)
+
+	// 只取消指定的机器
+	assert.Nil(t, Cancel(j.id, []string{"m1"}))
+	assert.NotNil(t, j.hosts["m1"].ctx.Err())
+	assert.Nil(t, j.hosts["m2"].ctx.Err())
+	assert.NotNil(t, Cancel(j.id, []string{"m3"}))
+
+	assert.Nil(t, Cancel(j.id, nil))
+	assert.NotNil(t, j.hosts["m2"].ctx.Err())
+	assert.NotNil(t, ctx.Err())
+}
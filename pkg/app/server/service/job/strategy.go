@@ -0,0 +1,146 @@
+package job
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// 分批执行策略，为空时所有机器同时执行
+type Strategy struct {
+	// 金丝雀机器数，先在这些机器上执行
+	Canary int `json:"canary"`
+	// 每批机器数
+	WaveSize int `json:"wave_size"`
+	// 每批机器占总数的百分比，设置后忽略wave_size
+	WavePercent int `json:"wave_percent"`
+	// 每批之间的暂停时间，单位秒
+	PauseSeconds int `json:"pause_seconds"`
+	// 每批执行结束后暂停，等待手动继续
+	ManualPause bool `json:"manual_pause"`
+	// 累计失败率超过该百分比时自动暂停，0表示不检查
+	FailureThreshold float64 `json:"failure_threshold"`
+}
+
+func (s *Strategy) validate() error {
+	if s.Canary < 0 || s.WaveSize < 0 || s.PauseSeconds < 0 {
+		return errors.New("canary, wave_size and pause_seconds must not be negative")
+	}
+	if s.WavePercent < 0 || s.WavePercent > 100 {
+		return errors.New("wave_percent must be between 0 and 100")
+	}
+	if s.FailureThreshold < 0 || s.FailureThreshold > 100 {
+		return errors.New("failure_threshold must be between 0 and 100")
+	}
+	return nil
+}
+
+// 按策略将机器划分为多个批次
+func (s *Strategy) waves(uuids []string) [][]string {
+	if s == nil {
+		return [][]string{uuids}
+	}
+
+	waves := [][]string{}
+	rest := uuids
+	if s.Canary > 0 {
+		n := s.Canary
+		if n > len(rest) {
+			n = len(rest)
+		}
+		waves = append(waves, rest[:n])
+		rest = rest[n:]
+	}
+
+	size := s.WaveSize
+	if s.WavePercent > 0 {
+		size = (len(uuids)*s.WavePercent + 99) / 100
+	}
+	if size <= 0 {
+		size = len(rest)
+	}
+	for len(rest) > 0 {
+		n := size
+		if n > len(rest) {
+			n = len(rest)
+		}
+		waves = append(waves, rest[:n])
+		rest = rest[n:]
+	}
+	return waves
+}
+
+// 继续执行暂停的任务
+func Resume(id int) error {
+	v, ok := jobs.Load(id)
+	if !ok {
+		return fmt.Errorf("job %d is not running", id)
+	}
+	j := v.(*job)
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if j.parent.JobState != StatePaused {
+		return fmt.Errorf("job %d is not paused", id)
+	}
+	select {
+	case j.resume <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// 终止任务，未执行的机器全部取消
+func Abort(id int) error {
+	return Cancel(id, nil)
+}
+
+// 每批执行结束后检查失败率并等待进入下一批
+func (j *job) betweenWaves() {
+	s := j.strategy
+
+	reason := ""
+	if rate := j.failureRate(); s.FailureThreshold > 0 && rate > s.FailureThreshold {
+		reason = fmt.Sprintf("failure rate %.2f%% exceeds threshold %.2f%%", rate, s.FailureThreshold)
+	} else if s.ManualPause {
+		reason = "waiting for resume"
+	}
+
+	if reason != "" {
+		j.setState(StatePaused, reason)
+		select {
+		case <-j.resume:
+		case <-j.ctx.Done():
+		}
+		j.setState(StateRunning, "")
+		return
+	}
+
+	if s.PauseSeconds > 0 {
+		select {
+		case <-time.After(time.Duration(s.PauseSeconds) * time.Second):
+		case <-j.ctx.Done():
+		}
+	}
+}
+
+// 已结束机器中的失败百分比，取消的机器不计入
+func (j *job) failureRate() float64 {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	finished, failed := 0, 0
+	for _, h := range j.hosts {
+		switch h.log.State {
+		case StateSuccess:
+			finished++
+		case StateFailed:
+			finished++
+			failed++
+		}
+	}
+	if finished == 0 {
+		return 0
+	}
+	return float64(failed) * 100 / float64(finished)
+}
@@ -0,0 +1,47 @@
+package job
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStrategyWaves(t *testing.T) {
+	uuids := []string{"a", "b", "c", "d", "e"}
+	cases := []struct {
+		name     string
+		strategy *Strategy
+		uuids    []string
+		waves    [][]string
+	}{
+		{"nil", nil, uuids, [][]string{{"a", "b", "c", "d", "e"}}},
+		{"empty", &Strategy{}, uuids, [][]string{{"a", "b", "c", "d", "e"}}},
+		{"canary", &Strategy{Canary: 1}, uuids, [][]string{{"a"}, {"b", "c", "d", "e"}}},
+		{"canary exceeds", &Strategy{Canary: 10}, uuids, [][]string{{"a", "b", "c", "d", "e"}}},
+		{"wave size", &Strategy{WaveSize: 2}, uuids, [][]string{{"a", "b"}, {"c", "d"}, {"e"}}},
+		{"canary and wave size", &Strategy{Canary: 1, WaveSize: 3}, uuids, [][]string{{"a"}, {"b", "c", "d"}, {"e"}}},
+		{"wave percent rounds up", &Strategy{WavePercent: 30}, uuids, [][]string{{"a", "b"}, {"c", "d"}, {"e"}}},
+		{"wave percent overrides size", &Strategy{WaveSize: 1, WavePercent: 50}, uuids, [][]string{{"a", "b", "c"}, {"d", "e"}}},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.waves, c.strategy.waves(c.uuids), c.name)
+	}
+}
+
+func TestStrategyValidate(t *testing.T) {
+	cases := []struct {
+		strategy *Strategy
+		valid    bool
+	}{
+		{&Strategy{}, true},
+		{&Strategy{Canary: 1, WaveSize: 2, WavePercent: 100, FailureThreshold: 50}, true},
+		{&Strategy{Canary: -1}, false},
+		{&Strategy{WaveSize: -1}, false},
+		{&Strategy{PauseSeconds: -1}, false},
+		{&Strategy{WavePercent: 101}, false},
+		{&Strategy{FailureThreshold: -0.1}, false},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.valid, c.strategy.validate() == nil, "%+v", *c.strategy)
+	}
+}
@@ -3,6 +3,7 @@ package machine
 import (
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/common"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/eventbus"
 )
 
 type MachineNode = dao.MachineNode
@@ -56,7 +57,12 @@ func DeleteMachine(Deluuid []string) map[string]string {
 	for _, machinedeluuid := range Deluuid {
 		if err := dao.DeleteMachine(machinedeluuid); err != nil {
 			machinelist[machinedeluuid] = err.Error()
+			continue
 		}
+		eventbus.PublishEvent(&eventbus.EventMessage{
+			MessageType: eventbus.MsgHostRemove,
+			MachineUUID: machinedeluuid,
+		})
 	}
 	return machinelist
 }
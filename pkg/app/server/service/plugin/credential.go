@@ -0,0 +1,445 @@
+package plugin
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/batch"
+	"openeuler.org/PilotGo/PilotGo/pkg/utils"
+)
+
+// 插件凭证类型
+const (
+	CredentialToken = "token"
+	CredentialHMAC  = "hmac"
+)
+
+// 插件凭证相关请求头
+const (
+	HeaderPluginToken     = "X-PilotGo-Plugin-Token"
+	HeaderPluginName      = "X-PilotGo-Plugin"
+	HeaderPluginTimestamp = "X-PilotGo-Timestamp"
+	HeaderPluginSignature = "X-PilotGo-Signature"
+	HeaderPluginNonce     = "X-PilotGo-Nonce"
+
+	// 握手时下发给插件的凭证
+	HeaderCredentialType = "X-PilotGo-Credential-Type"
+	HeaderCredential     = "X-PilotGo-Credential"
+)
+
+// hmac签名允许的时间偏差
+const signatureMaxSkew = 5 * time.Minute
+
+// nonce的最大长度
+const maxNonceLength = 64
+
+// 授权全部接口
+const ScopeAll = "*"
+
+// 未指定授权范围时默认允许的只读接口
+var DefaultEndpoints = []string{"machine_list", "plugins", "listener", "events"}
+
+type Credential struct {
+	PluginUUID string `json:"plugin_uuid"`
+	PluginName string `json:"plugin_name"`
+	Type       string `json:"type"`
+	// 仅在生成或重新生成时返回，之后无法再查询
+	Secret      string   `json:"secret,omitempty"`
+	Endpoints   []string `json:"endpoints"`
+	Departments []int    `json:"departments"`
+	Batches     []int    `json:"batches"`
+}
+
+type CredentialParam struct {
+	Type        string   `json:"credential_type"`
+	Endpoints   []string `json:"endpoints"`
+	Departments []int    `json:"departments"`
+	Batches     []int    `json:"batches"`
+}
+
+func randomSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// 生成插件凭证
+func NewCredential(p *Plugin, param *CredentialParam) (*Credential, error) {
+	c := &Credential{
+		PluginUUID: p.UUID,
+		PluginName: p.Name,
+		Type:       CredentialToken,
+		Endpoints:  DefaultEndpoints,
+	}
+	if param != nil {
+		if param.Type != "" {
+			c.Type = param.Type
+		}
+		if len(param.Endpoints) != 0 {
+			c.Endpoints = param.Endpoints
+		}
+		c.Departments = param.Departments
+		c.Batches = param.Batches
+	}
+	if c.Type != CredentialToken && c.Type != CredentialHMAC {
+		return nil, fmt.Errorf("unsupported credential type: %s", c.Type)
+	}
+
+	secret, err := randomSecret()
+	if err != nil {
+		return nil, err
+	}
+	c.Secret = secret
+	return c, nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// 将密钥写入凭证记录，token方式只保存hash
+func setSecret(m *dao.PluginCredential, secret string) {
+	if m.Type == CredentialToken {
+		m.Secret = ""
+		m.SecretHash = hashSecret(secret)
+		return
+	}
+	m.Secret = secret
+	m.SecretHash = ""
+}
+
+func (c *Credential) toModel() *dao.PluginCredential {
+	m := &dao.PluginCredential{
+		PluginUUID:  c.PluginUUID,
+		PluginName:  c.PluginName,
+		Type:        c.Type,
+		Endpoints:   strings.Join(c.Endpoints, ","),
+		Departments: joinInts(c.Departments),
+		Batches:     joinInts(c.Batches),
+	}
+	if c.Secret != "" {
+		setSecret(m, c.Secret)
+	}
+	return m
+}
+
+// 不含密钥
+func fromModel(m *dao.PluginCredential) *Credential {
+	return &Credential{
+		PluginUUID:  m.PluginUUID,
+		PluginName:  m.PluginName,
+		Type:        m.Type,
+		Endpoints:   splitStrings(m.Endpoints),
+		Departments: utils.String2Int(splitStrings(m.Departments)),
+		Batches:     utils.String2Int(splitStrings(m.Batches)),
+	}
+}
+
+func joinInts(ids []int) string {
+	s := make([]string, 0, len(ids))
+	for _, id := range ids {
+		s = append(s, strconv.Itoa(id))
+	}
+	return strings.Join(s, ",")
+}
+
+func splitStrings(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, ",")
+}
+
+func SaveCredential(c *Credential) error {
+	return dao.SavePluginCredential(c.toModel())
+}
+
+// 旧版本以明文保存的token改为只保存hash
+func hashPlainTokens() error {
+	list, err := dao.PluginCredentials()
+	if err != nil {
+		return err
+	}
+	for i := range list {
+		m := &list[i]
+		if m.Type != CredentialToken || m.Secret == "" {
+			continue
+		}
+		setSecret(m, m.Secret)
+		if err := dao.SavePluginCredential(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 获取插件凭证
+func GetCredential(pluginUUID string) (*Credential, error) {
+	m, err := dao.GetPluginCredential(pluginUUID)
+	if err != nil {
+		return nil, err
+	}
+	if m.ID == 0 {
+		return nil, errors.New("plugin credential not found")
+	}
+	return fromModel(m), nil
+}
+
+// 更新插件授权范围，rotate为true时重新生成密钥
+func UpdateCredential(pluginUUID string, param *CredentialParam, rotate bool) (*Credential, error) {
+	p := globalManager.getByUUID(pluginUUID)
+	if p == nil {
+		return nil, errors.New("plugin not found")
+	}
+
+	m, err := dao.GetPluginCredential(pluginUUID)
+	if err != nil {
+		return nil, err
+	}
+	if m.ID == 0 {
+		// 旧版本添加的插件没有凭证，直接生成
+		c, err := NewCredential(p, param)
+		if err != nil {
+			return nil, err
+		}
+		return c, SaveCredential(c)
+	}
+
+	c := fromModel(m)
+	if param.Type != "" && param.Type != c.Type {
+		if param.Type != CredentialToken && param.Type != CredentialHMAC {
+			return nil, fmt.Errorf("unsupported credential type: %s", param.Type)
+		}
+		c.Type = param.Type
+		rotate = true
+	}
+	if param.Endpoints != nil {
+		c.Endpoints = param.Endpoints
+	}
+	if param.Departments != nil {
+		c.Departments = param.Departments
+	}
+	if param.Batches != nil {
+		c.Batches = param.Batches
+	}
+	if rotate {
+		secret, err := randomSecret()
+		if err != nil {
+			return nil, err
+		}
+		c.Secret = secret
+	}
+
+	nm := c.toModel()
+	if !rotate {
+		nm.Secret, nm.SecretHash = m.Secret, m.SecretHash
+	}
+	return c, dao.SavePluginCredential(nm)
+}
+
+// 校验插件请求携带的凭证，已停用或已卸载的插件不允许调用
+// token方式：Authorization: Bearer <token> 或 X-PilotGo-Plugin-Token: <token>
+// hmac方式：X-PilotGo-Plugin: <插件名>，X-PilotGo-Timestamp: <unix秒>，X-PilotGo-Nonce: <随机串>，
+// X-PilotGo-Signature: hex(hmac_sha256(key, method\nrequest_uri\ntimestamp\nnonce\nhex(sha256(body))))
+func Authenticate(req *http.Request) (*Credential, error) {
+	var c *Credential
+	var err error
+	if name := req.Header.Get(HeaderPluginName); name != "" {
+		c, err = authenticateHMAC(req, name)
+	} else {
+		c, err = authenticateToken(req)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	p := globalManager.getByUUID(c.PluginUUID)
+	if p == nil {
+		return nil, errors.New("plugin not found")
+	}
+	if p.Enabled == PluginDisabled {
+		return nil, errors.New("plugin disabled")
+	}
+	return c, nil
+}
+
+func authenticateToken(req *http.Request) (*Credential, error) {
+	token := req.Header.Get(HeaderPluginToken)
+	if token == "" {
+		token = strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+	}
+	if token == "" {
+		return nil, errors.New("missing plugin credential")
+	}
+
+	hash := hashSecret(token)
+	m, err := dao.GetPluginCredentialBySecretHash(CredentialToken, hash)
+	if err != nil {
+		return nil, err
+	}
+	if m.ID == 0 || !hmac.Equal([]byte(m.SecretHash), []byte(hash)) {
+		return nil, errors.New("invalid plugin token")
+	}
+	return fromModel(m), nil
+}
+
+func authenticateHMAC(req *http.Request, name string) (*Credential, error) {
+	m, err := dao.GetPluginCredentialByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if m.ID == 0 || m.Type != CredentialHMAC {
+		return nil, errors.New("invalid plugin credential")
+	}
+
+	sec, nonce, err := verifySignature(req, m.Secret, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	// 签名校验通过后再记录nonce，避免伪造请求占用
+	if !usedNonces.use(name+"\n"+nonce, time.Unix(sec, 0).Add(signatureMaxSkew)) {
+		return nil, errors.New("replayed request")
+	}
+	return fromModel(m), nil
+}
+
+// 校验请求的时间戳、nonce及hmac签名，返回签名时间戳及nonce
+func verifySignature(req *http.Request, key string, now time.Time) (int64, string, error) {
+	ts := req.Header.Get(HeaderPluginTimestamp)
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return 0, "", errors.New("invalid signature timestamp")
+	}
+	skew := now.Sub(time.Unix(sec, 0))
+	if skew > signatureMaxSkew || skew < -signatureMaxSkew {
+		return 0, "", errors.New("signature expired")
+	}
+	nonce := req.Header.Get(HeaderPluginNonce)
+	if nonce == "" || len(nonce) > maxNonceLength {
+		return 0, "", errors.New("invalid signature nonce")
+	}
+
+	body := []byte{}
+	if req.Body != nil {
+		body, err = io.ReadAll(req.Body)
+		if err != nil {
+			return 0, "", err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	expected := Sign(key, req.Method, req.URL.RequestURI(), ts, nonce, body)
+	if !hmac.Equal([]byte(expected), []byte(req.Header.Get(HeaderPluginSignature))) {
+		return 0, "", errors.New("invalid signature")
+	}
+	return sec, nonce, nil
+}
+
+// 计算hmac签名
+func Sign(key, method, uri, timestamp, nonce string, body []byte) string {
+	bodyHash := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(method + "\n" + uri + "\n" + timestamp + "\n" + nonce + "\n" + hex.EncodeToString(bodyHash[:])))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// 签名有效期内已使用的nonce，防止请求被重放
+type nonceCache struct {
+	lock sync.Mutex
+	// nonce到过期时间
+	seen      map[string]time.Time
+	lastClean time.Time
+}
+
+var usedNonces = &nonceCache{seen: map[string]time.Time{}}
+
+// 记录nonce，已使用过时返回false
+func (n *nonceCache) use(nonce string, expire time.Time) bool {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	now := time.Now()
+	if now.Sub(n.lastClean) > time.Minute {
+		for k, t := range n.seen {
+			if now.After(t) {
+				delete(n.seen, k)
+			}
+		}
+		n.lastClean = now
+	}
+	if t, ok := n.seen[nonce]; ok && !now.After(t) {
+		return false
+	}
+	n.seen[nonce] = expire
+	return true
+}
+
+// 检查凭证是否允许调用接口
+func (c *Credential) AllowEndpoint(endpoint string) bool {
+	for _, e := range c.Endpoints {
+		if e == ScopeAll || e == endpoint {
+			return true
+		}
+	}
+	return false
+}
+
+// 凭证是否限制了可操作的机器范围
+func (c *Credential) Restricted() bool {
+	return len(c.Departments) != 0 || len(c.Batches) != 0
+}
+
+// 获取凭证允许操作的机器
+func (c *Credential) AllowedMachines() (map[string]bool, error) {
+	allowed := map[string]bool{}
+	uuids, err := batch.DepartMachineUUIDs(c.Departments)
+	if err != nil {
+		return nil, err
+	}
+	uuids = append(uuids, dao.BatchIds2UUIDs(c.Batches)...)
+	for _, uuid := range uuids {
+		allowed[uuid] = true
+	}
+	return allowed, nil
+}
+
+// 检查凭证是否允许获取机器上的事件，与机器无关的事件不受机器范围限制
+func (c *Credential) AllowEvent(machineUUID string) bool {
+	if machineUUID == "" || !c.Restricted() {
+		return true
+	}
+	return c.CheckMachines([]string{machineUUID}) == nil
+}
+
+// 检查凭证是否允许操作指定机器
+func (c *Credential) CheckMachines(uuids []string) error {
+	if !c.Restricted() {
+		return nil
+	}
+
+	allowed, err := c.AllowedMachines()
+	if err != nil {
+		return err
+	}
+	for _, uuid := range uuids {
+		if !allowed[uuid] {
+			return fmt.Errorf("machine %s is out of plugin scope", uuid)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package plugin
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+)
+
+func signedRequest(key, body string, ts time.Time, nonce string) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/pluginapi/run_command?x=1", strings.NewReader(body))
+	sec := strconv.FormatInt(ts.Unix(), 10)
+	req.Header.Set(HeaderPluginName, "demo")
+	req.Header.Set(HeaderPluginTimestamp, sec)
+	req.Header.Set(HeaderPluginNonce, nonce)
+	req.Header.Set(HeaderPluginSignature, Sign(key, req.Method, req.URL.RequestURI(), sec, nonce, []byte(body)))
+	return req
+}
+
+func TestVerifySignature(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		name   string
+		req    func() *http.Request
+		hasErr bool
+	}{
+		{"valid", func() *http.Request { return signedRequest("key", `{"a":1}`, now, "n1") }, false},
+		{"empty body", func() *http.Request { return signedRequest("key", "", now, "n1") }, false},
+		{"small skew", func() *http.Request { return signedRequest("key", "", now.Add(time.Minute), "n1") }, false},
+		{"wrong key", func() *http.Request { return signedRequest("other", `{"a":1}`, now, "n1") }, true},
+		{"expired", func() *http.Request { return signedRequest("key", "", now.Add(-signatureMaxSkew-time.Second), "n1") }, true},
+		{"future", func() *http.Request { return signedRequest("key", "", now.Add(signatureMaxSkew+time.Second), "n1") }, true},
+		{"no nonce", func() *http.Request { return signedRequest("key", "", now, "") }, true},
+		{"long nonce", func() *http.Request { return signedRequest("key", "", now, strings.Repeat("n", maxNonceLength+1)) }, true},
+		{"bad timestamp", func() *http.Request {
+			req := signedRequest("key", "", now, "n1")
+			req.Header.Set(HeaderPluginTimestamp, "now")
+			return req
+		}, true},
+		{"tampered body", func() *http.Request {
+			req := signedRequest("key", `{"a":1}`, now, "n1")
+			req.Body = http.NoBody
+			return req
+		}, true},
+		{"tampered uri", func() *http.Request {
+			req := signedRequest("key", "", now, "n1")
+			req.URL.RawQuery = "x=2"
+			return req
+		}, true},
+		{"tampered nonce", func() *http.Request {
+			req := signedRequest("key", "", now, "n1")
+			req.Header.Set(HeaderPluginNonce, "n2")
+			return req
+		}, true},
+	}
+	for _, c := range cases {
+		_, _, err := verifySignature(c.req(), "key", now)
+		assert.Equal(t, c.hasErr, err != nil, c.name)
+	}
+
+	// 校验后请求体仍可被读取
+	req := signedRequest("key", `{"a":1}`, now, "n1")
+	sec, nonce, err := verifySignature(req, "key", now)
+	assert.Nil(t, err)
+	assert.Equal(t, now.Unix(), sec)
+	assert.Equal(t, "n1", nonce)
+	body, err := io.ReadAll(req.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"a":1}`, string(body))
+}
+
+func TestNonceCache(t *testing.T) {
+	n := &nonceCache{seen: map[string]time.Time{}}
+	expire := time.Now().Add(time.Minute)
+
+	assert.True(t, n.use("demo\nn1", expire))
+	assert.False(t, n.use("demo\nn1", expire))
+	// 不同插件的nonce互不影响
+	assert.True(t, n.use("other\nn1", expire))
+	// 过期的nonce可以再次使用
+	assert.True(t, n.use("demo\nn2", time.Now().Add(-time.Second)))
+	assert.True(t, n.use("demo\nn2", expire))
+}
+
+func TestNewCredential(t *testing.T) {
+	p := &Plugin{UUID: "u1", Name: "demo"}
+
+	c, err := NewCredential(p, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, CredentialToken, c.Type)
+	assert.Equal(t, DefaultEndpoints, c.Endpoints)
+	assert.Len(t, c.Secret, 64)
+	assert.False(t, c.Restricted())
+
+	c, err = NewCredential(p, &CredentialParam{Type: CredentialHMAC, Endpoints: []string{"run_command"}, Batches: []int{2}})
+	assert.Nil(t, err)
+	assert.Equal(t, CredentialHMAC, c.Type)
+	assert.True(t, c.AllowEndpoint("run_command"))
+	assert.False(t, c.AllowEndpoint("machine_list"))
+	assert.True(t, c.Restricted())
+
+	_, err = NewCredential(p, &CredentialParam{Type: "password"})
+	assert.NotNil(t, err)
+}
+
+func TestCredentialModel(t *testing.T) {
+	c := &Credential{
+		PluginUUID:  "u1",
+		PluginName:  "demo",
+		Type:        CredentialToken,
+		Secret:      "secret",
+		Endpoints:   []string{ScopeAll},
+		Departments: []int{1, 3},
+	}
+
+	// token方式只保存hash
+	m := c.toModel()
+	assert.Empty(t, m.Secret)
+	assert.Equal(t, hashSecret("secret"), m.SecretHash)
+	assert.Equal(t, "1,3", m.Departments)
+	assert.Empty(t, m.Batches)
+
+	restored := fromModel(m)
+	assert.Empty(t, restored.Secret)
+	assert.Equal(t, []int{1, 3}, restored.Departments)
+	assert.Empty(t, restored.Batches)
+	assert.True(t, restored.AllowEndpoint("install_package"))
+
+	// hmac方式需要密钥原文计算签名
+	hm := &dao.PluginCredential{Type: CredentialHMAC}
+	setSecret(hm, "secret")
+	assert.Equal(t, "secret", hm.Secret)
+	assert.Empty(t, hm.SecretHash)
+}
+
+func TestAllowEvent(t *testing.T) {
+	c := &Credential{}
+	assert.True(t, c.AllowEvent("m1"))
+	assert.True(t, c.AllowEvent(""))
+
+	c.Departments = []int{1}
+	assert.True(t, c.AllowEvent(""))
+}
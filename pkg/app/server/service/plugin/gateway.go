@@ -0,0 +1,130 @@
+package plugin
+
+import (
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/auth"
+	"openeuler.org/PilotGo/PilotGo/pkg/logger"
+)
+
+const (
+	// 插件网关默认超时时间
+	DefaultGatewayTimeout = 30 * time.Second
+
+	// 插件网关路由前缀
+	GatewayPrefix = "/plugin/"
+
+	// 转发给插件的用户身份信息
+	HeaderUserID     = "X-PilotGo-User-Id"
+	HeaderUserName   = "X-PilotGo-User-Name"
+	HeaderUserEmail  = "X-PilotGo-User-Email"
+	HeaderUserDepart = "X-PilotGo-User-Depart"
+	HeaderUserRole   = "X-PilotGo-User-Role"
+)
+
+type gatewayProxy struct {
+	url     string
+	timeout int
+	proxy   *httputil.ReverseProxy
+}
+
+// 插件名称到反向代理的缓存，复用后端连接
+var gatewayProxies sync.Map
+
+// 获取插件的反向代理，插件地址或超时配置变化时重新创建
+func GetReverseProxy(p *Plugin) (*httputil.ReverseProxy, error) {
+	if v, ok := gatewayProxies.Load(p.Name); ok {
+		gp := v.(*gatewayProxy)
+		if gp.url == p.Url && gp.timeout == p.Timeout {
+			return gp.proxy, nil
+		}
+	}
+
+	target, err := url.Parse(p.Url)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := DefaultGatewayTimeout
+	if p.Timeout > 0 {
+		timeout = time.Duration(p.Timeout) * time.Second
+	}
+
+	prefix := GatewayPrefix + p.Name
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	director := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		req.URL.Path = stripPrefix(req.URL.Path, prefix)
+		if req.URL.RawPath != "" {
+			req.URL.RawPath = stripPrefix(req.URL.RawPath, prefix)
+		}
+		director(req)
+	}
+	// 仅限制建立连接及等待响应头的时间，websocket及流式响应建立后不受超时影响
+	proxy.Transport = &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   timeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ResponseHeaderTimeout: timeout,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+	}
+	// 立即刷新响应，支持SSE等流式输出
+	proxy.FlushInterval = -1
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		logger.Error("plugin %s gateway error: %s", p.Name, err.Error())
+		w.WriteHeader(http.StatusBadGateway)
+	}
+
+	gatewayProxies.Store(p.Name, &gatewayProxy{
+		url:     p.Url,
+		timeout: p.Timeout,
+		proxy:   proxy,
+	})
+	return proxy, nil
+}
+
+func stripPrefix(path, prefix string) string {
+	path = strings.TrimPrefix(path, prefix)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return path
+}
+
+// 移除平台登录凭证及客户端伪造的身份头，并写入当前登录用户的身份信息
+func ForwardIdentity(req *http.Request, user *dao.User) {
+	req.Header.Del("Authorization")
+	req.Header.Del("authToken")
+	for _, h := range []string{HeaderUserID, HeaderUserName, HeaderUserEmail, HeaderUserDepart, HeaderUserRole} {
+		req.Header.Del(h)
+	}
+
+	cookies := req.Cookies()
+	req.Header.Del("Cookie")
+	for _, c := range cookies {
+		// 平台登录凭证，不转发给插件
+		if c.Name != auth.TokenCookie {
+			req.AddCookie(c)
+		}
+	}
+
+	if user == nil {
+		return
+	}
+	req.Header.Set(HeaderUserID, strconv.FormatUint(uint64(user.ID), 10))
+	req.Header.Set(HeaderUserName, url.QueryEscape(user.Username))
+	req.Header.Set(HeaderUserEmail, user.Email)
+	req.Header.Set(HeaderUserDepart, url.QueryEscape(user.DepartName))
+	req.Header.Set(HeaderUserRole, user.RoleID)
+}
@@ -0,0 +1,85 @@
+package plugin
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/auth"
+)
+
+func TestStripPrefix(t *testing.T) {
+	cases := map[string]string{
+		"/plugin/grafana":            "/",
+		"/plugin/grafana/":           "/",
+		"/plugin/grafana/api/health": "/api/health",
+		"/plugin/grafana%2Fx":        "/%2Fx",
+	}
+	for path, want := range cases {
+		assert.Equal(t, want, stripPrefix(path, "/plugin/grafana"), path)
+	}
+}
+
+func TestForwardIdentity(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/plugin/grafana/", nil)
+	req.Header.Set("Authorization", "token")
+	req.Header.Set("authToken", "token")
+	req.Header.Set(HeaderUserID, "0")
+	req.Header.Set(HeaderUserRole, "admin")
+	req.AddCookie(&http.Cookie{Name: auth.TokenCookie, Value: "token"})
+	req.AddCookie(&http.Cookie{Name: "grafana_session", Value: "s"})
+
+	ForwardIdentity(req, &dao.User{ID: 7, Username: "张三", Email: "zs@example.com", DepartName: "研发部", RoleID: "2"})
+	assert.Empty(t, req.Header.Get("Authorization"))
+	assert.Empty(t, req.Header.Get("authToken"))
+	assert.Equal(t, "7", req.Header.Get(HeaderUserID))
+	assert.Equal(t, "%E5%BC%A0%E4%B8%89", req.Header.Get(HeaderUserName))
+	assert.Equal(t, "zs@example.com", req.Header.Get(HeaderUserEmail))
+	assert.Equal(t, "2", req.Header.Get(HeaderUserRole))
+
+	_, err := req.Cookie(auth.TokenCookie)
+	assert.Equal(t, http.ErrNoCookie, err)
+	c, err := req.Cookie("grafana_session")
+	assert.Nil(t, err)
+	assert.Equal(t, "s", c.Value)
+
+	t.Run("anonymous", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/plugin/grafana/", nil)
+		req.Header.Set(HeaderUserID, "1")
+		ForwardIdentity(req, nil)
+		assert.Empty(t, req.Header.Get(HeaderUserID))
+	})
+}
+
+func TestGetReverseProxy(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.URL.Path+"?"+r.URL.RawQuery)
+	}))
+	defer backend.Close()
+
+	p := &Plugin{Name: "gateway-test", Url: backend.URL}
+	proxy, err := GetReverseProxy(p)
+	assert.Nil(t, err)
+	cached, err := GetReverseProxy(p)
+	assert.Nil(t, err)
+	assert.Same(t, proxy, cached)
+
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/plugin/gateway-test/api/status?full=1", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "/api/status?full=1", w.Body.String())
+
+	// 超时配置变化后重新创建代理
+	p.Timeout = 5
+	changed, err := GetReverseProxy(p)
+	assert.Nil(t, err)
+	assert.NotSame(t, proxy, changed)
+
+	backend.Close()
+	w = httptest.NewRecorder()
+	changed.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/plugin/gateway-test/", nil))
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+}
@@ -8,12 +8,14 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"time"
 
 	"gitee.com/openeuler/PilotGo-plugins/sdk/common"
 	"gitee.com/openeuler/PilotGo-plugins/sdk/plugin/client"
 	"github.com/google/uuid"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/config"
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/eventbus"
 	"openeuler.org/PilotGo/PilotGo/pkg/logger"
 )
 
@@ -33,11 +35,23 @@ type Plugin struct {
 	PluginType  string `json:"plugin_type"`
 	Enabled     int    `json:"enabled"`
 	Status      string `json:"status"`
+	// 插件网关超时时间，单位秒，为0时使用默认值
+	Timeout int `json:"timeout"`
+	// 最近一次健康探测结果
+	LastProbe *ProbeResult `json:"last_probe"`
 }
 
 // 初始化插件服务
 func ServiceInit() error {
-	return globalManager.RestorePluginInfo()
+	if err := globalManager.RestorePluginInfo(); err != nil {
+		return err
+	}
+	if err := hashPlainTokens(); err != nil {
+		logger.Error("failed to hash plugin tokens: %s", err.Error())
+	}
+
+	globalManager.startProber()
+	return nil
 }
 
 // TODO： 替换成concurrent hashmap
@@ -73,6 +87,7 @@ func (pm *PluginManager) RestorePluginInfo() error {
 			PluginType:  p.PluginType,
 			Enabled:     p.Enabled,
 			Status:      common.StatusOffline,
+			Timeout:     p.Timeout,
 		}
 
 		pm.loadedPlugin[np.Name] = np
@@ -104,12 +119,14 @@ func (pm *PluginManager) Add(p *Plugin) error {
 		Url:         p.Url,
 		PluginType:  p.PluginType,
 		Enabled:     PluginEnabled,
+		Timeout:     p.Timeout,
 	})
 	if err != nil {
 		return err
 	}
 
 	// 记录db无报错之后才更新缓存数据
+	p.Enabled = PluginEnabled
 	pm.loadedPlugin[p.Name] = p
 
 	return nil
@@ -124,29 +141,42 @@ func (pm *PluginManager) Remove(uuid string) error {
 
 	pm.lock.Lock()
 	defer pm.lock.Unlock()
-	name := ""
 	for _, p := range pm.loadedPlugin {
 		if p.UUID == uuid {
-			name = p.Name
-			break
+			delete(pm.loadedPlugin, p.Name)
+			gatewayProxies.Delete(p.Name)
+			return nil
 		}
 	}
-	delete(pm.loadedPlugin, name)
 
 	return errors.New("plugin not found")
 }
 
 // 获取注册的插件
-// func (pm *PluginManager) Get(name string) (string, error) {
-// 	pm.lock.RLock()
-// 	defer pm.lock.RUnlock()
+func (pm *PluginManager) Get(name string) (*Plugin, error) {
+	pm.lock.RLock()
+	defer pm.lock.RUnlock()
 
-// 	if p, ok := pm.loadedPlugin[name]; ok {
-// 		return p.Url, nil
-// 	}
+	if p, ok := pm.loadedPlugin[name]; ok {
+		np := *p
+		return &np, nil
+	}
 
-// 	return "", errors.New("plugin not found")
-// }
+	return nil, errors.New("plugin not found")
+}
+
+func (pm *PluginManager) getByUUID(uuid string) *Plugin {
+	pm.lock.RLock()
+	defer pm.lock.RUnlock()
+
+	for _, p := range pm.loadedPlugin {
+		if p.UUID == uuid {
+			np := *p
+			return &np
+		}
+	}
+	return nil
+}
 
 // 获取所有的插件
 func (pm *PluginManager) GetAll() []*Plugin {
@@ -165,6 +195,9 @@ func (pm *PluginManager) GetAll() []*Plugin {
 			Url:         value.Url,
 			PluginType:  value.PluginType,
 			Enabled:     value.Enabled,
+			Status:      value.Status,
+			Timeout:     value.Timeout,
+			LastProbe:   value.LastProbe,
 		}
 
 		plugins = append(plugins, p)
@@ -197,13 +230,13 @@ func (pm *PluginManager) UpdatePlugin(uuid string, enable int) error {
 		return err
 	}
 
-	pm.lock.RLock()
-	defer pm.lock.RUnlock()
+	pm.lock.Lock()
+	defer pm.lock.Unlock()
 	for _, p := range pm.loadedPlugin {
 		if p.UUID == uuid {
 			p.Enabled = status
+			return nil
 		}
-		return nil
 	}
 
 	return nil
@@ -229,10 +262,11 @@ func GetManager() *PluginManager {
 }
 
 // 与plugin进行握手，交换必要信息
-func Handshake(url string) (*Plugin, error) {
-	info, err := requestPluginInfo(url)
+// 握手时通过请求头将插件凭证下发给插件
+func Handshake(url string, cred *Credential) (*Plugin, error) {
+	info, err := requestPluginInfo(url, cred)
 	if err != nil {
-		logger.Debug("")
+		logger.Debug("handshake with plugin %s failed: %s", url, err.Error())
 		return nil, err
 	}
 
@@ -250,17 +284,32 @@ func Handshake(url string) (*Plugin, error) {
 	return plugin, nil
 }
 
+// 请求插件信息时使用的http client
+var pluginClient = &http.Client{Timeout: 10 * time.Second}
+
 // 发起http请求，提供server地址，同时获取到插件的基本信息
-func requestPluginInfo(url string) (*client.PluginInfo, error) {
+func requestPluginInfo(url string, cred *Credential) (*client.PluginInfo, error) {
 	conf := config.Config().HttpServer
 	url = url + fmt.Sprintf("?server=%s", conf.Addr)
 
-	resp, err := http.Get(url)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if cred != nil {
+		req.Header.Set(HeaderCredentialType, cred.Type)
+		req.Header.Set(HeaderCredential, cred.Secret)
+	}
+
+	resp, err := pluginClient.Do(req)
 	if err != nil {
 		logger.Debug("request plugin info error:%s", err.Error())
 		return nil, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("request plugin info failed, status code: %d", resp.StatusCode)
+	}
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
@@ -272,8 +321,11 @@ func requestPluginInfo(url string) (*client.PluginInfo, error) {
 	err = json.Unmarshal(body, info)
 	if err != nil {
 		logger.Debug("unmarshal request plugin info error:%s", err.Error())
+		return nil, err
+	}
+	if info.Name == "" {
+		return nil, errors.New("invalid plugin info: empty name")
 	}
-	// TODO: check info valid
 	return info, nil
 }
 
@@ -283,24 +335,45 @@ func GetPlugins() []*Plugin {
 }
 
 func GetPlugin(name string) *Plugin {
-	// TODO
-	return nil
+	p, err := globalManager.Get(name)
+	if err != nil {
+		return nil
+	}
+	return p
 }
 
-func AddPlugin(url string) error {
+// 添加插件，并生成插件调用平台接口的凭证
+func AddPlugin(url string, timeout int, param *CredentialParam) (*Credential, error) {
 	logger.Debug("add plugin from %s", url)
 	url = strings.TrimRight(url, "/")
 
-	plugin, err := Handshake(url + "/plugin_manage/info")
+	cred, err := NewCredential(&Plugin{UUID: uuid.New().String()}, param)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	plugin, err := Handshake(url+pluginInfoPath, cred)
+	if err != nil {
+		return nil, err
 	}
-	plugin.UUID = uuid.New().String()
+	plugin.UUID = cred.PluginUUID
+	plugin.Timeout = timeout
+	cred.PluginName = plugin.Name
 
 	if err := globalManager.Add(plugin); err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	if err := SaveCredential(cred); err != nil {
+		logger.Error("failed to save plugin %s credential: %s", plugin.Name, err.Error())
+		globalManager.Remove(plugin.UUID)
+		return nil, err
+	}
+
+	eventbus.PublishEvent(&eventbus.EventMessage{
+		MessageType: eventbus.MsgPluginAdd,
+		MessageData: plugin,
+	})
+	return cred, nil
 }
 
 func DeletePlugin(uuid string) error {
@@ -309,6 +382,14 @@ func DeletePlugin(uuid string) error {
 	if err := globalManager.Remove(uuid); err != nil {
 		return err
 	}
+	if err := dao.DeletePluginCredential(uuid); err != nil {
+		logger.Error("failed to delete plugin credential:%s", err.Error())
+	}
+
+	eventbus.PublishEvent(&eventbus.EventMessage{
+		MessageType: eventbus.MsgPluginRemove,
+		MessageData: map[string]string{"uuid": uuid},
+	})
 	return nil
 }
 
@@ -0,0 +1,161 @@
+package plugin
+
+import (
+	"sync"
+	"time"
+
+	"gitee.com/openeuler/PilotGo-plugins/sdk/common"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/eventbus"
+	"openeuler.org/PilotGo/PilotGo/pkg/logger"
+)
+
+const (
+	// 插件健康探测周期
+	probeInterval = 30 * time.Second
+	// 插件信息接口
+	pluginInfoPath = "/plugin_manage/info"
+)
+
+// 最近一次探测结果
+type ProbeResult struct {
+	Time    time.Time `json:"time"`
+	Latency int64     `json:"latency_ms"`
+	Version string    `json:"version"`
+	Error   string    `json:"error"`
+}
+
+// 周期性探测所有插件的/plugin_manage/info接口，维护插件运行状态
+func (pm *PluginManager) startProber() {
+	go func() {
+		ticker := time.NewTicker(probeInterval)
+		defer ticker.Stop()
+		for {
+			pm.ProbeAll()
+			<-ticker.C
+		}
+	}()
+}
+
+func (pm *PluginManager) ProbeAll() {
+	plugins := pm.GetAll()
+
+	wg := sync.WaitGroup{}
+	for _, p := range plugins {
+		wg.Add(1)
+		go func(p *Plugin) {
+			defer wg.Done()
+			pm.probe(p)
+		}(p)
+	}
+	wg.Wait()
+}
+
+func (pm *PluginManager) probe(p *Plugin) {
+	start := time.Now()
+	info, err := requestPluginInfo(p.Url+pluginInfoPath, nil)
+	result := &ProbeResult{
+		Time:    start,
+		Latency: time.Since(start).Milliseconds(),
+	}
+
+	status := common.StatusRunning
+	if err != nil {
+		status = common.StatusOffline
+		result.Error = err.Error()
+	} else {
+		result.Version = info.Version
+	}
+
+	pm.lock.Lock()
+	cur, ok := pm.loadedPlugin[p.Name]
+	if !ok || cur.UUID != p.UUID {
+		// 探测期间插件已被卸载
+		pm.lock.Unlock()
+		return
+	}
+	oldStatus := cur.Status
+	oldVersion := cur.Version
+	cur.Status = status
+	cur.LastProbe = result
+	pm.lock.Unlock()
+
+	if oldStatus != status {
+		logger.Info("plugin %s status changed: %s -> %s", p.Name, oldStatus, status)
+		msgType := eventbus.MsgPluginOnline
+		if status == common.StatusOffline {
+			msgType = eventbus.MsgPluginOffline
+		}
+		eventbus.PublishEvent(&eventbus.EventMessage{
+			MessageType: msgType,
+			MessageData: map[string]string{"uuid": p.UUID, "name": p.Name, "status": status},
+		})
+	}
+
+	if err == nil && info.Version != oldVersion {
+		// 地址上可能已换成另一个插件，名称不一致时不能重新生成密钥并下发
+		if info.Name != p.Name {
+			logger.Warn("plugin %s reported a different name %s, skip re-handshake", p.Name, info.Name)
+			return
+		}
+		pm.rehandshake(p, oldVersion)
+	}
+}
+
+// 插件版本变化后重新握手，更新插件信息
+func (pm *PluginManager) rehandshake(p *Plugin, oldVersion string) {
+	// 插件升级重启后可能丢失凭证，平台不保存token原文，因此重新生成密钥并在握手时下发，握手成功后才生效
+	cred, err := GetCredential(p.UUID)
+	if err == nil {
+		cred.Secret, err = randomSecret()
+	}
+	if err != nil {
+		logger.Warn("plugin %s has no credential: %s", p.Name, err.Error())
+		cred = nil
+	}
+	np, err := Handshake(p.Url+pluginInfoPath, cred)
+	if err != nil {
+		logger.Error("re-handshake with plugin %s failed: %s", p.Name, err.Error())
+		return
+	}
+	if np.Name != p.Name {
+		logger.Warn("plugin %s reported a different name %s, ignore", p.Name, np.Name)
+		return
+	}
+	if cred != nil {
+		if err := SaveCredential(cred); err != nil {
+			logger.Error("failed to save plugin %s credential: %s", p.Name, err.Error())
+		}
+	}
+
+	err = dao.UpdatePluginInfo(&dao.PluginModel{
+		UUID:        p.UUID,
+		Version:     np.Version,
+		Description: np.Description,
+		Author:      np.Author,
+		Email:       np.Email,
+		Url:         np.Url,
+		PluginType:  np.PluginType,
+	})
+	if err != nil {
+		logger.Error("failed to update plugin %s info: %s", p.Name, err.Error())
+		return
+	}
+
+	pm.lock.Lock()
+	if cur, ok := pm.loadedPlugin[p.Name]; ok && cur.UUID == p.UUID {
+		cur.Version = np.Version
+		cur.Description = np.Description
+		cur.Author = np.Author
+		cur.Email = np.Email
+		cur.Url = np.Url
+		cur.PluginType = np.PluginType
+	}
+	pm.lock.Unlock()
+
+	logger.Info("plugin %s version changed: %s -> %s", p.Name, oldVersion, np.Version)
+	eventbus.PublishEvent(&eventbus.EventMessage{
+		MessageType: eventbus.MsgPluginUpdate,
+		MessageData: map[string]string{"uuid": p.UUID, "name": p.Name, "old_version": oldVersion, "version": np.Version},
+	})
+}
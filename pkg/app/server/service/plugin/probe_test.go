@@ -0,0 +1,87 @@
+package plugin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"gitee.com/openeuler/PilotGo-plugins/sdk/common"
+	"gitee.com/openeuler/PilotGo-plugins/sdk/plugin/client"
+	"github.com/stretchr/testify/assert"
+)
+
+// 返回固定插件信息的插件服务，记录请求中携带的凭证
+func pluginServer(info *client.PluginInfo, credentials *[]string) *httptest.Server {
+	lock := sync.Mutex{}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != pluginInfoPath {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		lock.Lock()
+		*credentials = append(*credentials, r.Header.Get(HeaderCredential))
+		lock.Unlock()
+		json.NewEncoder(w).Encode(info)
+	}))
+}
+
+func TestProbe(t *testing.T) {
+	var credentials []string
+	server := pluginServer(&client.PluginInfo{Name: "probe-test", Version: "1.0"}, &credentials)
+	defer server.Close()
+
+	pm := &PluginManager{loadedPlugin: map[string]*Plugin{}}
+	p := &Plugin{UUID: "u1", Name: "probe-test", Version: "1.0", Url: server.URL, Status: common.StatusRunning}
+	pm.loadedPlugin[p.Name] = p
+
+	pm.probe(p)
+	assert.Equal(t, common.StatusRunning, p.Status)
+	assert.NotNil(t, p.LastProbe)
+	assert.Equal(t, "1.0", p.LastProbe.Version)
+	assert.Empty(t, p.LastProbe.Error)
+	// 探测不携带凭证
+	assert.Equal(t, []string{""}, credentials)
+
+	t.Run("unloaded", func(t *testing.T) {
+		other := &Plugin{UUID: "u2", Name: "probe-test", Url: server.URL}
+		pm.probe(other)
+		assert.Nil(t, other.LastProbe)
+	})
+}
+
+func TestProbeRenamedPlugin(t *testing.T) {
+	var credentials []string
+	server := pluginServer(&client.PluginInfo{Name: "another", Version: "2.0"}, &credentials)
+	defer server.Close()
+
+	pm := &PluginManager{loadedPlugin: map[string]*Plugin{}}
+	p := &Plugin{UUID: "u1", Name: "probe-test", Version: "1.0", Url: server.URL, Status: common.StatusRunning}
+	pm.loadedPlugin[p.Name] = p
+
+	// 名称不一致时不重新握手，不会生成新的密钥并下发
+	pm.probe(p)
+	assert.Equal(t, []string{""}, credentials)
+	assert.Equal(t, "1.0", p.Version)
+	assert.Equal(t, "2.0", p.LastProbe.Version)
+}
+
+func TestRequestPluginInfo(t *testing.T) {
+	var credentials []string
+	server := pluginServer(&client.PluginInfo{Name: "probe-test", Version: "1.0"}, &credentials)
+	defer server.Close()
+
+	info, err := requestPluginInfo(server.URL+pluginInfoPath, &Credential{Type: CredentialToken, Secret: "secret"})
+	assert.Nil(t, err)
+	assert.Equal(t, "probe-test", info.Name)
+	assert.Equal(t, []string{"secret"}, credentials)
+
+	_, err = requestPluginInfo(server.URL+"/missing", nil)
+	assert.NotNil(t, err)
+
+	empty := pluginServer(&client.PluginInfo{}, &credentials)
+	defer empty.Close()
+	_, err = requestPluginInfo(empty.URL+pluginInfoPath, nil)
+	assert.NotNil(t, err)
+}
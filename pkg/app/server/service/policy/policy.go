@@ -0,0 +1,269 @@
+package policy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/auditlog"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/auth"
+	"openeuler.org/PilotGo/PilotGo/pkg/logger"
+)
+
+// 策略动作
+const (
+	ActionAllow     = "allow"
+	ActionDeny      = "deny"
+	ActionDangerous = "dangerous"
+)
+
+// 审计日志中记录的命令最大长度
+const maxAuditContent = 200
+
+// 未配置策略时也生效的危险命令，优先级低于所有自定义策略
+var builtinPolicies = []dao.CommandPolicy{
+	{Name: "builtin:rm-root", Pattern: `rm\s+(-[a-zA-Z]*\s+)*-[a-zA-Z]*[rR][a-zA-Z]*\s+(-[a-zA-Z-]+\s+)*(/|/\*|~|\$HOME)(\s|;|&|\||$)`, Action: ActionDangerous},
+	{Name: "builtin:mkfs", Pattern: `(^|[\s;&|])mkfs(\.\w+)?\s`, Action: ActionDangerous},
+	{Name: "builtin:dd-device", Pattern: `(^|[\s;&|])dd\s.*of=/dev/`, Action: ActionDangerous},
+	{Name: "builtin:write-device", Pattern: `>\s*/dev/(sd|hd|vd|nvme|xvd|dm-)`, Action: ActionDangerous},
+	{Name: "builtin:power", Pattern: `(^|[\s;&|])(shutdown|reboot|poweroff|halt|init\s+[06])(\s|;|&|\||$)`, Action: ActionDangerous},
+	{Name: "builtin:fork-bomb", Pattern: `:\(\)\s*\{\s*:\s*\|\s*:\s*&\s*\}\s*;\s*:`, Action: ActionDangerous},
+}
+
+// 执行命令的操作者
+type Subject struct {
+	// 用户邮箱或plugin:<插件名>
+	Name   string
+	UserID uint
+	// 角色id及部门id，插件以plugin:<插件名>作为角色
+	Roles       []string
+	Departments []string
+}
+
+// 策略检查结果
+type Decision struct {
+	Action  string `json:"action"`
+	Policy  string `json:"policy"`
+	Allowed bool   `json:"allowed"`
+	// 危险命令的确认码，重新提交时需在confirm中填写
+	ConfirmCode string `json:"confirm_code,omitempty"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+func UserSubject(u dao.User) *Subject {
+	s := &Subject{
+		Name:   u.Email,
+		UserID: u.ID,
+		Roles:  splitList(u.RoleID),
+	}
+	for _, d := range []int{u.DepartFirst, u.DepartSecond} {
+		if d != 0 {
+			s.Departments = append(s.Departments, strconv.Itoa(d))
+		}
+	}
+	return s
+}
+
+// 根据请求中的用户邮箱查找操作者，用户不存在时按匿名用户检查
+func UserSubjectByName(email string) *Subject {
+	if email != "" {
+		if u, err := dao.UserInfo(email); err == nil && u.ID != 0 {
+			return UserSubject(u)
+		}
+	}
+	return &Subject{Name: email}
+}
+
+// 以AuthMiddleware写入的登录用户作为操作者，未登录时按匿名用户检查
+func LoginSubject(c *gin.Context) *Subject {
+	if u, ok := auth.LoginUser(c); ok {
+		return UserSubject(*u)
+	}
+	return &Subject{}
+}
+
+func PluginSubject(name string, departments []int) *Subject {
+	s := &Subject{
+		Name:  "plugin:" + name,
+		Roles: []string{"plugin:" + name},
+	}
+	for _, d := range departments {
+		s.Departments = append(s.Departments, strconv.Itoa(d))
+	}
+	return s
+}
+
+// 危险命令的确认码，为命令内容sha256的前8位
+func ConfirmCode(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])[:8]
+}
+
+// 校验策略内容
+func Validate(p *dao.CommandPolicy) error {
+	if p.Name == "" {
+		return errors.New("策略名称不能为空")
+	}
+	if p.Pattern == "" {
+		return errors.New("匹配规则不能为空")
+	}
+	if _, err := regexp.Compile(p.Pattern); err != nil {
+		return fmt.Errorf("匹配规则格式错误: %s", err.Error())
+	}
+	switch p.Action {
+	case ActionAllow, ActionDeny, ActionDangerous:
+	default:
+		return fmt.Errorf("不支持的策略动作: %s", p.Action)
+	}
+	return nil
+}
+
+func Add(p *dao.CommandPolicy) error {
+	if err := Validate(p); err != nil {
+		return err
+	}
+	return dao.AddCommandPolicy(p)
+}
+
+func Update(p *dao.CommandPolicy) error {
+	old, err := dao.GetCommandPolicy(p.ID)
+	if err != nil {
+		return fmt.Errorf("策略 %d 不存在", p.ID)
+	}
+	if err := Validate(p); err != nil {
+		return err
+	}
+	p.CreatedAt = old.CreatedAt
+	return dao.UpdateCommandPolicy(p)
+}
+
+func Delete(ids []uint) error {
+	return dao.DeleteCommandPolicy(ids)
+}
+
+func List() (*[]dao.CommandPolicy, *gorm.DB) {
+	return dao.CommandPolicyList()
+}
+
+// 按优先级匹配第一条适用于操作者的策略，未匹配时允许执行
+func Evaluate(s *Subject, content, confirm string) (*Decision, error) {
+	policies, err := dao.GetEnabledCommandPolicies()
+	if err != nil {
+		return nil, err
+	}
+	return evaluate(append(policies, builtinPolicies...), s, content, confirm), nil
+}
+
+func evaluate(policies []dao.CommandPolicy, s *Subject, content, confirm string) *Decision {
+	for _, p := range policies {
+		if !appliesTo(&p, s) {
+			continue
+		}
+		re, err := regexp.Compile(p.Pattern)
+		if err != nil {
+			logger.Warn("invalid command policy %s: %s", p.Name, err.Error())
+			continue
+		}
+		if !re.MatchString(content) {
+			continue
+		}
+
+		d := &Decision{Action: p.Action, Policy: p.Name}
+		switch p.Action {
+		case ActionAllow:
+			d.Allowed = true
+		case ActionDeny:
+			d.Reason = "命令被策略 " + p.Name + " 禁止执行"
+		case ActionDangerous:
+			d.ConfirmCode = ConfirmCode(content)
+			d.Allowed = confirm == d.ConfirmCode
+			if !d.Allowed {
+				d.Reason = fmt.Sprintf("命令被策略 %s 判定为危险操作，请输入确认码 %s 后重新提交", p.Name, d.ConfirmCode)
+			}
+		}
+		return d
+	}
+	return &Decision{Action: ActionAllow, Allowed: true}
+}
+
+// 检查命令或脚本能否执行并记录审计日志，不允许执行时返回错误
+func Check(s *Subject, kind, content, confirm string) (*Decision, error) {
+	d, err := Evaluate(s, content, confirm)
+	if err != nil {
+		return nil, err
+	}
+	record(s, kind, content, d)
+	if !d.Allowed {
+		return d, errors.New(d.Reason)
+	}
+	return d, nil
+}
+
+func record(s *Subject, kind, content string, d *Decision) {
+	msg := fmt.Sprintf("策略:%s 动作:%s 内容:%s", d.Policy, d.Action, truncate(content))
+	if d.Action == ActionDangerous && d.Allowed {
+		msg = "已确认 " + msg
+	}
+
+	log := auditlog.New(auditlog.LogTypePolicy, kind, msg, dao.User{ID: s.UserID})
+	log.Operator = s.Name
+	log.Status = auditlog.StatusSuccess
+	if !d.Allowed {
+		log.Status = auditlog.StatusFail
+		log.Message = "拒绝: " + log.Message
+	}
+	if err := auditlog.Add(log); err != nil {
+		logger.Error("failed to record command policy audit log: %s", err.Error())
+	}
+}
+
+func truncate(content string) string {
+	if len(content) <= maxAuditContent {
+		return content
+	}
+	cut := maxAuditContent
+	for cut > 0 && !utf8.RuneStart(content[cut]) {
+		cut--
+	}
+	return content[:cut] + "..."
+}
+
+func splitList(s string) []string {
+	result := []string{}
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// 策略的角色及部门均为空或与操作者有交集时适用
+func appliesTo(p *dao.CommandPolicy, s *Subject) bool {
+	if roles := splitList(p.Roles); len(roles) > 0 && !intersects(roles, s.Roles) {
+		return false
+	}
+	if departs := splitList(p.Departments); len(departs) > 0 && !intersects(departs, s.Departments) {
+		return false
+	}
+	return true
+}
+
+func intersects(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package policy
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+)
+
+func TestEvaluateBuiltin(t *testing.T) {
+	cases := []struct {
+		content string
+		policy  string
+	}{
+		{"rm -rf /", "builtin:rm-root"},
+		{"rm -rf /*", "builtin:rm-root"},
+		{"sudo rm -fr ~", "builtin:rm-root"},
+		{"rm -rf /tmp/cache", ""},
+		{"mkfs.ext4 /dev/sdb1", "builtin:mkfs"},
+		{"dd if=/dev/zero of=/dev/sda bs=1M", "builtin:dd-device"},
+		{"echo x > /dev/sda", "builtin:write-device"},
+		{"echo x > /dev/null", ""},
+		{"systemctl status sshd; reboot", "builtin:power"},
+		{"init 0", "builtin:power"},
+		{"cat /etc/rebooted.conf", ""},
+		{":(){ :|:& };:", "builtin:fork-bomb"},
+		{"ls -l /", ""},
+	}
+	for _, c := range cases {
+		d := evaluate(builtinPolicies, &Subject{}, c.content, "")
+		assert.Equal(t, c.policy, d.Policy, c.content)
+		if c.policy == "" {
+			assert.True(t, d.Allowed, c.content)
+			continue
+		}
+		assert.Equal(t, ActionDangerous, d.Action, c.content)
+		assert.False(t, d.Allowed, c.content)
+		assert.Equal(t, ConfirmCode(c.content), d.ConfirmCode, c.content)
+	}
+}
+
+func TestEvaluateConfirm(t *testing.T) {
+	content := "reboot"
+	cases := []struct {
+		confirm string
+		allowed bool
+	}{
+		{"", false},
+		{"00000000", false},
+		{ConfirmCode(content), true},
+	}
+	for _, c := range cases {
+		d := evaluate(builtinPolicies, &Subject{}, content, c.confirm)
+		assert.Equal(t, c.allowed, d.Allowed, c.confirm)
+	}
+}
+
+func TestEvaluateSubject(t *testing.T) {
+	policies := []dao.CommandPolicy{
+		{Name: "ops-allow-reboot", Pattern: `^reboot$`, Action: ActionAllow, Roles: "2"},
+		{Name: "depart-deny-curl", Pattern: `curl\s`, Action: ActionDeny, Departments: "10, 11"},
+		{Name: "invalid", Pattern: `(`, Action: ActionDeny},
+		{Name: "deny-wget", Pattern: `wget\s`, Action: ActionDeny},
+	}
+	policies = append(policies, builtinPolicies...)
+
+	ops := &Subject{Name: "ops@example.com", Roles: []string{"2"}, Departments: []string{"11"}}
+	guest := &Subject{Name: "guest@example.com", Roles: []string{"3"}}
+	cases := []struct {
+		subject *Subject
+		content string
+		action  string
+		policy  string
+		allowed bool
+	}{
+		{ops, "reboot", ActionAllow, "ops-allow-reboot", true},
+		{guest, "reboot", ActionDangerous, "builtin:power", false},
+		{ops, "curl http://x", ActionDeny, "depart-deny-curl", false},
+		{guest, "curl http://x", ActionAllow, "", true},
+		{guest, "wget http://x", ActionDeny, "deny-wget", false},
+		{&Subject{}, "wget http://x", ActionDeny, "deny-wget", false},
+	}
+	for _, c := range cases {
+		d := evaluate(policies, c.subject, c.content, "")
+		assert.Equal(t, c.action, d.Action, c.content)
+		assert.Equal(t, c.policy, d.Policy, c.content)
+		assert.Equal(t, c.allowed, d.Allowed, c.content)
+	}
+}
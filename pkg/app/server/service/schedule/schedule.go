@@ -0,0 +1,436 @@
+package schedule
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/agentmanager"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/job"
+	"openeuler.org/PilotGo/PilotGo/pkg/logger"
+)
+
+// 机器离线时的处理方式
+const (
+	// 跳过离线机器
+	OfflineSkip = "skip"
+	// 离线机器在上线后补充执行，须在维护窗口或下次执行前上线
+	OfflineDefer = "defer"
+)
+
+// 触发方式
+const (
+	TriggerSchedule = "schedule"
+	TriggerDeferred = "deferred"
+	TriggerManual   = "manual"
+)
+
+// 检查到期操作的间隔
+const checkInterval = 30 * time.Second
+
+// 创建或修改定时操作的参数
+type Param struct {
+	ID   uint      `json:"id"`
+	Name string    `json:"name"`
+	Job  job.Param `json:"job"`
+	// 单次执行的时间，与Spec二选一
+	RunAt *time.Time `json:"run_at"`
+	// 周期执行的标准5段cron表达式
+	Spec     string `json:"spec"`
+	WindowID uint   `json:"window_id"`
+	Offline  string `json:"offline"`
+	Enabled  bool   `json:"enabled"`
+	// 创建人，由登录用户填写，执行时作为命令策略检查的操作者
+	UserName string `json:"-"`
+	UserDept string `json:"-"`
+}
+
+// 定时操作的触发过程互斥，避免重复执行
+var lock sync.Mutex
+
+func Init() {
+	go func() {
+		ticker := time.NewTicker(checkInterval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			runDue(now)
+		}
+	}()
+}
+
+func AddWindow(w *dao.MaintenanceWindow) error {
+	if err := validateWindow(w); err != nil {
+		return err
+	}
+	return dao.AddMaintenanceWindow(w)
+}
+
+func UpdateWindow(w *dao.MaintenanceWindow) error {
+	old, err := dao.GetMaintenanceWindow(w.ID)
+	if err != nil {
+		return fmt.Errorf("维护窗口 %d 不存在", w.ID)
+	}
+	if err := validateWindow(w); err != nil {
+		return err
+	}
+	w.CreatedAt = old.CreatedAt
+	return dao.UpdateMaintenanceWindow(w)
+}
+
+// 删除维护窗口，仍被定时操作使用的窗口不能删除
+func DeleteWindows(ids []uint) error {
+	count, err := dao.CountScheduledJobsByWindow(ids)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("维护窗口仍被定时操作使用")
+	}
+	return dao.DeleteMaintenanceWindows(ids)
+}
+
+func WindowList() ([]dao.MaintenanceWindow, error) {
+	return dao.MaintenanceWindowList()
+}
+
+func validateWindow(w *dao.MaintenanceWindow) error {
+	if w.Name == "" {
+		return errors.New("维护窗口名称不能为空")
+	}
+	if _, err := cron.ParseStandard(w.Spec); err != nil {
+		return fmt.Errorf("cron表达式有误: %s", err.Error())
+	}
+	if w.Duration <= 0 {
+		return errors.New("维护窗口时长须大于0")
+	}
+	return nil
+}
+
+// 判断t是否处于维护窗口内，处于窗口内时返回窗口的结束时间
+func windowOpen(w *dao.MaintenanceWindow, t time.Time) (bool, time.Time) {
+	sched, err := cron.ParseStandard(w.Spec)
+	if err != nil {
+		return false, time.Time{}
+	}
+	d := time.Duration(w.Duration) * time.Minute
+	start := sched.Next(t.Add(-d))
+	if start.After(t) {
+		return false, time.Time{}
+	}
+	return true, start.Add(d)
+}
+
+// 维护窗口在t之后的下次开启时间
+func windowNext(w *dao.MaintenanceWindow, t time.Time) time.Time {
+	sched, err := cron.ParseStandard(w.Spec)
+	if err != nil {
+		return time.Time{}
+	}
+	return sched.Next(t)
+}
+
+func Add(p *Param) (*dao.ScheduledJob, error) {
+	s := &dao.ScheduledJob{}
+	if err := p.apply(s); err != nil {
+		return nil, err
+	}
+	if err := dao.AddScheduledJob(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func Update(p *Param) (*dao.ScheduledJob, error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	s, err := dao.GetScheduledJob(p.ID)
+	if err != nil {
+		return nil, fmt.Errorf("定时操作 %d 不存在", p.ID)
+	}
+	if err := p.apply(s); err != nil {
+		return nil, err
+	}
+	if err := dao.UpdateScheduledJob(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func Delete(ids []uint) error {
+	lock.Lock()
+	defer lock.Unlock()
+	return dao.DeleteScheduledJobs(ids)
+}
+
+func List() (*[]dao.ScheduledJob, *gorm.DB) {
+	return dao.ScheduledJobList()
+}
+
+func Runs(id uint) (*[]dao.ScheduleRun, *gorm.DB) {
+	return dao.QueryScheduleRuns(id)
+}
+
+// 校验参数并更新定时操作，重新计算下次执行时间
+func (p *Param) apply(s *dao.ScheduledJob) error {
+	if p.Name == "" {
+		return errors.New("名称不能为空")
+	}
+	if (p.RunAt == nil) == (p.Spec == "") {
+		return errors.New("须指定执行时间或cron表达式之一")
+	}
+	if p.Spec != "" {
+		if _, err := cron.ParseStandard(p.Spec); err != nil {
+			return fmt.Errorf("cron表达式有误: %s", err.Error())
+		}
+	}
+	if p.WindowID != 0 {
+		if _, err := dao.GetMaintenanceWindow(p.WindowID); err != nil {
+			return fmt.Errorf("维护窗口 %d 不存在", p.WindowID)
+		}
+	}
+	switch p.Offline {
+	case "":
+		p.Offline = OfflineSkip
+	case OfflineSkip, OfflineDefer:
+	default:
+		return fmt.Errorf("unsupported offline policy: %s", p.Offline)
+	}
+	p.Job.UserName = p.UserName
+	p.Job.UserDept = p.UserDept
+	if err := p.Job.Validate(); err != nil {
+		return err
+	}
+	param, err := json.Marshal(&p.Job)
+	if err != nil {
+		return err
+	}
+
+	s.Name = p.Name
+	s.Param = string(param)
+	s.RunAt = p.RunAt
+	s.Spec = p.Spec
+	s.WindowID = p.WindowID
+	s.Offline = p.Offline
+	s.Enabled = p.Enabled
+	s.UserName = p.UserName
+	s.LastRunAt = nil
+	s.Deferred = ""
+	s.DeferUntil = nil
+	s.NextRunAt = next(s, time.Now())
+	return nil
+}
+
+// 开启或关闭定时操作，开启时从当前时间重新计算下次执行时间
+func SetEnabled(id uint, enabled bool) error {
+	lock.Lock()
+	defer lock.Unlock()
+
+	s, err := dao.GetScheduledJob(id)
+	if err != nil {
+		return fmt.Errorf("定时操作 %d 不存在", id)
+	}
+	s.Enabled = enabled
+	if enabled {
+		s.NextRunAt = next(s, time.Now())
+	} else {
+		s.Deferred = ""
+		s.DeferUntil = nil
+	}
+	return dao.UpdateScheduledJob(s)
+}
+
+// 立即执行一次，不受维护窗口限制，也不影响后续的定时执行
+func RunNow(id uint) (*dao.ScheduleRun, error) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	s, err := dao.GetScheduledJob(id)
+	if err != nil {
+		return nil, fmt.Errorf("定时操作 %d 不存在", id)
+	}
+	run := trigger(s, TriggerManual, nil, time.Now(), nil)
+	if run.Error != "" {
+		return run, errors.New(run.Error)
+	}
+	return run, nil
+}
+
+// 计算after之后的下次执行时间，单次执行的操作执行后不再执行
+func next(s *dao.ScheduledJob, after time.Time) *time.Time {
+	if s.Spec == "" {
+		if s.LastRunAt != nil || s.RunAt == nil {
+			return nil
+		}
+		t := *s.RunAt
+		return &t
+	}
+	sched, err := cron.ParseStandard(s.Spec)
+	if err != nil {
+		return nil
+	}
+	t := sched.Next(after)
+	return &t
+}
+
+func runDue(now time.Time) {
+	lock.Lock()
+	defer lock.Unlock()
+
+	list, err := dao.DueScheduledJobs(now)
+	if err != nil {
+		logger.Error("failed to get scheduled jobs: %s", err.Error())
+		return
+	}
+	for i := range list {
+		s := &list[i]
+
+		var w *dao.MaintenanceWindow
+		open, end := true, time.Time{}
+		if s.WindowID != 0 {
+			if w, err = dao.GetMaintenanceWindow(s.WindowID); err != nil {
+				logger.Error("failed to get maintenance window of schedule %d: %s", s.ID, err.Error())
+				continue
+			}
+			open, end = windowOpen(w, now)
+		}
+
+		due := s.NextRunAt != nil && !s.NextRunAt.After(now)
+		switch {
+		case due && open:
+			trigger(s, TriggerSchedule, nil, now, &end)
+		case due:
+			// 不在维护窗口内时推迟到窗口开启
+			t := windowNext(w, now)
+			s.NextRunAt = &t
+			logger.Info("schedule %d postponed to maintenance window at %s", s.ID, t.Format("2006-01-02 15:04:05"))
+		case s.Deferred != "" && s.DeferUntil != nil && now.After(*s.DeferUntil):
+			expireDeferred(s)
+		case s.Deferred != "" && open:
+			runDeferred(s, now)
+			continue
+		default:
+			continue
+		}
+		if err := dao.UpdateScheduledJob(s); err != nil {
+			logger.Error("failed to update schedule %d: %s", s.ID, err.Error())
+		}
+	}
+}
+
+// 对在线机器提交任务并记录离线机器，uuids为空时执行任务选择的所有机器
+func trigger(s *dao.ScheduledJob, triggerType string, uuids []string, now time.Time, windowEnd *time.Time) *dao.ScheduleRun {
+	run := &dao.ScheduleRun{ScheduleID: s.ID, Trigger: triggerType}
+	if triggerType == TriggerSchedule {
+		s.LastRunAt = &now
+		s.NextRunAt = next(s, now)
+	}
+	defer func() {
+		if err := dao.AddScheduleRun(run); err != nil {
+			logger.Error("failed to save run of schedule %d: %s", s.ID, err.Error())
+		}
+		if run.Error != "" {
+			logger.Error("schedule %d failed: %s", s.ID, run.Error)
+		}
+	}()
+
+	p := &job.Param{}
+	if err := json.Unmarshal([]byte(s.Param), p); err != nil {
+		run.Error = err.Error()
+		return run
+	}
+	if uuids == nil {
+		var err error
+		if uuids, err = p.Machines(); err != nil {
+			run.Error = err.Error()
+			return run
+		}
+	}
+	online, offline := split(uuids)
+
+	if len(offline) > 0 {
+		if s.Offline == OfflineDefer {
+			run.Deferred = strings.Join(offline, ",")
+			if triggerType == TriggerSchedule {
+				s.DeferUntil = deferUntil(s, windowEnd)
+			}
+		} else {
+			run.Skipped = strings.Join(offline, ",")
+		}
+	}
+	if triggerType != TriggerManual {
+		s.Deferred = run.Deferred
+	}
+
+	if len(online) == 0 {
+		run.Error = "目标机器均不在线"
+		return run
+	}
+	// 仅对在线机器执行，执行结果记录在操作日志中
+	p.UUIDs = online
+	p.BatchIDs = nil
+	p.DepartIDs = nil
+	id, err := job.Submit(p)
+	if err != nil {
+		run.Error = err.Error()
+		return run
+	}
+	run.JobID = id
+	return run
+}
+
+// 补充执行的截止时间为维护窗口结束或下次执行前
+func deferUntil(s *dao.ScheduledJob, windowEnd *time.Time) *time.Time {
+	if windowEnd != nil && !windowEnd.IsZero() {
+		t := *windowEnd
+		return &t
+	}
+	return s.NextRunAt
+}
+
+// 对已上线的推迟机器补充执行
+func runDeferred(s *dao.ScheduledJob, now time.Time) {
+	online, _ := split(strings.Split(s.Deferred, ","))
+	if len(online) == 0 {
+		return
+	}
+	trigger(s, TriggerDeferred, strings.Split(s.Deferred, ","), now, nil)
+	if err := dao.UpdateScheduledJob(s); err != nil {
+		logger.Error("failed to update schedule %d: %s", s.ID, err.Error())
+	}
+}
+
+func expireDeferred(s *dao.ScheduledJob) {
+	run := &dao.ScheduleRun{
+		ScheduleID: s.ID,
+		Trigger:    TriggerDeferred,
+		Skipped:    s.Deferred,
+		Error:      "机器未在截止时间前上线",
+	}
+	if err := dao.AddScheduleRun(run); err != nil {
+		logger.Error("failed to save run of schedule %d: %s", s.ID, err.Error())
+	}
+	s.Deferred = ""
+	s.DeferUntil = nil
+}
+
+// 区分在线及离线机器
+func split(uuids []string) (online, offline []string) {
+	for _, uuid := range uuids {
+		if uuid == "" {
+			continue
+		}
+		if agentmanager.GetAgent(uuid) != nil {
+			online = append(online, uuid)
+		} else {
+			offline = append(offline, uuid)
+		}
+	}
+	return online, offline
+}
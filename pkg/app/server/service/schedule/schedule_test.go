@@ -0,0 +1,131 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/agentmanager"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/job"
+)
+
+func date(day, hour, min int) time.Time {
+	return time.Date(2026, 10, day, hour, min, 0, 0, time.UTC)
+}
+
+func TestValidateWindow(t *testing.T) {
+	cases := []struct {
+		window dao.MaintenanceWindow
+		valid  bool
+	}{
+		{dao.MaintenanceWindow{Name: "weekend", Spec: "0 2 * * 6", Duration: 120}, true},
+		{dao.MaintenanceWindow{Spec: "0 2 * * 6", Duration: 120}, false},
+		{dao.MaintenanceWindow{Name: "weekend", Spec: "0 2 * *", Duration: 120}, false},
+		{dao.MaintenanceWindow{Name: "weekend", Spec: "0 2 * * 6"}, false},
+	}
+	for _, c := range cases {
+		err := validateWindow(&c.window)
+		assert.Equal(t, c.valid, err == nil, "%+v", c.window)
+	}
+}
+
+func TestWindowOpen(t *testing.T) {
+	// 每周六02:00开始，持续两小时，2026-10-17为周六
+	w := &dao.MaintenanceWindow{Spec: "0 2 * * 6", Duration: 120}
+	cases := []struct {
+		now  time.Time
+		open bool
+		end  time.Time
+	}{
+		{date(17, 1, 59), false, time.Time{}},
+		{date(17, 2, 0), true, date(17, 4, 0)},
+		{date(17, 3, 30), true, date(17, 4, 0)},
+		{date(17, 4, 0), false, time.Time{}},
+		{date(19, 3, 0), false, time.Time{}},
+	}
+	for _, c := range cases {
+		open, end := windowOpen(w, c.now)
+		assert.Equal(t, c.open, open, c.now.String())
+		assert.True(t, c.end.Equal(end), c.now.String())
+	}
+
+	assert.Equal(t, date(24, 2, 0), windowNext(w, date(17, 3, 0)))
+	assert.Equal(t, date(17, 2, 0), windowNext(w, date(16, 12, 0)))
+
+	broken := &dao.MaintenanceWindow{Spec: "bad", Duration: 60}
+	open, _ := windowOpen(broken, date(17, 2, 0))
+	assert.False(t, open)
+	assert.True(t, windowNext(broken, date(17, 2, 0)).IsZero())
+}
+
+func TestNext(t *testing.T) {
+	runAt := date(20, 8, 0)
+	once := &dao.ScheduledJob{RunAt: &runAt}
+	assert.Equal(t, &runAt, next(once, date(19, 0, 0)))
+	// 单次执行的操作执行后不再执行
+	last := date(20, 8, 0)
+	once.LastRunAt = &last
+	assert.Nil(t, next(once, date(20, 9, 0)))
+	assert.Nil(t, next(&dao.ScheduledJob{}, date(19, 0, 0)))
+
+	periodic := &dao.ScheduledJob{Spec: "30 1 * * *"}
+	want := date(20, 1, 30)
+	assert.Equal(t, &want, next(periodic, date(19, 1, 30)))
+	assert.Nil(t, next(&dao.ScheduledJob{Spec: "every day"}, date(19, 0, 0)))
+}
+
+func TestDeferUntil(t *testing.T) {
+	nextRun := date(21, 1, 30)
+	s := &dao.ScheduledJob{NextRunAt: &nextRun}
+	end := date(17, 4, 0)
+	assert.Equal(t, &end, deferUntil(s, &end))
+	assert.Equal(t, &nextRun, deferUntil(s, &time.Time{}))
+	assert.Equal(t, &nextRun, deferUntil(s, nil))
+}
+
+func TestApply(t *testing.T) {
+	runAt := date(20, 8, 0)
+	command := job.Param{Type: job.TypeCommand, UUIDs: []string{"m1"}, Command: "uptime"}
+	cases := []struct {
+		name  string
+		param Param
+		valid bool
+	}{
+		{"no name", Param{Spec: "0 * * * *", Job: command}, false},
+		{"no time", Param{Name: "n", Job: command}, false},
+		{"time and spec", Param{Name: "n", RunAt: &runAt, Spec: "0 * * * *", Job: command}, false},
+		{"bad spec", Param{Name: "n", Spec: "0 * *", Job: command}, false},
+		{"bad offline", Param{Name: "n", Spec: "0 * * * *", Offline: "retry", Job: command}, false},
+		{"bad job", Param{Name: "n", Spec: "0 * * * *", Job: job.Param{Type: job.TypeCommand}}, false},
+		{"once", Param{Name: "n", RunAt: &runAt, Job: command}, true},
+		{"periodic", Param{Name: "n", Spec: "0 * * * *", Offline: OfflineDefer, Job: command}, true},
+	}
+	for _, c := range cases {
+		p := c.param
+		p.UserName = "alice"
+		p.UserDept = "ops"
+		s := &dao.ScheduledJob{Deferred: "m2", LastRunAt: &runAt}
+		err := p.apply(s)
+		assert.Equal(t, c.valid, err == nil, c.name)
+		if err != nil {
+			continue
+		}
+		assert.Equal(t, "alice", s.UserName, c.name)
+		assert.Contains(t, s.Param, `"userName":"alice"`, c.name)
+		assert.Contains(t, s.Param, `"userDept":"ops"`, c.name)
+		assert.NotEmpty(t, s.Offline, c.name)
+		assert.Equal(t, "", s.Deferred, c.name)
+		assert.Nil(t, s.LastRunAt, c.name)
+		assert.NotNil(t, s.NextRunAt, c.name)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	agentmanager.AddAgent(&agentmanager.Agent{UUID: "schedule-online", IP: "10.0.0.1"})
+	t.Cleanup(func() { agentmanager.DeleteAgent("schedule-online") })
+
+	online, offline := split([]string{"schedule-online", "", "schedule-offline"})
+	assert.Equal(t, []string{"schedule-online"}, online)
+	assert.Equal(t, []string{"schedule-offline"}, offline)
+}
@@ -0,0 +1,149 @@
+package script
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/service/job"
+	"openeuler.org/PilotGo/PilotGo/pkg/utils"
+)
+
+// 脚本参数声明，执行时按声明顺序作为位置参数传给脚本
+type ParamSpec struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Required    bool   `json:"required"`
+	Default     string `json:"default"`
+}
+
+// 执行脚本库中脚本的参数
+type RunParam struct {
+	Name      string            `json:"name"`
+	Version   string            `json:"version"`
+	Params    map[string]string `json:"params"`
+	UUIDs     []string          `json:"uuids"`
+	BatchIDs  []int             `json:"batch_ids"`
+	DepartIDs []int             `json:"depart_ids"`
+	UserName  string            `json:"userName"`
+	UserDept  string            `json:"userDept"`
+	// 可选，分批执行策略
+	Strategy *job.Strategy `json:"strategy"`
+	// 可选，脚本的执行用户、工作目录、环境变量、超时及资源限制
+	Options *utils.ScriptOptions `json:"options"`
+	// 危险脚本的确认码
+	Confirm string `json:"confirm"`
+}
+
+type RunFilter = dao.ScriptRunFilter
+
+func ContentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func parseParamSpecs(params string) ([]ParamSpec, error) {
+	specs := []ParamSpec{}
+	if params == "" {
+		return specs, nil
+	}
+	if err := json.Unmarshal([]byte(params), &specs); err != nil {
+		return nil, fmt.Errorf("脚本参数声明格式错误: %s", err.Error())
+	}
+	for _, spec := range specs {
+		if spec.Name == "" {
+			return nil, errors.New("脚本参数名不能为空")
+		}
+	}
+	return specs, nil
+}
+
+// 按参数声明校验并生成位置参数
+func buildArgs(specs []ParamSpec, values map[string]string) ([]string, error) {
+	declared := map[string]bool{}
+	args := make([]string, 0, len(specs))
+	for _, spec := range specs {
+		declared[spec.Name] = true
+		v, ok := values[spec.Name]
+		if !ok {
+			if spec.Required {
+				return nil, fmt.Errorf("缺少必填参数: %s", spec.Name)
+			}
+			v = spec.Default
+		}
+		args = append(args, v)
+	}
+	for name := range values {
+		if !declared[name] {
+			return nil, fmt.Errorf("未声明的参数: %s", name)
+		}
+	}
+	return args, nil
+}
+
+// 在机器、批次或部门上执行脚本库中的脚本，返回执行记录ID及任务ID
+func Run(p *RunParam) (uint, int, error) {
+	if p.Name == "" {
+		return 0, 0, errors.New("请输入脚本文件名字")
+	}
+	script, err := dao.GetScript(p.Name, p.Version)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, 0, fmt.Errorf("脚本 %s 版本 %s 不存在", p.Name, p.Version)
+		}
+		return 0, 0, err
+	}
+
+	specs, err := parseParamSpecs(script.Params)
+	if err != nil {
+		return 0, 0, err
+	}
+	args, err := buildArgs(specs, p.Params)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	jobID, err := job.Submit(&job.Param{
+		Type:        job.TypeScript,
+		UUIDs:       p.UUIDs,
+		BatchIDs:    p.BatchIDs,
+		DepartIDs:   p.DepartIDs,
+		UserName:    p.UserName,
+		UserDept:    p.UserDept,
+		Strategy:    p.Strategy,
+		Script:      script.Content,
+		Params:      args,
+		Options:     p.Options,
+		Interpreter: script.Interpreter,
+		Confirm:     p.Confirm,
+		Object:      script.Name + "(" + script.Version + ")",
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	params, _ := json.Marshal(p.Params)
+	run := &dao.ScriptRun{
+		ScriptID:   script.ID,
+		ScriptName: script.Name,
+		Version:    script.Version,
+		Hash:       ContentHash(script.Content),
+		Params:     string(params),
+		UserName:   p.UserName,
+		UserDept:   p.UserDept,
+		JobID:      jobID,
+	}
+	if err := dao.AddScriptRun(run); err != nil {
+		return 0, jobID, err
+	}
+	return run.ID, jobID, nil
+}
+
+// 查询谁在哪些机器上执行了哪个版本的脚本
+func QueryRuns(f *RunFilter) (*[]dao.ScriptRunRecord, *gorm.DB) {
+	return dao.QueryScriptRuns(f)
+}
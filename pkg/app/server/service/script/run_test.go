@@ -0,0 +1,69 @@
+package script
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+)
+
+func TestParseParamSpecs(t *testing.T) {
+	specs, err := parseParamSpecs("")
+	assert.Nil(t, err)
+	assert.Empty(t, specs)
+
+	specs, err = parseParamSpecs(`[{"name":"port","required":true},{"name":"mode","default":"fast"}]`)
+	assert.Nil(t, err)
+	assert.Equal(t, []ParamSpec{{Name: "port", Required: true}, {Name: "mode", Default: "fast"}}, specs)
+
+	_, err = parseParamSpecs(`{"name":"port"}`)
+	assert.NotNil(t, err)
+	_, err = parseParamSpecs(`[{"description":"no name"}]`)
+	assert.NotNil(t, err)
+}
+
+func TestBuildArgs(t *testing.T) {
+	specs := []ParamSpec{
+		{Name: "port", Required: true},
+		{Name: "mode", Default: "fast"},
+		{Name: "extra"},
+	}
+	cases := []struct {
+		name   string
+		values map[string]string
+		args   []string
+		valid  bool
+	}{
+		{"defaults", map[string]string{"port": "80"}, []string{"80", "fast", ""}, true},
+		{"all", map[string]string{"port": "80", "mode": "slow", "extra": "-v"}, []string{"80", "slow", "-v"}, true},
+		{"empty value kept", map[string]string{"port": "80", "mode": ""}, []string{"80", "", ""}, true},
+		{"missing required", map[string]string{"mode": "slow"}, nil, false},
+		{"undeclared", map[string]string{"port": "80", "user": "root"}, nil, false},
+	}
+	for _, c := range cases {
+		args, err := buildArgs(specs, c.values)
+		assert.Equal(t, c.valid, err == nil, c.name)
+		assert.Equal(t, c.args, args, c.name)
+	}
+
+	args, err := buildArgs(nil, nil)
+	assert.Nil(t, err)
+	assert.Empty(t, args)
+}
+
+func TestContentHash(t *testing.T) {
+	assert.Equal(t, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", ContentHash(""))
+	assert.NotEqual(t, ContentHash("echo 1"), ContentHash("echo 1\n"))
+}
+
+func TestAddScriptValidate(t *testing.T) {
+	cases := []dao.Script{
+		{Content: "echo 1", Description: "d"},
+		{Name: "s", Description: "d"},
+		{Name: "s", Content: "echo 1"},
+		{Name: "s", Content: "echo 1", Description: "d", Params: "[{}]"},
+	}
+	for _, s := range cases {
+		assert.NotNil(t, AddScript(&s), "%+v", s)
+	}
+}
@@ -4,9 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
+	"strings"
 	"time"
 
 	"openeuler.org/PilotGo/PilotGo/pkg/app/server/dao"
+	"openeuler.org/PilotGo/PilotGo/pkg/utils"
 )
 
 type Script = dao.Script
@@ -22,6 +24,12 @@ func AddScript(script *dao.Script) error {
 	if len(script.Description) == 0 {
 		return errors.New("请输入脚本描述")
 	}
+	if _, err := parseParamSpecs(script.Params); err != nil {
+		return err
+	}
+	if err := checkInterpreter(script.Interpreter); err != nil {
+		return err
+	}
 	rnd := rand.New(rand.NewSource(time.Now().UnixNano()))
 	vcode := fmt.Sprintf("%06v", rnd.Int31n(1000000))
 	version := time.Now().Format("2006-01-02 15:04:05") + "-" + vcode
@@ -32,6 +40,9 @@ func AddScript(script *dao.Script) error {
 		UpdatedAt:   time.Time{},
 		Version:     version,
 		Deleted:     0,
+		Params:      script.Params,
+		Interpreter: script.Interpreter,
+		Hash:        ContentHash(script.Content),
 	}
 	err := dao.AddScript(sc)
 	if err != nil {
@@ -39,3 +50,17 @@ func AddScript(script *dao.Script) error {
 	}
 	return nil
 }
+
+// 检查解释器是否为支持的解释器名称或shebang行，解释器是否存在由agent检查
+func checkInterpreter(interpreter string) error {
+	interpreter = strings.TrimSpace(interpreter)
+	if interpreter == "" || strings.HasPrefix(interpreter, "#!") {
+		return nil
+	}
+	for _, s := range utils.SupportedInterpreters {
+		if s == interpreter {
+			return nil
+		}
+	}
+	return fmt.Errorf("不支持的脚本解释器: %s", interpreter)
+}
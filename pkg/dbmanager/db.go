@@ -32,17 +32,36 @@ func MysqldbInit(conf *sconfig.MysqlDBInfo) error {
 	}
 
 	mysqlmanager.MySQL().AutoMigrate(&dao.CrontabList{})
+	mysqlmanager.MySQL().AutoMigrate(&dao.CronRun{})
 	mysqlmanager.MySQL().AutoMigrate(&dao.MachineNode{})
 	mysqlmanager.MySQL().AutoMigrate(&dao.RoleButton{})
 	mysqlmanager.MySQL().AutoMigrate(&dao.Batch{})
 	mysqlmanager.MySQL().AutoMigrate(&dao.AgentLogParent{})
 	mysqlmanager.MySQL().AutoMigrate(&dao.AgentLog{})
 	mysqlmanager.MySQL().AutoMigrate(&dao.AuditLog{})
+	mysqlmanager.MySQL().AutoMigrate(&dao.AuditCheckpoint{})
 	mysqlmanager.MySQL().AutoMigrate(&dao.Files{})
 	mysqlmanager.MySQL().AutoMigrate(&dao.HistoryFiles{})
+	mysqlmanager.MySQL().AutoMigrate(&dao.TemplateVar{})
+	mysqlmanager.MySQL().AutoMigrate(&dao.FileDrift{})
+	mysqlmanager.MySQL().AutoMigrate(&dao.FileWatch{})
+	mysqlmanager.MySQL().AutoMigrate(&dao.FileWatchEvent{})
+	mysqlmanager.MySQL().AutoMigrate(&dao.IntegrityProfile{})
+	mysqlmanager.MySQL().AutoMigrate(&dao.IntegrityBaseline{})
+	mysqlmanager.MySQL().AutoMigrate(&dao.IntegrityChange{})
+	mysqlmanager.MySQL().AutoMigrate(&dao.IntegrityScan{})
 	mysqlmanager.MySQL().AutoMigrate(&dao.Script{})
+	mysqlmanager.MySQL().AutoMigrate(&dao.ScriptRun{})
 	mysqlmanager.MySQL().AutoMigrate(&dao.ConfigFile{})
 	mysqlmanager.MySQL().AutoMigrate(&dao.PluginModel{})
+	mysqlmanager.MySQL().AutoMigrate(&dao.PluginCredential{})
+	mysqlmanager.MySQL().AutoMigrate(&dao.CommandPolicy{})
+	mysqlmanager.MySQL().AutoMigrate(&dao.Approval{})
+	mysqlmanager.MySQL().AutoMigrate(&dao.MaintenanceWindow{})
+	mysqlmanager.MySQL().AutoMigrate(&dao.ScheduledJob{})
+	mysqlmanager.MySQL().AutoMigrate(&dao.ScheduleRun{})
+	mysqlmanager.MySQL().AutoMigrate(&dao.EventRecord{})
+	mysqlmanager.MySQL().AutoMigrate(&dao.EventAck{})
 
 	// 创建超级管理员账户
 	mysqlmanager.MySQL().AutoMigrate(&dao.User{})
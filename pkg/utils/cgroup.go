@@ -0,0 +1,202 @@
+package utils
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	cgroupRoot   = "/sys/fs/cgroup"
+	cgroupParent = "pilotgo"
+	cpuPeriod    = 100000
+)
+
+// 单次脚本执行创建的临时cgroup，执行结束后删除
+type cgroup struct {
+	v2 bool
+	// v2时只有一个目录，v1时cpu及memory各一个目录
+	cpuPath    string
+	memoryPath string
+}
+
+// 创建临时cgroup，cpuQuota为单核cpu的百分比，memoryLimit单位为MB
+func newCgroup(name string, cpuQuota int, memoryLimit int64) (*cgroup, error) {
+	cg := &cgroup{}
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err == nil {
+		cg.v2 = true
+		parent := filepath.Join(cgroupRoot, cgroupParent)
+		if err := os.MkdirAll(parent, 0755); err != nil {
+			return nil, err
+		}
+		// 根cgroup可能已开启控制器，失败时以子cgroup的结果为准
+		_ = writeCgroupFile(cgroupRoot, "cgroup.subtree_control", "+cpu +memory")
+		if err := writeCgroupFile(parent, "cgroup.subtree_control", "+cpu +memory"); err != nil {
+			return nil, err
+		}
+		path := filepath.Join(parent, name)
+		if err := os.Mkdir(path, 0755); err != nil {
+			return nil, err
+		}
+		cg.cpuPath = path
+		cg.memoryPath = path
+	} else {
+		if cpuQuota > 0 {
+			cg.cpuPath = filepath.Join(cgroupRoot, "cpu", cgroupParent, name)
+			if err := os.MkdirAll(cg.cpuPath, 0755); err != nil {
+				return nil, err
+			}
+		}
+		if memoryLimit > 0 {
+			cg.memoryPath = filepath.Join(cgroupRoot, "memory", cgroupParent, name)
+			if err := os.MkdirAll(cg.memoryPath, 0755); err != nil {
+				cg.destroy()
+				return nil, err
+			}
+		}
+	}
+
+	if err := cg.setLimits(cpuQuota, memoryLimit); err != nil {
+		cg.destroy()
+		return nil, err
+	}
+	return cg, nil
+}
+
+func (c *cgroup) setLimits(cpuQuota int, memoryLimit int64) error {
+	quota := cpuQuota * cpuPeriod / 100
+	memory := strconv.FormatInt(memoryLimit*1024*1024, 10)
+
+	if c.v2 {
+		if cpuQuota > 0 {
+			if err := writeCgroupFile(c.cpuPath, "cpu.max", fmt.Sprintf("%d %d", quota, cpuPeriod)); err != nil {
+				return err
+			}
+		}
+		if memoryLimit > 0 {
+			if err := writeCgroupFile(c.memoryPath, "memory.max", memory); err != nil {
+				return err
+			}
+			// 禁止使用swap，避免超出内存限制后长时间换页
+			_ = writeCgroupFile(c.memoryPath, "memory.swap.max", "0")
+		}
+		return nil
+	}
+
+	if cpuQuota > 0 {
+		if err := writeCgroupFile(c.cpuPath, "cpu.cfs_period_us", strconv.Itoa(cpuPeriod)); err != nil {
+			return err
+		}
+		if err := writeCgroupFile(c.cpuPath, "cpu.cfs_quota_us", strconv.Itoa(quota)); err != nil {
+			return err
+		}
+	}
+	if memoryLimit > 0 {
+		if err := writeCgroupFile(c.memoryPath, "memory.limit_in_bytes", memory); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *cgroup) paths() []string {
+	if c.v2 || c.cpuPath == c.memoryPath {
+		return []string{c.cpuPath}
+	}
+	paths := []string{}
+	for _, p := range []string{c.cpuPath, c.memoryPath} {
+		if p != "" {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// 将进程加入cgroup
+func (c *cgroup) addProcess(pid int) error {
+	for _, p := range c.paths() {
+		if err := writeCgroupFile(p, "cgroup.procs", strconv.Itoa(pid)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 返回是否因超出内存限制被oom kill，以及是否因cpu限制被节流
+func (c *cgroup) limitsHit() (oomKilled bool, cpuThrottled bool) {
+	if c.memoryPath != "" {
+		if c.v2 {
+			oomKilled = readCgroupValue(c.memoryPath, "memory.events", "oom_kill") > 0
+		} else {
+			oomKilled = readCgroupValue(c.memoryPath, "memory.oom_control", "oom_kill") > 0 ||
+				readCgroupValue(c.memoryPath, "memory.failcnt", "") > 0
+		}
+	}
+	if c.cpuPath != "" {
+		cpuThrottled = readCgroupValue(c.cpuPath, "cpu.stat", "nr_throttled") > 0
+	}
+	return
+}
+
+// 结束cgroup中残留的进程并删除cgroup
+func (c *cgroup) destroy() {
+	for _, p := range c.paths() {
+		if p == "" {
+			continue
+		}
+		for i := 0; i < 10; i++ {
+			killCgroupProcs(p)
+			err := os.Remove(p)
+			if err == nil || errors.Is(err, os.ErrNotExist) {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+}
+
+func killCgroupProcs(path string) {
+	data, err := ioutil.ReadFile(filepath.Join(path, "cgroup.procs"))
+	if err != nil {
+		return
+	}
+	for _, line := range strings.Fields(string(data)) {
+		if pid, err := strconv.Atoi(line); err == nil {
+			_ = syscall.Kill(pid, syscall.SIGKILL)
+		}
+	}
+}
+
+func writeCgroupFile(dir string, file string, value string) error {
+	return ioutil.WriteFile(filepath.Join(dir, file), []byte(value), 0644)
+}
+
+// 读取cgroup统计值，key为空时文件内容即为数值
+func readCgroupValue(dir string, file string, key string) int64 {
+	f, err := os.Open(filepath.Join(dir, file))
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if key == "" && len(fields) == 1 {
+			v, _ := strconv.ParseInt(fields[0], 10, 64)
+			return v
+		}
+		if len(fields) == 2 && fields[0] == key {
+			v, _ := strconv.ParseInt(fields[1], 10, 64)
+			return v
+		}
+	}
+	return 0
+}
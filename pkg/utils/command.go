@@ -25,6 +25,10 @@ type CmdResult struct {
 	RetCode int
 	Stdout  string
 	Stderr  string
+	// 沙箱执行时是否超时，是否超出内存限制被kill，是否被cpu限制节流
+	TimedOut     bool
+	OOMKilled    bool
+	CPUThrottled bool
 }
 
 func RunCommand(s string) (int, string, string, error) {
@@ -73,54 +77,5 @@ func RunCommand(s string) (int, string, string, error) {
 
 // 运行指定的shell脚本文件
 func RunScript(absPath string, params []string) (*CmdResult, error) {
-	arr_command := []string{}
-	arr_command = append(arr_command, absPath)
-	arr_command = append(arr_command, params...)
-
-	cmd := exec.Command("/bin/bash", arr_command...)
-	cmd.Env = append(cmd.Env, "LANG=en_US.utf8")
-
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return nil, err
-	}
-
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		return nil, err
-	}
-
-	exitCode := 0
-	err = cmd.Start()
-	if err != nil {
-		return nil, err
-	}
-
-	b1, err := ioutil.ReadAll(stdout)
-	if err != nil {
-		return nil, err
-	}
-	s1 := strings.TrimRight(string(b1), "\n")
-
-	b2, err := ioutil.ReadAll(stderr)
-	if err != nil {
-		return nil, err
-	}
-	s2 := strings.TrimRight(string(b2), "\n")
-
-	err = cmd.Wait()
-	if err != nil {
-		fmt.Println(err)
-		e, ok := err.(*exec.ExitError)
-		if !ok {
-			return nil, err
-		}
-		exitCode = e.ExitCode()
-	}
-
-	return &CmdResult{
-		RetCode: exitCode,
-		Stdout:  s1,
-		Stderr:  s2,
-	}, nil
+	return RunScriptWithOptions(absPath, params, nil)
 }
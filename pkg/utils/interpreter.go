@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// 默认脚本解释器
+const DefaultInterpreter = "bash"
+
+// 支持的脚本解释器
+var SupportedInterpreters = []string{"bash", "sh", "python3", "perl"}
+
+type Interpreter struct {
+	Name string `json:"name" mapstructure:"name"`
+	Path string `json:"path" mapstructure:"path"`
+}
+
+// 返回本机可用的脚本解释器
+func AvailableInterpreters() []Interpreter {
+	result := []Interpreter{}
+	for _, name := range SupportedInterpreters {
+		if path, err := exec.LookPath(name); err == nil {
+			result = append(result, Interpreter{Name: name, Path: path})
+		}
+	}
+	return result
+}
+
+func isSupportedInterpreter(name string) bool {
+	for _, s := range SupportedInterpreters {
+		if s == name {
+			return true
+		}
+	}
+	return false
+}
+
+// 解析解释器名称或shebang行，返回本机上的解释器路径及参数
+func ResolveInterpreter(interpreter string) ([]string, error) {
+	interpreter = strings.TrimSpace(interpreter)
+	if interpreter == "" {
+		interpreter = DefaultInterpreter
+	}
+
+	if !strings.HasPrefix(interpreter, "#!") {
+		if !isSupportedInterpreter(interpreter) {
+			return nil, fmt.Errorf("unsupported interpreter: %s", interpreter)
+		}
+		path, err := exec.LookPath(interpreter)
+		if err != nil {
+			return nil, fmt.Errorf("interpreter %s is not available on this host", interpreter)
+		}
+		return []string{path}, nil
+	}
+
+	fields := strings.Fields(strings.TrimPrefix(interpreter, "#!"))
+	if len(fields) == 0 {
+		return nil, errors.New("empty shebang line")
+	}
+	prog, args := fields[0], fields[1:]
+	if filepath.Base(prog) == "env" {
+		if len(args) == 0 {
+			return nil, errors.New("shebang line missing program after env")
+		}
+		prog, args = args[0], args[1:]
+		path, err := exec.LookPath(prog)
+		if err != nil {
+			return nil, fmt.Errorf("interpreter %s is not available on this host", prog)
+		}
+		prog = path
+	} else {
+		info, err := os.Stat(prog)
+		if err != nil || info.IsDir() || info.Mode()&0111 == 0 {
+			return nil, fmt.Errorf("interpreter %s is not available on this host", prog)
+		}
+	}
+	if !isSupportedInterpreter(filepath.Base(prog)) {
+		return nil, fmt.Errorf("unsupported interpreter: %s", prog)
+	}
+	return append([]string{prog}, args...), nil
+}
@@ -0,0 +1,49 @@
+package utils
+
+import (
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveInterpreter(t *testing.T) {
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not available on this host")
+	}
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh is not available on this host")
+	}
+
+	cases := []struct {
+		interpreter string
+		args        []string
+		ok          bool
+	}{
+		{"", []string{bash}, true},
+		{"  bash  ", []string{bash}, true},
+		{"sh", []string{sh}, true},
+		{"ruby", nil, false},
+		{"/bin/bash", nil, false},
+		{"#!" + sh, []string{sh}, true},
+		{"#!" + sh + " -e", []string{sh, "-e"}, true},
+		{"#!/usr/bin/env bash", []string{bash}, true},
+		{"#! /usr/bin/env bash -x", []string{bash, "-x"}, true},
+		{"#!", nil, false},
+		{"#!/usr/bin/env", nil, false},
+		{"#!/nonexistent/bash", nil, false},
+		{"#!/usr/bin/env nonexistent-interpreter", nil, false},
+		{"#!/", nil, false},
+	}
+	for _, c := range cases {
+		args, err := ResolveInterpreter(c.interpreter)
+		if !c.ok {
+			assert.Error(t, err, c.interpreter)
+			continue
+		}
+		assert.NoError(t, err, c.interpreter)
+		assert.Equal(t, c.args, args, c.interpreter)
+	}
+}
@@ -147,8 +147,23 @@ const (
 	AgentConfig = 67
 	//配置文件修改
 	ConfigFileMonitor = 68
+	// 获取agent上运行的定时任务
+	CronList = 69
+	// agent上报定时任务执行结果
+	CronResult = 70
+	// 立即执行一次定时任务
+	CronRunNow = 71
+	// 校验后安装配置文件并使其生效
+	ApplyFile = 72
+	// 下发文件监控列表
+	FileWatchSet = 73
+	// 记录文件的完整性快照
+	IntegritySnapshot = 74
 )
 
+// agent与server间的协议版本，新增消息类型时递增，旧版本agent不上报协议版本，视为0
+const Version = 1
+
 type Message struct {
 	UUID   string `json:"message_uuid"`
 	Type   int    `json:"message_type"`
@@ -25,6 +25,7 @@ func (b *BaseOS) GetHostInfo() (*common.SystemInfo, error) {
 		uptime = strings.Replace(uptime, "\n", "", -1)
 		sysinfo := &common.SystemInfo{
 			IP:              IP,
+			Hostname:        SysInfo.Hostname,
 			Platform:        SysInfo.Platform,
 			PlatformVersion: SysInfo.PlatformVersion,
 			KernelVersion:   SysInfo.KernelVersion,
@@ -15,20 +15,60 @@
 package common
 
 import (
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	cron "github.com/robfig/cron/v3"
 	"openeuler.org/PilotGo/PilotGo/pkg/utils"
 )
 
+// agent本地保存定时任务的文件，重启后据此恢复任务，位于agent安装目录下而不随启动目录变化
+const CronStorageFile = "/opt/PilotGo/agent/.pilotgo-cron.data"
+
+// 上报的执行输出最大长度
+const CronOutputLimit = 4096
+
+// 任务触发方式
+const (
+	CronTriggerSchedule = "schedule"
+	CronTriggerManual   = "manual"
+)
+
 var Cron *Crontab
 
+// 定时任务每次执行的结果，由agent上报给server
+var CronResults = make(chan *CronRunResult, 100)
+
+// 定时任务定义
+type CronTask struct {
+	ID      int    `json:"id" mapstructure:"id"`
+	Spec    string `json:"spec" mapstructure:"spec"`
+	Command string `json:"command" mapstructure:"command"`
+}
+
+// 定时任务单次执行结果
+type CronRunResult struct {
+	TaskID    int       `json:"task_id" mapstructure:"task_id"`
+	Trigger   string    `json:"trigger" mapstructure:"trigger"`
+	StartTime time.Time `json:"start_time" mapstructure:"start_time"`
+	EndTime   time.Time `json:"end_time" mapstructure:"end_time"`
+	ExitCode  int       `json:"exit_code" mapstructure:"exit_code"`
+	Stdout    string    `json:"stdout" mapstructure:"stdout"`
+	Stderr    string    `json:"stderr" mapstructure:"stderr"`
+	Error     string    `json:"error" mapstructure:"error"`
+}
+
 // crontab manager
 type Crontab struct {
 	Inner    *cron.Cron
 	EntryIDs map[int]cron.EntryID
+	Tasks    map[int]CronTask
 	Mutex    sync.Mutex
 }
 
@@ -37,6 +77,7 @@ func NewCrontab() *Crontab {
 	return &Crontab{
 		Inner:    cron.New(cron.WithSeconds()),
 		EntryIDs: make(map[int]cron.EntryID),
+		Tasks:    make(map[int]CronTask),
 	}
 }
 
@@ -55,6 +96,7 @@ func (c *Crontab) IDs() []int {
 	}
 	for _, id := range invalidIDs {
 		delete(c.EntryIDs, id)
+		delete(c.Tasks, id)
 	}
 	return validIDs
 }
@@ -80,6 +122,7 @@ func (c *Crontab) DeleteByID(id int) error {
 	}
 	c.Inner.Remove(eid)
 	delete(c.EntryIDs, id)
+	delete(c.Tasks, id)
 	return nil
 }
 
@@ -99,6 +142,31 @@ func (c *Crontab) AddByFunc(id int, spec string, f func()) error {
 	return nil
 }
 
+// 返回当前运行的所有定时任务，按id排序
+func (c *Crontab) List() []CronTask {
+	c.Mutex.Lock()
+	defer c.Mutex.Unlock()
+
+	tasks := make([]CronTask, 0, len(c.Tasks))
+	for _, t := range c.Tasks {
+		tasks = append(tasks, t)
+	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].ID < tasks[j].ID })
+	return tasks
+}
+
+// 将当前任务集合保存到本地文件
+func (c *Crontab) save() error {
+	bs, err := json.Marshal(c.List())
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(CronStorageFile), 0755); err != nil {
+		return err
+	}
+	return utils.FileSaveString(CronStorageFile, string(bs))
+}
+
 // 创建客户端实例
 func CronInit() error {
 	crontab := NewCrontab()
@@ -106,6 +174,89 @@ func CronInit() error {
 	return nil
 }
 
+// 从本地文件恢复agent重启前的定时任务
+func CronRestore() error {
+	if !utils.IsFileExist(CronStorageFile) {
+		return nil
+	}
+	s, err := utils.FileReadString(CronStorageFile)
+	if err != nil {
+		return err
+	}
+	tasks := []CronTask{}
+	if err := json.Unmarshal([]byte(s), &tasks); err != nil {
+		return err
+	}
+
+	var failed []int
+	for _, t := range tasks {
+		if err := addCronTask(t); err != nil {
+			failed = append(failed, t.ID)
+		}
+	}
+	Cron.Start()
+	if err := Cron.save(); err != nil {
+		return err
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to restore crontab tasks: %v", failed)
+	}
+	return nil
+}
+
+// 执行任务并将结果放入上报队列，队列已满时丢弃
+func runCronTask(t CronTask, trigger string) {
+	r := &CronRunResult{
+		TaskID:    t.ID,
+		Trigger:   trigger,
+		StartTime: time.Now(),
+	}
+	exitCode, stdout, stderr, err := utils.RunCommand(t.Command)
+	r.EndTime = time.Now()
+	r.ExitCode = exitCode
+	r.Stdout = truncateOutput(stdout)
+	r.Stderr = truncateOutput(stderr)
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	select {
+	case CronResults <- r:
+	default:
+	}
+}
+
+// 立即执行一次任务，不影响定时调度
+func CronRunNow(t CronTask) {
+	go runCronTask(t, CronTriggerManual)
+}
+
+// 输出过长时保留末尾部分
+func truncateOutput(s string) string {
+	if len(s) <= CronOutputLimit {
+		return s
+	}
+	i := len(s) - CronOutputLimit
+	for i < len(s) && !utf8.RuneStart(s[i]) {
+		i++
+	}
+	return s[i:]
+}
+
+func addCronTask(t CronTask) error {
+	// 添加函数作为定时任务
+	taskFunc := func() {
+		runCronTask(t, CronTriggerSchedule)
+	}
+	if err := Cron.AddByFunc(t.ID, t.Spec, taskFunc); err != nil {
+		return err
+	}
+	Cron.Mutex.Lock()
+	Cron.Tasks[t.ID] = t
+	Cron.Mutex.Unlock()
+	return nil
+}
+
 // 开启任务
 func CronStart(id int, spec string, command string) error {
 
@@ -116,16 +267,12 @@ func CronStart(id int, spec string, command string) error {
 	// 	fmt.Println("hello world", i)
 	// }
 
-	// 添加函数作为定时任务
-	taskFunc := func() {
-		utils.RunCommand(command)
-	}
-	if err := Cron.AddByFunc(id, spec, taskFunc); err != nil {
+	if err := addCronTask(CronTask{ID: id, Spec: spec, Command: command}); err != nil {
 		return fmt.Errorf("error to add crontab task: %s", err)
 	}
 	Cron.Start()
 	time.Sleep(time.Duration(time.Millisecond * 300))
-	return nil
+	return Cron.save()
 }
 
 // 暂停任务
@@ -133,5 +280,5 @@ func StopAndDel(id int) error {
 	if err := Cron.DeleteByID(id); err != nil {
 		return err
 	}
-	return nil
+	return Cron.save()
 }
@@ -0,0 +1,48 @@
+package common
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTruncateOutput(t *testing.T) {
+	assert.Equal(t, "", truncateOutput(""))
+	short := strings.Repeat("a", CronOutputLimit)
+	assert.Equal(t, short, truncateOutput(short))
+
+	long := "head" + strings.Repeat("b", CronOutputLimit-1) + "z"
+	out := truncateOutput(long)
+	assert.Equal(t, CronOutputLimit, len(out))
+	assert.True(t, strings.HasSuffix(out, "z"))
+
+	// 截断位置落在多字节字符中间时跳过不完整的字符
+	multi := strings.Repeat("中", CronOutputLimit/3+10)
+	out = truncateOutput(multi)
+	assert.True(t, utf8.ValidString(out))
+	assert.True(t, len(out) <= CronOutputLimit)
+	assert.True(t, len(out) > CronOutputLimit-3)
+}
+
+func TestRunCronTask(t *testing.T) {
+	for len(CronResults) > 0 {
+		<-CronResults
+	}
+
+	runCronTask(CronTask{ID: 7, Spec: "* * * * * *", Command: "echo out; echo err >&2; exit 3"}, CronTriggerManual)
+	r := <-CronResults
+	assert.Equal(t, 7, r.TaskID)
+	assert.Equal(t, CronTriggerManual, r.Trigger)
+	assert.Equal(t, 3, r.ExitCode)
+	assert.Equal(t, "out", r.Stdout)
+	assert.Equal(t, "err", r.Stderr)
+	assert.False(t, r.EndTime.Before(r.StartTime))
+
+	runCronTask(CronTask{ID: 8, Command: "true"}, CronTriggerSchedule)
+	r = <-CronResults
+	assert.Equal(t, 0, r.ExitCode)
+	assert.Equal(t, CronTriggerSchedule, r.Trigger)
+	assert.Equal(t, "", r.Error)
+}
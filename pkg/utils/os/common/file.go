@@ -1,8 +1,189 @@
 package common
 
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"openeuler.org/PilotGo/PilotGo/pkg/utils"
+)
+
 type UpdateFile struct {
 	FilePath    string `json:"path"`
 	FileName    string `json:"name"`
 	FileText    string `json:"text"`
 	FileVersion string `json:"filelast_version"`
 }
+
+// 配置文件生效方式
+const (
+	ActivateNone    = ""
+	ActivateReload  = "reload"
+	ActivateRestart = "restart"
+	ActivateCommand = "command"
+)
+
+// 校验命令中代表待安装文件路径的占位符，命令中不含占位符时将路径追加到命令末尾
+const StagedFilePlaceholder = "{file}"
+
+// reload、restart生效方式允许的服务名，避免服务名中夹带shell命令
+var unitNamePattern = regexp.MustCompile(`^[A-Za-z0-9@._-]+$`)
+
+func ValidUnitName(name string) bool {
+	return unitNamePattern.MatchString(name)
+}
+
+// 校验通过后安装配置文件并使其生效，生效失败时恢复原文件
+type FileApply struct {
+	FilePath string `json:"path" mapstructure:"path"`
+	FileName string `json:"name" mapstructure:"name"`
+	FileText string `json:"text" mapstructure:"text"`
+	// 对待安装文件执行的校验命令，如"nginx -t -c {file}"
+	Validate string `json:"validate" mapstructure:"validate"`
+	// 生效方式，reload、restart、command或为空
+	Activate string `json:"activate" mapstructure:"activate"`
+	// 生效方式为reload、restart时为服务名，为command时为执行的命令
+	ActivateTarget string `json:"activate_target" mapstructure:"activate_target"`
+}
+
+type FileApplyResult struct {
+	Validated      bool   `json:"validated" mapstructure:"validated"`
+	ValidateOutput string `json:"validate_output" mapstructure:"validate_output"`
+	Installed      bool   `json:"installed" mapstructure:"installed"`
+	Activated      bool   `json:"activated" mapstructure:"activated"`
+	ActivateOutput string `json:"activate_output" mapstructure:"activate_output"`
+	RolledBack     bool   `json:"rolled_back" mapstructure:"rolled_back"`
+	// 安装前的文件内容，文件原本不存在时为空
+	LastVersion string `json:"last_version" mapstructure:"last_version"`
+	Error       string `json:"error" mapstructure:"error"`
+}
+
+// 将文件写入同目录下的临时文件并校验，校验通过后替换原文件并执行生效动作
+func ApplyFile(a *FileApply) *FileApplyResult {
+	r := &FileApplyResult{}
+	if err := a.check(); err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	target := filepath.Join(a.FilePath, a.FileName)
+	staged := filepath.Join(a.FilePath, "."+a.FileName+".pilotgo-staged")
+	defer os.Remove(staged)
+
+	mode := os.FileMode(0644)
+	info, err := os.Stat(target)
+	existed := err == nil
+	if existed {
+		mode = info.Mode().Perm()
+		last, err := utils.FileReadString(target)
+		if err != nil {
+			r.Error = err.Error()
+			return r
+		}
+		r.LastVersion = last
+	}
+	if err := os.WriteFile(staged, []byte(a.FileText), mode); err != nil {
+		r.Error = err.Error()
+		return r
+	}
+
+	if a.Validate != "" {
+		ok, output := runStep(validateCommand(a.Validate, staged))
+		r.ValidateOutput = output
+		if !ok {
+			r.Error = "校验失败，文件未安装"
+			return r
+		}
+	}
+	r.Validated = true
+
+	if err := os.Rename(staged, target); err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	r.Installed = true
+
+	if a.Activate == ActivateNone {
+		return r
+	}
+	ok, output := runStep(a.activateCommand())
+	r.ActivateOutput = output
+	if ok {
+		r.Activated = true
+		return r
+	}
+
+	// 生效失败时恢复原文件，并以原文件重新生效
+	if existed {
+		err = os.WriteFile(target, []byte(r.LastVersion), mode)
+	} else {
+		err = os.Remove(target)
+	}
+	if err != nil {
+		r.Error = fmt.Sprintf("生效失败，恢复原文件失败: %s", err.Error())
+		return r
+	}
+	r.RolledBack = true
+	r.Error = "生效失败，已恢复原文件"
+	if existed {
+		if ok, output := runStep(a.activateCommand()); !ok {
+			r.Error += "，以原文件重新生效失败: " + output
+		}
+	}
+	return r
+}
+
+func (a *FileApply) check() error {
+	if a.FilePath == "" || a.FileName == "" {
+		return errors.New("文件路径及文件名不能为空")
+	}
+	if strings.Contains(a.FileName, "/") {
+		return errors.New("文件名不能包含路径分隔符")
+	}
+	switch a.Activate {
+	case ActivateNone:
+	case ActivateReload, ActivateRestart, ActivateCommand:
+		if a.ActivateTarget == "" {
+			return errors.New("未指定生效的服务或命令")
+		}
+		if a.Activate != ActivateCommand && !ValidUnitName(a.ActivateTarget) {
+			return fmt.Errorf("服务名不合法: %s", a.ActivateTarget)
+		}
+	default:
+		return fmt.Errorf("unsupported activate action: %s", a.Activate)
+	}
+	return nil
+}
+
+func (a *FileApply) activateCommand() string {
+	switch a.Activate {
+	case ActivateReload, ActivateRestart:
+		return fmt.Sprintf("systemctl %s %s", a.Activate, a.ActivateTarget)
+	}
+	return a.ActivateTarget
+}
+
+func validateCommand(cmd, staged string) string {
+	staged = shellQuote(staged)
+	if strings.Contains(cmd, StagedFilePlaceholder) {
+		return strings.ReplaceAll(cmd, StagedFilePlaceholder, staged)
+	}
+	return cmd + " " + staged
+}
+
+// 以单引号包裹参数，使文件名中的空格及shell元字符不被解释
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// 执行命令，返回是否成功及合并后的输出
+func runStep(cmd string) (bool, string) {
+	exitc, stdo, stde, err := utils.RunCommand(cmd)
+	output := strings.TrimSpace(stdo + "\n" + stde)
+	if err != nil {
+		return false, strings.TrimSpace(output + "\n" + err.Error())
+	}
+	return exitc == 0, output
+}
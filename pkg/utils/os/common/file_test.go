@@ -0,0 +1,103 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidUnitName(t *testing.T) {
+	for _, name := range []string{"nginx", "sshd.service", "getty@tty1.service", "my_app-2"} {
+		assert.True(t, ValidUnitName(name), name)
+	}
+	for _, name := range []string{"", "nginx; reboot", "a b", "$(id)", "nginx\n"} {
+		assert.False(t, ValidUnitName(name), name)
+	}
+}
+
+func TestFileApplyCommands(t *testing.T) {
+	assert.Equal(t, `'/etc/a b'`, shellQuote("/etc/a b"))
+	assert.Equal(t, `'it'\''s'`, shellQuote("it's"))
+
+	assert.Equal(t, "nginx -t -c '/etc/.n.conf'", validateCommand("nginx -t -c {file}", "/etc/.n.conf"))
+	assert.Equal(t, "cat '/etc/.n.conf' && cat '/etc/.n.conf'", validateCommand("cat {file} && cat {file}", "/etc/.n.conf"))
+	assert.Equal(t, "visudo -cf '/etc/.x; rm -rf /'", validateCommand("visudo -cf", "/etc/.x; rm -rf /"))
+
+	assert.Equal(t, "systemctl reload nginx", (&FileApply{Activate: ActivateReload, ActivateTarget: "nginx"}).activateCommand())
+	assert.Equal(t, "systemctl restart sshd", (&FileApply{Activate: ActivateRestart, ActivateTarget: "sshd"}).activateCommand())
+	assert.Equal(t, "nginx -s reload", (&FileApply{Activate: ActivateCommand, ActivateTarget: "nginx -s reload"}).activateCommand())
+}
+
+func TestFileApplyCheck(t *testing.T) {
+	cases := []struct {
+		apply FileApply
+		valid bool
+	}{
+		{FileApply{FilePath: "/etc", FileName: "motd"}, true},
+		{FileApply{FilePath: "/etc", FileName: "motd", Activate: ActivateReload, ActivateTarget: "nginx"}, true},
+		{FileApply{FilePath: "/etc", FileName: "motd", Activate: ActivateCommand, ActivateTarget: "nginx -s reload"}, true},
+		{FileApply{FileName: "motd"}, false},
+		{FileApply{FilePath: "/etc", FileName: "../motd"}, false},
+		{FileApply{FilePath: "/etc", FileName: "motd", Activate: ActivateRestart}, false},
+		{FileApply{FilePath: "/etc", FileName: "motd", Activate: ActivateRestart, ActivateTarget: "nginx; reboot"}, false},
+		{FileApply{FilePath: "/etc", FileName: "motd", Activate: "kill"}, false},
+	}
+	for _, c := range cases {
+		err := c.apply.check()
+		assert.Equal(t, c.valid, err == nil, "%+v", c.apply)
+	}
+}
+
+func TestApplyFile(t *testing.T) {
+	if _, err := os.Stat("/bin/bash"); err != nil {
+		t.Skip("bash is not available on this host")
+	}
+	dir := t.TempDir()
+	target := filepath.Join(dir, "app.conf")
+	read := func() string {
+		bs, err := os.ReadFile(target)
+		if err != nil {
+			return "<missing>"
+		}
+		return string(bs)
+	}
+
+	// 新文件生效失败时删除
+	r := ApplyFile(&FileApply{FilePath: dir, FileName: "app.conf", FileText: "v1", Activate: ActivateCommand, ActivateTarget: "false"})
+	assert.True(t, r.Installed)
+	assert.True(t, r.RolledBack)
+	assert.NotEmpty(t, r.Error)
+	assert.Equal(t, "<missing>", read())
+
+	r = ApplyFile(&FileApply{FilePath: dir, FileName: "app.conf", FileText: "v1 ok", Validate: "grep -q ok {file}"})
+	assert.Equal(t, "", r.Error)
+	assert.True(t, r.Validated)
+	assert.True(t, r.Installed)
+	assert.Equal(t, "", r.LastVersion)
+	assert.Equal(t, "v1 ok", read())
+
+	// 校验失败时不安装
+	r = ApplyFile(&FileApply{FilePath: dir, FileName: "app.conf", FileText: "v2", Validate: "grep -q ok"})
+	assert.False(t, r.Validated)
+	assert.False(t, r.Installed)
+	assert.NotEmpty(t, r.Error)
+	assert.Equal(t, "v1 ok", read())
+
+	// 已有文件生效失败时恢复原内容
+	r = ApplyFile(&FileApply{FilePath: dir, FileName: "app.conf", FileText: "v3", Activate: ActivateCommand, ActivateTarget: "grep -q ok " + target})
+	assert.True(t, r.Installed)
+	assert.False(t, r.Activated)
+	assert.True(t, r.RolledBack)
+	assert.Equal(t, "v1 ok", r.LastVersion)
+	assert.Equal(t, "v1 ok", read())
+
+	r = ApplyFile(&FileApply{FilePath: dir, FileName: "app.conf", FileText: "v4 ok", Activate: ActivateCommand, ActivateTarget: "grep -q v4 " + target})
+	assert.Equal(t, "", r.Error)
+	assert.True(t, r.Activated)
+	assert.Equal(t, "v4 ok", read())
+
+	matches, _ := filepath.Glob(filepath.Join(dir, ".*"))
+	assert.Empty(t, matches)
+}
@@ -0,0 +1,103 @@
+package common
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// 监控的文件操作
+const (
+	WatchOpCreate = "create"
+	WatchOpWrite  = "write"
+	WatchOpRemove = "remove"
+	WatchOpRename = "rename"
+	WatchOpChmod  = "chmod"
+)
+
+var WatchOps = []string{WatchOpCreate, WatchOpWrite, WatchOpRemove, WatchOpRename, WatchOpChmod}
+
+// 文件监控规则
+type WatchRule struct {
+	Path string `json:"path" mapstructure:"path"`
+	// 是否监控子目录，新建的子目录会自动加入监控
+	Recursive bool `json:"recursive" mapstructure:"recursive"`
+	// 按文件名匹配的glob，Include为空时匹配所有文件
+	Include []string `json:"include" mapstructure:"include"`
+	Exclude []string `json:"exclude" mapstructure:"exclude"`
+	// 监控的操作，为空时监控所有操作
+	Ops []string `json:"ops" mapstructure:"ops"`
+}
+
+// 由server下发的文件监控列表
+type WatchList struct {
+	Rules []WatchRule `json:"rules" mapstructure:"rules"`
+}
+
+// 文件变化事件，由agent上报给server
+type FileWatchEvent struct {
+	// 触发事件的监控规则路径
+	Rule string `json:"rule" mapstructure:"rule"`
+	Path string `json:"path" mapstructure:"path"`
+	Op   string `json:"op" mapstructure:"op"`
+	// 文件已删除或为目录时以下字段为空
+	Size int64  `json:"size" mapstructure:"size"`
+	Hash string `json:"hash" mapstructure:"hash"`
+	UID  int    `json:"uid" mapstructure:"uid"`
+	// 小文本文件相对上次内容的unified diff
+	Diff string    `json:"diff" mapstructure:"diff"`
+	Time time.Time `json:"time" mapstructure:"time"`
+}
+
+// 未配置监控列表时默认监控repo及网卡配置目录的修改
+func DefaultWatchList(paths ...string) *WatchList {
+	l := &WatchList{}
+	for _, p := range paths {
+		l.Rules = append(l.Rules, WatchRule{Path: p, Ops: []string{WatchOpWrite}})
+	}
+	return l
+}
+
+// 路径是否在规则的监控范围内且文件名满足过滤条件
+func (r *WatchRule) Match(path string) bool {
+	root := filepath.Clean(r.Path)
+	path = filepath.Clean(path)
+	if path != root {
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+			return false
+		}
+		if !r.Recursive && strings.Contains(rel, "/") {
+			return false
+		}
+	}
+
+	name := filepath.Base(path)
+	for _, p := range r.Exclude {
+		if ok, _ := filepath.Match(p, name); ok {
+			return false
+		}
+	}
+	if len(r.Include) == 0 {
+		return true
+	}
+	for _, p := range r.Include {
+		if ok, _ := filepath.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// 规则是否监控该操作
+func (r *WatchRule) Watches(op string) bool {
+	if len(r.Ops) == 0 {
+		return true
+	}
+	for _, o := range r.Ops {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
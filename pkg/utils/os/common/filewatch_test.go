@@ -0,0 +1,50 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchRuleMatch(t *testing.T) {
+	cases := []struct {
+		name  string
+		rule  WatchRule
+		path  string
+		match bool
+	}{
+		{"root itself", WatchRule{Path: "/etc/nginx"}, "/etc/nginx", true},
+		{"direct child", WatchRule{Path: "/etc/nginx"}, "/etc/nginx/nginx.conf", true},
+		{"trailing slash", WatchRule{Path: "/etc/nginx/"}, "/etc/nginx/nginx.conf", true},
+		{"nested not recursive", WatchRule{Path: "/etc/nginx"}, "/etc/nginx/conf.d/a.conf", false},
+		{"nested recursive", WatchRule{Path: "/etc/nginx", Recursive: true}, "/etc/nginx/conf.d/a.conf", true},
+		{"outside", WatchRule{Path: "/etc/nginx", Recursive: true}, "/etc/hosts", false},
+		{"sibling prefix", WatchRule{Path: "/etc/nginx", Recursive: true}, "/etc/nginx2/a.conf", false},
+		{"parent", WatchRule{Path: "/etc/nginx"}, "/etc", false},
+		{"unclean path", WatchRule{Path: "/etc/nginx"}, "/etc/nginx/../hosts", false},
+		{"include", WatchRule{Path: "/etc/nginx", Include: []string{"*.conf"}}, "/etc/nginx/nginx.conf", true},
+		{"include miss", WatchRule{Path: "/etc/nginx", Include: []string{"*.conf"}}, "/etc/nginx/mime.types", false},
+		{"exclude", WatchRule{Path: "/etc/nginx", Exclude: []string{"*.swp"}}, "/etc/nginx/.nginx.conf.swp", false},
+		{"exclude wins", WatchRule{Path: "/etc/nginx", Include: []string{"*"}, Exclude: []string{"*~"}}, "/etc/nginx/nginx.conf~", false},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.match, c.rule.Match(c.path), c.name)
+	}
+}
+
+func TestWatchRuleWatches(t *testing.T) {
+	cases := []struct {
+		ops     []string
+		op      string
+		watches bool
+	}{
+		{nil, WatchOpChmod, true},
+		{[]string{WatchOpWrite}, WatchOpWrite, true},
+		{[]string{WatchOpWrite, WatchOpRemove}, WatchOpRemove, true},
+		{[]string{WatchOpWrite}, WatchOpCreate, false},
+	}
+	for _, c := range cases {
+		r := &WatchRule{Ops: c.ops}
+		assert.Equal(t, c.watches, r.Watches(c.op), "%v %s", c.ops, c.op)
+	}
+}
@@ -0,0 +1,128 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+)
+
+// 单次完整性快照最多记录的文件数
+const IntegrityFileLimit = 200000
+
+// 完整性快照的路径，目录递归记录其下所有文件
+type IntegrityParam struct {
+	Paths []string `json:"paths" mapstructure:"paths"`
+	// 按完整路径或文件名匹配的glob，匹配的文件及目录不记录
+	Exclude []string `json:"exclude" mapstructure:"exclude"`
+}
+
+// 单个文件的完整性信息
+type FileBaseline struct {
+	Path string `json:"path" mapstructure:"path"`
+	// 普通文件内容的sha256，符号链接记录其指向
+	Hash    string    `json:"hash" mapstructure:"hash"`
+	Link    string    `json:"link" mapstructure:"link"`
+	Mode    string    `json:"mode" mapstructure:"mode"`
+	UID     int       `json:"uid" mapstructure:"uid"`
+	GID     int       `json:"gid" mapstructure:"gid"`
+	Size    int64     `json:"size" mapstructure:"size"`
+	ModTime time.Time `json:"mtime" mapstructure:"mtime"`
+}
+
+type IntegritySnapshot struct {
+	Files []FileBaseline `json:"files" mapstructure:"files"`
+	// 无法读取的文件及超过数量限制等错误
+	Errors    []string `json:"errors" mapstructure:"errors"`
+	Truncated bool     `json:"truncated" mapstructure:"truncated"`
+}
+
+// 记录路径下所有普通文件及符号链接的hash、权限、属主及修改时间
+func Snapshot(p *IntegrityParam) *IntegritySnapshot {
+	s := &IntegritySnapshot{Files: []FileBaseline{}, Errors: []string{}}
+	seen := map[string]bool{}
+	for _, root := range p.Paths {
+		err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				s.Errors = append(s.Errors, err.Error())
+				return nil
+			}
+			if excluded(p.Exclude, path) {
+				if info.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if info.IsDir() || seen[path] {
+				return nil
+			}
+			if !info.Mode().IsRegular() && info.Mode()&os.ModeSymlink == 0 {
+				return nil
+			}
+			if len(s.Files) >= IntegrityFileLimit {
+				s.Truncated = true
+				return io.EOF
+			}
+			seen[path] = true
+			f, err := baseline(path, info)
+			if err != nil {
+				s.Errors = append(s.Errors, err.Error())
+			}
+			s.Files = append(s.Files, f)
+			return nil
+		})
+		if err == io.EOF {
+			s.Errors = append(s.Errors, fmt.Sprintf("文件数超过%d，其余文件未记录", IntegrityFileLimit))
+			break
+		}
+	}
+	sort.Slice(s.Files, func(i, j int) bool { return s.Files[i].Path < s.Files[j].Path })
+	return s
+}
+
+func baseline(path string, info os.FileInfo) (FileBaseline, error) {
+	f := FileBaseline{
+		Path:    path,
+		Mode:    info.Mode().String(),
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		f.UID = int(stat.Uid)
+		f.GID = int(stat.Gid)
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		link, err := os.Readlink(path)
+		f.Link = link
+		return f, err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return f, err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return f, err
+	}
+	f.Hash = hex.EncodeToString(h.Sum(nil))
+	return f, nil
+}
+
+func excluded(patterns []string, path string) bool {
+	for _, p := range patterns {
+		if ok, _ := filepath.Match(p, path); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(p, filepath.Base(path)); ok {
+			return true
+		}
+	}
+	return false
+}
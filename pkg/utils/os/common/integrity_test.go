@@ -0,0 +1,56 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExcluded(t *testing.T) {
+	patterns := []string{"*.swp", "/etc/ssl/*", "cache"}
+	assert.True(t, excluded(patterns, "/etc/.hosts.swp"))
+	assert.True(t, excluded(patterns, "/etc/ssl/certs"))
+	assert.True(t, excluded(patterns, "/var/lib/app/cache"))
+	assert.False(t, excluded(patterns, "/etc/ssl"))
+	assert.False(t, excluded(patterns, "/etc/hosts"))
+	assert.False(t, excluded(nil, "/etc/hosts"))
+}
+
+func TestSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, text string) {
+		path := filepath.Join(dir, name)
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, os.WriteFile(path, []byte(text), 0640))
+	}
+	write("a.conf", "hello")
+	write("sub/b.conf", "")
+	write("sub/b.conf.swp", "tmp")
+	write("skip/c.conf", "ignored")
+	assert.Nil(t, os.Symlink("a.conf", filepath.Join(dir, "link")))
+
+	s := Snapshot(&IntegrityParam{
+		// 重复的路径只记录一次
+		Paths:   []string{dir, filepath.Join(dir, "sub"), filepath.Join(dir, "missing")},
+		Exclude: []string{"*.swp", "skip"},
+	})
+	assert.False(t, s.Truncated)
+	assert.Equal(t, 1, len(s.Errors))
+
+	paths := []string{}
+	for _, f := range s.Files {
+		paths = append(paths, f.Path)
+	}
+	assert.Equal(t, []string{filepath.Join(dir, "a.conf"), filepath.Join(dir, "link"), filepath.Join(dir, "sub/b.conf")}, paths)
+
+	a, link, b := s.Files[0], s.Files[1], s.Files[2]
+	assert.Equal(t, "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824", a.Hash)
+	assert.Equal(t, "-rw-r-----", a.Mode)
+	assert.Equal(t, int64(5), a.Size)
+	assert.Equal(t, os.Getuid(), a.UID)
+	assert.Equal(t, "a.conf", link.Link)
+	assert.Equal(t, "", link.Hash)
+	assert.Equal(t, "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855", b.Hash)
+}
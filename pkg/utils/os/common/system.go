@@ -2,6 +2,7 @@ package common
 
 type SystemInfo struct {
 	IP              string
+	Hostname        string //主机名
 	Platform        string //系统平台
 	PlatformVersion string //系统版本
 	KernelVersion   string //内核版本
@@ -20,7 +20,15 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// 上下文中记录的统一响应code及msg，供审计日志等中间件判断操作结果
+const (
+	CodeKey = "x-response-code"
+	MsgKey  = "x-response-msg"
+)
+
 func result(c *gin.Context, httpStatus int, code int, data interface{}, msg string) {
+	c.Set(CodeKey, code)
+	c.Set(MsgKey, msg)
 	c.JSON(httpStatus, gin.H{
 		"code": code,
 		"data": data,
@@ -0,0 +1,195 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"os/user"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 脚本执行选项
+type ScriptOptions struct {
+	// 执行脚本的用户，为空时以agent用户执行
+	User string `json:"user" mapstructure:"user"`
+	// 工作目录
+	WorkDir string `json:"work_dir" mapstructure:"work_dir"`
+	// 额外的环境变量
+	Env map[string]string `json:"env" mapstructure:"env"`
+	// 超时时间，单位秒，超时后结束整个进程组，0表示不限制
+	Timeout int `json:"timeout" mapstructure:"timeout"`
+	// cpu限制，单核cpu的百分比，0表示不限制
+	CPUQuota int `json:"cpu_quota" mapstructure:"cpu_quota"`
+	// 内存限制，单位MB，0表示不限制
+	MemoryLimit int64 `json:"memory_limit" mapstructure:"memory_limit"`
+}
+
+// 是否未设置任何执行选项
+func (o *ScriptOptions) IsEmpty() bool {
+	return o == nil || (o.User == "" && o.WorkDir == "" && len(o.Env) == 0 &&
+		o.Timeout == 0 && o.CPUQuota == 0 && o.MemoryLimit == 0)
+}
+
+// 等待父进程将其加入cgroup后再执行脚本，避免脚本派生的进程逃逸出cgroup
+const cgroupTrampoline = `read -r _ <&3; exec 3<&-; exec "$@"`
+
+// 在沙箱中运行指定的shell脚本文件
+func RunScriptWithOptions(absPath string, params []string, opts *ScriptOptions) (*CmdResult, error) {
+	return RunScriptWithInterpreter([]string{"/bin/bash"}, absPath, params, opts)
+}
+
+// 在沙箱中使用指定的解释器运行脚本文件，interpreter为解释器路径及参数
+func RunScriptWithInterpreter(interpreter []string, absPath string, params []string, opts *ScriptOptions) (*CmdResult, error) {
+	if len(interpreter) == 0 {
+		return nil, errors.New("interpreter is empty")
+	}
+	if opts == nil {
+		opts = &ScriptOptions{}
+	}
+	if opts.Timeout < 0 || opts.CPUQuota < 0 || opts.MemoryLimit < 0 {
+		return nil, errors.New("timeout, cpu quota and memory limit must not be negative")
+	}
+	useCgroup := opts.CPUQuota > 0 || opts.MemoryLimit > 0
+
+	args := sandboxArgs(interpreter, absPath, params, useCgroup)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = opts.WorkDir
+	cmd.Env = []string{"LANG=en_US.utf8"}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if opts.User != "" {
+		credential, home, err := lookupCredential(opts.User)
+		if err != nil {
+			return nil, err
+		}
+		cmd.SysProcAttr.Credential = credential
+		cmd.Env = append(cmd.Env, "USER="+opts.User, "LOGNAME="+opts.User, "HOME="+home)
+	}
+	for k, v := range opts.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	var cg *cgroup
+	var release *os.File
+	if useCgroup {
+		var err error
+		cg, err = newCgroup(uuid.New().String(), opts.CPUQuota, opts.MemoryLimit)
+		if err != nil {
+			return nil, errors.New("create cgroup failed: " + err.Error())
+		}
+		defer cg.destroy()
+
+		r, w, err := os.Pipe()
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close()
+		cmd.ExtraFiles = []*os.File{r}
+		release = w
+	}
+
+	if err := cmd.Start(); err != nil {
+		if release != nil {
+			release.Close()
+		}
+		return nil, err
+	}
+	pid := cmd.Process.Pid
+
+	if cg != nil {
+		err := cg.addProcess(pid)
+		if err != nil {
+			_ = syscall.Kill(-pid, syscall.SIGKILL)
+		} else {
+			_, err = release.Write([]byte("\n"))
+		}
+		release.Close()
+		if err != nil {
+			cmd.Wait()
+			return nil, errors.New("add process to cgroup failed: " + err.Error())
+		}
+	}
+
+	var timedOut int32
+	if opts.Timeout > 0 {
+		timer := time.AfterFunc(time.Duration(opts.Timeout)*time.Second, func() {
+			atomic.StoreInt32(&timedOut, 1)
+			_ = syscall.Kill(-pid, syscall.SIGKILL)
+		})
+		defer timer.Stop()
+	}
+
+	exitCode := 0
+	if err := cmd.Wait(); err != nil {
+		e, ok := err.(*exec.ExitError)
+		if !ok {
+			return nil, err
+		}
+		exitCode = e.ExitCode()
+	}
+
+	result := &CmdResult{
+		RetCode:  exitCode,
+		Stdout:   strings.TrimRight(stdout.String(), "\n"),
+		Stderr:   strings.TrimRight(stderr.String(), "\n"),
+		TimedOut: atomic.LoadInt32(&timedOut) == 1,
+	}
+	if cg != nil {
+		result.OOMKilled, result.CPUThrottled = cg.limitsHit()
+	}
+	return result, nil
+}
+
+// 拼接执行脚本的命令行，需要资源限制时由cgroupTrampoline代为启动解释器
+func sandboxArgs(interpreter []string, absPath string, params []string, useCgroup bool) []string {
+	args := append(append(append([]string{}, interpreter...), absPath), params...)
+	if useCgroup {
+		args = append([]string{"/bin/bash", "-c", cgroupTrampoline, "pilotgo-sandbox"}, args...)
+	}
+	return args
+}
+
+func lookupCredential(name string) (*syscall.Credential, string, error) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return nil, "", err
+	}
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, "", err
+	}
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	groups := []uint32{}
+	if ids, err := u.GroupIds(); err == nil {
+		for _, id := range ids {
+			if g, err := strconv.ParseUint(id, 10, 32); err == nil {
+				groups = append(groups, uint32(g))
+			}
+		}
+	}
+	return &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid), Groups: groups}, u.HomeDir, nil
+}
+
+// 查询用户的uid及gid
+func LookupUser(name string) (int, int, error) {
+	credential, _, err := lookupCredential(name)
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(credential.Uid), int(credential.Gid), nil
+}
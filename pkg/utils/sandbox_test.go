@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScriptOptionsIsEmpty(t *testing.T) {
+	var nilOpts *ScriptOptions
+	assert.True(t, nilOpts.IsEmpty())
+	assert.True(t, (&ScriptOptions{}).IsEmpty())
+	assert.True(t, (&ScriptOptions{Env: map[string]string{}}).IsEmpty())
+
+	for _, o := range []*ScriptOptions{
+		{User: "nobody"},
+		{WorkDir: "/tmp"},
+		{Env: map[string]string{"A": "1"}},
+		{Timeout: 10},
+		{CPUQuota: 50},
+		{MemoryLimit: 128},
+	} {
+		assert.False(t, o.IsEmpty(), "%+v", o)
+	}
+}
+
+func TestSandboxArgs(t *testing.T) {
+	interpreter := []string{"/usr/bin/python3", "-u"}
+	params := []string{"a", "b c"}
+
+	args := sandboxArgs(interpreter, "/tmp/x.py", params, false)
+	assert.Equal(t, []string{"/usr/bin/python3", "-u", "/tmp/x.py", "a", "b c"}, args)
+
+	args = sandboxArgs(interpreter, "/tmp/x.py", params, true)
+	assert.Equal(t, []string{"/bin/bash", "-c", cgroupTrampoline, "pilotgo-sandbox",
+		"/usr/bin/python3", "-u", "/tmp/x.py", "a", "b c"}, args)
+
+	// 不得修改调用方传入的解释器切片
+	assert.Equal(t, []string{"/usr/bin/python3", "-u"}, interpreter)
+
+	assert.Equal(t, []string{"/bin/bash", "/tmp/x.sh"}, sandboxArgs([]string{"/bin/bash"}, "/tmp/x.sh", nil, false))
+}
+
+func TestRunScriptWithOptions(t *testing.T) {
+	if _, err := os.Stat("/bin/bash"); err != nil {
+		t.Skip("bash is not available on this host")
+	}
+	dir := t.TempDir()
+	script := filepath.Join(dir, "run.sh")
+	assert.Nil(t, os.WriteFile(script, []byte("echo \"$PWD $FOO $1\"\n[ \"$1\" = sleep ] && sleep 5\nexit 3\n"), 0755))
+
+	_, err := RunScriptWithOptions(script, nil, &ScriptOptions{Timeout: -1})
+	assert.NotNil(t, err)
+	_, err = RunScriptWithInterpreter(nil, script, nil, nil)
+	assert.NotNil(t, err)
+
+	result, err := RunScriptWithOptions(script, []string{"x"}, &ScriptOptions{WorkDir: dir, Env: map[string]string{"FOO": "bar"}})
+	assert.Nil(t, err)
+	assert.Equal(t, dir+" bar x", result.Stdout)
+	assert.Equal(t, 3, result.RetCode)
+	assert.False(t, result.TimedOut)
+
+	result, err = RunScriptWithOptions(script, []string{"sleep"}, &ScriptOptions{Timeout: 1})
+	assert.Nil(t, err)
+	assert.True(t, result.TimedOut)
+}